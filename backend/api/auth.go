@@ -0,0 +1,255 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"spikeshield/db"
+	"spikeshield/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is where authenticate stores the caller in the gin context
+const principalKey = "principal"
+
+// touchInterval limits how often a key's last use is written back
+const touchInterval = time.Minute
+
+// principal is the caller of a request
+type principal struct {
+	KeyID int // 0 for anonymous callers and the configured admin token
+	Name  string
+	Role  string
+}
+
+var anonymous = &principal{Name: "anonymous", Role: db.RolePublic}
+
+// principalOf returns the caller set by authenticate
+func principalOf(c *gin.Context) *principal {
+	if p, ok := c.Get(principalKey); ok {
+		return p.(*principal)
+	}
+	return anonymous
+}
+
+// credential returns the API key or wallet session token of a request, sent as
+// "Authorization: Bearer <key>", "X-API-Key" or, for older clients, "X-Admin-Token"
+func credential(c *gin.Context) string {
+	if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	return c.GetHeader("X-Admin-Token")
+}
+
+// authenticate identifies the caller: requests without a key are public, the configured
+// admin token is an admin, session tokens are public callers acting for a wallet, and
+// anything else must be an unrevoked API key
+func (s *Server) authenticate(c *gin.Context) {
+	key := credential(c)
+	if key == "" {
+		c.Next()
+		return
+	}
+	if strings.HasPrefix(key, sessionTokenPrefix) {
+		s.authenticateSession(c, key)
+		return
+	}
+	if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.adminToken)) == 1 {
+		c.Set(principalKey, &principal{Name: "admin-token", Role: db.RoleAdmin})
+		c.Next()
+		return
+	}
+
+	k, err := s.store.Auth.GetAPIKeyByHash(db.HashToken(key))
+	if err == sql.ErrNoRows || (err == nil && k.RevokedAt != nil) {
+		if !s.allowIP(c) {
+			return
+		}
+		abort(c, errUnauthorized("invalid or revoked API key"))
+		return
+	}
+	if err != nil {
+		abort(c, errInternal("Failed to authenticate", err))
+		return
+	}
+	if now := time.Now().UTC(); k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) > touchInterval {
+		if err := s.store.Auth.TouchAPIKey(k.ID, now); err != nil {
+			utils.LogDebugCtx(c.Request.Context(), "Failed to record use of API key %d: %v", k.ID, err)
+		}
+	}
+	c.Set(principalKey, &principal{KeyID: k.ID, Name: k.Name, Role: k.Role})
+	c.Next()
+}
+
+// requireRole rejects callers below role: 401 without a key, 403 with a weaker one.
+// Every call through it is written to the audit log, denied ones included.
+func (s *Server) requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := principalOf(c)
+		if db.RoleRank(p.Role) < db.RoleRank(role) {
+			if p == anonymous {
+				abort(c, errUnauthorized(fmt.Sprintf("API key with role %s required", role)))
+			} else {
+				abort(c, errForbidden(fmt.Sprintf("role %s required (key has %s)", role, p.Role)))
+			}
+		} else {
+			c.Next()
+		}
+		s.audit(c, p)
+	}
+}
+
+// audit records a privileged call once it has been answered
+func (s *Server) audit(c *gin.Context, p *principal) {
+	entry := &db.AuditEntry{
+		APIKeyID: p.KeyID,
+		Actor:    p.Name,
+		Role:     p.Role,
+		Method:   c.Request.Method,
+		Path:     c.Request.URL.Path,
+		Status:   c.Writer.Status(),
+		ClientIP: c.ClientIP(),
+	}
+	utils.LogInfoCtx(c.Request.Context(), "🔐 %s %s by %s (%s) from %s: %d", entry.Method, entry.Path, entry.Actor, entry.Role, entry.ClientIP, entry.Status)
+	if err := s.store.Auth.InsertAuditEntry(entry); err != nil {
+		utils.LogErrorCtx(c.Request.Context(), "Failed to write audit log: %v", err)
+	}
+}
+
+// allowOrigin reports whether a browser page on origin may call the API. Requests
+// without an Origin header do not come from a browser and are always allowed.
+func (s *Server) allowOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	for _, o := range s.corsOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebSocketOrigin applies the CORS allow-list to WebSocket upgrades, which
+// browsers do not preflight; same-host pages are always allowed
+func (s *Server) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if s.allowOrigin(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// apiKeyView is an API key as returned by the API, without its hash
+type apiKeyView struct {
+	ID         int
+	Name       string
+	Role       string
+	Prefix     string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func newAPIKeyView(k *db.APIKey) *apiKeyView {
+	return &apiKeyView{ID: k.ID, Name: k.Name, Role: k.Role, Prefix: k.Prefix, CreatedAt: k.CreatedAt,
+		LastUsedAt: k.LastUsedAt, RevokedAt: k.RevokedAt}
+}
+
+// handleCreateAPIKey issues a key: {"name", "role": "partner" | "operator" | "admin"}.
+// The response is the only time the key is returned.
+func (s *Server) handleCreateAPIKey(c *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		abort(c, errInvalid("invalid request"))
+		return
+	}
+	if req.Name == "" || len(req.Name) > 100 {
+		abort(c, errInvalid("name required (at most 100 characters)"))
+		return
+	}
+	if db.RoleRank(req.Role) <= db.RoleRank(db.RolePublic) {
+		abort(c, errInvalid("role must be partner, operator or admin"))
+		return
+	}
+
+	key, k := db.NewAPIKey(req.Name, req.Role)
+	if err := s.store.Auth.CreateAPIKey(k); err != nil {
+		abort(c, errInternal("Failed to create API key", err))
+		return
+	}
+	utils.LogInfoCtx(c.Request.Context(), "🔑 API key %d (%s, %s) issued by %s", k.ID, k.Name, k.Role, principalOf(c).Name)
+
+	reply(c, http.StatusCreated, gin.H{"api_key": newAPIKeyView(k), "key": key}, gin.H{"api_key": newAPIKeyV2(k), "key": key})
+}
+
+// handleAPIKeys lists every key, revoked ones included
+func (s *Server) handleAPIKeys(c *gin.Context) {
+	keys, err := s.store.Auth.ListAPIKeys()
+	if err != nil {
+		abort(c, errInternal("Failed to fetch API keys", err))
+		return
+	}
+	views := make([]*apiKeyView, len(keys))
+	for i, k := range keys {
+		views[i] = newAPIKeyView(k)
+	}
+	reply(c, http.StatusOK, gin.H{"count": len(views), "api_keys": views}, newPageV2(keys, "", newAPIKeyV2))
+}
+
+// handleRevokeAPIKey disables a key immediately
+func (s *Server) handleRevokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abort(c, errInvalid("invalid key id"))
+		return
+	}
+	err = s.store.Auth.RevokeAPIKey(id)
+	if err == sql.ErrNoRows {
+		abort(c, errNotFound("API key not found"))
+		return
+	}
+	if err != nil {
+		abort(c, errInternal("Failed to revoke API key", err))
+		return
+	}
+	utils.LogInfoCtx(c.Request.Context(), "🔑 API key %d revoked by %s", id, principalOf(c).Name)
+	c.JSON(http.StatusOK, gin.H{"revoked": id})
+}
+
+// handleAuditLog returns the latest privileged calls: ?key=<id>&limit=
+func (s *Server) handleAuditLog(c *gin.Context) {
+	keyID := 0
+	if raw := c.Query("key"); raw != "" {
+		var err error
+		if keyID, err = strconv.Atoi(raw); err != nil {
+			abort(c, errInvalid("key must be an API key id"))
+			return
+		}
+	}
+	limit, ok := pageLimit(c, 100)
+	if !ok {
+		return
+	}
+
+	entries, err := s.store.Auth.GetAuditLog(keyID, limit)
+	if err != nil {
+		abort(c, errInternal("Failed to fetch audit log", err))
+		return
+	}
+	reply(c, http.StatusOK, gin.H{"count": len(entries), "entries": entries}, newPageV2(entries, "", newAuditEntryV2))
+}
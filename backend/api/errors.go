@@ -0,0 +1,174 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"spikeshield/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes tell clients what went wrong without parsing messages. Every error
+// response carries one, with the HTTP status it maps to in codeStatus.
+const (
+	CodeInvalidRequest = "invalid_request" // malformed body or parameters
+	CodeUnauthorized   = "unauthorized"    // missing, unknown or revoked credentials
+	CodeForbidden      = "forbidden"       // credentials lack the role or wallet
+	CodeNotFound       = "not_found"
+	CodeConflict       = "conflict"     // the resource's state does not allow the call
+	CodeRateLimited    = "rate_limited" // retry after retry_after seconds
+	CodeInternal       = "internal"
+	CodeUpstream       = "upstream_error" // the chain RPC failed
+	CodeUnavailable    = "unavailable"    // the feature is disabled
+)
+
+// codeStatus maps each error code to its HTTP status
+var codeStatus = map[string]int{
+	CodeInvalidRequest: http.StatusBadRequest,
+	CodeUnauthorized:   http.StatusUnauthorized,
+	CodeForbidden:      http.StatusForbidden,
+	CodeNotFound:       http.StatusNotFound,
+	CodeConflict:       http.StatusConflict,
+	CodeRateLimited:    http.StatusTooManyRequests,
+	CodeInternal:       http.StatusInternalServerError,
+	CodeUpstream:       http.StatusBadGateway,
+	CodeUnavailable:    http.StatusServiceUnavailable,
+}
+
+// Error is an error answered to an API client:
+// {"error": Message, "code": Code, "request_id", ...Details}. Cause is logged with the
+// request ID but never shown to the client.
+type Error struct {
+	Code    string
+	Message string
+	Details gin.H
+	Cause   error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Status is the HTTP status of the error's code
+func (e *Error) Status() int {
+	if status, ok := codeStatus[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// With adds a field to the error response
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = gin.H{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+func newError(code, message string, cause error) *Error {
+	return &Error{Code: code, Message: message, Cause: cause}
+}
+
+func errInvalid(message string) *Error      { return newError(CodeInvalidRequest, message, nil) }
+func errUnauthorized(message string) *Error { return newError(CodeUnauthorized, message, nil) }
+func errForbidden(message string) *Error    { return newError(CodeForbidden, message, nil) }
+func errNotFound(message string) *Error     { return newError(CodeNotFound, message, nil) }
+func errConflict(message string) *Error     { return newError(CodeConflict, message, nil) }
+func errUnavailable(message string) *Error  { return newError(CodeUnavailable, message, nil) }
+
+// errInternal hides cause behind message
+func errInternal(message string, cause error) *Error {
+	return newError(CodeInternal, message, cause)
+}
+
+// errUpstream reports a failed chain call
+func errUpstream(message string, cause error) *Error {
+	return newError(CodeUpstream, message, cause)
+}
+
+// abort answers err and stops the handler chain. Errors that are not an *Error are
+// internal. Server-side failures are logged with their cause and request ID.
+func abort(c *gin.Context, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = errInternal("Internal server error", err)
+	}
+	c.Error(apiErr)
+	status := apiErr.Status()
+	if status >= http.StatusInternalServerError {
+		utils.LogErrorCtx(c.Request.Context(), "%s %s: %v", c.Request.Method, c.Request.URL.Path, apiErr)
+	}
+
+	body := gin.H{"error": apiErr.Message, "code": apiErr.Code, "request_id": requestIDOf(c)}
+	for k, v := range apiErr.Details {
+		body[k] = v
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+// recovered answers a handler panic as an internal error
+func recovered(c *gin.Context, value interface{}) {
+	abort(c, errInternal("Internal server error", fmt.Errorf("panic: %v", value)))
+}
+
+// notFound answers requests for unknown routes
+func notFound(c *gin.Context) {
+	abort(c, errNotFound("no such endpoint: "+c.Request.Method+" "+c.Request.URL.Path))
+}
+
+const (
+	// requestIDHeader carries the request ID both ways: clients may send their own to
+	// correlate logs, and every response returns the one used
+	requestIDHeader = "X-Request-ID"
+	// requestIDKey is where requestID stores the ID in the gin context
+	requestIDKey = "request_id"
+)
+
+// validRequestID bounds the IDs accepted from clients, which end up in logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID tags each request with an ID, passed on in the request context so log lines
+// of the call carry it, and logs every failed request with it
+func requestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if !validRequestID.MatchString(id) {
+		buf := make([]byte, 12)
+		rand.Read(buf)
+		id = hex.EncodeToString(buf)
+	}
+	c.Set(requestIDKey, id)
+	c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), id))
+	c.Header(requestIDHeader, id)
+
+	start := time.Now()
+	c.Next()
+
+	if status := c.Writer.Status(); status >= http.StatusBadRequest {
+		code := ""
+		var apiErr *Error
+		if last := c.Errors.Last(); last != nil && errors.As(last.Err, &apiErr) {
+			code = apiErr.Code
+		}
+		utils.LogInfoCtx(c.Request.Context(), "↩️  %s %s from %s: %d %s in %s", c.Request.Method, c.Request.URL.Path,
+			c.ClientIP(), status, code, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// requestIDOf returns the ID requestID gave the request
+func requestIDOf(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
@@ -0,0 +1,463 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"spikeshield/db"
+	"spikeshield/events"
+	"spikeshield/openapi"
+	"spikeshield/replay"
+	"spikeshield/utils"
+	"spikeshield/webhooks"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+)
+
+// handleOpenAPI serves the OpenAPI document of the API version
+func (s *Server) handleOpenAPI(c *gin.Context) {
+	spec, err := openapi.Spec(apiVersionOf(c))
+	if err != nil {
+		abort(c, errInternal("Failed to load the API document", err))
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+}
+
+// ginParam matches the :name segments of gin routes
+var ginParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// CheckContract keeps the handlers and the OpenAPI documents of each API version in
+// sync: every route must be documented and every documented operation routed, and
+// sample requests covering each operation must answer with a status the document
+// declares and a body matching its schema. Each version runs against its own in-memory
+// store, without database, chain or network. It returns the number of responses
+// checked and every mismatch found.
+func CheckContract(docs map[string]*openapi.Document) (int, []error) {
+	if utils.AppConfig == nil {
+		// Wallet handlers read the configured token
+		utils.AppConfig = &utils.Config{}
+	}
+	cc := &contractCheck{docs: docs, seen: make(map[string]bool)}
+	for i, version := range apiVersions {
+		if docs[version] == nil {
+			cc.fail("%s: routed but has no document", version)
+			continue
+		}
+		if err := cc.runVersion(version, i == 0); err != nil {
+			cc.fail("%s: %v", version, err)
+		}
+	}
+
+	for _, version := range apiVersions {
+		if docs[version] == nil {
+			continue
+		}
+		for _, route := range docs[version].Routes() {
+			if !cc.seen[route.Method+" "+route.Path] {
+				cc.fail("%s %s: no sample request covers it", route.Method, route.Path)
+			}
+		}
+	}
+	return cc.checked, cc.errs
+}
+
+// runVersion sends the sample requests of a version to a fresh server, after comparing
+// its routes with the documents if checkRoutes is set
+func (cc *contractCheck) runVersion(version string, checkRoutes bool) error {
+	uploads, err := os.MkdirTemp("", "spikeshield-contract")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(uploads)
+
+	store := db.NewMemoryStore().Store()
+	replays := replay.NewManager(store, 1, 0.3, uploads)
+	// The dispatcher is never run, so queued deliveries are not sent
+	hooks := webhooks.NewDispatcher(store.Webhooks, webhooks.Options{})
+	cc.server = NewServer("", store, replays, events.NewBus(), hooks, Options{
+		AdminToken:   contractAdminToken,
+		V1Deprecated: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+	})
+	cc.version, cc.doc = version, cc.docs[version]
+
+	if checkRoutes {
+		cc.checkRoutes()
+	}
+	if err := seedContractData(store); err != nil {
+		return fmt.Errorf("failed to seed sample data: %w", err)
+	}
+	cc.run()
+	return nil
+}
+
+// contractAdminToken authenticates the sample requests of CheckContract
+const contractAdminToken = "contract-check-admin-token"
+
+// contractCheck collects the results of CheckContract
+type contractCheck struct {
+	docs    map[string]*openapi.Document
+	seen    map[string]bool // "METHOD /path" of the operations a sample request covered
+	checked int
+	errs    []error
+
+	// The version being checked
+	version string
+	doc     *openapi.Document
+	server  *Server
+
+	walletAddress string // signed in by signIn
+}
+
+func (cc *contractCheck) fail(format string, args ...interface{}) {
+	cc.errs = append(cc.errs, fmt.Errorf(format, args...))
+}
+
+// checkRoutes compares the routes of the server with the paths of the documents
+func (cc *contractCheck) checkRoutes() {
+	routed := make(map[string]bool)
+	for _, r := range cc.server.router.Routes() {
+		key := r.Method + " " + ginParam.ReplaceAllString(r.Path, "{$1}")
+		routed[key] = true
+		path := strings.TrimPrefix(key, r.Method+" ")
+		documented := false
+		for _, doc := range cc.docs {
+			documented = documented || doc.Operation(r.Method, path) != nil
+		}
+		if !documented {
+			cc.fail("%s: routed but not documented", key)
+		}
+	}
+	for _, doc := range cc.docs {
+		for _, route := range doc.Routes() {
+			if !routed[route.Method+" "+route.Path] {
+				cc.fail("%s %s: documented but not routed", route.Method, route.Path)
+			}
+			if route.OperationID == "" {
+				cc.fail("%s %s: no operationId", route.Method, route.Path)
+			}
+		}
+	}
+}
+
+// contractRequest is one sample request of the contract check. Paths and URLs are
+// written without the version, e.g. /api/webhooks/{id}, and sent to the version checked.
+type contractRequest struct {
+	method, path string // path is the documented template
+	url          string // defaults to path
+	token        string
+	body         interface{} // JSON encoded unless it is a *bytes.Buffer
+	contentType  string
+	unversioned  bool // send url as is, as clients from before versioning do
+}
+
+// versioned inserts the version checked into a path under /api
+func (cc *contractCheck) versioned(path string) string {
+	return apiPrefix(cc.version) + strings.TrimPrefix(path, "/api")
+}
+
+// do sends a sample request and checks the response against the document; the decoded
+// JSON body is returned for later requests to pick IDs from
+func (cc *contractCheck) do(r contractRequest) (int, map[string]interface{}) {
+	if r.url == "" {
+		r.url = r.path
+	}
+	r.path = cc.versioned(r.path)
+	if !r.unversioned {
+		r.url = cc.versioned(r.url)
+	}
+	key := r.method + " " + r.path
+	cc.seen[key] = true
+
+	var body bytes.Buffer
+	switch b := r.body.(type) {
+	case nil:
+	case *bytes.Buffer:
+		body = *b
+	default:
+		data, _ := json.Marshal(b)
+		body.Write(data)
+		r.contentType = "application/json"
+	}
+	req := httptest.NewRequest(r.method, r.url, &body)
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	rec := httptest.NewRecorder()
+	cc.server.ServeHTTP(rec, req)
+	if deprecated := rec.Header().Get("Deprecation") != ""; deprecated != (cc.version == apiV1) {
+		cc.fail("%s (%s): Deprecation header %q on %s", key, r.url, rec.Header().Get("Deprecation"), cc.version)
+	}
+
+	op := cc.doc.Operation(r.method, r.path)
+	if op == nil {
+		cc.fail("%s: not documented", key)
+		return rec.Code, nil
+	}
+	cc.checked++
+	resp, err := cc.doc.Response(op, rec.Code)
+	if err != nil {
+		cc.fail("%s (%s): %v: %s", key, r.url, err, rec.Body.String())
+		return rec.Code, nil
+	}
+	media := resp.Content["application/json"]
+	if media == nil || media.Schema == nil {
+		return rec.Code, nil
+	}
+	if err := cc.doc.ValidateJSON(media.Schema, rec.Body.Bytes()); err != nil {
+		cc.fail("%s (%s) %d: %v", key, r.url, rec.Code, err)
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &decoded)
+	return rec.Code, decoded
+}
+
+// expect sends a sample request that must answer status
+func (cc *contractCheck) expect(status int, r contractRequest) map[string]interface{} {
+	code, decoded := cc.do(r)
+	if code != status {
+		cc.fail("%s %s (%s): expected %d, got %d", r.method, r.path, r.url, status, code)
+	}
+	return decoded
+}
+
+// field reads a nested field of a decoded response as a string, "" if it is missing.
+// Fields are named as in v1; later versions name them in lower case.
+func (cc *contractCheck) field(v map[string]interface{}, path ...string) string {
+	var cur interface{} = v
+	for _, name := range path {
+		if cc.version != apiV1 {
+			name = strings.ToLower(name)
+		}
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		cur = m[name]
+	}
+	switch x := cur.(type) {
+	case string:
+		return x
+	case float64:
+		return fmt.Sprint(int64(x))
+	}
+	return ""
+}
+
+// seedContractData stores a few candles, spikes and payouts so lists are not empty
+func seedContractData(store *db.Store) error {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		p := &db.PriceData{Timestamp: start.Add(time.Duration(i) * time.Minute), Symbol: "BTCUSDT",
+			Open: 100, High: 101, Low: 99, Close: 100.5, Volume: 10}
+		if i%2 == 1 {
+			p.Low = 90 // a long lower wick
+		}
+		if err := store.Prices.InsertPrice(p); err != nil {
+			return err
+		}
+		if i%2 == 0 {
+			continue
+		}
+		spike := &db.Spike{Timestamp: p.Timestamp, Symbol: p.Symbol, Open: p.Open, High: p.High, Low: p.Low,
+			Close: p.Close, BodyRatio: 0.05, RangeClosePercent: 0.1}
+		if err := store.Spikes.InsertSpike(spike, p.ID); err != nil {
+			return err
+		}
+		payout := &db.Payout{PolicyID: i, UserAddress: "0x0000000000000000000000000000000000000001",
+			Amount: utils.NewAmount(big.NewInt(1500000), 6), SpikeID: spike.ID, TxHash: fmt.Sprintf("0x%064x", i)}
+		if _, err := store.Payouts.InsertPayout(payout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contractCSV is the file uploaded for the sample replay session
+const contractCSV = `timestamp,open,high,low,close,volume
+2024-02-01T00:00:00Z,100,101,99,100.5,10
+2024-02-01T00:01:00Z,100.5,101,90,100.7,10
+2024-02-01T00:02:00Z,100.7,102,100,101,10
+`
+
+// run sends the sample requests, at least one for every documented operation
+func (cc *contractCheck) run() {
+	admin := contractAdminToken
+	get := func(status int, path, url string) map[string]interface{} {
+		return cc.expect(status, contractRequest{method: http.MethodGet, path: path, url: url, token: admin})
+	}
+
+	// Public reads
+	get(http.StatusOK, "/api/health", "")
+	if cc.version == apiV1 {
+		cc.expect(http.StatusOK, contractRequest{method: http.MethodGet, path: "/api/health", unversioned: true})
+	}
+	get(http.StatusOK, "/api/openapi.json", "")
+	page := get(http.StatusOK, "/api/spikes", "/api/spikes?limit=1&direction=down")
+	if cursor := cc.field(page, "next_cursor"); cursor != "" {
+		get(http.StatusOK, "/api/spikes", "/api/spikes?limit=1&cursor="+cursor)
+	}
+	get(http.StatusBadRequest, "/api/spikes", "/api/spikes?sort=nope")
+	get(http.StatusOK, "/api/prices", "/api/prices?limit=2&from=2024-01-01T00:00:00Z")
+	get(http.StatusOK, "/api/candles", "/api/candles?interval=5m")
+	get(http.StatusBadRequest, "/api/candles", "/api/candles?interval=2m")
+	get(http.StatusOK, "/api/quarantine", "")
+	get(http.StatusOK, "/api/payouts", "/api/payouts?user=0x0000000000000000000000000000000000000001")
+	get(http.StatusOK, "/api/stats", "")
+	cc.expect(http.StatusUnauthorized, contractRequest{method: http.MethodGet, path: "/api/health", token: "not-a-key"})
+	cc.expect(http.StatusBadRequest, contractRequest{method: http.MethodGet, path: "/api/stream"})
+	cc.expect(http.StatusBadRequest, contractRequest{method: http.MethodGet, path: "/api/ws", url: "/api/ws?topics=nope"})
+
+	// Wallet sign-in and wallet-scoped reads
+	token := cc.signIn()
+	wallet := "/api/policies?address=" + cc.walletAddress
+	cc.expect(http.StatusUnauthorized, contractRequest{method: http.MethodGet, path: "/api/policies", url: wallet})
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodGet, path: "/api/policies", url: wallet, token: token})
+	cc.expect(http.StatusForbidden, contractRequest{method: http.MethodGet, path: "/api/policies",
+		url: "/api/policies?address=0x0000000000000000000000000000000000000001", token: token})
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodGet, path: "/api/balance",
+		url: "/api/balance?address=" + cc.walletAddress, token: token})
+	cc.expect(http.StatusBadRequest, contractRequest{method: http.MethodPost, path: "/api/balance/refresh", token: token})
+	cc.expect(http.StatusBadRequest, contractRequest{method: http.MethodPost, path: "/api/wallet/link",
+		body: map[string]string{}, token: token})
+	cc.expect(http.StatusAccepted, contractRequest{method: http.MethodPost, path: "/api/wallet/link",
+		body: map[string]string{"address": cc.walletAddress}, token: token})
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodGet, path: "/api/auth/session", token: token})
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodPost, path: "/api/auth/logout", token: token})
+
+	cc.replaySamples()
+	cc.adminSamples()
+}
+
+// signIn opens a wallet session with a throwaway key, as a wallet would
+func (cc *contractCheck) signIn() string {
+	nonce := cc.expect(http.StatusOK, contractRequest{method: http.MethodGet, path: "/api/auth/nonce"})
+	cc.expect(http.StatusUnauthorized, contractRequest{method: http.MethodGet, path: "/api/auth/session"})
+	cc.expect(http.StatusBadRequest, contractRequest{method: http.MethodPost, path: "/api/auth/verify",
+		body: map[string]string{"message": "hello", "signature": "0x"}})
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		cc.fail("failed to generate a wallet key: %v", err)
+		return ""
+	}
+	cc.walletAddress = crypto.PubkeyToAddress(key.PublicKey).Hex()
+	message := fmt.Sprintf("localhost wants you to sign in with your Ethereum account:\n%s\n\nContract check\n\n"+
+		"URI: http://localhost\nVersion: 1\nChain ID: 1\nNonce: %s\nIssued At: %s",
+		cc.walletAddress, cc.field(nonce, "nonce"), time.Now().UTC().Format(time.RFC3339))
+	sig, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+	if err != nil {
+		cc.fail("failed to sign in: %v", err)
+		return ""
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	session := cc.expect(http.StatusOK, contractRequest{method: http.MethodPost, path: "/api/auth/verify",
+		body: map[string]string{"message": message, "signature": hexutil.Encode(sig)}})
+	return cc.field(session, "token")
+}
+
+// replaySamples uploads a file, replays it and walks the session endpoints
+func (cc *contractCheck) replaySamples() {
+	admin := contractAdminToken
+	var form bytes.Buffer
+	w := multipart.NewWriter(&form)
+	part, _ := w.CreateFormFile("file", "contract.csv")
+	part.Write([]byte(contractCSV))
+	w.Close()
+	upload := cc.expect(http.StatusCreated, contractRequest{method: http.MethodPost, path: "/api/replay/uploads",
+		body: &form, contentType: w.FormDataContentType(), token: admin})
+	cc.expect(http.StatusUnauthorized, contractRequest{method: http.MethodPost, path: "/api/replay/uploads"})
+
+	started := cc.expect(http.StatusAccepted, contractRequest{method: http.MethodPost, path: "/api/replay/sessions",
+		body: map[string]interface{}{"file": cc.field(upload, "File"), "paused": true}, token: admin})
+	cc.expect(http.StatusBadRequest, contractRequest{method: http.MethodPost, path: "/api/replay/sessions",
+		body: map[string]interface{}{"file": "../config.yaml"}, token: admin})
+	cc.expect(http.StatusNotFound, contractRequest{method: http.MethodPost, path: "/api/replay/sessions",
+		body: map[string]interface{}{"file": strings.Repeat("0", 32) + ".csv"}, token: admin})
+	cc.expect(http.StatusUnauthorized, contractRequest{method: http.MethodPost, path: "/api/insert_fake_kline"})
+	id := cc.field(started, "session", "ID")
+	session := "/api/replay/sessions/" + id
+	sub := func(method string, status int, name string, body interface{}) {
+		cc.expect(status, contractRequest{method: method, path: "/api/replay/sessions/{id}" + name,
+			url: session + name, body: body, token: admin})
+	}
+
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodGet, path: "/api/replay/sessions"})
+	sub(http.MethodGet, http.StatusOK, "", nil)
+	cc.expect(http.StatusNotFound, contractRequest{method: http.MethodGet, path: "/api/replay/sessions/{id}",
+		url: "/api/replay/sessions/missing"})
+	sub(http.MethodGet, http.StatusOK, "/progress", nil)
+	sub(http.MethodPost, http.StatusOK, "/seek", map[string]string{"timestamp": "2024-02-01T00:02:00Z"})
+	sub(http.MethodPost, http.StatusBadRequest, "/seek", map[string]string{})
+	sub(http.MethodPost, http.StatusOK, "/resume", nil)
+	sub(http.MethodPost, http.StatusOK, "/pause", nil)
+	for _, name := range []string{"/prices", "/spikes", "/quarantine", "/payouts"} {
+		sub(http.MethodGet, http.StatusOK, name, nil)
+	}
+	sub(http.MethodPost, http.StatusOK, "/stop", nil)
+	// Stop returns once the session has ended
+	sub(http.MethodPost, http.StatusConflict, "/pause", nil)
+	sub(http.MethodGet, http.StatusOK, "/progress", nil)
+	sub(http.MethodDelete, http.StatusOK, "", nil)
+}
+
+// adminSamples covers API keys, webhooks, the audit log and the reset
+func (cc *contractCheck) adminSamples() {
+	admin := contractAdminToken
+	created := cc.expect(http.StatusCreated, contractRequest{method: http.MethodPost, path: "/api/admin/keys",
+		body: map[string]string{"name": "contract", "role": db.RolePartner}, token: admin})
+	partner := cc.field(created, "key")
+	cc.expect(http.StatusBadRequest, contractRequest{method: http.MethodPost, path: "/api/admin/keys",
+		body: map[string]string{"name": "contract", "role": db.RolePublic}, token: admin})
+	cc.expect(http.StatusForbidden, contractRequest{method: http.MethodGet, path: "/api/admin/keys", token: partner})
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodGet, path: "/api/admin/keys", token: admin})
+
+	// A public address literal, so registration needs no DNS
+	hook := cc.expect(http.StatusCreated, contractRequest{method: http.MethodPost, path: "/api/webhooks",
+		body: map[string]interface{}{"url": "https://203.0.113.10/hook", "events": []string{"spike.detected"}}, token: partner})
+	cc.expect(http.StatusBadRequest, contractRequest{method: http.MethodPost, path: "/api/webhooks",
+		body: map[string]string{"url": "ftp://example.com"}, token: partner})
+	cc.expect(http.StatusBadRequest, contractRequest{method: http.MethodPost, path: "/api/webhooks",
+		body: map[string]string{"url": "http://169.254.169.254/latest/meta-data"}, token: partner})
+	cc.expect(http.StatusUnauthorized, contractRequest{method: http.MethodGet, path: "/api/webhooks"})
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodGet, path: "/api/webhooks", token: partner})
+
+	id := cc.field(hook, "webhook", "ID")
+	url := "/api/webhooks/" + id
+	on := func(method string, status int, path, suffix string, body interface{}) map[string]interface{} {
+		return cc.expect(status, contractRequest{method: method, path: "/api/webhooks/{id}" + path,
+			url: url + suffix, body: body, token: partner})
+	}
+	on(http.MethodGet, http.StatusOK, "", "", nil)
+	cc.expect(http.StatusNotFound, contractRequest{method: http.MethodGet, path: "/api/webhooks/{id}",
+		url: "/api/webhooks/999999", token: partner})
+	on(http.MethodPatch, http.StatusOK, "", "", map[string]interface{}{"active": false, "rotate_secret": true})
+	ping := on(http.MethodPost, http.StatusAccepted, "/ping", "/ping", nil)
+	on(http.MethodGet, http.StatusOK, "/deliveries", "/deliveries", nil)
+	on(http.MethodGet, http.StatusBadRequest, "/deliveries", "/deliveries?status=nope", nil)
+	delivery := cc.field(ping, "delivery", "ID")
+	on(http.MethodPost, http.StatusConflict, "/deliveries/{delivery}/redeliver", "/deliveries/"+delivery+"/redeliver", nil)
+	on(http.MethodDelete, http.StatusOK, "", "", nil)
+
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodGet, path: "/api/admin/audit", url: "/api/admin/audit?limit=5", token: admin})
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodDelete, path: "/api/admin/keys/{id}",
+		url: "/api/admin/keys/" + cc.field(created, "api_key", "ID"), token: admin})
+	cc.expect(http.StatusNotFound, contractRequest{method: http.MethodDelete, path: "/api/admin/keys/{id}",
+		url: "/api/admin/keys/999999", token: admin})
+	cc.expect(http.StatusOK, contractRequest{method: http.MethodPost, path: "/api/admin/reset", token: admin})
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"spikeshield/openapi"
+)
+
+// loadDocuments loads the OpenAPI document of every version
+func loadDocuments(t *testing.T) map[string]*openapi.Document {
+	t.Helper()
+	docs := make(map[string]*openapi.Document)
+	for _, version := range openapi.Versions {
+		doc, err := openapi.Load(version)
+		if err != nil {
+			t.Fatalf("load %s: %v", version, err)
+		}
+		docs[version] = doc
+	}
+	return docs
+}
+
+func TestContract(t *testing.T) {
+	checked, errs := CheckContract(loadDocuments(t))
+	for _, err := range errs {
+		t.Error(err)
+	}
+	if checked == 0 {
+		t.Error("no responses were checked")
+	}
+}
+
+// TestClientUpToDate fails when client/client.go differs from what
+// `go generate ./client/` writes for the latest document
+func TestClientUpToDate(t *testing.T) {
+	latest := openapi.Versions[len(openapi.Versions)-1]
+	doc, err := openapi.Load(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := doc.GenerateClient("client", latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../client/client.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("client/client.go is out of date with the API document; run `go generate ./client/`")
+	}
+}
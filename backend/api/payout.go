@@ -5,10 +5,12 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 	"math/big"
+	"sync/atomic"
 	"time"
 
 	"spikeshield/contracts"
 	"spikeshield/db"
+	"spikeshield/events"
 	"spikeshield/utils"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -17,17 +19,36 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
-// PayoutService handles on-chain payout executions
+// PayoutService handles on-chain payout executions.
+// It pauses itself while its key is not the contract's oracle.
 type PayoutService struct {
 	Client          *ethclient.Client
 	ContractAddress common.Address
 	Contract        *contracts.InsurancePool
 	PrivateKey      *ecdsa.PrivateKey
+	OracleAddress   common.Address
 	ChainID         *big.Int
+
+	policies db.PolicyStore
+	paused   atomic.Bool
+	events   *events.Bus // receives payout transactions, nil for none
+}
+
+// PayoutTransaction is published on payouts:ADDRESS as a payout transaction is sent
+// ("payout.sent"), mined ("payout.mined") or reverted ("payout.reverted"). The
+// indexed payout follows as "payout.executed" once the listener sees the event.
+type PayoutTransaction struct {
+	PolicyID        int // DB policy
+	OnchainPolicyID int64
+	UserAddress     string
+	SpikeID         int
+	Amount          utils.Amount
+	TxHash          string
+	BlockNumber     uint64 // 0 until mined
 }
 
 // NewPayoutService creates a new payout service instance
-func NewPayoutService(rpcURL, contractAddr, privateKeyHex string) (*PayoutService, error) {
+func NewPayoutService(rpcURL, contractAddr, privateKeyHex string, policies db.PolicyStore) (*PayoutService, error) {
 	client, err := ethclient.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to RPC: %w", err)
@@ -51,33 +72,78 @@ func NewPayoutService(rpcURL, contractAddr, privateKeyHex string) (*PayoutServic
 		return nil, fmt.Errorf("failed to create contract instance: %w", err)
 	}
 
-	// Verify oracle address matches
-	oracleAddr := crypto.PubkeyToAddress(privateKey.PublicKey)
-	currentOracle, err := insuranceContract.Oracle(&bind.CallOpts{})
-	if err != nil {
-		utils.LogError("Could not verify oracle address: %v", err)
-	} else if currentOracle != oracleAddr {
-		return nil, fmt.Errorf("private key does not match contract oracle. Expected: %s, Got: %s", currentOracle.Hex(), oracleAddr.Hex())
-	}
-
-	utils.LogInfo("✅ Connected to InsurancePool contract at %s", contractAddress.Hex())
-	utils.LogInfo("✅ Oracle address: %s", oracleAddr.Hex())
-
-	return &PayoutService{
+	ps := &PayoutService{
 		Client:          client,
 		ContractAddress: contractAddress,
 		Contract:        insuranceContract,
 		PrivateKey:      privateKey,
+		OracleAddress:   crypto.PubkeyToAddress(privateKey.PublicKey),
 		ChainID:         chainID,
-	}, nil
+		policies:        policies,
+	}
+
+	utils.LogInfo("✅ Connected to InsurancePool contract at %s", contractAddress.Hex())
+	utils.LogInfo("✅ Oracle address: %s", ps.OracleAddress.Hex())
+
+	// Verify oracle address matches; a mismatch starts the service paused
+	if _, err := ps.CheckAuthority(context.Background()); err != nil {
+		utils.LogError("Could not verify oracle address: %v", err)
+	}
+
+	return ps, nil
+}
+
+// CheckAuthority reads the contract's current oracle and pauses or resumes the service.
+// Returns whether the service may execute payouts.
+func (ps *PayoutService) CheckAuthority(ctx context.Context) (bool, error) {
+	currentOracle, err := ps.Contract.Oracle(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return !ps.Paused(), fmt.Errorf("failed to read contract oracle: %w", err)
+	}
+	ps.HandleOracleUpdated(currentOracle)
+	return !ps.Paused(), nil
+}
+
+// HandleOracleUpdated pauses the service when the contract's oracle is no longer our key,
+// and resumes it when the oracle is rotated back. Used as the event listener's oracle handler.
+func (ps *PayoutService) HandleOracleUpdated(currentOracle common.Address) {
+	if currentOracle == ps.OracleAddress {
+		if ps.paused.Swap(false) {
+			utils.LogInfo("▶️  Payout service resumed: %s is the contract oracle again", ps.OracleAddress.Hex())
+		}
+		return
+	}
+	if !ps.paused.Swap(true) {
+		utils.LogError("⏸️  Payout service paused: contract oracle is %s, our key is %s", currentOracle.Hex(), ps.OracleAddress.Hex())
+	}
+}
+
+// Paused reports whether payouts are suspended because our key is not the oracle
+func (ps *PayoutService) Paused() bool {
+	return ps.paused.Load()
+}
+
+// SetEvents publishes the progress of payout transactions to bus
+func (ps *PayoutService) SetEvents(bus *events.Bus) {
+	ps.events = bus
 }
 
 // ExecutePayout triggers on-chain payout for a spike event
 func (ps *PayoutService) ExecutePayout(spike *db.Spike) error {
 	utils.LogInfo("Executing payout for spike ID %d", spike.ID)
 
+	// Re-check live so a rotated oracle doesn't cost reverted transactions
+	authorized, err := ps.CheckAuthority(context.Background())
+	if err != nil {
+		utils.LogError("Could not verify oracle address, using last known state: %v", err)
+	}
+	if !authorized {
+		utils.LogInfo("Payout service is paused (not the contract oracle), skipping payout for spike %d", spike.ID)
+		return nil
+	}
+
 	// Get all active policies
-	policies, err := db.GetActivePolicies()
+	policies, err := ps.policies.GetActivePolicies()
 	if err != nil {
 		return fmt.Errorf("failed to get active policies: %w", err)
 	}
@@ -131,12 +197,9 @@ func (ps *PayoutService) executeForPolicy(policy *db.Policy, spike *db.Spike) er
 	}
 	auth.GasPrice = gasPrice
 
-	// Query on-chain policies to find correct active policy ID
+	// The DB row maps to exactly one on-chain policy
 	userAddr := common.HexToAddress(policy.UserAddress)
-	onchainPolicies, err := ps.Contract.GetUserPolicies(&bind.CallOpts{}, userAddr)
-	if err != nil {
-		return fmt.Errorf("failed to get user policies: %w", err)
-	}
+	targetPolicyId := policy.OnchainPolicyID
 
 	ctx := context.Background()
 
@@ -145,36 +208,27 @@ func (ps *PayoutService) executeForPolicy(policy *db.Policy, spike *db.Spike) er
 		return fmt.Errorf("failed to get chain timestamp: %w", err)
 	}
 	now := int64(header.Time)
-	var targetPolicyId int64 = -1
-	for i := range onchainPolicies {
-		p := onchainPolicies[i]
-		if p.Active && !p.Claimed && p.ExpiryTime.Int64() >= now {
-			targetPolicyId = int64(i)
-			break // Use first active unclaimed policy
-		}
-	}
-
-	if targetPolicyId == -1 {
-		utils.LogInfo("No active unclaimed policy found on-chain for user %s (DB policy %d)", policy.UserAddress, policy.ID)
-		return nil
-	}
 
-	// Check pool balance
 	onchainPolicy, err := ps.Contract.GetPolicy(&bind.CallOpts{}, userAddr, big.NewInt(targetPolicyId))
 	if err != nil {
 		return fmt.Errorf("failed to get on-chain policy %d: %w", targetPolicyId, err)
 	}
+	if !onchainPolicy.Active || onchainPolicy.Claimed || onchainPolicy.ExpiryTime.Int64() < now {
+		utils.LogInfo("On-chain policy %d for user %s is no longer claimable (DB policy %d)", targetPolicyId, policy.UserAddress, policy.ID)
+		return nil
+	}
+
+	// Check pool balance
 	poolBal, err := ps.Contract.GetPoolBalance(&bind.CallOpts{})
 	if err != nil {
 		return fmt.Errorf("failed to get pool balance: %w", err)
 	}
 
-	// Convert coverage to wei (6 decimals)
+	// Coverage is in token base units
 	coverageWei := onchainPolicy.CoverageAmount
-	covUSDT := new(big.Float).Quo(new(big.Float).SetInt(coverageWei), big.NewFloat(1e6))
-	covFloat, _ := covUSDT.Float64()
+	coverage := utils.NewAmount(coverageWei, policy.CoverageAmount.Decimals)
 	if poolBal.Cmp(coverageWei) < 0 {
-		utils.LogError("Insufficient pool balance for user %s policy %d: %s < %s wei (%.2f USDT)", userAddr.Hex(), targetPolicyId, poolBal.String(), coverageWei.String(), covFloat)
+		utils.LogError("Insufficient pool balance for user %s policy %d: %s < %s wei (%s USDT)", userAddr.Hex(), targetPolicyId, poolBal.String(), coverageWei.String(), coverage)
 		return nil
 	}
 
@@ -197,6 +251,15 @@ func (ps *PayoutService) executeForPolicy(policy *db.Policy, spike *db.Spike) er
 	}
 
 	utils.LogInfo("📤 Transaction sent: %s", tx.Hash().Hex())
+	sent := &PayoutTransaction{
+		PolicyID:        policy.ID,
+		OnchainPolicyID: targetPolicyId,
+		UserAddress:     userAddr.Hex(),
+		SpikeID:         spike.ID,
+		Amount:          coverage,
+		TxHash:          tx.Hash().Hex(),
+	}
+	ps.events.Publish(events.PayoutsTopic(sent.UserAddress), "payout.sent", sent)
 	utils.LogInfo("⏳ Waiting for transaction to be mined...")
 
 	// Wait for transaction to be mined
@@ -208,34 +271,20 @@ func (ps *PayoutService) executeForPolicy(policy *db.Policy, spike *db.Spike) er
 		return fmt.Errorf("failed to wait for transaction: %w", err)
 	}
 
+	mined := *sent
+	mined.BlockNumber = receipt.BlockNumber.Uint64()
 	if receipt.Status != 1 {
+		ps.events.Publish(events.PayoutsTopic(mined.UserAddress), "payout.reverted", &mined)
 		utils.LogError("Payout tx reverted (status %d) for DB policy %d (on-chain policy %d) - likely race condition or already claimed", receipt.Status, policy.ID, targetPolicyId)
 		return nil
 	}
 
+	ps.events.Publish(events.PayoutsTopic(mined.UserAddress), "payout.mined", &mined)
 	utils.LogInfo("✅ Transaction mined in block %d", receipt.BlockNumber.Uint64())
 	utils.LogInfo("   Gas Used: %d", receipt.GasUsed)
 
-	// // Record payout in database (use DB policy ID)
-	// payout := &db.Payout{
-	// 	PolicyID:    policy.ID,
-	// 	UserAddress: policy.UserAddress,
-	// 	Amount:      policy.CoverageAmount,
-	// 	SpikeID:     spike.ID,
-	// 	TxHash:      tx.Hash().Hex(),
-	// }
-
-	// if err := db.InsertPayout(payout); err != nil {
-	// 	return fmt.Errorf("failed to insert payout record: %w", err)
-	// }
-
-	// // Update policy status
-	// if err := db.UpdatePolicyStatus(policy.ID, "claimed"); err != nil {
-	// 	return fmt.Errorf("failed to update policy status: %w", err)
-	// }
-
-	utils.LogInfo("💰 Payout executed successfully for user %s policy %d: $%.2f (tx: %s)",
-		userAddr.Hex(), targetPolicyId, covFloat, tx.Hash().Hex())
+	utils.LogInfo("💰 Payout executed successfully for user %s policy %d: $%s (tx: %s)",
+		userAddr.Hex(), targetPolicyId, coverage, tx.Hash().Hex())
 
 	return nil
 }
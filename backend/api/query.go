@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"spikeshield/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxPageSize caps the limit of every list endpoint, and the candles of one request
+	maxPageSize = 1000
+	// defaultCandles is how many intervals /api/candles spans without from
+	defaultCandles = 300
+)
+
+// pageLimit reads ?limit=, defaulting to def and capped at maxPageSize. It answers 400
+// itself if the limit is not a positive integer.
+func pageLimit(c *gin.Context, def int) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return def, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		abort(c, errInvalid("limit must be a positive integer"))
+		return 0, false
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return limit, true
+}
+
+// parseTime accepts RFC 3339 timestamps and Unix seconds. The result is in UTC, like the
+// TIMESTAMP columns it is compared with.
+func parseTime(raw string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// listQuery reads the paging and filter parameters shared by list endpoints:
+//
+//	?limit=&cursor=&from=&to=&symbol=&direction=up|down&sort=[-]<column>
+//
+// A leading "-" on sort orders descending; without sort, lists are newest first if desc.
+// A cursor carries the order of the page it came from, so only the filters need repeating.
+// Answers 400 itself on invalid parameters.
+func listQuery(c *gin.Context, defaultLimit int, desc bool) (db.ListQuery, bool) {
+	q := db.ListQuery{
+		Symbol:    c.Query("symbol"),
+		Direction: strings.ToLower(c.Query("direction")),
+		Desc:      desc,
+	}
+	var ok bool
+	if q.Limit, ok = pageLimit(c, defaultLimit); !ok {
+		return q, false
+	}
+
+	if raw := c.Query("sort"); raw != "" {
+		q.Desc = strings.HasPrefix(raw, "-")
+		q.Sort = strings.TrimPrefix(raw, "-")
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := db.DecodeCursor(raw)
+		if err != nil {
+			abort(c, errInvalid(err.Error()))
+			return q, false
+		}
+		if c.Query("sort") == "" {
+			q.Sort, q.Desc = cursor.Sort, cursor.Desc
+		}
+		q.After = cursor
+	}
+
+	q.From, q.To, ok = timeWindow(c)
+	return q, ok
+}
+
+// timeWindow reads ?from= and ?to=, either of which may be left out (zero). Answers 400
+// itself on invalid times.
+func timeWindow(c *gin.Context) (from, to time.Time, ok bool) {
+	for _, bound := range []struct {
+		param string
+		dst   *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		raw := c.Query(bound.param)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTime(raw)
+		if err != nil {
+			abort(c, errInvalid(bound.param+" must be an RFC 3339 timestamp or Unix seconds"))
+			return from, to, false
+		}
+		*bound.dst = t
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		abort(c, errInvalid("from must be before to"))
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// listFailed answers a failed list query: 400 for queries the store rejected, 500 otherwise
+func listFailed(c *gin.Context, err error, message string) {
+	var queryErr *db.QueryError
+	if errors.As(err, &queryErr) {
+		abort(c, errInvalid(queryErr.Reason))
+		return
+	}
+	abort(c, errInternal(message, err))
+}
+
+// nextCursor is the next_cursor of a list response: null on the last page
+func nextCursor(next string) interface{} {
+	if next == "" {
+		return nil
+	}
+	return next
+}
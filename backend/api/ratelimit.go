@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"spikeshield/eventlistener"
+	"spikeshield/utils"
+	"spikeshield/workpool"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gin-gonic/gin"
+)
+
+// syncRetryAfter is suggested to clients while the wallet sync queue is full
+const syncRetryAfter = 5 * time.Second
+
+// tooManyRequests answers 429 with the wait in Retry-After (whole seconds, at least 1)
+func tooManyRequests(c *gin.Context, wait time.Duration, reason string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	abort(c, newError(CodeRateLimited, reason, nil).With("retry_after", seconds))
+}
+
+// rateLimit applies the per-key limit to callers with an API key and the per-IP limit
+// to everyone else, wallet sessions included
+func (s *Server) rateLimit(c *gin.Context) {
+	p := principalOf(c)
+	if p == anonymous {
+		if s.allowIP(c) {
+			c.Next()
+		}
+		return
+	}
+	if ok, wait := s.keyLimiter.Allow("key:" + p.Name + ":" + strconv.Itoa(p.KeyID)); !ok {
+		utils.LogDebugCtx(c.Request.Context(), "Rate limited API key %s (%s)", p.Name, c.Request.URL.Path)
+		tooManyRequests(c, wait, "rate limit exceeded for this API key")
+		return
+	}
+	c.Next()
+}
+
+// allowIP takes a token from the client IP's bucket, answering 429 when it is empty.
+// Failed authentications are charged here too, which bounds key guessing.
+func (s *Server) allowIP(c *gin.Context) bool {
+	if ok, wait := s.ipLimiter.Allow(c.ClientIP()); !ok {
+		utils.LogDebugCtx(c.Request.Context(), "Rate limited %s (%s)", c.ClientIP(), c.Request.URL.Path)
+		tooManyRequests(c, wait, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// allowWallet applies the per-wallet limit of the endpoints that spend RPC quota,
+// whoever calls them
+func (s *Server) allowWallet(c *gin.Context, address string) bool {
+	if ok, wait := s.walletLimiter.Allow(strings.ToLower(address)); !ok {
+		utils.LogDebugCtx(c.Request.Context(), "Rate limited wallet %s (%s)", address, c.Request.URL.Path)
+		tooManyRequests(c, wait, "rate limit exceeded for this wallet")
+		return false
+	}
+	return true
+}
+
+// queueWalletSync syncs a wallet's policies from the chain on the worker pool; a sync
+// already queued or running for the wallet absorbs the request
+func (s *Server) queueWalletSync(c *gin.Context, address string) {
+	// The sync outlives the request; its log lines keep the request ID
+	ctx := utils.WithRequestID(context.Background(), requestIDOf(c))
+	queued, err := s.syncs.Submit(strings.ToLower(address), func() {
+		if err := eventlistener.UpsertForUser(utils.AppConfig, s.store, address); err != nil {
+			utils.LogErrorCtx(ctx, "UpsertForUser failed for %s: %v", address, err)
+		} else {
+			utils.LogInfoCtx(ctx, "UpsertForUser succeeded for %s", address)
+		}
+	})
+	if err == workpool.ErrFull {
+		tooManyRequests(c, syncRetryAfter, "too many wallet syncs pending")
+		return
+	}
+	status := "accepted"
+	if !queued {
+		status = "already queued"
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": status})
+}
+
+// rpc returns the RPC client shared by requests, dialing it on first use
+func (s *Server) rpc() (*ethclient.Client, error) {
+	s.rpcMu.Lock()
+	defer s.rpcMu.Unlock()
+
+	if s.rpcClient != nil {
+		return s.rpcClient, nil
+	}
+	cfg := utils.AppConfig
+	if cfg == nil || cfg.RPC.URL == "" {
+		return nil, fmt.Errorf("RPC not configured")
+	}
+	client, err := ethclient.Dial(cfg.RPC.URL)
+	if err != nil {
+		return nil, err
+	}
+	s.rpcClient = client
+	return client, nil
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"spikeshield/db"
+	"spikeshield/ratelimit"
+)
+
+// TestIPLimitTrustedProxies checks that X-Forwarded-For picks the IP bucket only when
+// the connection comes from a trusted proxy
+func TestIPLimitTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		remote  string
+		limited bool // whether rotating X-Forwarded-For still hits the limit
+	}{
+		{name: "no trusted proxies", remote: "198.51.100.7:4000", limited: true},
+		{name: "untrusted peer", proxies: []string{"192.0.2.1"}, remote: "198.51.100.7:4000", limited: true},
+		{name: "trusted proxy", proxies: []string{"192.0.2.0/24"}, remote: "192.0.2.1:4000", limited: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("", db.NewMemoryStore().Store(), nil, nil, nil, Options{
+				IPLimit:        ratelimit.Limit{Rate: 0.01, Burst: 2},
+				TrustedProxies: tt.proxies,
+			})
+			limited := false
+			for i := 0; i < 5; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/api/v2/health", nil)
+				req.RemoteAddr = tt.remote
+				req.Header.Set("X-Forwarded-For", "203.0.113."+strconv.Itoa(i+1))
+				w := httptest.NewRecorder()
+				s.router.ServeHTTP(w, req)
+				if w.Code == http.StatusTooManyRequests {
+					limited = true
+				}
+			}
+			if limited != tt.limited {
+				t.Errorf("limited = %t, want %t", limited, tt.limited)
+			}
+		})
+	}
+}
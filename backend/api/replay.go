@@ -0,0 +1,379 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"spikeshield/datafeed"
+	"spikeshield/db"
+	"spikeshield/replay"
+	"spikeshield/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultReplayCSV is replayed when a session is started without a file
+const defaultReplayCSV = "../data/btcusdt_wick_test.csv"
+
+// maxReplayUpload caps the size of an uploaded CSV
+const maxReplayUpload = 32 << 20
+
+// handleStartReplay starts an isolated replay session; production data is untouched.
+// The body is optional: {"symbol", "file" (from /replay/uploads, default sample CSV),
+// "speed" (0 = as fast as possible), "realtime" (speed 1), "paused"}.
+func (s *Server) handleStartReplay(c *gin.Context) {
+	var req struct {
+		Symbol   string  `json:"symbol"`
+		File     string  `json:"file"`
+		Speed    float64 `json:"speed"`
+		Realtime bool    `json:"realtime"`
+		Paused   bool    `json:"paused"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.BindJSON(&req); err != nil {
+			abort(c, errInvalid("invalid request"))
+			return
+		}
+	}
+	if req.Symbol == "" {
+		req.Symbol = "BTCUSDT"
+	}
+	if req.Realtime {
+		req.Speed = 1
+	}
+	if req.Speed < 0 {
+		abort(c, errInvalid("speed must not be negative"))
+		return
+	}
+
+	csvPath := defaultReplayCSV
+	if req.File != "" {
+		path, err := s.replays.UploadPath(req.File)
+		switch {
+		case errors.Is(err, replay.ErrInvalidUpload):
+			abort(c, errInvalid(err.Error()))
+			return
+		case errors.Is(err, replay.ErrUploadNotFound):
+			abort(c, errNotFound(err.Error()))
+			return
+		case err != nil:
+			abort(c, errInternal("Failed to open upload", err))
+			return
+		}
+		csvPath = path
+	}
+
+	session, err := s.replays.Start(req.Symbol, csvPath, replay.Options{Speed: req.Speed, Paused: req.Paused})
+	if err != nil {
+		abort(c, errInternal("Failed to start replay", err))
+		return
+	}
+
+	reply(c, http.StatusAccepted, gin.H{
+		"status":  "started",
+		"session": session,
+	}, gin.H{
+		"status":  "started",
+		"session": newReplaySessionV2(session),
+	})
+}
+
+// handleReplayUpload imports an exchange export (multipart field "file") for later
+// sessions. Optional form fields: "format" (auto, csv, binance, coinbase, okx) and
+// "columns" (generic CSV mapping, e.g. "timestamp=time,open=o,high=h,low=l,close=c").
+func (s *Server) handleReplayUpload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReplayUpload)
+	header, err := c.FormFile("file")
+	if err != nil {
+		abort(c, errInvalid("multipart field \"file\" required (max 32 MiB)"))
+		return
+	}
+	columns, err := datafeed.ParseColumnMapping(c.PostForm("columns"))
+	if err != nil {
+		abort(c, errInvalid(err.Error()))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		abort(c, errInvalid("Failed to read upload"))
+		return
+	}
+	defer file.Close()
+
+	upload, err := s.replays.SaveUpload(header.Filename, file, c.PostForm("format"), columns)
+	if err != nil {
+		apiErr := errInvalid(err.Error())
+		if upload != nil {
+			var report interface{} = upload.Report
+			if apiVersionOf(c) == apiV2 {
+				report = newImportReportV2(upload.Report)
+			}
+			apiErr.With("report", report)
+		}
+		abort(c, apiErr)
+		return
+	}
+	utils.LogInfoCtx(c.Request.Context(), "📤 Replay upload %s: %d candles from %s (%s, %d rows rejected)",
+		upload.File, upload.Candles, header.Filename, upload.Report.Format, upload.Report.Rejected)
+	reply(c, http.StatusCreated, upload, newUploadV2(upload))
+}
+
+// handleReplayProgress returns the playback position of an active session, or just
+// the status of one that has ended
+func (s *Server) handleReplayProgress(c *gin.Context) {
+	session, ok := s.lookupReplaySession(c)
+	if !ok {
+		return
+	}
+	progress, err := s.replays.Progress(session.ID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"session": session.ID, "status": session.Status, "active": false})
+		return
+	}
+	replyProgress(c, session, progress)
+}
+
+// replyProgress answers the playback position of an active session
+func replyProgress(c *gin.Context, session *db.ReplaySession, progress *replay.Progress) {
+	reply(c, http.StatusOK,
+		gin.H{"session": session.ID, "status": progress.Status, "active": true, "progress": progress},
+		gin.H{"session": session.ID, "status": progress.Status, "active": true, "progress": newProgressV2(progress)})
+}
+
+// handleReplayPause holds an active session
+func (s *Server) handleReplayPause(c *gin.Context) {
+	s.controlReplay(c, s.replays.Pause)
+}
+
+// handleReplayResume continues a paused session
+func (s *Server) handleReplayResume(c *gin.Context) {
+	s.controlReplay(c, s.replays.Resume)
+}
+
+// handleReplaySeek moves an active session to {"timestamp": RFC3339}
+func (s *Server) handleReplaySeek(c *gin.Context) {
+	var req struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.Timestamp.IsZero() {
+		abort(c, errInvalid("timestamp (RFC3339) required"))
+		return
+	}
+	s.controlReplay(c, func(id string) (*replay.Progress, error) {
+		return s.replays.Seek(id, req.Timestamp)
+	})
+}
+
+// handleReplayStop ends an active session early
+func (s *Server) handleReplayStop(c *gin.Context) {
+	s.controlReplay(c, func(id string) (*replay.Progress, error) {
+		return nil, s.replays.Stop(id)
+	})
+}
+
+// controlReplay applies a playback control to the :id session and returns its progress
+func (s *Server) controlReplay(c *gin.Context, control func(id string) (*replay.Progress, error)) {
+	session, ok := s.lookupReplaySession(c)
+	if !ok {
+		return
+	}
+	progress, err := control(session.ID)
+	if err == replay.ErrNotActive {
+		abort(c, errConflict("replay session is not active").With("status", session.Status))
+		return
+	}
+	if err != nil {
+		abort(c, errInternal("Failed to control replay", err))
+		return
+	}
+	if progress == nil {
+		// Stopped: the session has its final status now
+		s.handleReplayProgress(c)
+		return
+	}
+	replyProgress(c, session, progress)
+}
+
+// handleInsertFakeKline is kept for existing scripts: it now starts a replay session
+// instead of wiping and refilling production prices
+func (s *Server) handleInsertFakeKline(c *gin.Context) {
+	s.handleStartReplay(c)
+}
+
+// handleReplaySessions lists replay sessions, newest first
+func (s *Server) handleReplaySessions(c *gin.Context) {
+	sessions, err := s.store.Replays.ListReplaySessions()
+	if err != nil {
+		abort(c, errInternal("Failed to fetch replay sessions", err))
+		return
+	}
+
+	reply(c, http.StatusOK, gin.H{
+		"count":    len(sessions),
+		"sessions": sessions,
+	}, newPageV2(sessions, "", newReplaySessionV2))
+}
+
+// handleReplaySession returns one session and its status
+func (s *Server) handleReplaySession(c *gin.Context) {
+	session, ok := s.lookupReplaySession(c)
+	if !ok {
+		return
+	}
+	reply(c, http.StatusOK, gin.H{"session": session}, gin.H{"session": newReplaySessionV2(session)})
+}
+
+// handleReplayPrices returns the candles replayed so far in a session, newest first
+func (s *Server) handleReplayPrices(c *gin.Context) {
+	session, ok := s.lookupReplaySession(c)
+	if !ok {
+		return
+	}
+	q, ok := listQuery(c, 100, true)
+	if !ok {
+		return
+	}
+	q.Symbol = session.Symbol
+
+	prices, _ := s.store.Replays.ReplayScope(session.ID)
+	rows, next, err := prices.GetPrices(q)
+	if err != nil {
+		listFailed(c, err, "Failed to fetch replay prices")
+		return
+	}
+
+	reply(c, http.StatusOK, gin.H{
+		"session":     session.ID,
+		"symbol":      session.Symbol,
+		"count":       len(rows),
+		"prices":      rows,
+		"next_cursor": nextCursor(next),
+	}, newPageV2(rows, next, newPriceV2))
+}
+
+// handleReplaySpikes returns the spikes detected in a session, newest first
+func (s *Server) handleReplaySpikes(c *gin.Context) {
+	session, ok := s.lookupReplaySession(c)
+	if !ok {
+		return
+	}
+	q, ok := listQuery(c, 50, true)
+	if !ok {
+		return
+	}
+
+	_, spikes := s.store.Replays.ReplayScope(session.ID)
+	rows, next, err := spikes.GetSpikes(q)
+	if err != nil {
+		listFailed(c, err, "Failed to fetch replay spikes")
+		return
+	}
+
+	reply(c, http.StatusOK, gin.H{
+		"session":     session.ID,
+		"count":       len(rows),
+		"spikes":      rows,
+		"next_cursor": nextCursor(next),
+	}, newPageV2(rows, next, newSpikeV2))
+}
+
+// handleReplayQuarantine returns the candles of a session that failed validation
+func (s *Server) handleReplayQuarantine(c *gin.Context) {
+	session, ok := s.lookupReplaySession(c)
+	if !ok {
+		return
+	}
+	q, ok := listQuery(c, 100, true)
+	if !ok {
+		return
+	}
+
+	rows, next, err := s.store.Quarantine.GetQuarantinedPrices(session.ID, q)
+	if err != nil {
+		listFailed(c, err, "Failed to fetch quarantined prices")
+		return
+	}
+
+	reply(c, http.StatusOK, gin.H{
+		"session":     session.ID,
+		"count":       len(rows),
+		"quarantined": rows,
+		"next_cursor": nextCursor(next),
+	}, newPageV2(rows, next, newQuarantinedPriceV2))
+}
+
+// handleReplayPayouts returns the payouts a session's spikes would have triggered, in the
+// order they were simulated
+func (s *Server) handleReplayPayouts(c *gin.Context) {
+	session, ok := s.lookupReplaySession(c)
+	if !ok {
+		return
+	}
+	q, ok := listQuery(c, 100, false)
+	if !ok {
+		return
+	}
+	q.User = c.Query("user")
+
+	payouts, next, err := s.store.Replays.GetSimulatedPayouts(session.ID, q)
+	if err != nil {
+		listFailed(c, err, "Failed to fetch simulated payouts")
+		return
+	}
+
+	reply(c, http.StatusOK, gin.H{
+		"session":     session.ID,
+		"count":       len(payouts),
+		"payouts":     payouts,
+		"next_cursor": nextCursor(next),
+	}, newPageV2(payouts, next, newSimulatedPayoutV2))
+}
+
+// handleDeleteReplaySession removes a session and everything it produced (admin only)
+func (s *Server) handleDeleteReplaySession(c *gin.Context) {
+	session, ok := s.lookupReplaySession(c)
+	if !ok {
+		return
+	}
+	// Stop a running replay first so it does not write into the deleted session
+	if err := s.replays.Stop(session.ID); err != nil && err != replay.ErrNotActive {
+		utils.LogErrorCtx(c.Request.Context(), "Failed to stop replay session %s: %v", session.ID, err)
+	}
+	if err := s.store.Replays.DeleteReplaySession(session.ID); err != nil {
+		abort(c, errInternal("Failed to delete replay session", err))
+		return
+	}
+	utils.LogInfoCtx(c.Request.Context(), "🗑️  Deleted replay session %s", session.ID)
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "session": session.ID})
+}
+
+// handleAdminReset deletes every production spike and price (admin only)
+func (s *Server) handleAdminReset(c *gin.Context) {
+	utils.LogInfoCtx(c.Request.Context(), "🗑️  Admin reset from %s: deleting all spikes and prices...", c.ClientIP())
+	if err := s.store.Spikes.DeleteAllSpikes(); err != nil {
+		abort(c, errInternal("Failed to delete spikes", err))
+		return
+	}
+	if err := s.store.Prices.DeleteAllPrices(); err != nil {
+		abort(c, errInternal("Failed to delete prices", err))
+		return
+	}
+	utils.LogInfoCtx(c.Request.Context(), "✅ All spikes and prices deleted")
+	c.JSON(http.StatusOK, gin.H{"status": "reset"})
+}
+
+// lookupReplaySession loads the :id session, writing a 404 or 500 if it cannot
+func (s *Server) lookupReplaySession(c *gin.Context) (*db.ReplaySession, bool) {
+	session, err := s.store.Replays.GetReplaySession(c.Param("id"))
+	if err == sql.ErrNoRows {
+		abort(c, errNotFound("replay session not found"))
+		return nil, false
+	}
+	if err != nil {
+		abort(c, errInternal("Failed to fetch replay session", err))
+		return nil, false
+	}
+	return session, true
+}
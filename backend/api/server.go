@@ -2,51 +2,147 @@ package api
 
 import (
 	"database/sql"
-	"encoding/csv"
-	"fmt"
-	"math"
 	"math/big"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"spikeshield/contracts"
 	"spikeshield/db"
+	"spikeshield/events"
+	"spikeshield/ratelimit"
+	"spikeshield/replay"
 	"spikeshield/utils"
+	"spikeshield/webhooks"
+	"spikeshield/workpool"
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
 // Server handles HTTP API requests
 type Server struct {
-	addr   string
-	router *gin.Engine
+	addr       string
+	router     *gin.Engine
+	store      *db.Store
+	replays    *replay.Manager
+	events     *events.Bus
+	webhooks   *webhooks.Dispatcher
+	adminToken string
+
+	corsOrigins []string
+	upgrader    websocket.Upgrader
+
+	siweDomains []string
+	siweChainID int64
+	sessionTTL  time.Duration
+
+	ipLimiter     *ratelimit.Limiter
+	keyLimiter    *ratelimit.Limiter
+	walletLimiter *ratelimit.Limiter
+	syncs         *workpool.Pool
+
+	rollupInterval time.Duration
+	v1Deprecated   time.Time
+	v1Sunset       time.Time
+
+	rpcMu     sync.Mutex
+	rpcClient *ethclient.Client
+}
+
+// Options configures access to the API
+type Options struct {
+	// AdminToken is accepted as an admin API key besides the keys in the store; empty disables it
+	AdminToken string
+	// CORSOrigins lists the browser origins allowed to call the API ("*" for any); empty
+	// allows same-origin pages only
+	CORSOrigins []string
+	// SIWEDomains lists the domains Sign-In With Ethereum messages may be issued for;
+	// empty accepts any
+	SIWEDomains []string
+	// SIWEChainID is the chain sign-in messages must name; 0 accepts any
+	SIWEChainID int64
+	// SessionTTL bounds wallet sessions (default 24h)
+	SessionTTL time.Duration
+	// IPLimit applies to callers without an API key, KeyLimit to each API key, and
+	// WalletLimit to the endpoints of each wallet that call the chain; a zero Rate disables one
+	IPLimit     ratelimit.Limit
+	KeyLimit    ratelimit.Limit
+	WalletLimit ratelimit.Limit
+	// SyncWorkers and SyncQueue bound the background wallet syncs of /wallet/link
+	SyncWorkers int
+	SyncQueue   int
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For names the client IP
+	// the IP limit keys on; with none, the connection's address is the client IP
+	TrustedProxies []string
+	// RollupInterval is the size of the rollups retention compacts prices into; candles of
+	// a multiple of it are served from the rollups where prices were compacted
+	RollupInterval time.Duration
+	// V1Deprecated is announced in the Deprecation header of v1 responses; zero leaves it out
+	V1Deprecated time.Time
+	// V1Sunset is announced in the Sunset header of v1 responses; zero leaves it out
+	V1Sunset time.Time
 }
 
 // NewServer creates a new API server with Gin
-func NewServer(addr string) *Server {
+// bus feeds the real-time streams and hooks sends partner webhooks (nil disables them).
+func NewServer(addr string, store *db.Store, replays *replay.Manager, bus *events.Bus, hooks *webhooks.Dispatcher, opts Options) *Server {
 	// Set Gin to release mode for production
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
-	router.Use(gin.Recovery())
-
-	// CORS middleware
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
-	config.AllowMethods = []string{"GET", "POST", "OPTIONS"}
-	config.AllowHeaders = []string{"Content-Type"}
-	router.Use(cors.New(config))
+	// Gin trusts every proxy by default, which would let callers pick their IP bucket
+	if err := router.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		utils.LogError("Invalid trusted proxies, trusting none: %v", err)
+		router.SetTrustedProxies(nil)
+	}
+	router.Use(gin.CustomRecovery(recovered), requestID)
+	router.NoRoute(notFound)
 
 	s := &Server{
-		addr:   addr,
-		router: router,
+		addr:        addr,
+		router:      router,
+		store:       store,
+		replays:     replays,
+		events:      bus,
+		webhooks:    hooks,
+		adminToken:  opts.AdminToken,
+		corsOrigins: opts.CORSOrigins,
+		siweDomains: opts.SIWEDomains,
+		siweChainID: opts.SIWEChainID,
+		sessionTTL:  opts.SessionTTL,
+
+		ipLimiter:      ratelimit.NewLimiter(opts.IPLimit),
+		keyLimiter:     ratelimit.NewLimiter(opts.KeyLimit),
+		walletLimiter:  ratelimit.NewLimiter(opts.WalletLimit),
+		syncs:          workpool.New(opts.SyncWorkers, opts.SyncQueue),
+		rollupInterval: opts.RollupInterval,
+		v1Deprecated:   opts.V1Deprecated,
+		v1Sunset:       opts.V1Sunset,
+	}
+	if s.sessionTTL <= 0 {
+		s.sessionTTL = 24 * time.Hour
+	}
+	s.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     s.checkWebSocketOrigin,
+	}
+
+	// CORS middleware for the configured origins
+	if len(s.corsOrigins) > 0 {
+		config := cors.DefaultConfig()
+		config.AllowOriginFunc = s.allowOrigin
+		config.AllowMethods = []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"}
+		config.AllowHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "X-Admin-Token", requestIDHeader}
+		config.ExposeHeaders = []string{requestIDHeader, "Retry-After", "Deprecation", "Sunset", "Link"}
+		router.Use(cors.New(config))
 	}
 
 	// Register routes
@@ -55,27 +151,92 @@ func NewServer(addr string) *Server {
 	return s
 }
 
-// setupRoutes configures all API routes
+// setupRoutes serves every route under each API version (see version.go)
 func (s *Server) setupRoutes() {
-	api := s.router.Group("/api")
+	for _, version := range apiVersions {
+		s.routes(s.router.Group(apiPrefix(version), s.versioned(version), s.authenticate, s.rateLimit))
+	}
+}
+
+// routes configures the API routes of a version. Reads are public except a wallet's
+// policies and balances, which need a session for that wallet (see authorizeWallet).
+// The wallet-scoped routes that call the chain, /balance/refresh and /wallet/link, take
+// the same session (or an operator key) and are rate limited per wallet (see
+// allowWallet). Sign-in creates its own sessions; anything else that writes or starts
+// work needs an API key with a role (see requireRole).
+func (s *Server) routes(api *gin.RouterGroup) {
+	partner := s.requireRole(db.RolePartner)
+	operator := s.requireRole(db.RoleOperator)
+	admin := s.requireRole(db.RoleAdmin)
+
+	api.GET("/health", s.handleHealth)
+	api.GET("/openapi.json", s.handleOpenAPI)
+	api.GET("/spikes", s.handleSpikes)
+	api.GET("/prices", s.handlePrices)
+	api.GET("/candles", s.handleCandles)
+	api.GET("/quarantine", s.handleQuarantine)
+	api.GET("/payouts", s.handlePayouts)
+	api.GET("/stats", s.handleStats)
+	api.GET("/policies", s.handlePolicies)
+	api.GET("/balance", s.handleBalance)
+	api.POST("/balance/refresh", s.handleBalanceRefresh)
+	api.POST("/insert_fake_kline", operator, s.handleInsertFakeKline)
+	api.POST("/wallet/link", s.handleWalletLink)
+
+	// Sign-In With Ethereum: wallet sessions for the wallet-scoped endpoints
+	api.GET("/auth/nonce", s.handleSIWENonce)
+	api.POST("/auth/verify", s.handleSIWEVerify)
+	api.GET("/auth/session", s.handleSIWESession)
+	api.POST("/auth/logout", s.handleSIWELogout)
+
+	// Real-time push of prices:SYMBOL, spikes and payouts:ADDRESS
+	api.GET("/stream", s.handleStream)
+	api.GET("/ws", s.handleWebSocket)
+
+	// Replays run in their own sessions and never touch production data
+	api.GET("/replay/sessions", s.handleReplaySessions)
+	api.POST("/replay/sessions", operator, s.handleStartReplay)
+	api.GET("/replay/sessions/:id", s.handleReplaySession)
+	api.GET("/replay/sessions/:id/prices", s.handleReplayPrices)
+	api.GET("/replay/sessions/:id/spikes", s.handleReplaySpikes)
+	api.GET("/replay/sessions/:id/quarantine", s.handleReplayQuarantine)
+	api.GET("/replay/sessions/:id/payouts", s.handleReplayPayouts)
+	api.GET("/replay/sessions/:id/progress", s.handleReplayProgress)
+	api.POST("/replay/sessions/:id/pause", operator, s.handleReplayPause)
+	api.POST("/replay/sessions/:id/resume", operator, s.handleReplayResume)
+	api.POST("/replay/sessions/:id/seek", operator, s.handleReplaySeek)
+	api.POST("/replay/sessions/:id/stop", operator, s.handleReplayStop)
+	api.POST("/replay/uploads", operator, s.handleReplayUpload)
+	api.DELETE("/replay/sessions/:id", admin, s.handleDeleteReplaySession)
+
+	// Partner webhooks: each key manages the endpoints it registered, admins all of them
+	hooks := api.Group("/webhooks", partner, s.requireWebhooks)
 	{
-		api.GET("/health", s.handleHealth)
-		api.GET("/spikes", s.handleSpikes)
-		api.GET("/prices", s.handlePrices)
-		api.GET("/payouts", s.handlePayouts)
-		api.GET("/stats", s.handleStats)
-		api.GET("/policies", s.handlePolicies)
-		api.GET("/balance", s.handleBalance)
-		api.POST("/balance/refresh", s.handleBalanceRefresh)
-		api.POST("/insert_fake_kline", s.handleInsertFakeKline)
-		api.POST("/wallet/link", s.handleWalletLink)
+		hooks.POST("", s.handleCreateWebhook)
+		hooks.GET("", s.handleWebhooks)
+		hooks.GET("/:id", s.handleWebhook)
+		hooks.PATCH("/:id", s.handleUpdateWebhook)
+		hooks.DELETE("/:id", s.handleDeleteWebhook)
+		hooks.GET("/:id/deliveries", s.handleWebhookDeliveries)
+		hooks.POST("/:id/deliveries/:delivery/redeliver", s.handleRedeliverWebhook)
+		hooks.POST("/:id/ping", s.handlePingWebhook)
+	}
+
+	// API keys and the audit log of privileged calls
+	adm := api.Group("/admin", admin)
+	{
+		adm.POST("/reset", s.handleAdminReset)
+		adm.POST("/keys", s.handleCreateAPIKey)
+		adm.GET("/keys", s.handleAPIKeys)
+		adm.DELETE("/keys/:id", s.handleRevokeAPIKey)
+		adm.GET("/audit", s.handleAuditLog)
 	}
 }
 
 // Start begins serving HTTP requests
 func (s *Server) Start() error {
 	utils.LogInfo("🌐 API Server starting on %s", s.addr)
-	return s.router.Run(s.addr)
+	return http.ListenAndServe(s.addr, s)
 }
 
 // handleHealth returns server health status
@@ -86,224 +247,228 @@ func (s *Server) handleHealth(c *gin.Context) {
 	})
 }
 
-// handleSpikes returns recent wick detection events
+// handleSpikes returns wick detection events, newest first, filtered and paged by the
+// parameters of listQuery
 func (s *Server) handleSpikes(c *gin.Context) {
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	q, ok := listQuery(c, 50, true)
+	if !ok {
+		return
+	}
 
-	spikes, err := db.GetRecentSpikes(limit)
+	spikes, next, err := s.store.Spikes.GetSpikes(q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch spikes"})
+		listFailed(c, err, "Failed to fetch spikes")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"count":  len(spikes),
-		"spikes": spikes,
-	})
+	reply(c, http.StatusOK, gin.H{
+		"count":       len(spikes),
+		"spikes":      spikes,
+		"next_cursor": nextCursor(next),
+	}, newPageV2(spikes, next, newSpikeV2))
 }
 
-// handlePrices returns recent price data
+// handlePrices returns the candles of a symbol, newest first
 func (s *Server) handlePrices(c *gin.Context) {
+	q, ok := listQuery(c, 100, true)
+	if !ok {
+		return
+	}
+	if q.Symbol == "" {
+		q.Symbol = "BTCUSDT"
+	}
+
+	prices, next, err := s.store.Prices.GetPrices(q)
+	if err != nil {
+		listFailed(c, err, "Failed to fetch prices")
+		return
+	}
+
+	reply(c, http.StatusOK, gin.H{
+		"symbol":      q.Symbol,
+		"count":       len(prices),
+		"prices":      prices,
+		"next_cursor": nextCursor(next),
+	}, newPageV2(prices, next, newPriceV2))
+}
+
+// handleCandles aggregates the candles of a symbol to an interval for charts:
+// ?symbol=&interval=1m|5m|1h|1d&from=&to=. A window missing either end spans
+// defaultCandles intervals; missing both, it ends with the latest candle. Ranges
+// compacted by retention come from the rollups if the interval is a multiple of
+// theirs; finer intervals only see the spike candles kept there.
+func (s *Server) handleCandles(c *gin.Context) {
 	symbol := c.DefaultQuery("symbol", "BTCUSDT")
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	name := c.DefaultQuery("interval", "1m")
+	interval, ok := db.CandleIntervals[name]
+	if !ok {
+		abort(c, errInvalid("interval must be 1m, 5m, 1h or 1d"))
+		return
+	}
 
-	prices, err := db.GetRecentPrices(symbol, limit)
+	from, to, ok := timeWindow(c)
+	if !ok {
+		return
+	}
+	switch {
+	case to.IsZero() && !from.IsZero():
+		to = from.Add(defaultCandles * interval)
+	case to.IsZero():
+		to = time.Now().UTC()
+		latest, err := s.store.Prices.GetLatestPrice(symbol)
+		if err != nil && err != sql.ErrNoRows {
+			abort(c, errInternal("Failed to fetch latest price", err))
+			return
+		}
+		if latest != nil {
+			to = latest.Timestamp.UTC().Truncate(interval).Add(interval)
+		}
+		fallthrough
+	case from.IsZero():
+		from = to.Add(-defaultCandles * interval)
+	}
+	if to.Sub(from)/interval > maxPageSize {
+		abort(c, errInvalid("at most "+strconv.Itoa(maxPageSize)+" candles per request; narrow from/to or use a longer interval"))
+		return
+	}
+
+	candles, err := s.store.Candles.GetCandles(symbol, interval, s.rollupInterval, from, to)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prices"})
+		abort(c, errInternal("Failed to fetch candles", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"symbol": symbol,
-		"count":  len(prices),
-		"prices": prices,
-	})
+	body := gin.H{
+		"symbol":   symbol,
+		"interval": name,
+		"from":     from,
+		"to":       to,
+		"count":    len(candles),
+		"candles":  candles,
+	}
+	if apiVersionOf(c) == apiV2 {
+		// A window rather than a page: the next one starts at to
+		views := make([]candleV2, len(candles))
+		for i, candle := range candles {
+			views[i] = newCandleV2(candle)
+		}
+		body["candles"] = views
+	}
+	c.JSON(http.StatusOK, body)
 }
 
-// handlePayouts returns payout history
-func (s *Server) handlePayouts(c *gin.Context) {
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+// handleQuarantine returns candles that failed validation and were kept away from detection
+func (s *Server) handleQuarantine(c *gin.Context) {
+	q, ok := listQuery(c, 100, true)
+	if !ok {
+		return
+	}
 
-	user := c.Query("user")
-	var (
-		payouts []*db.Payout
-		err     error
-	)
+	rows, next, err := s.store.Quarantine.GetQuarantinedPrices("", q)
+	if err != nil {
+		listFailed(c, err, "Failed to fetch quarantined prices")
+		return
+	}
 
-	// Use unified GetRecentPayouts which accepts an optional user filter
-	payouts, err = db.GetRecentPayouts(limit, user)
+	reply(c, http.StatusOK, gin.H{
+		"count":       len(rows),
+		"quarantined": rows,
+		"next_cursor": nextCursor(next),
+	}, newPageV2(rows, next, newQuarantinedPriceV2))
+}
 
+// handlePayouts returns payout history, optionally for one wallet (?user=)
+func (s *Server) handlePayouts(c *gin.Context) {
+	q, ok := listQuery(c, 50, true)
+	if !ok {
+		return
+	}
+	q.User = c.Query("user")
+
+	payouts, next, err := s.store.Payouts.GetPayouts(q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payouts"})
+		listFailed(c, err, "Failed to fetch payouts")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"count":   len(payouts),
-		"payouts": payouts,
-	})
+	reply(c, http.StatusOK, gin.H{
+		"count":       len(payouts),
+		"payouts":     payouts,
+		"next_cursor": nextCursor(next),
+	}, newPageV2(payouts, next, newPayoutV2))
 }
 
 // handleStats returns system statistics
 func (s *Server) handleStats(c *gin.Context) {
-	stats, err := db.GetSystemStats()
+	stats, err := s.store.Stats.GetSystemStats()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats"})
+		abort(c, errInternal("Failed to fetch stats", err))
 		return
 	}
 
-	latestPrice, _ := db.GetLatestPrice("BTCUSDT")
+	// No candles yet is not an error: latest_price is null
+	latestPrice, err := s.store.Prices.GetLatestPrice("BTCUSDT")
+	if err != nil && err != sql.ErrNoRows {
+		abort(c, errInternal("Failed to fetch latest price", err))
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
+	v2 := statsV2{Stats: stats, Status: "monitoring"}
+	if latestPrice != nil {
+		price := newPriceV2(latestPrice)
+		v2.LatestPrice = &price
+	}
+	reply(c, http.StatusOK, gin.H{
 		"stats":        stats,
 		"latest_price": latestPrice,
 		"status":       "monitoring",
-	})
-}
-
-// handleInsertFakeKline inserts CSV data line by line with 1 second delay
-func (s *Server) handleInsertFakeKline(c *gin.Context) {
-	utils.LogInfo("📝 Starting fake kline insertion from CSV...")
-
-	// Run in background to avoid blocking the HTTP response
-	go func() {
-		// First, delete all existing spikes
-		utils.LogInfo("🗑️  Deleting all existing spikes...")
-		if err := db.DeleteAllSpikes(); err != nil {
-			utils.LogError("Failed to delete spikes: %v", err)
-			return
-		}
-		utils.LogInfo("✅ All spikes deleted")
-
-		// Then delete all existing prices
-		utils.LogInfo("🗑️  Deleting all existing prices...")
-		if err := db.DeleteAllPrices(); err != nil {
-			utils.LogError("Failed to delete prices: %v", err)
-			return
-		}
-		utils.LogInfo("✅ All prices deleted")
-
-		csvPath := "../data/btcusdt_wick_test.csv"
-		file, err := os.Open(csvPath)
-		if err != nil {
-			utils.LogError("Failed to open CSV file: %v", err)
-			return
-		}
-		defer file.Close()
-
-		reader := csv.NewReader(file)
-
-		// Read header
-		header, err := reader.Read()
-		if err != nil {
-			utils.LogError("Failed to read CSV header: %v", err)
-			return
-		}
-
-		utils.LogInfo("CSV header: %v", header)
-
-		lineCount := 0
-		for {
-			record, err := reader.Read()
-			if err != nil {
-				// End of file
-				utils.LogInfo("✅ Finished inserting %d lines from CSV", lineCount)
-				break
-			}
-
-			// Parse CSV record (timestamp, open, high, low, close, volume)
-			if len(record) < 6 {
-				utils.LogError("Invalid CSV record: %v", record)
-				continue
-			}
-
-			// Parse timestamp (ISO 8601 format)
-			timestamp, err := time.Parse(time.RFC3339, record[0])
-			if err != nil {
-				utils.LogError("Failed to parse timestamp: %v", err)
-				continue
-			}
-
-			open, _ := strconv.ParseFloat(record[1], 64)
-			high, _ := strconv.ParseFloat(record[2], 64)
-			low, _ := strconv.ParseFloat(record[3], 64)
-			close, _ := strconv.ParseFloat(record[4], 64)
-			volume, _ := strconv.ParseFloat(record[5], 64)
-
-			// Create price data
-			priceData := &db.PriceData{
-				Timestamp: timestamp,
-				Symbol:    "BTCUSDT",
-				Open:      open,
-				High:      high,
-				Low:       low,
-				Close:     close,
-				Volume:    volume,
-			}
-
-			// Insert into database (this will trigger the channel notification)
-			if err := db.InsertPrice(priceData); err != nil {
-				utils.LogError("Failed to insert price: %v", err)
-				continue
-			}
-
-			lineCount++
-			utils.LogInfo("Inserted line %d: %s @ $%.2f", lineCount, priceData.Timestamp.Format(time.RFC3339), priceData.Close)
-
-			//// Sleep 1 second before next insert
-			//time.Sleep(1 * time.Second)
-		}
-	}()
-
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "started",
-		"message": "Fake kline insertion started in background",
-	})
+	}, v2)
 }
 
 // handleBalance returns token balance from DB (indexer). Defaults to USDT if token not provided.
 func (s *Server) handleBalance(c *gin.Context) {
 	address := c.Query("address")
 	if address == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "address query parameter required"})
+		abort(c, errInvalid("address query parameter required"))
+		return
+	}
+	if !s.authorizeWallet(c, address) {
 		return
 	}
 
 	token := c.DefaultQuery("token", utils.AppConfig.RPC.UsdtAddress)
 
 	// Read from DB cache
-	bal, err := db.GetBalanceForUser(token, address)
+	bal, err := s.store.Balances.GetBalanceForUser(token, address)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusOK, gin.H{
+			reply(c, http.StatusOK, gin.H{
 				"address": address,
 				"token":   token,
 				"found":   false,
-			})
+			}, newBalanceV2(address, token, nil, time.Time{}))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		abort(c, errInternal("Failed to fetch balance", err))
 		return
 	}
 
-	// Format using configured decimals if available
-	decimals := utils.AppConfig.RPC.UsdtDecimals
-	if decimals <= 0 {
-		decimals = 6
-	}
-	format := fmt.Sprintf("%%.%df", decimals)
-	formatted := fmt.Sprintf(format, bal.Balance)
-
-	c.JSON(http.StatusOK, gin.H{
+	reply(c, http.StatusOK, gin.H{
 		"address":      bal.UserAddress,
 		"token":        bal.TokenAddress,
-		"balance":      formatted,
+		"balance":      bal.Balance,
+		"raw":          bal.Balance.Raw.String(),
+		"decimals":     bal.Balance.Decimals,
 		"last_updated": bal.LastUpdated,
 		"found":        true,
-	})
+	}, newBalanceV2(bal.UserAddress, bal.TokenAddress, &bal.Balance, bal.LastUpdated))
 }
 
-// handleBalanceRefresh forces an on-chain read of the token balance and upserts to DB
+// handleBalanceRefresh checks a wallet's indexed balance of the configured token against
+// balanceOf at the last block the token listener synced, and records the result in
+// balance_checks like the sampled reconciliation does. Balances are not written: they
+// only change through journaled Transfer deltas. Other tokens are refused: their
+// decimals are unknown, and the RPC quota is not for arbitrary contracts.
 func (s *Server) handleBalanceRefresh(c *gin.Context) {
 	address := c.PostForm("address")
 	if address == "" {
@@ -311,111 +476,127 @@ func (s *Server) handleBalanceRefresh(c *gin.Context) {
 		address = c.Query("address")
 	}
 	if address == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "address required"})
+		abort(c, errInvalid("address required"))
+		return
+	}
+	if !s.authorizeWallet(c, address) || !s.allowWallet(c, address) {
 		return
 	}
 
-	token := c.DefaultQuery("token", utils.AppConfig.RPC.UsdtAddress)
 	cfg := utils.AppConfig
 	if cfg == nil || cfg.RPC.URL == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "RPC not configured"})
+		abort(c, errUnavailable("RPC not configured"))
 		return
 	}
-
-	client, err := ethclient.Dial(cfg.RPC.URL)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect RPC"})
+	token := cfg.RPC.UsdtAddress
+	if raw := c.Query("token"); raw != "" && !strings.EqualFold(raw, token) {
+		abort(c, errInvalid("token must be the configured token "+token))
 		return
 	}
-	defer client.Close()
+	tokenAddr := common.HexToAddress(token)
+	user := common.HexToAddress(address)
 
-	// Use minimal ERC20 ABI
-	const erc20ABI = `[{"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"type":"function"}]`
-	parsed, err := abi.JSON(strings.NewReader(erc20ABI))
+	// Pin the read to the synced block so transfers not yet indexed don't show up as drift
+	block, err := s.store.SyncState.GetLastSyncedBlock(tokenAddr)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse ABI"})
+		abort(c, errInternal("Failed to get last synced block", err))
 		return
 	}
-
-	tokenAddr := common.HexToAddress(token)
-	contract := bind.NewBoundContract(tokenAddr, parsed, client, client, client)
-
-	var out []interface{}
-	if err := contract.Call(&bind.CallOpts{Context: c.Request.Context()}, &out, "balanceOf", common.HexToAddress(address)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to call balanceOf"})
+	if block == 0 {
+		abort(c, errUnavailable("token transfers have not been indexed yet"))
 		return
 	}
-	balanceRaw := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
 
-	// Convert to human float using configured decimals
-	decimals := cfg.RPC.UsdtDecimals
-	balf := new(big.Float).SetInt(balanceRaw)
-	denom := new(big.Float).SetFloat64(math.Pow10(int(decimals)))
-	human := new(big.Float).Quo(balf, denom)
-	humanVal, _ := human.Float64()
+	decimals := cfg.TokenDecimals()
+	indexed := new(big.Int)
+	bal, err := s.store.Balances.GetBalanceForUser(tokenAddr.Hex(), user.Hex())
+	switch {
+	case err == nil:
+		indexed, decimals = bal.Balance.Raw, bal.Balance.Decimals
+	case err != sql.ErrNoRows:
+		abort(c, errInternal("Failed to fetch balance", err))
+		return
+	}
 
-	// Upsert into DB
-	if err := db.UpsertBalance(token, address, humanVal); err != nil {
-		utils.LogError("Failed to upsert balance: %v", err)
+	client, err := s.rpc()
+	if err != nil {
+		abort(c, errUpstream("Failed to connect RPC", err))
+		return
+	}
+	caller, err := contracts.NewMockUSDTCaller(tokenAddr, client)
+	if err != nil {
+		abort(c, errInternal("Failed to bind token", err))
+		return
+	}
+	chainBalance, err := caller.BalanceOf(&bind.CallOpts{Context: c.Request.Context(), BlockNumber: new(big.Int).SetUint64(block)}, user)
+	if err != nil {
+		abort(c, errUpstream("Failed to call balanceOf", err))
+		return
 	}
 
-	// Format according to configured decimals
-	if cfg.RPC.UsdtDecimals <= 0 {
-		cfg.RPC.UsdtDecimals = 6
+	check := db.NewBalanceCheck(tokenAddr.Hex(), user.Hex(), block, indexed, chainBalance)
+	if err := s.store.Balances.InsertBalanceCheck(check); err != nil {
+		abort(c, errInternal("Failed to record balance check", err))
+		return
 	}
-	format := fmt.Sprintf("%%.%df", cfg.RPC.UsdtDecimals)
-	c.JSON(http.StatusOK, gin.H{
-		"address": address,
-		"token":   token,
-		"balance": fmt.Sprintf(format, humanVal),
-	})
+	if check.Drift.Sign() != 0 {
+		utils.LogErrorCtx(c.Request.Context(), "Balance drift for %s at block %d: indexed=%s chain=%s",
+			check.UserAddress, block, utils.FormatUnits(indexed, decimals), utils.FormatUnits(chainBalance, decimals))
+	}
+
+	reply(c, http.StatusOK, gin.H{
+		"address":       address,
+		"token":         token,
+		"block_number":  block,
+		"balance":       utils.NewAmount(indexed, decimals),
+		"chain_balance": utils.NewAmount(chainBalance, decimals),
+		"drift":         utils.NewAmount(check.Drift, decimals),
+		"decimals":      decimals,
+	}, newBalanceCheckV2(address, token, check, decimals))
 }
 
 // handlePolicies returns policies for a given user address
 func (s *Server) handlePolicies(c *gin.Context) {
 	address := c.Query("address")
 	if address == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "address query parameter required"})
+		abort(c, errInvalid("address query parameter required"))
+		return
+	}
+	if !s.authorizeWallet(c, address) {
 		return
 	}
 
-	policies, err := db.GetPoliciesForUser(address)
+	policies, err := s.store.Policies.GetPoliciesForUser(address)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch policies"})
+		abort(c, errInternal("Failed to fetch policies", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	reply(c, http.StatusOK, gin.H{
 		"count":    len(policies),
 		"policies": policies,
-	})
+	}, newPageV2(policies, "", newPolicyV2))
 }
 
-// handleWalletLink triggers a background upsert of balances and policies for the linked wallet
+// handleWalletLink queues a background sync of the linked wallet's policies
 func (s *Server) handleWalletLink(c *gin.Context) {
 	var req struct {
 		Address string `json:"address"`
-		Token   string `json:"token"`
 	}
 	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		abort(c, errInvalid("invalid request"))
 		return
 	}
-	utils.LogInfo("wallet/link called address=%s token=%s", req.Address, req.Token)
+	utils.LogInfoCtx(c.Request.Context(), "wallet/link called address=%s", req.Address)
 
-	if req.Address == "" || req.Token == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "address required"})
+	if req.Address == "" {
+		abort(c, errInvalid("address required"))
+		return
+	}
+	if !s.authorizeWallet(c, req.Address) || !s.allowWallet(c, req.Address) {
 		return
 	}
 
 	// Run upsert in background to avoid blocking client
-	go func(addr string) {
-		if err := db.UpsertForUser(utils.AppConfig, addr); err != nil {
-			utils.LogError("UpsertForUser failed for %s: %v", addr, err)
-		} else {
-			utils.LogInfo("UpsertForUser succeeded for %s", addr)
-		}
-	}(req.Address)
-
-	c.JSON(http.StatusAccepted, gin.H{"status": "accepted"})
+	s.queueWalletSync(c, req.Address)
 }
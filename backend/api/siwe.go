@@ -0,0 +1,195 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"spikeshield/db"
+	"spikeshield/siwe"
+	"spikeshield/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sessionKey is where authenticate stores a wallet session in the gin context
+	sessionKey = "wallet_session"
+	// sessionTokenPrefix tells session tokens apart from API keys
+	sessionTokenPrefix = "sess_"
+	// nonceTTL is how long a nonce may wait for its signed message
+	nonceTTL = 10 * time.Minute
+	// siweClockSkew tolerates clients whose clock runs ahead
+	siweClockSkew = time.Minute
+)
+
+// sessionOf returns the wallet session set by authenticate, or nil
+func sessionOf(c *gin.Context) *db.WalletSession {
+	if s, ok := c.Get(sessionKey); ok {
+		return s.(*db.WalletSession)
+	}
+	return nil
+}
+
+// authenticateSession resolves a session token; expired, revoked and unknown tokens are
+// rejected rather than treated as anonymous, so clients know to sign in again
+func (s *Server) authenticateSession(c *gin.Context, token string) {
+	session, err := s.store.Sessions.GetWalletSessionByHash(db.HashToken(token))
+	if err == sql.ErrNoRows || (err == nil && !session.Valid(time.Now().UTC())) {
+		if !s.allowIP(c) {
+			return
+		}
+		abort(c, errUnauthorized("invalid or expired wallet session"))
+		return
+	}
+	if err != nil {
+		abort(c, errInternal("Failed to authenticate", err))
+		return
+	}
+	c.Set(sessionKey, session)
+	c.Next()
+}
+
+// authorizeWallet lets a request act on address if it carries a session for that wallet
+// or an operator key, and answers 400/401/403 itself otherwise
+func (s *Server) authorizeWallet(c *gin.Context, address string) bool {
+	if !common.IsHexAddress(address) {
+		abort(c, errInvalid("invalid address"))
+		return false
+	}
+	if db.RoleRank(principalOf(c).Role) >= db.RoleRank(db.RoleOperator) {
+		return true
+	}
+	session := sessionOf(c)
+	if session == nil {
+		abort(c, errUnauthorized("wallet session required (sign in with /api/auth/nonce and /api/auth/verify)"))
+		return false
+	}
+	if !strings.EqualFold(session.Address, address) {
+		abort(c, errForbidden("session belongs to another wallet"))
+		return false
+	}
+	return true
+}
+
+// handleSIWENonce issues a single-use nonce for a Sign-In With Ethereum message
+func (s *Server) handleSIWENonce(c *gin.Context) {
+	nonce := siwe.NewNonce()
+	expiresAt := time.Now().UTC().Add(nonceTTL)
+	if err := s.store.Sessions.CreateNonce(nonce, expiresAt); err != nil {
+		abort(c, errInternal("Failed to issue nonce", err))
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"nonce": nonce, "expires_at": expiresAt})
+}
+
+// handleSIWEVerify checks a signed EIP-4361 message, {"message", "signature"}, and opens
+// a session for its address. The session ends at the message's Expiration Time if that
+// comes before the configured TTL.
+func (s *Server) handleSIWEVerify(c *gin.Context) {
+	var req struct {
+		Message   string `json:"message"`
+		Signature string `json:"signature"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		abort(c, errInvalid("invalid request"))
+		return
+	}
+	msg, err := siwe.Parse(req.Message)
+	if err != nil {
+		abort(c, errInvalid("invalid sign-in message: "+err.Error()))
+		return
+	}
+	if !s.allowSIWEDomain(msg.Domain) {
+		abort(c, errInvalid("sign-in message is for another domain: "+msg.Domain))
+		return
+	}
+	if s.siweChainID != 0 && msg.ChainID != s.siweChainID {
+		abort(c, errInvalid("sign-in message is for another chain"))
+		return
+	}
+
+	now := time.Now().UTC()
+	if err := msg.CheckTime(now, siweClockSkew); err != nil {
+		abort(c, errUnauthorized(err.Error()))
+		return
+	}
+	if err := msg.VerifySignature(req.Message, req.Signature); err != nil {
+		abort(c, errUnauthorized(err.Error()))
+		return
+	}
+	// Only a valid signature spends the nonce
+	err = s.store.Sessions.ConsumeNonce(msg.Nonce, now)
+	if err == sql.ErrNoRows {
+		abort(c, errUnauthorized("nonce is unknown, used or expired"))
+		return
+	}
+	if err != nil {
+		abort(c, errInternal("Failed to verify nonce", err))
+		return
+	}
+
+	expiresAt := now.Add(s.sessionTTL)
+	if msg.ExpirationTime != nil && msg.ExpirationTime.Before(expiresAt) {
+		expiresAt = msg.ExpirationTime.UTC()
+	}
+	token, session := db.NewWalletSession(msg.Address, msg.ChainID, expiresAt)
+	if err := s.store.Sessions.CreateWalletSession(session); err != nil {
+		abort(c, errInternal("Failed to create session", err))
+		return
+	}
+	utils.LogInfoCtx(c.Request.Context(), "🔏 Wallet %s signed in (chain %d) until %s", session.Address, session.ChainID, expiresAt.Format(time.RFC3339))
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"address":    session.Address,
+		"chain_id":   session.ChainID,
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// allowSIWEDomain reports whether sign-in messages for domain are accepted
+func (s *Server) allowSIWEDomain(domain string) bool {
+	if len(s.siweDomains) == 0 {
+		return true
+	}
+	for _, d := range s.siweDomains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleSIWESession describes the session of the request
+func (s *Server) handleSIWESession(c *gin.Context) {
+	session := sessionOf(c)
+	if session == nil {
+		abort(c, errUnauthorized("no wallet session"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"address":    session.Address,
+		"chain_id":   session.ChainID,
+		"created_at": session.CreatedAt,
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// handleSIWELogout ends the session of the request
+func (s *Server) handleSIWELogout(c *gin.Context) {
+	session := sessionOf(c)
+	if session == nil {
+		abort(c, errUnauthorized("no wallet session"))
+		return
+	}
+	if err := s.store.Sessions.RevokeWalletSession(session.ID); err != nil {
+		abort(c, errInternal("Failed to end session", err))
+		return
+	}
+	utils.LogInfoCtx(c.Request.Context(), "🔏 Wallet %s signed out", session.Address)
+	c.JSON(http.StatusOK, gin.H{"status": "signed out"})
+}
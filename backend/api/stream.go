@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"spikeshield/events"
+	"spikeshield/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamHeartbeat keeps idle SSE connections and proxies alive
+	streamHeartbeat = 15 * time.Second
+	// wsPingInterval is how often WebSocket clients are pinged; a client that does not
+	// answer within wsPongWait is disconnected
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsWriteWait    = 10 * time.Second
+	// wsMaxMessage bounds client messages, which only carry subscriptions
+	wsMaxMessage = 4096
+)
+
+// streamMessage is the JSON form of a bus event on both transports; v2 streams carry
+// the v2 form of its data
+func streamMessage(ev events.Event, version string) gin.H {
+	data := ev.Data
+	if version == apiV2 {
+		data = eventDataV2(data)
+	}
+	return gin.H{
+		"seq":   ev.Seq,
+		"topic": ev.Topic,
+		"type":  ev.Type,
+		"data":  data,
+		"time":  ev.Time.Format(time.RFC3339Nano),
+	}
+}
+
+// parseTopics parses a comma-separated topic list
+func parseTopics(list []string) ([]string, error) {
+	var topics []string
+	for _, item := range list {
+		for _, t := range strings.Split(item, ",") {
+			if strings.TrimSpace(t) == "" {
+				continue
+			}
+			topic, err := events.ParseTopic(t)
+			if err != nil {
+				return nil, err
+			}
+			topics = append(topics, topic)
+		}
+	}
+	return topics, nil
+}
+
+// handleStream streams events as Server-Sent Events:
+// GET /api/stream?topics=prices:BTCUSDT,spikes,payouts:0x...
+// Each event is sent with its sequence number as id and its type as event name.
+func (s *Server) handleStream(c *gin.Context) {
+	topics, err := parseTopics(c.QueryArray("topics"))
+	if err != nil {
+		abort(c, errInvalid(err.Error()))
+		return
+	}
+	if len(topics) == 0 {
+		abort(c, errInvalid("topics required (prices:SYMBOL, spikes, payouts:ADDRESS)"))
+		return
+	}
+
+	version := apiVersionOf(c)
+	sub := s.events.Subscribe(c.Request.Context(), topics...)
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	c.Status(http.StatusOK)
+
+	writeSSE(c.Writer, 0, "subscribed", gin.H{"topics": topics})
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			return writeSSE(w, ev.Seq, ev.Type, streamMessage(ev, version)) == nil
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		}
+	})
+}
+
+// writeSSE writes one event; id 0 is omitted
+func writeSSE(w io.Writer, id uint64, name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+	return err
+}
+
+// wsRequest is a client message: {"action": "subscribe" | "unsubscribe", "topics": [...]}
+type wsRequest struct {
+	Action string   `json:"action"`
+	Topics []string `json:"topics"`
+}
+
+// handleWebSocket streams events over a WebSocket: GET /api/ws?topics=... subscribes
+// up front (browsers only from allowed origins), and clients subscribe and unsubscribe at any time with wsRequest messages.
+// Events are sent as streamMessage JSON; requests are answered with a "subscribed",
+// "unsubscribed" or "error" message.
+func (s *Server) handleWebSocket(c *gin.Context) {
+	topics, err := parseTopics(c.QueryArray("topics"))
+	if err != nil {
+		abort(c, errInvalid(err.Error()))
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already answered the request
+		utils.LogDebugCtx(c.Request.Context(), "WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	version := apiVersionOf(c)
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	sub := s.events.Subscribe(ctx, topics...)
+
+	// Replies are written by the loop below, the only writer of the connection
+	replies := make(chan gin.H, 16)
+	go func() {
+		defer cancel()
+		readWebSocket(ctx, conn, sub, replies)
+	}()
+	if len(topics) > 0 {
+		replies <- gin.H{"type": "subscribed", "topics": sub.Topics()}
+	}
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			err = writeWebSocket(conn, streamMessage(ev, version))
+		case reply := <-replies:
+			err = writeWebSocket(conn, reply)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readWebSocket applies client subscription requests until the connection fails
+func readWebSocket(ctx context.Context, conn *websocket.Conn, sub *events.Subscription, replies chan<- gin.H) {
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	reply := func(msg gin.H) {
+		select {
+		case replies <- msg:
+		case <-ctx.Done():
+		}
+	}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			reply(gin.H{"type": "error", "error": "invalid message (expected {\"action\", \"topics\"})"})
+			continue
+		}
+		topics, err := parseTopics(req.Topics)
+		if err != nil {
+			reply(gin.H{"type": "error", "error": err.Error()})
+			continue
+		}
+		switch req.Action {
+		case "subscribe":
+			sub.Add(topics...)
+			reply(gin.H{"type": "subscribed", "topics": sub.Topics()})
+		case "unsubscribe":
+			sub.Remove(topics...)
+			reply(gin.H{"type": "unsubscribed", "topics": sub.Topics()})
+		default:
+			reply(gin.H{"type": "error", "error": fmt.Sprintf("unknown action %q (expected subscribe or unsubscribe)", req.Action)})
+		}
+	}
+}
+
+func writeWebSocket(conn *websocket.Conn, msg gin.H) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteJSON(msg)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API versions are served side by side, every route under each, by the same handlers:
+// handlers answer v1 the shapes the dashboard was built on and v2 the typed resources of
+// views.go. v1 is deprecated; its responses say so and name their v2 successor.
+const (
+	apiV1 = "v1"
+	apiV2 = "v2"
+	// versionKey is where versioned stores the API version of a request
+	versionKey = "api_version"
+)
+
+// apiVersions lists the versions served, oldest first
+var apiVersions = []string{apiV1, apiV2}
+
+// versionSegment matches the version segment of a versioned path, e.g. "v2"
+var versionSegment = regexp.MustCompile(`^v[0-9]+$`)
+
+// apiPrefix is the path every route of a version is served under
+func apiPrefix(version string) string {
+	return "/api/" + version
+}
+
+// versioned tags the requests of a version; v1 responses are marked deprecated
+func (s *Server) versioned(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(versionKey, version)
+		if version == apiV1 {
+			s.deprecate(c)
+		}
+		c.Next()
+	}
+}
+
+// deprecate sets the Deprecation (RFC 9745) and Sunset (RFC 8594) headers of a v1
+// response for the dates configured, and links the same route in v2
+func (s *Server) deprecate(c *gin.Context) {
+	if !s.v1Deprecated.IsZero() {
+		c.Header("Deprecation", fmt.Sprintf("@%d", s.v1Deprecated.Unix()))
+	}
+	if !s.v1Sunset.IsZero() {
+		c.Header("Sunset", s.v1Sunset.UTC().Format(http.TimeFormat))
+	}
+	successor := apiPrefix(apiV2) + strings.TrimPrefix(c.Request.URL.Path, apiPrefix(apiV1))
+	c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+}
+
+// apiVersionOf returns the version of the route serving the request
+func apiVersionOf(c *gin.Context) string {
+	return c.GetString(versionKey)
+}
+
+// reply answers v1 or v2 by the version of the request
+func reply(c *gin.Context, status int, v1, v2 interface{}) {
+	if apiVersionOf(c) == apiV2 {
+		c.JSON(status, v2)
+		return
+	}
+	c.JSON(status, v1)
+}
+
+// ServeHTTP serves the API. Paths under /api without a version predate versioning and
+// are served by v1, so existing clients keep working until they move to /api/v1 or v2.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/api/"); ok {
+		segment, _, _ := strings.Cut(rest, "/")
+		if !versionSegment.MatchString(segment) {
+			r.URL.Path = apiPrefix(apiV1) + "/" + rest
+			r.URL.RawPath = ""
+		}
+	}
+	s.router.ServeHTTP(w, r)
+}
@@ -0,0 +1,397 @@
+package api
+
+import (
+	"time"
+
+	"spikeshield/datafeed"
+	"spikeshield/db"
+	"spikeshield/replay"
+	"spikeshield/utils"
+)
+
+// The types below are the resources of API v2. Unlike v1, which answers store rows as
+// they are, every field is snake_case and documented, token amounts carry their base
+// units and decimals, and lists are pages.
+
+// pageV2 is a list in v2: data is never null, and next_cursor is null on the last page.
+// Lists the store returns whole are a single page.
+type pageV2[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// newPageV2 converts the rows of a page and its next cursor ("" on the last page)
+func newPageV2[R, T any](rows []R, next string, view func(R) T) pageV2[T] {
+	page := pageV2[T]{Data: make([]T, len(rows))}
+	for i, row := range rows {
+		page.Data[i] = view(row)
+	}
+	if next != "" {
+		page.NextCursor = &next
+	}
+	return page
+}
+
+// amountV2 is an exact token amount: value is the decimal string v1 answers, raw the
+// integer number of base units
+type amountV2 struct {
+	Value    string `json:"value"`
+	Raw      string `json:"raw"`
+	Decimals int    `json:"decimals"`
+}
+
+func newAmountV2(a utils.Amount) amountV2 {
+	raw := "0"
+	if a.Raw != nil {
+		raw = a.Raw.String()
+	}
+	return amountV2{Value: a.String(), Raw: raw, Decimals: a.Decimals}
+}
+
+type priceV2 struct {
+	ID        int       `json:"id"`
+	Symbol    string    `json:"symbol"`
+	Timestamp time.Time `json:"timestamp"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    float64   `json:"volume"`
+}
+
+func newPriceV2(p *db.PriceData) priceV2 {
+	return priceV2{ID: p.ID, Symbol: p.Symbol, Timestamp: p.Timestamp, Open: p.Open, High: p.High, Low: p.Low,
+		Close: p.Close, Volume: p.Volume}
+}
+
+type spikeV2 struct {
+	ID                int        `json:"id"`
+	Symbol            string     `json:"symbol"`
+	Timestamp         time.Time  `json:"timestamp"`
+	PriceID           int        `json:"price_id"`
+	Open              float64    `json:"open"`
+	High              float64    `json:"high"`
+	Low               float64    `json:"low"`
+	Close             float64    `json:"close"`
+	BodyRatio         float64    `json:"body_ratio"`
+	RangeClosePercent float64    `json:"range_close_percent"`
+	DetectedAt        time.Time  `json:"detected_at"`
+	VoidedAt          *time.Time `json:"voided_at"`
+}
+
+func newSpikeV2(s *db.Spike) spikeV2 {
+	return spikeV2{ID: s.ID, Symbol: s.Symbol, Timestamp: s.Timestamp, PriceID: s.PriceID, Open: s.Open, High: s.High,
+		Low: s.Low, Close: s.Close, BodyRatio: s.BodyRatio, RangeClosePercent: s.RangeClosePercent,
+		DetectedAt: s.DetectedAt, VoidedAt: s.VoidedAt}
+}
+
+type quarantinedPriceV2 struct {
+	ID            int       `json:"id"`
+	SessionID     string    `json:"session_id"` // "" for production
+	Symbol        string    `json:"symbol"`
+	Timestamp     time.Time `json:"timestamp"`
+	Open          float64   `json:"open"`
+	High          float64   `json:"high"`
+	Low           float64   `json:"low"`
+	Close         float64   `json:"close"`
+	Volume        float64   `json:"volume"`
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+func newQuarantinedPriceV2(q *db.QuarantinedPrice) quarantinedPriceV2 {
+	return quarantinedPriceV2{ID: q.ID, SessionID: q.SessionID, Symbol: q.Symbol, Timestamp: q.Timestamp, Open: q.Open,
+		High: q.High, Low: q.Low, Close: q.Close, Volume: q.Volume, Reason: q.Reason, QuarantinedAt: q.QuarantinedAt}
+}
+
+type spikeMarkerV2 struct {
+	ID                int       `json:"id"`
+	Timestamp         time.Time `json:"timestamp"`
+	Direction         string    `json:"direction"`
+	RangeClosePercent float64   `json:"range_close_percent"`
+}
+
+type candleV2 struct {
+	Time    time.Time       `json:"time"` // start of the interval
+	Open    float64         `json:"open"`
+	High    float64         `json:"high"`
+	Low     float64         `json:"low"`
+	Close   float64         `json:"close"`
+	Volume  float64         `json:"volume"`
+	Candles int             `json:"candles"`
+	Spikes  []spikeMarkerV2 `json:"spikes"`
+}
+
+func newCandleV2(c *db.Candle) candleV2 {
+	v := candleV2{Time: c.Bucket, Open: c.Open, High: c.High, Low: c.Low, Close: c.Close, Volume: c.Volume,
+		Candles: c.Candles, Spikes: make([]spikeMarkerV2, len(c.Spikes))}
+	for i, m := range c.Spikes {
+		v.Spikes[i] = spikeMarkerV2{ID: m.ID, Timestamp: m.Timestamp, Direction: m.Direction, RangeClosePercent: m.RangeClosePercent}
+	}
+	return v
+}
+
+type payoutV2 struct {
+	ID          int       `json:"id"`
+	PolicyID    int       `json:"policy_id"`
+	UserAddress string    `json:"user_address"`
+	Amount      amountV2  `json:"amount"`
+	SpikeID     int       `json:"spike_id"`
+	TxHash      string    `json:"tx_hash"`
+	ExecutedAt  time.Time `json:"executed_at"`
+}
+
+func newPayoutV2(p *db.Payout) payoutV2 {
+	return payoutV2{ID: p.ID, PolicyID: p.PolicyID, UserAddress: p.UserAddress, Amount: newAmountV2(p.Amount),
+		SpikeID: p.SpikeID, TxHash: p.TxHash, ExecutedAt: p.ExecutedAt}
+}
+
+// payoutTransactionV2 is a PayoutTransaction on the v2 streams
+type payoutTransactionV2 struct {
+	PolicyID        int      `json:"policy_id"`
+	OnchainPolicyID int64    `json:"onchain_policy_id"`
+	UserAddress     string   `json:"user_address"`
+	SpikeID         int      `json:"spike_id"`
+	Amount          amountV2 `json:"amount"`
+	TxHash          string   `json:"tx_hash"`
+	BlockNumber     uint64   `json:"block_number"` // 0 until mined
+}
+
+func newPayoutTransactionV2(t *PayoutTransaction) payoutTransactionV2 {
+	return payoutTransactionV2{PolicyID: t.PolicyID, OnchainPolicyID: t.OnchainPolicyID, UserAddress: t.UserAddress,
+		SpikeID: t.SpikeID, Amount: newAmountV2(t.Amount), TxHash: t.TxHash, BlockNumber: t.BlockNumber}
+}
+
+type policyV2 struct {
+	ID              int       `json:"id"`
+	UserAddress     string    `json:"user_address"`
+	OnchainPolicyID int64     `json:"onchain_policy_id"`
+	Premium         amountV2  `json:"premium"`
+	Coverage        amountV2  `json:"coverage"`
+	PurchasedAt     time.Time `json:"purchased_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	Status          string    `json:"status"`
+	TxHash          string    `json:"tx_hash"`
+	BlockNumber     uint64    `json:"block_number"`
+	LogIndex        uint      `json:"log_index"`
+}
+
+func newPolicyV2(p *db.Policy) policyV2 {
+	return policyV2{ID: p.ID, UserAddress: p.UserAddress, OnchainPolicyID: p.OnchainPolicyID,
+		Premium: newAmountV2(p.Premium), Coverage: newAmountV2(p.CoverageAmount), PurchasedAt: p.PurchaseTime,
+		ExpiresAt: p.ExpiryTime, Status: p.Status, TxHash: p.TxHash, BlockNumber: p.BlockNumber, LogIndex: p.LogIndex}
+}
+
+// balanceV2 is a wallet's token balance; balance and last_updated are null when none is cached
+type balanceV2 struct {
+	Address     string     `json:"address"`
+	Token       string     `json:"token"`
+	Found       bool       `json:"found"`
+	Balance     *amountV2  `json:"balance"`
+	LastUpdated *time.Time `json:"last_updated"`
+}
+
+func newBalanceV2(address, token string, balance *utils.Amount, lastUpdated time.Time) balanceV2 {
+	v := balanceV2{Address: address, Token: token}
+	if balance != nil {
+		amount := newAmountV2(*balance)
+		v.Found, v.Balance, v.LastUpdated = true, &amount, &lastUpdated
+	}
+	return v
+}
+
+type balanceCheckV2 struct {
+	Address      string   `json:"address"`
+	Token        string   `json:"token"`
+	BlockNumber  uint64   `json:"block_number"`
+	Balance      amountV2 `json:"balance"` // indexed from Transfer deltas
+	ChainBalance amountV2 `json:"chain_balance"`
+	Drift        amountV2 `json:"drift"` // chain_balance - balance
+}
+
+func newBalanceCheckV2(address, token string, c *db.BalanceCheck, decimals int) balanceCheckV2 {
+	return balanceCheckV2{
+		Address:      address,
+		Token:        token,
+		BlockNumber:  c.BlockNumber,
+		Balance:      newAmountV2(utils.NewAmount(c.IndexedBalance, decimals)),
+		ChainBalance: newAmountV2(utils.NewAmount(c.ChainBalance, decimals)),
+		Drift:        newAmountV2(utils.NewAmount(c.Drift, decimals)),
+	}
+}
+
+type statsV2 struct {
+	Stats       *db.SystemStats `json:"stats"`
+	LatestPrice *priceV2        `json:"latest_price"` // null before the first candle
+	Status      string          `json:"status"`
+}
+
+type replaySessionV2 struct {
+	ID         string     `json:"id"`
+	Symbol     string     `json:"symbol"`
+	Source     string     `json:"source"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+func newReplaySessionV2(s *db.ReplaySession) replaySessionV2 {
+	return replaySessionV2{ID: s.ID, Symbol: s.Symbol, Source: s.Source, Status: s.Status, Error: s.Error,
+		CreatedAt: s.CreatedAt, FinishedAt: s.FinishedAt}
+}
+
+type progressV2 struct {
+	Status      string     `json:"status"`
+	Speed       float64    `json:"speed"`
+	Position    int        `json:"position"`
+	Total       int        `json:"total"`
+	Percent     float64    `json:"percent"`
+	Timestamp   *time.Time `json:"timestamp"`
+	Spikes      int        `json:"spikes"`
+	Quarantined int        `json:"quarantined"`
+}
+
+func newProgressV2(p *replay.Progress) *progressV2 {
+	return &progressV2{Status: p.Status, Speed: p.Speed, Position: p.Position, Total: p.Total, Percent: p.Percent,
+		Timestamp: p.Timestamp, Spikes: p.Spikes, Quarantined: p.Quarantined}
+}
+
+type simulatedPayoutV2 struct {
+	ID          int       `json:"id"`
+	SessionID   string    `json:"session_id"`
+	SpikeID     int       `json:"spike_id"`
+	PolicyID    int       `json:"policy_id"`
+	UserAddress string    `json:"user_address"`
+	Amount      amountV2  `json:"amount"`
+	SimulatedAt time.Time `json:"simulated_at"`
+}
+
+func newSimulatedPayoutV2(p *db.SimulatedPayout) simulatedPayoutV2 {
+	return simulatedPayoutV2{ID: p.ID, SessionID: p.SessionID, SpikeID: p.SpikeID, PolicyID: p.PolicyID,
+		UserAddress: p.UserAddress, Amount: newAmountV2(p.Amount), SimulatedAt: p.SimulatedAt}
+}
+
+type rejectedRowV2 struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+	Raw    string `json:"raw"`
+}
+
+type importReportV2 struct {
+	Format   string          `json:"format"`
+	Accepted int             `json:"accepted"`
+	Rejected int             `json:"rejected"`
+	Rows     []rejectedRowV2 `json:"rows"` // the first rejected rows
+}
+
+func newImportReportV2(r *datafeed.ImportReport) *importReportV2 {
+	if r == nil {
+		return nil
+	}
+	v := &importReportV2{Format: r.Format, Accepted: r.Accepted, Rejected: r.Rejected, Rows: make([]rejectedRowV2, len(r.Rows))}
+	for i, row := range r.Rows {
+		v.Rows[i] = rejectedRowV2{Source: row.Source, Line: row.Line, Reason: row.Reason, Raw: row.Raw}
+	}
+	return v
+}
+
+type uploadV2 struct {
+	File    string          `json:"file"`
+	Candles int             `json:"candles"`
+	From    time.Time       `json:"from"`
+	To      time.Time       `json:"to"`
+	Report  *importReportV2 `json:"report"`
+}
+
+func newUploadV2(u *replay.Upload) uploadV2 {
+	return uploadV2{File: u.File, Candles: u.Candles, From: u.From, To: u.To, Report: newImportReportV2(u.Report)}
+}
+
+type webhookV2 struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	OwnerKeyID  int       `json:"owner_key_id"` // 0 for admin-managed endpoints
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newWebhookV2(w *db.Webhook) webhookV2 {
+	events := w.Events
+	if events == nil {
+		events = []string{}
+	}
+	return webhookV2{ID: w.ID, URL: w.URL, Events: events, Description: w.Description, Active: w.Active,
+		OwnerKeyID: w.OwnerKeyID, CreatedAt: w.CreatedAt}
+}
+
+type webhookDeliveryV2 struct {
+	ID             int        `json:"id"`
+	WebhookID      int        `json:"webhook_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+func newWebhookDeliveryV2(d *db.WebhookDelivery) webhookDeliveryV2 {
+	return webhookDeliveryV2{ID: d.ID, WebhookID: d.WebhookID, EventID: d.EventID, EventType: d.EventType,
+		Payload: d.Payload, Status: d.Status, Attempts: d.Attempts, NextAttemptAt: d.NextAttemptAt,
+		LastStatusCode: d.LastStatusCode, LastError: d.LastError, CreatedAt: d.CreatedAt, DeliveredAt: d.DeliveredAt}
+}
+
+type apiKeyV2 struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func newAPIKeyV2(k *db.APIKey) apiKeyV2 {
+	return apiKeyV2{ID: k.ID, Name: k.Name, Role: k.Role, Prefix: k.Prefix, CreatedAt: k.CreatedAt,
+		LastUsedAt: k.LastUsedAt, RevokedAt: k.RevokedAt}
+}
+
+type auditEntryV2 struct {
+	ID        int       `json:"id"`
+	APIKeyID  int       `json:"api_key_id"` // 0 for anonymous callers and the configured admin token
+	Actor     string    `json:"actor"`
+	Role      string    `json:"role"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	ClientIP  string    `json:"client_ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newAuditEntryV2(e *db.AuditEntry) auditEntryV2 {
+	return auditEntryV2{ID: e.ID, APIKeyID: e.APIKeyID, Actor: e.Actor, Role: e.Role, Method: e.Method, Path: e.Path,
+		Status: e.Status, ClientIP: e.ClientIP, CreatedAt: e.CreatedAt}
+}
+
+// eventDataV2 converts the data of a bus event to its v2 form
+func eventDataV2(data interface{}) interface{} {
+	switch d := data.(type) {
+	case *db.PriceData:
+		return newPriceV2(d)
+	case *db.Spike:
+		return newSpikeV2(d)
+	case *db.Payout:
+		return newPayoutV2(d)
+	case *PayoutTransaction:
+		return newPayoutTransactionV2(d)
+	}
+	return data
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"spikeshield/db"
+	"spikeshield/utils"
+	"spikeshield/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookView is a webhook as returned by the API: the secret is only shown when it is
+// created or rotated
+type webhookView struct {
+	ID          int
+	URL         string
+	Events      []string
+	Description string
+	Active      bool
+	OwnerKeyID  int
+	CreatedAt   time.Time
+}
+
+func newWebhookView(w *db.Webhook) *webhookView {
+	return &webhookView{ID: w.ID, URL: w.URL, Events: w.Events, Description: w.Description, Active: w.Active,
+		OwnerKeyID: w.OwnerKeyID, CreatedAt: w.CreatedAt}
+}
+
+// requireWebhooks rejects webhook requests while delivery is disabled
+func (s *Server) requireWebhooks(c *gin.Context) {
+	if s.webhooks == nil {
+		abort(c, errUnavailable("webhooks are disabled (webhooks.enabled is false)"))
+		return
+	}
+	c.Next()
+}
+
+// validateWebhookEvents checks an event filter; empty means every event
+func validateWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return []string{"*"}, nil
+	}
+	for _, e := range events {
+		known := e == "*"
+		for _, t := range webhooks.EventTypes {
+			known = known || e == t
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown event %q (expected * or one of %s)", e, strings.Join(webhooks.EventTypes, ", "))
+		}
+	}
+	return events, nil
+}
+
+// handleCreateWebhook registers an endpoint:
+// {"url", "events" (default ["*"]), "description", "secret" (generated if empty)}.
+// The response is the only time the secret is returned.
+func (s *Server) handleCreateWebhook(c *gin.Context) {
+	var req struct {
+		URL         string   `json:"url"`
+		Events      []string `json:"events"`
+		Description string   `json:"description"`
+		Secret      string   `json:"secret"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		abort(c, errInvalid("invalid request"))
+		return
+	}
+	if err := s.webhooks.CheckURL(c.Request.Context(), req.URL); err != nil {
+		abort(c, errInvalid(err.Error()))
+		return
+	}
+	events, err := validateWebhookEvents(req.Events)
+	if err != nil {
+		abort(c, errInvalid(err.Error()))
+		return
+	}
+	if req.Secret == "" {
+		req.Secret = webhooks.NewSecret()
+	} else if len(req.Secret) < 16 || len(req.Secret) > 128 {
+		abort(c, errInvalid("secret must be 16 to 128 characters"))
+		return
+	}
+
+	w := &db.Webhook{URL: req.URL, Secret: req.Secret, Events: events, Description: req.Description, Active: true,
+		OwnerKeyID: principalOf(c).KeyID}
+	if err := s.store.Webhooks.CreateWebhook(w); err != nil {
+		abort(c, errInternal("Failed to create webhook", err))
+		return
+	}
+	utils.LogInfoCtx(c.Request.Context(), "🪝 Webhook %d registered for %s (%s)", w.ID, w.URL, strings.Join(w.Events, ","))
+
+	reply(c, http.StatusCreated, gin.H{"webhook": newWebhookView(w), "secret": w.Secret},
+		gin.H{"webhook": newWebhookV2(w), "secret": w.Secret})
+}
+
+// ownsWebhook reports whether the caller may manage an endpoint: admins manage every
+// endpoint, other keys those they registered
+func ownsWebhook(c *gin.Context, w *db.Webhook) bool {
+	p := principalOf(c)
+	return p.Role == db.RoleAdmin || (p.KeyID != 0 && w.OwnerKeyID == p.KeyID)
+}
+
+// handleWebhooks lists the endpoints the caller manages
+func (s *Server) handleWebhooks(c *gin.Context) {
+	list, err := s.store.Webhooks.ListWebhooks()
+	if err != nil {
+		abort(c, errInternal("Failed to fetch webhooks", err))
+		return
+	}
+	var owned []*db.Webhook
+	views := make([]*webhookView, 0, len(list))
+	for _, w := range list {
+		if ownsWebhook(c, w) {
+			owned = append(owned, w)
+			views = append(views, newWebhookView(w))
+		}
+	}
+	reply(c, http.StatusOK, gin.H{"count": len(views), "webhooks": views}, newPageV2(owned, "", newWebhookV2))
+}
+
+// lookupWebhook loads the :id webhook, answering 400/404/500 itself when it cannot;
+// endpoints of other keys are not found
+func (s *Server) lookupWebhook(c *gin.Context) (*db.Webhook, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abort(c, errInvalid("invalid webhook id"))
+		return nil, false
+	}
+	w, err := s.store.Webhooks.GetWebhook(id)
+	if err == sql.ErrNoRows || (err == nil && !ownsWebhook(c, w)) {
+		abort(c, errNotFound("webhook not found"))
+		return nil, false
+	}
+	if err != nil {
+		abort(c, errInternal("Failed to fetch webhook", err))
+		return nil, false
+	}
+	return w, true
+}
+
+// handleWebhook returns one endpoint
+func (s *Server) handleWebhook(c *gin.Context) {
+	w, ok := s.lookupWebhook(c)
+	if !ok {
+		return
+	}
+	reply(c, http.StatusOK, gin.H{"webhook": newWebhookView(w)}, gin.H{"webhook": newWebhookV2(w)})
+}
+
+// handleUpdateWebhook changes an endpoint; every field is optional:
+// {"url", "events", "description", "active", "rotate_secret"}. A rotated secret is returned.
+func (s *Server) handleUpdateWebhook(c *gin.Context) {
+	w, ok := s.lookupWebhook(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		URL          *string  `json:"url"`
+		Events       []string `json:"events"`
+		Description  *string  `json:"description"`
+		Active       *bool    `json:"active"`
+		RotateSecret bool     `json:"rotate_secret"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		abort(c, errInvalid("invalid request"))
+		return
+	}
+	if req.URL != nil {
+		if err := s.webhooks.CheckURL(c.Request.Context(), *req.URL); err != nil {
+			abort(c, errInvalid(err.Error()))
+			return
+		}
+		w.URL = *req.URL
+	}
+	if req.Events != nil {
+		events, err := validateWebhookEvents(req.Events)
+		if err != nil {
+			abort(c, errInvalid(err.Error()))
+			return
+		}
+		w.Events = events
+	}
+	if req.Description != nil {
+		w.Description = *req.Description
+	}
+	if req.Active != nil {
+		w.Active = *req.Active
+	}
+	if req.RotateSecret {
+		w.Secret = webhooks.NewSecret()
+	}
+
+	if err := s.store.Webhooks.UpdateWebhook(w); err != nil {
+		abort(c, errInternal("Failed to update webhook", err))
+		return
+	}
+	resp := gin.H{"webhook": newWebhookView(w)}
+	if apiVersionOf(c) == apiV2 {
+		resp["webhook"] = newWebhookV2(w)
+	}
+	if req.RotateSecret {
+		resp["secret"] = w.Secret
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleDeleteWebhook removes an endpoint and its delivery log
+func (s *Server) handleDeleteWebhook(c *gin.Context) {
+	w, ok := s.lookupWebhook(c)
+	if !ok {
+		return
+	}
+	if err := s.store.Webhooks.DeleteWebhook(w.ID); err != nil && err != sql.ErrNoRows {
+		abort(c, errInternal("Failed to delete webhook", err))
+		return
+	}
+	utils.LogInfoCtx(c.Request.Context(), "🪝 Webhook %d (%s) deleted", w.ID, w.URL)
+	c.JSON(http.StatusOK, gin.H{"deleted": w.ID})
+}
+
+// handleWebhookDeliveries returns an endpoint's delivery log, newest first:
+// ?status=pending|succeeded|dead&limit=
+func (s *Server) handleWebhookDeliveries(c *gin.Context) {
+	w, ok := s.lookupWebhook(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", db.DeliveryPending, db.DeliverySucceeded, db.DeliveryDead:
+	default:
+		abort(c, errInvalid("status must be pending, succeeded or dead"))
+		return
+	}
+	limit, ok := pageLimit(c, 50)
+	if !ok {
+		return
+	}
+
+	deliveries, err := s.store.Webhooks.GetWebhookDeliveries(w.ID, status, limit)
+	if err != nil {
+		abort(c, errInternal("Failed to fetch webhook deliveries", err))
+		return
+	}
+	reply(c, http.StatusOK, gin.H{"webhook": w.ID, "count": len(deliveries), "deliveries": deliveries},
+		newPageV2(deliveries, "", newWebhookDeliveryV2))
+}
+
+// handleRedeliverWebhook queues a delivery again from its first attempt, e.g. one that
+// was dead-lettered
+func (s *Server) handleRedeliverWebhook(c *gin.Context) {
+	w, ok := s.lookupWebhook(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("delivery"))
+	if err != nil {
+		abort(c, errInvalid("invalid delivery id"))
+		return
+	}
+	delivery, err := s.store.Webhooks.GetWebhookDelivery(id)
+	if err == sql.ErrNoRows || (err == nil && delivery.WebhookID != w.ID) {
+		abort(c, errNotFound("delivery not found"))
+		return
+	}
+	if err != nil {
+		abort(c, errInternal("Failed to fetch delivery", err))
+		return
+	}
+	if delivery.Status == db.DeliveryPending {
+		abort(c, errConflict("delivery is still pending"))
+		return
+	}
+	if err := s.webhooks.Redeliver(delivery); err != nil {
+		abort(c, errInternal("Failed to queue delivery", err))
+		return
+	}
+	reply(c, http.StatusAccepted, gin.H{"delivery": delivery}, gin.H{"delivery": newWebhookDeliveryV2(delivery)})
+}
+
+// handlePingWebhook queues a "ping" event to an endpoint, ignoring its filter, to test it
+func (s *Server) handlePingWebhook(c *gin.Context) {
+	w, ok := s.lookupWebhook(c)
+	if !ok {
+		return
+	}
+	eventID := fmt.Sprintf("%s:%d:%d", webhooks.EventPing, w.ID, time.Now().UnixNano())
+	delivery, err := s.webhooks.Send(w, webhooks.EventPing, eventID, gin.H{"webhook": w.ID})
+	if err != nil {
+		abort(c, errInternal("Failed to queue ping", err))
+		return
+	}
+	reply(c, http.StatusAccepted, gin.H{"delivery": delivery}, gin.H{"delivery": newWebhookDeliveryV2(delivery)})
+}
@@ -0,0 +1,1535 @@
+// Code generated by `spikeshield openapi client`; DO NOT EDIT.
+
+// Package client is a Go client for the SpikeShield API v2, generated from openapi/v2.json.
+// Streaming operations are not generated: streamEvents, streamWebSocket.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the SpikeShield API
+type Client struct {
+	BaseURL    string       // e.g. http://localhost:8080
+	Token      string       // API key or wallet session token, sent as a bearer token
+	HTTPClient *http.Client // http.DefaultClient if nil
+}
+
+// New returns a client for the API at baseURL; token may be empty for public endpoints
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: baseURL, Token: token}
+}
+
+// APIError is a response with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Body       *Error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("spikeshield: %d %s: %s (request %s)", e.StatusCode, e.Body.Code, e.Body.Error, e.Body.RequestID)
+}
+
+// do sends a request and decodes a 2xx JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out interface{}) error {
+	u := strings.TrimRight(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: &Error{}}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr.Body); err != nil || apiErr.Body.Error == "" {
+			apiErr.Body.Error = resp.Status
+		}
+		if apiErr.Body.RequestID == "" {
+			apiErr.Body.RequestID = resp.Header.Get("X-Request-ID")
+		}
+		return apiErr
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jsonBody encodes a request body; a nil body is sent empty
+func jsonBody(v interface{}, isNil bool) (io.Reader, string, error) {
+	if isNil {
+		return nil, "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, "", err
+	}
+	return bytes.NewReader(data), "application/json", nil
+}
+
+// APIKey is the APIKey schema of the API
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// APIKeyCreated is the APIKeyCreated schema of the API
+type APIKeyCreated struct {
+	APIKey *APIKey `json:"api_key"`
+	Key    string  `json:"key"` // Shown only once
+}
+
+// APIKeyPage is the APIKeyPage schema of the API
+//
+// Every APIKey resource, in one page: next_cursor is always null
+type APIKeyPage struct {
+	Data       []*APIKey `json:"data"`
+	NextCursor *string   `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// APIKeyRevoked is the APIKeyRevoked schema of the API
+type APIKeyRevoked struct {
+	Revoked int `json:"revoked"`
+}
+
+// Amount is the Amount schema of the API
+//
+// An exact token amount
+type Amount struct {
+	Value    string `json:"value"` // Exact decimal string, e.g. 1.500000
+	Raw      string `json:"raw"`   // Integer number of the token's base units
+	Decimals int    `json:"decimals"`
+}
+
+// AuditEntry is the AuditEntry schema of the API
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	APIKeyID  int       `json:"api_key_id"`
+	Actor     string    `json:"actor"`
+	Role      string    `json:"role"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	ClientIP  string    `json:"client_ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditEntryPage is the AuditEntryPage schema of the API
+//
+// The latest audit entries, up to limit, in one page: next_cursor is always null
+type AuditEntryPage struct {
+	Data       []*AuditEntry `json:"data"`
+	NextCursor *string       `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// Balance is the Balance schema of the API
+//
+// A wallet's token balance; balance and last_updated are null if none is cached
+type Balance struct {
+	Address     string     `json:"address"`
+	Token       string     `json:"token"`
+	Found       bool       `json:"found"`
+	Balance     *Amount    `json:"balance"`
+	LastUpdated *time.Time `json:"last_updated"`
+}
+
+// BalanceCheck is the BalanceCheck schema of the API
+//
+// An indexed balance compared with balanceOf at block_number
+type BalanceCheck struct {
+	Address      string  `json:"address"`
+	Token        string  `json:"token"`
+	BlockNumber  int64   `json:"block_number"`  // Last block the token listener synced; balanceOf is read at it
+	Balance      *Amount `json:"balance"`       // Indexed balance, 0 if none is indexed
+	ChainBalance *Amount `json:"chain_balance"` // balanceOf at block_number
+	Drift        *Amount `json:"drift"`         // chain_balance - balance
+}
+
+// Candle is the Candle schema of the API
+type Candle struct {
+	Time    time.Time      `json:"time"` // Start of the interval
+	Open    float64        `json:"open"`
+	High    float64        `json:"high"`
+	Low     float64        `json:"low"`
+	Close   float64        `json:"close"`
+	Volume  float64        `json:"volume"`
+	Candles int            `json:"candles"` // Source candles aggregated
+	Spikes  []*SpikeMarker `json:"spikes"`
+}
+
+// CandleList is the CandleList schema of the API
+//
+// A window of candles; the next one starts at to
+type CandleList struct {
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Count    int       `json:"count"`
+	Candles  []*Candle `json:"candles"`
+}
+
+// CreateAPIKeyRequest is the CreateAPIKeyRequest schema of the API
+type CreateAPIKeyRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// CreateWebhookRequest is the CreateWebhookRequest schema of the API
+type CreateWebhookRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description,omitempty"`
+	Events      []string `json:"events,omitempty"` // Event types or *; default every event
+	Secret      string   `json:"secret,omitempty"` // 16 to 128 characters; generated if empty
+}
+
+// Error is the Error schema of the API
+//
+// Every error response. 409s on replay controls add the session status, 429s the seconds to wait and failed uploads the import report.
+type Error struct {
+	Error      string        `json:"error"`      // Human-readable message
+	Code       string        `json:"code"`       // Machine-readable error code; each maps to one HTTP status
+	RequestID  string        `json:"request_id"` // ID of the request, also in the X-Request-ID header and the server logs
+	Report     *ImportReport `json:"report,omitempty"`
+	RetryAfter int           `json:"retry_after,omitempty"`
+	Status     string        `json:"status,omitempty"`
+}
+
+// Health is the Health schema of the API
+type Health struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// ImportReport is the ImportReport schema of the API
+type ImportReport struct {
+	Format   string         `json:"format"`
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Rows     []*RejectedRow `json:"rows"`
+}
+
+// Nonce is the Nonce schema of the API
+type Nonce struct {
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// OpenAPIDocument is the OpenAPIDocument schema of the API
+//
+// This document
+type OpenAPIDocument map[string]interface{}
+
+// Payout is the Payout schema of the API
+type Payout struct {
+	ID          int       `json:"id"`
+	PolicyID    int       `json:"policy_id"`
+	UserAddress string    `json:"user_address"`
+	Amount      *Amount   `json:"amount"`
+	SpikeID     int       `json:"spike_id"`
+	TxHash      string    `json:"tx_hash"`
+	ExecutedAt  time.Time `json:"executed_at"`
+}
+
+// PayoutPage is the PayoutPage schema of the API
+//
+// A page of Payout resources
+type PayoutPage struct {
+	Data       []*Payout `json:"data"`
+	NextCursor *string   `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// Policy is the Policy schema of the API
+type Policy struct {
+	ID              int       `json:"id"`
+	UserAddress     string    `json:"user_address"`
+	OnchainPolicyID int64     `json:"onchain_policy_id"`
+	Premium         *Amount   `json:"premium"`
+	Coverage        *Amount   `json:"coverage"`
+	PurchasedAt     time.Time `json:"purchased_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	Status          string    `json:"status"`
+	TxHash          string    `json:"tx_hash"`
+	BlockNumber     int64     `json:"block_number"`
+	LogIndex        int       `json:"log_index"`
+}
+
+// PolicyPage is the PolicyPage schema of the API
+//
+// Every Policy resource, in one page: next_cursor is always null
+type PolicyPage struct {
+	Data       []*Policy `json:"data"`
+	NextCursor *string   `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// Price is the Price schema of the API
+type Price struct {
+	ID        int       `json:"id"`
+	Symbol    string    `json:"symbol"`
+	Timestamp time.Time `json:"timestamp"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    float64   `json:"volume"`
+}
+
+// PricePage is the PricePage schema of the API
+//
+// A page of Price resources
+type PricePage struct {
+	Data       []*Price `json:"data"`
+	NextCursor *string  `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// Progress is the Progress schema of the API
+type Progress struct {
+	Status      string     `json:"status"`
+	Speed       float64    `json:"speed"`
+	Position    int        `json:"position"` // Index of the next candle to replay
+	Total       int        `json:"total"`
+	Percent     float64    `json:"percent"`
+	Timestamp   *time.Time `json:"timestamp"`
+	Spikes      int        `json:"spikes"`
+	Quarantined int        `json:"quarantined"`
+}
+
+// QuarantinedPrice is the QuarantinedPrice schema of the API
+type QuarantinedPrice struct {
+	ID            int       `json:"id"`
+	SessionID     string    `json:"session_id"` // Replay session; empty for production
+	Symbol        string    `json:"symbol"`
+	Timestamp     time.Time `json:"timestamp"`
+	Open          float64   `json:"open"`
+	High          float64   `json:"high"`
+	Low           float64   `json:"low"`
+	Close         float64   `json:"close"`
+	Volume        float64   `json:"volume"`
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// QuarantinedPricePage is the QuarantinedPricePage schema of the API
+//
+// A page of QuarantinedPrice resources
+type QuarantinedPricePage struct {
+	Data       []*QuarantinedPrice `json:"data"`
+	NextCursor *string             `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// RejectedRow is the RejectedRow schema of the API
+type RejectedRow struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+	Raw    string `json:"raw"`
+}
+
+// ReplayDeleted is the ReplayDeleted schema of the API
+type ReplayDeleted struct {
+	Status  string `json:"status"`
+	Session string `json:"session"`
+}
+
+// ReplayProgress is the ReplayProgress schema of the API
+//
+// Playback position; progress is only set while the session is active
+type ReplayProgress struct {
+	Session  string    `json:"session"`
+	Status   string    `json:"status"`
+	Active   bool      `json:"active"`
+	Progress *Progress `json:"progress,omitempty"`
+}
+
+// ReplaySession is the ReplaySession schema of the API
+type ReplaySession struct {
+	ID         string     `json:"id"`
+	Symbol     string     `json:"symbol"`
+	Source     string     `json:"source"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// ReplaySessionPage is the ReplaySessionPage schema of the API
+//
+// Every ReplaySession resource, in one page: next_cursor is always null
+type ReplaySessionPage struct {
+	Data       []*ReplaySession `json:"data"`
+	NextCursor *string          `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// ReplaySessionResponse is the ReplaySessionResponse schema of the API
+type ReplaySessionResponse struct {
+	Session *ReplaySession `json:"session"`
+}
+
+// ReplayStarted is the ReplayStarted schema of the API
+type ReplayStarted struct {
+	Status  string         `json:"status"`
+	Session *ReplaySession `json:"session"`
+}
+
+// SeekRequest is the SeekRequest schema of the API
+type SeekRequest struct {
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SessionInfo is the SessionInfo schema of the API
+type SessionInfo struct {
+	Address   string    `json:"address"`
+	ChainID   int64     `json:"chain_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SignInRequest is the SignInRequest schema of the API
+type SignInRequest struct {
+	Message   string `json:"message"`   // EIP-4361 message
+	Signature string `json:"signature"` // 0x-prefixed personal_sign signature
+}
+
+// SimulatedPayout is the SimulatedPayout schema of the API
+type SimulatedPayout struct {
+	ID          int       `json:"id"`
+	SessionID   string    `json:"session_id"`
+	SpikeID     int       `json:"spike_id"`
+	PolicyID    int       `json:"policy_id"`
+	UserAddress string    `json:"user_address"`
+	Amount      *Amount   `json:"amount"`
+	SimulatedAt time.Time `json:"simulated_at"`
+}
+
+// SimulatedPayoutPage is the SimulatedPayoutPage schema of the API
+//
+// A page of SimulatedPayout resources
+type SimulatedPayoutPage struct {
+	Data       []*SimulatedPayout `json:"data"`
+	NextCursor *string            `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// Spike is the Spike schema of the API
+type Spike struct {
+	ID                int        `json:"id"`
+	Symbol            string     `json:"symbol"`
+	Timestamp         time.Time  `json:"timestamp"`
+	PriceID           int        `json:"price_id"`
+	Open              float64    `json:"open"`
+	High              float64    `json:"high"`
+	Low               float64    `json:"low"`
+	Close             float64    `json:"close"`
+	BodyRatio         float64    `json:"body_ratio"`
+	RangeClosePercent float64    `json:"range_close_percent"`
+	DetectedAt        time.Time  `json:"detected_at"`
+	VoidedAt          *time.Time `json:"voided_at"`
+}
+
+// SpikeMarker is the SpikeMarker schema of the API
+type SpikeMarker struct {
+	ID                int       `json:"id"`
+	Timestamp         time.Time `json:"timestamp"`
+	Direction         string    `json:"direction"`
+	RangeClosePercent float64   `json:"range_close_percent"`
+}
+
+// SpikePage is the SpikePage schema of the API
+//
+// A page of Spike resources
+type SpikePage struct {
+	Data       []*Spike `json:"data"`
+	NextCursor *string  `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// StartReplayRequest is the StartReplayRequest schema of the API
+type StartReplayRequest struct {
+	File     string  `json:"file,omitempty"` // An uploaded file; default the sample CSV
+	Paused   bool    `json:"paused,omitempty"`
+	Realtime bool    `json:"realtime,omitempty"` // Speed 1
+	Speed    float64 `json:"speed,omitempty"`    // 0 replays as fast as possible
+	Symbol   string  `json:"symbol,omitempty"`   // Default BTCUSDT
+}
+
+// Stats is the Stats schema of the API
+type Stats struct {
+	Stats       *SystemStats `json:"stats"`
+	LatestPrice *Price       `json:"latest_price"`
+	Status      string       `json:"status"`
+}
+
+// Status is the Status schema of the API
+type Status struct {
+	Status string `json:"status"`
+}
+
+// SystemStats is the SystemStats schema of the API
+type SystemStats struct {
+	TotalSpikes    int `json:"total_spikes"`
+	TotalPayouts   int `json:"total_payouts"`
+	TotalPolicies  int `json:"total_policies"`
+	ActivePolicies int `json:"active_policies"`
+	TotalPrices    int `json:"total_prices"`
+}
+
+// UpdateWebhookRequest is the UpdateWebhookRequest schema of the API
+//
+// Fields left out or null are unchanged
+type UpdateWebhookRequest struct {
+	Active       *bool    `json:"active,omitempty"`
+	Description  *string  `json:"description,omitempty"`
+	Events       []string `json:"events,omitempty"`
+	RotateSecret bool     `json:"rotate_secret,omitempty"`
+	URL          *string  `json:"url,omitempty"`
+}
+
+// Upload is the Upload schema of the API
+type Upload struct {
+	File    string        `json:"file"` // Name to pass as file when starting a session
+	Candles int           `json:"candles"`
+	From    time.Time     `json:"from"`
+	To      time.Time     `json:"to"`
+	Report  *ImportReport `json:"report"`
+}
+
+// UploadRequest is the UploadRequest schema of the API
+type UploadRequest struct {
+	Columns string `json:"columns,omitempty"` // Generic CSV mapping, e.g. timestamp=time,open=o,high=h,low=l,close=c
+	Format  string `json:"format,omitempty"`
+}
+
+// WalletLinkRequest is the WalletLinkRequest schema of the API
+type WalletLinkRequest struct {
+	Address string `json:"address"`
+}
+
+// WalletSession is the WalletSession schema of the API
+type WalletSession struct {
+	Token     string    `json:"token"` // Bearer token for wallet-scoped endpoints
+	Address   string    `json:"address"`
+	ChainID   int64     `json:"chain_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Webhook is the Webhook schema of the API
+type Webhook struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	OwnerKeyID  int       `json:"owner_key_id"` // API key that registered it; 0 for admin-managed endpoints
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WebhookCreated is the WebhookCreated schema of the API
+type WebhookCreated struct {
+	Webhook *Webhook `json:"webhook"`
+	Secret  string   `json:"secret"`
+}
+
+// WebhookDeleted is the WebhookDeleted schema of the API
+type WebhookDeleted struct {
+	Deleted int `json:"deleted"`
+}
+
+// WebhookDelivery is the WebhookDelivery schema of the API
+type WebhookDelivery struct {
+	ID             int        `json:"id"`
+	WebhookID      int        `json:"webhook_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload"` // The signed JSON body
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+// WebhookDeliveryPage is the WebhookDeliveryPage schema of the API
+//
+// The latest deliveries of an endpoint, up to limit, in one page: next_cursor is always null
+type WebhookDeliveryPage struct {
+	Data       []*WebhookDelivery `json:"data"`
+	NextCursor *string            `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// WebhookDeliveryResponse is the WebhookDeliveryResponse schema of the API
+type WebhookDeliveryResponse struct {
+	Delivery *WebhookDelivery `json:"delivery"`
+}
+
+// WebhookPage is the WebhookPage schema of the API
+//
+// Every Webhook resource, in one page: next_cursor is always null
+type WebhookPage struct {
+	Data       []*Webhook `json:"data"`
+	NextCursor *string    `json:"next_cursor"` // Cursor of the next page; null on the last one
+}
+
+// WebhookResponse is the WebhookResponse schema of the API
+type WebhookResponse struct {
+	Webhook *Webhook `json:"webhook"`
+	Secret  string   `json:"secret,omitempty"` // Only set when the secret was rotated
+}
+
+// GetAuditLogParams are the query parameters of GET /api/v2/admin/audit
+type GetAuditLogParams struct {
+	Key   int // Only calls with this API key
+	Limit int // Default 100, at most 1000
+}
+
+func (p *GetAuditLogParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Key != 0 {
+		q.Set("key", strconv.Itoa(p.Key))
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return q
+}
+
+// GetAuditLog calls GET /api/v2/admin/audit: Latest privileged calls.
+// Requires an API key with role admin or above.
+func (c *Client) GetAuditLog(ctx context.Context, params *GetAuditLogParams) (*AuditEntryPage, error) {
+	out := &AuditEntryPage{}
+	if err := c.do(ctx, "GET", "/api/v2/admin/audit", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListAPIKeys calls GET /api/v2/admin/keys: Every API key, revoked ones included.
+// Requires an API key with role admin or above.
+func (c *Client) ListAPIKeys(ctx context.Context) (*APIKeyPage, error) {
+	out := &APIKeyPage{}
+	if err := c.do(ctx, "GET", "/api/v2/admin/keys", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateAPIKey calls POST /api/v2/admin/keys: Issue an API key.
+// Requires an API key with role admin or above.
+// It answers 201 on success.
+func (c *Client) CreateAPIKey(ctx context.Context, body *CreateAPIKeyRequest) (*APIKeyCreated, error) {
+	reader, contentType, err := jsonBody(body, body == nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &APIKeyCreated{}
+	if err := c.do(ctx, "POST", "/api/v2/admin/keys", nil, contentType, reader, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RevokeAPIKey calls DELETE /api/v2/admin/keys/{id}: Revoke an API key.
+// Requires an API key with role admin or above.
+func (c *Client) RevokeAPIKey(ctx context.Context, id int) (*APIKeyRevoked, error) {
+	out := &APIKeyRevoked{}
+	if err := c.do(ctx, "DELETE", "/api/v2/admin/keys/"+strconv.Itoa(id), nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResetData calls POST /api/v2/admin/reset: Delete every production spike and price.
+// Requires an API key with role admin or above.
+func (c *Client) ResetData(ctx context.Context) (*Status, error) {
+	out := &Status{}
+	if err := c.do(ctx, "POST", "/api/v2/admin/reset", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SignOut calls POST /api/v2/auth/logout: End the wallet session of the request.
+func (c *Client) SignOut(ctx context.Context) (*Status, error) {
+	out := &Status{}
+	if err := c.do(ctx, "POST", "/api/v2/auth/logout", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetNonce calls GET /api/v2/auth/nonce: Issue a Sign-In With Ethereum nonce.
+func (c *Client) GetNonce(ctx context.Context) (*Nonce, error) {
+	out := &Nonce{}
+	if err := c.do(ctx, "GET", "/api/v2/auth/nonce", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetSession calls GET /api/v2/auth/session: The wallet session of the request.
+func (c *Client) GetSession(ctx context.Context) (*SessionInfo, error) {
+	out := &SessionInfo{}
+	if err := c.do(ctx, "GET", "/api/v2/auth/session", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SignIn calls POST /api/v2/auth/verify: Verify a signed sign-in message and open a wallet session.
+func (c *Client) SignIn(ctx context.Context, body *SignInRequest) (*WalletSession, error) {
+	reader, contentType, err := jsonBody(body, body == nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &WalletSession{}
+	if err := c.do(ctx, "POST", "/api/v2/auth/verify", nil, contentType, reader, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetBalanceParams are the query parameters of GET /api/v2/balance
+type GetBalanceParams struct {
+	Address string // Required. Wallet address
+	Token   string // ERC-20 token address; default the configured USDT
+}
+
+func (p *GetBalanceParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Address != "" {
+		q.Set("address", p.Address)
+	}
+	if p.Token != "" {
+		q.Set("token", p.Token)
+	}
+	return q
+}
+
+// GetBalance calls GET /api/v2/balance: Cached token balance of a wallet (wallet session or operator key).
+func (c *Client) GetBalance(ctx context.Context, params *GetBalanceParams) (*Balance, error) {
+	out := &Balance{}
+	if err := c.do(ctx, "GET", "/api/v2/balance", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RefreshBalanceParams are the query parameters of POST /api/v2/balance/refresh
+type RefreshBalanceParams struct {
+	Address string // Required. Wallet address
+	Token   string // The configured USDT address, the default; other tokens are refused with 400
+}
+
+func (p *RefreshBalanceParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Address != "" {
+		q.Set("address", p.Address)
+	}
+	if p.Token != "" {
+		q.Set("token", p.Token)
+	}
+	return q
+}
+
+// RefreshBalance calls POST /api/v2/balance/refresh: Check a wallet's indexed token balance against balanceOf at the last indexed block and record the result (wallet session or operator key).
+// Balances are only written from journaled Transfer events; this reads balanceOf at the block the token listener last synced, so transfers not yet indexed don't show up as drift, and records the comparison in the drift report. 503 until the token listener has synced a block.
+func (c *Client) RefreshBalance(ctx context.Context, params *RefreshBalanceParams) (*BalanceCheck, error) {
+	out := &BalanceCheck{}
+	if err := c.do(ctx, "POST", "/api/v2/balance/refresh", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetCandlesParams are the query parameters of GET /api/v2/candles
+type GetCandlesParams struct {
+	Symbol   string    // Default BTCUSDT
+	Interval string    // Default 1m. Ranges compacted by retention come from the rollups when the interval is a multiple of the rollup interval; finer intervals only include the candles kept there for their spikes
+	From     time.Time // RFC 3339 or Unix seconds
+	To       time.Time // RFC 3339 or Unix seconds
+}
+
+func (p *GetCandlesParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Symbol != "" {
+		q.Set("symbol", p.Symbol)
+	}
+	if p.Interval != "" {
+		q.Set("interval", p.Interval)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	return q
+}
+
+// GetCandles calls GET /api/v2/candles: Candles aggregated to an interval, with spike markers.
+func (c *Client) GetCandles(ctx context.Context, params *GetCandlesParams) (*CandleList, error) {
+	out := &CandleList{}
+	if err := c.do(ctx, "GET", "/api/v2/candles", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetHealth calls GET /api/v2/health: Server health.
+func (c *Client) GetHealth(ctx context.Context) (*Health, error) {
+	out := &Health{}
+	if err := c.do(ctx, "GET", "/api/v2/health", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InsertFakeKline calls POST /api/v2/insert_fake_kline: Start a replay session (kept for existing scripts).
+// Requires an API key with role operator or above.
+// It answers 202 on success.
+func (c *Client) InsertFakeKline(ctx context.Context, body *StartReplayRequest) (*ReplayStarted, error) {
+	reader, contentType, err := jsonBody(body, body == nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &ReplayStarted{}
+	if err := c.do(ctx, "POST", "/api/v2/insert_fake_kline", nil, contentType, reader, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetOpenAPI calls GET /api/v2/openapi.json: This OpenAPI document.
+func (c *Client) GetOpenAPI(ctx context.Context) (OpenAPIDocument, error) {
+	var out OpenAPIDocument
+	if err := c.do(ctx, "GET", "/api/v2/openapi.json", nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListPayoutsParams are the query parameters of GET /api/v2/payouts
+type ListPayoutsParams struct {
+	Limit     int       // Page size (at most 1000)
+	Cursor    string    // next_cursor of the previous page
+	From      time.Time // RFC 3339 or Unix seconds, inclusive
+	To        time.Time // RFC 3339 or Unix seconds, exclusive
+	Symbol    string
+	Direction string // Longer wick of the spike (spikes and payouts)
+	Sort      string // Column to sort by; a leading - sorts descending
+	User      string // Wallet address
+}
+
+func (p *ListPayoutsParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Symbol != "" {
+		q.Set("symbol", p.Symbol)
+	}
+	if p.Direction != "" {
+		q.Set("direction", p.Direction)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	if p.User != "" {
+		q.Set("user", p.User)
+	}
+	return q
+}
+
+// ListPayouts calls GET /api/v2/payouts: Payout history, newest first.
+func (c *Client) ListPayouts(ctx context.Context, params *ListPayoutsParams) (*PayoutPage, error) {
+	out := &PayoutPage{}
+	if err := c.do(ctx, "GET", "/api/v2/payouts", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListPoliciesParams are the query parameters of GET /api/v2/policies
+type ListPoliciesParams struct {
+	Address string // Required. Wallet address
+}
+
+func (p *ListPoliciesParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Address != "" {
+		q.Set("address", p.Address)
+	}
+	return q
+}
+
+// ListPolicies calls GET /api/v2/policies: Policies of a wallet (wallet session or operator key).
+func (c *Client) ListPolicies(ctx context.Context, params *ListPoliciesParams) (*PolicyPage, error) {
+	out := &PolicyPage{}
+	if err := c.do(ctx, "GET", "/api/v2/policies", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListPricesParams are the query parameters of GET /api/v2/prices
+type ListPricesParams struct {
+	Limit     int       // Page size (at most 1000)
+	Cursor    string    // next_cursor of the previous page
+	From      time.Time // RFC 3339 or Unix seconds, inclusive
+	To        time.Time // RFC 3339 or Unix seconds, exclusive
+	Symbol    string
+	Direction string // Longer wick of the spike (spikes and payouts)
+	Sort      string // Column to sort by; a leading - sorts descending
+}
+
+func (p *ListPricesParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Symbol != "" {
+		q.Set("symbol", p.Symbol)
+	}
+	if p.Direction != "" {
+		q.Set("direction", p.Direction)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	return q
+}
+
+// ListPrices calls GET /api/v2/prices: Candles of a symbol (default BTCUSDT), newest first.
+func (c *Client) ListPrices(ctx context.Context, params *ListPricesParams) (*PricePage, error) {
+	out := &PricePage{}
+	if err := c.do(ctx, "GET", "/api/v2/prices", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListQuarantineParams are the query parameters of GET /api/v2/quarantine
+type ListQuarantineParams struct {
+	Limit     int       // Page size (at most 1000)
+	Cursor    string    // next_cursor of the previous page
+	From      time.Time // RFC 3339 or Unix seconds, inclusive
+	To        time.Time // RFC 3339 or Unix seconds, exclusive
+	Symbol    string
+	Direction string // Longer wick of the spike (spikes and payouts)
+	Sort      string // Column to sort by; a leading - sorts descending
+}
+
+func (p *ListQuarantineParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Symbol != "" {
+		q.Set("symbol", p.Symbol)
+	}
+	if p.Direction != "" {
+		q.Set("direction", p.Direction)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	return q
+}
+
+// ListQuarantine calls GET /api/v2/quarantine: Candles that failed validation, newest first.
+func (c *Client) ListQuarantine(ctx context.Context, params *ListQuarantineParams) (*QuarantinedPricePage, error) {
+	out := &QuarantinedPricePage{}
+	if err := c.do(ctx, "GET", "/api/v2/quarantine", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListReplaySessions calls GET /api/v2/replay/sessions: Replay sessions, newest first.
+func (c *Client) ListReplaySessions(ctx context.Context) (*ReplaySessionPage, error) {
+	out := &ReplaySessionPage{}
+	if err := c.do(ctx, "GET", "/api/v2/replay/sessions", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StartReplay calls POST /api/v2/replay/sessions: Start an isolated replay session.
+// Requires an API key with role operator or above.
+// It answers 202 on success.
+func (c *Client) StartReplay(ctx context.Context, body *StartReplayRequest) (*ReplayStarted, error) {
+	reader, contentType, err := jsonBody(body, body == nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &ReplayStarted{}
+	if err := c.do(ctx, "POST", "/api/v2/replay/sessions", nil, contentType, reader, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteReplaySession calls DELETE /api/v2/replay/sessions/{id}: Stop and delete a replay session.
+// Requires an API key with role admin or above.
+func (c *Client) DeleteReplaySession(ctx context.Context, id string) (*ReplayDeleted, error) {
+	out := &ReplayDeleted{}
+	if err := c.do(ctx, "DELETE", "/api/v2/replay/sessions/"+url.PathEscape(id), nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetReplaySession calls GET /api/v2/replay/sessions/{id}: One replay session.
+func (c *Client) GetReplaySession(ctx context.Context, id string) (*ReplaySessionResponse, error) {
+	out := &ReplaySessionResponse{}
+	if err := c.do(ctx, "GET", "/api/v2/replay/sessions/"+url.PathEscape(id), nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PauseReplay calls POST /api/v2/replay/sessions/{id}/pause: Pause an active session.
+// Requires an API key with role operator or above.
+func (c *Client) PauseReplay(ctx context.Context, id string) (*ReplayProgress, error) {
+	out := &ReplayProgress{}
+	if err := c.do(ctx, "POST", "/api/v2/replay/sessions/"+url.PathEscape(id)+"/pause", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListReplayPayoutsParams are the query parameters of GET /api/v2/replay/sessions/{id}/payouts
+type ListReplayPayoutsParams struct {
+	Limit     int       // Page size (at most 1000)
+	Cursor    string    // next_cursor of the previous page
+	From      time.Time // RFC 3339 or Unix seconds, inclusive
+	To        time.Time // RFC 3339 or Unix seconds, exclusive
+	Symbol    string
+	Direction string // Longer wick of the spike (spikes and payouts)
+	Sort      string // Column to sort by; a leading - sorts descending
+	User      string // Wallet address
+}
+
+func (p *ListReplayPayoutsParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Symbol != "" {
+		q.Set("symbol", p.Symbol)
+	}
+	if p.Direction != "" {
+		q.Set("direction", p.Direction)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	if p.User != "" {
+		q.Set("user", p.User)
+	}
+	return q
+}
+
+// ListReplayPayouts calls GET /api/v2/replay/sessions/{id}/payouts: Payouts a session's spikes would have triggered.
+func (c *Client) ListReplayPayouts(ctx context.Context, id string, params *ListReplayPayoutsParams) (*SimulatedPayoutPage, error) {
+	out := &SimulatedPayoutPage{}
+	if err := c.do(ctx, "GET", "/api/v2/replay/sessions/"+url.PathEscape(id)+"/payouts", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListReplayPricesParams are the query parameters of GET /api/v2/replay/sessions/{id}/prices
+type ListReplayPricesParams struct {
+	Limit     int       // Page size (at most 1000)
+	Cursor    string    // next_cursor of the previous page
+	From      time.Time // RFC 3339 or Unix seconds, inclusive
+	To        time.Time // RFC 3339 or Unix seconds, exclusive
+	Direction string    // Longer wick of the spike (spikes and payouts)
+	Sort      string    // Column to sort by; a leading - sorts descending
+}
+
+func (p *ListReplayPricesParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Direction != "" {
+		q.Set("direction", p.Direction)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	return q
+}
+
+// ListReplayPrices calls GET /api/v2/replay/sessions/{id}/prices: Candles replayed so far.
+func (c *Client) ListReplayPrices(ctx context.Context, id string, params *ListReplayPricesParams) (*PricePage, error) {
+	out := &PricePage{}
+	if err := c.do(ctx, "GET", "/api/v2/replay/sessions/"+url.PathEscape(id)+"/prices", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetReplayProgress calls GET /api/v2/replay/sessions/{id}/progress: Playback position of a session.
+func (c *Client) GetReplayProgress(ctx context.Context, id string) (*ReplayProgress, error) {
+	out := &ReplayProgress{}
+	if err := c.do(ctx, "GET", "/api/v2/replay/sessions/"+url.PathEscape(id)+"/progress", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListReplayQuarantineParams are the query parameters of GET /api/v2/replay/sessions/{id}/quarantine
+type ListReplayQuarantineParams struct {
+	Limit     int       // Page size (at most 1000)
+	Cursor    string    // next_cursor of the previous page
+	From      time.Time // RFC 3339 or Unix seconds, inclusive
+	To        time.Time // RFC 3339 or Unix seconds, exclusive
+	Symbol    string
+	Direction string // Longer wick of the spike (spikes and payouts)
+	Sort      string // Column to sort by; a leading - sorts descending
+}
+
+func (p *ListReplayQuarantineParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Symbol != "" {
+		q.Set("symbol", p.Symbol)
+	}
+	if p.Direction != "" {
+		q.Set("direction", p.Direction)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	return q
+}
+
+// ListReplayQuarantine calls GET /api/v2/replay/sessions/{id}/quarantine: Candles quarantined in a session.
+func (c *Client) ListReplayQuarantine(ctx context.Context, id string, params *ListReplayQuarantineParams) (*QuarantinedPricePage, error) {
+	out := &QuarantinedPricePage{}
+	if err := c.do(ctx, "GET", "/api/v2/replay/sessions/"+url.PathEscape(id)+"/quarantine", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResumeReplay calls POST /api/v2/replay/sessions/{id}/resume: Resume a paused session.
+// Requires an API key with role operator or above.
+func (c *Client) ResumeReplay(ctx context.Context, id string) (*ReplayProgress, error) {
+	out := &ReplayProgress{}
+	if err := c.do(ctx, "POST", "/api/v2/replay/sessions/"+url.PathEscape(id)+"/resume", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SeekReplay calls POST /api/v2/replay/sessions/{id}/seek: Move an active session to a candle time.
+// Requires an API key with role operator or above.
+func (c *Client) SeekReplay(ctx context.Context, id string, body *SeekRequest) (*ReplayProgress, error) {
+	reader, contentType, err := jsonBody(body, body == nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &ReplayProgress{}
+	if err := c.do(ctx, "POST", "/api/v2/replay/sessions/"+url.PathEscape(id)+"/seek", nil, contentType, reader, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListReplaySpikesParams are the query parameters of GET /api/v2/replay/sessions/{id}/spikes
+type ListReplaySpikesParams struct {
+	Limit     int       // Page size (at most 1000)
+	Cursor    string    // next_cursor of the previous page
+	From      time.Time // RFC 3339 or Unix seconds, inclusive
+	To        time.Time // RFC 3339 or Unix seconds, exclusive
+	Symbol    string
+	Direction string // Longer wick of the spike (spikes and payouts)
+	Sort      string // Column to sort by; a leading - sorts descending
+}
+
+func (p *ListReplaySpikesParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Symbol != "" {
+		q.Set("symbol", p.Symbol)
+	}
+	if p.Direction != "" {
+		q.Set("direction", p.Direction)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	return q
+}
+
+// ListReplaySpikes calls GET /api/v2/replay/sessions/{id}/spikes: Spikes detected in a session.
+func (c *Client) ListReplaySpikes(ctx context.Context, id string, params *ListReplaySpikesParams) (*SpikePage, error) {
+	out := &SpikePage{}
+	if err := c.do(ctx, "GET", "/api/v2/replay/sessions/"+url.PathEscape(id)+"/spikes", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StopReplay calls POST /api/v2/replay/sessions/{id}/stop: End an active session.
+// Requires an API key with role operator or above.
+func (c *Client) StopReplay(ctx context.Context, id string) (*ReplayProgress, error) {
+	out := &ReplayProgress{}
+	if err := c.do(ctx, "POST", "/api/v2/replay/sessions/"+url.PathEscape(id)+"/stop", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UploadReplayFile calls POST /api/v2/replay/uploads: Import an exchange export for later sessions.
+// Requires an API key with role operator or above.
+// It answers 201 on success.
+func (c *Client) UploadReplayFile(ctx context.Context, filename string, file io.Reader, body *UploadRequest) (*Upload, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, err
+	}
+	if body != nil && body.Columns != "" {
+		if err := w.WriteField("columns", body.Columns); err != nil {
+			return nil, err
+		}
+	}
+	if body != nil && body.Format != "" {
+		if err := w.WriteField("format", body.Format); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	reader, contentType := io.Reader(&buf), w.FormDataContentType()
+	out := &Upload{}
+	if err := c.do(ctx, "POST", "/api/v2/replay/uploads", nil, contentType, reader, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListSpikesParams are the query parameters of GET /api/v2/spikes
+type ListSpikesParams struct {
+	Limit     int       // Page size (at most 1000)
+	Cursor    string    // next_cursor of the previous page
+	From      time.Time // RFC 3339 or Unix seconds, inclusive
+	To        time.Time // RFC 3339 or Unix seconds, exclusive
+	Symbol    string
+	Direction string // Longer wick of the spike (spikes and payouts)
+	Sort      string // Column to sort by; a leading - sorts descending
+}
+
+func (p *ListSpikesParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Symbol != "" {
+		q.Set("symbol", p.Symbol)
+	}
+	if p.Direction != "" {
+		q.Set("direction", p.Direction)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	return q
+}
+
+// ListSpikes calls GET /api/v2/spikes: Detected spikes, newest first.
+func (c *Client) ListSpikes(ctx context.Context, params *ListSpikesParams) (*SpikePage, error) {
+	out := &SpikePage{}
+	if err := c.do(ctx, "GET", "/api/v2/spikes", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetStats calls GET /api/v2/stats: System statistics.
+func (c *Client) GetStats(ctx context.Context) (*Stats, error) {
+	out := &Stats{}
+	if err := c.do(ctx, "GET", "/api/v2/stats", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LinkWallet calls POST /api/v2/wallet/link: Queue a sync of a wallet's policies (wallet session or operator key).
+// It answers 202 on success.
+func (c *Client) LinkWallet(ctx context.Context, body *WalletLinkRequest) (*Status, error) {
+	reader, contentType, err := jsonBody(body, body == nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &Status{}
+	if err := c.do(ctx, "POST", "/api/v2/wallet/link", nil, contentType, reader, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListWebhooks calls GET /api/v2/webhooks: Endpoints the caller manages.
+// Requires an API key with role partner or above.
+func (c *Client) ListWebhooks(ctx context.Context) (*WebhookPage, error) {
+	out := &WebhookPage{}
+	if err := c.do(ctx, "GET", "/api/v2/webhooks", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateWebhook calls POST /api/v2/webhooks: Register a webhook endpoint.
+// Requires an API key with role partner or above.
+// It answers 201 on success.
+func (c *Client) CreateWebhook(ctx context.Context, body *CreateWebhookRequest) (*WebhookCreated, error) {
+	reader, contentType, err := jsonBody(body, body == nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &WebhookCreated{}
+	if err := c.do(ctx, "POST", "/api/v2/webhooks", nil, contentType, reader, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteWebhook calls DELETE /api/v2/webhooks/{id}: Remove an endpoint and its delivery log.
+// Requires an API key with role partner or above.
+func (c *Client) DeleteWebhook(ctx context.Context, id int) (*WebhookDeleted, error) {
+	out := &WebhookDeleted{}
+	if err := c.do(ctx, "DELETE", "/api/v2/webhooks/"+strconv.Itoa(id), nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetWebhook calls GET /api/v2/webhooks/{id}: One endpoint.
+// Requires an API key with role partner or above.
+func (c *Client) GetWebhook(ctx context.Context, id int) (*WebhookResponse, error) {
+	out := &WebhookResponse{}
+	if err := c.do(ctx, "GET", "/api/v2/webhooks/"+strconv.Itoa(id), nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateWebhook calls PATCH /api/v2/webhooks/{id}: Change an endpoint.
+// Requires an API key with role partner or above.
+func (c *Client) UpdateWebhook(ctx context.Context, id int, body *UpdateWebhookRequest) (*WebhookResponse, error) {
+	reader, contentType, err := jsonBody(body, body == nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &WebhookResponse{}
+	if err := c.do(ctx, "PATCH", "/api/v2/webhooks/"+strconv.Itoa(id), nil, contentType, reader, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListWebhookDeliveriesParams are the query parameters of GET /api/v2/webhooks/{id}/deliveries
+type ListWebhookDeliveriesParams struct {
+	Status string
+	Limit  int // Default 50, at most 1000
+}
+
+func (p *ListWebhookDeliveriesParams) values() url.Values {
+	if p == nil {
+		return nil
+	}
+	q := url.Values{}
+	if p.Status != "" {
+		q.Set("status", p.Status)
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return q
+}
+
+// ListWebhookDeliveries calls GET /api/v2/webhooks/{id}/deliveries: Delivery log of an endpoint, newest first.
+// Requires an API key with role partner or above.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id int, params *ListWebhookDeliveriesParams) (*WebhookDeliveryPage, error) {
+	out := &WebhookDeliveryPage{}
+	if err := c.do(ctx, "GET", "/api/v2/webhooks/"+strconv.Itoa(id)+"/deliveries", params.values(), "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RedeliverWebhook calls POST /api/v2/webhooks/{id}/deliveries/{delivery}/redeliver: Queue a finished delivery again.
+// Requires an API key with role partner or above.
+// It answers 202 on success.
+func (c *Client) RedeliverWebhook(ctx context.Context, id int, delivery int) (*WebhookDeliveryResponse, error) {
+	out := &WebhookDeliveryResponse{}
+	if err := c.do(ctx, "POST", "/api/v2/webhooks/"+strconv.Itoa(id)+"/deliveries/"+strconv.Itoa(delivery)+"/redeliver", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PingWebhook calls POST /api/v2/webhooks/{id}/ping: Queue a ping event to an endpoint.
+// Requires an API key with role partner or above.
+// It answers 202 on success.
+func (c *Client) PingWebhook(ctx context.Context, id int) (*WebhookDeliveryResponse, error) {
+	out := &WebhookDeliveryResponse{}
+	if err := c.do(ctx, "POST", "/api/v2/webhooks/"+strconv.Itoa(id)+"/ping", nil, "", nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
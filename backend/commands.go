@@ -0,0 +1,332 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"spikeshield/api"
+	"spikeshield/datafeed"
+	"spikeshield/db"
+	"spikeshield/eventlistener"
+	"spikeshield/openapi"
+	"spikeshield/utils"
+)
+
+// runCommand executes a one-off maintenance subcommand and returns the process exit code.
+// Usage: spikeshield <command> [action] [--config config.yaml]
+func runCommand(name string, args []string) int {
+	// Optional action right after the command, e.g. `migrate up`
+	action := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config file")
+	limit := fs.Int("limit", 50, "Maximum rows to print (drift-report, import)")
+	since := fs.Duration("since", 24*time.Hour, "Summary window (drift-report)")
+	steps := fs.Int("steps", 1, "Number of migrations to revert (migrate down)")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading symbol of the imported candles (import)")
+	format := fs.String("format", datafeed.FormatAuto, "Input format (import): auto, csv, binance, coinbase, okx")
+	columns := fs.String("columns", "", "Generic CSV column mapping (import), e.g. timestamp=time,open=o,high=h,low=l,close=c,volume=v")
+	keyName := fs.String("name", "", "Name of the new key (api-key create)")
+	keyRole := fs.String("role", db.RolePartner, "Role of the new key (api-key create): partner, operator, admin")
+	keyID := fs.Int("id", 0, "Key to revoke (api-key revoke)")
+	out := fs.String("out", "client/client.go", "File to write the generated client to (openapi client)")
+	fs.Parse(args)
+
+	// The API document needs neither config nor database
+	if name == "openapi" {
+		return runOpenAPI(action, *out)
+	}
+
+	config, err := utils.LoadConfig(*configPath)
+	if err != nil {
+		utils.LogError("Failed to load config: %v", err)
+		return 1
+	}
+	setImportLimits(config)
+
+	database, err := db.Connect(config)
+	if err != nil {
+		utils.LogError("Failed to connect to database: %v", err)
+		return 1
+	}
+	defer database.Close()
+
+	if name == "migrate" {
+		return runMigrate(database, action, *steps)
+	}
+
+	// Everything else needs the schema this binary was built for
+	if err := database.CheckSchema(); err != nil {
+		utils.LogError("Database schema check failed: %v (run `spikeshield migrate up`)", err)
+		return 1
+	}
+
+	switch name {
+	case "rebuild-projections":
+		// Stop running backends first: their listeners write to the same projections
+		utils.LogInfo("🔄 Rebuilding policies, payouts and balances from chain_events...")
+		count, err := eventlistener.RebuildProjections(database.Store(), config.TokenDecimals())
+		if err != nil {
+			utils.LogError("Projection rebuild failed after %d events: %v", count, err)
+			return 1
+		}
+		utils.LogInfo("✅ Replayed %d events", count)
+	case "compact-prices":
+		compactor, err := datafeed.NewCompactor(config.Retention.RawDays, config.Retention.RollupInterval,
+			config.Retention.CompactInterval, database.Store().Retention)
+		if err != nil {
+			utils.LogError("%v", err)
+			return 1
+		}
+		if _, err := compactor.RunOnce(); err != nil {
+			utils.LogError("Price compaction failed: %v", err)
+			return 1
+		}
+	case "partition-prices":
+		// Stop running backends first: the conversion locks prices
+		if action == "" {
+			action = db.PartitionNative
+		}
+		utils.LogInfo("🔄 Converting prices to %s partitioning...", action)
+		if err := database.PartitionPrices(action); err != nil {
+			utils.LogError("Partitioning failed: %v", err)
+			return 1
+		}
+		utils.LogInfo("✅ prices is now partitioned (%s)", action)
+	case "import":
+		// Usage: spikeshield import <file> [--format binance] [--symbol BTCUSDT]
+		if action == "" {
+			utils.LogError("import needs a file: spikeshield import <file> [--format %s] [--symbol %s]", *format, *symbol)
+			return 2
+		}
+		if err := runImport(database, action, *format, *columns, *symbol, *limit); err != nil {
+			utils.LogError("Import failed: %v", err)
+			return 1
+		}
+	case "drift-report":
+		if err := printDriftReport(database, config, *limit, *since); err != nil {
+			utils.LogError("Drift report failed: %v", err)
+			return 1
+		}
+	case "api-key":
+		// Usage: spikeshield api-key create --name NAME --role ROLE | list | revoke --id ID
+		return runAPIKey(database.Store().Auth, action, *keyName, *keyRole, *keyID)
+	default:
+		utils.LogError("Unknown command: %s (available: migrate, rebuild-projections, compact-prices, partition-prices, import, drift-report, api-key, openapi)", name)
+		return 2
+	}
+	return 0
+}
+
+// runOpenAPI handles `openapi check|client`: check compares the handlers with the API
+// document of every version, client regenerates the Go client package from the latest
+func runOpenAPI(action, out string) int {
+	docs := make(map[string]*openapi.Document)
+	for _, version := range openapi.Versions {
+		doc, err := openapi.Load(version)
+		if err != nil {
+			utils.LogError("%v", err)
+			return 1
+		}
+		docs[version] = doc
+	}
+	latest := openapi.Versions[len(openapi.Versions)-1]
+
+	switch action {
+	case "check":
+		checked, errs := api.CheckContract(docs)
+		for _, err := range errs {
+			utils.LogError("%v", err)
+		}
+		if len(errs) > 0 {
+			utils.LogError("❌ API contract check failed: %d problems", len(errs))
+			return 1
+		}
+		routes := 0
+		for _, doc := range docs {
+			routes += len(doc.Routes())
+		}
+		utils.LogInfo("✅ API matches its documents (%s): %d routes, %d responses checked",
+			strings.Join(openapi.Versions, ", "), routes, checked)
+	case "client":
+		dir, err := filepath.Abs(filepath.Dir(out))
+		if err != nil {
+			utils.LogError("%v", err)
+			return 1
+		}
+		// The package is named after its directory
+		src, err := docs[latest].GenerateClient(filepath.Base(dir), latest)
+		if err != nil {
+			utils.LogError("Client generation failed: %v", err)
+			return 1
+		}
+		if err := os.WriteFile(out, src, 0644); err != nil {
+			utils.LogError("Failed to write client: %v", err)
+			return 1
+		}
+		utils.LogInfo("✅ Wrote %s (API %s)", out, latest)
+	default:
+		utils.LogError("openapi needs an action: check, or client [--out client/client.go]")
+		return 2
+	}
+	return 0
+}
+
+// runAPIKey handles `api-key create|list|revoke`; created keys are printed once
+func runAPIKey(auth db.AuthStore, action, name, role string, id int) int {
+	switch action {
+	case "create":
+		if name == "" || db.RoleRank(role) <= db.RoleRank(db.RolePublic) {
+			utils.LogError("api-key create needs --name and --role partner, operator or admin")
+			return 2
+		}
+		key, k := db.NewAPIKey(name, role)
+		if err := auth.CreateAPIKey(k); err != nil {
+			utils.LogError("Failed to create API key: %v", err)
+			return 1
+		}
+		utils.LogInfo("🔑 API key %d (%s, %s) created; it is not shown again:", k.ID, k.Name, k.Role)
+		fmt.Println(key)
+	case "", "list":
+		keys, err := auth.ListAPIKeys()
+		if err != nil {
+			utils.LogError("Failed to list API keys: %v", err)
+			return 1
+		}
+		fmt.Printf("%-5s %-24s %-9s %-13s %-20s %s\n", "ID", "NAME", "ROLE", "PREFIX", "LAST USED", "REVOKED")
+		for _, k := range keys {
+			lastUsed, revoked := "-", "-"
+			if k.LastUsedAt != nil {
+				lastUsed = k.LastUsedAt.Format("2006-01-02 15:04:05")
+			}
+			if k.RevokedAt != nil {
+				revoked = k.RevokedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-5d %-24s %-9s %-13s %-20s %s\n", k.ID, k.Name, k.Role, k.Prefix, lastUsed, revoked)
+		}
+	case "revoke":
+		if err := auth.RevokeAPIKey(id); err != nil {
+			utils.LogError("Failed to revoke API key %d: %v", id, err)
+			return 1
+		}
+		utils.LogInfo("🔑 API key %d revoked", id)
+	default:
+		utils.LogError("Unknown api-key action: %s (expected create, list or revoke)", action)
+		return 2
+	}
+	return 0
+}
+
+// runMigrate handles `migrate up|down|status`
+func runMigrate(database *db.SQLStore, action string, steps int) int {
+	switch action {
+	case "", "up":
+		count, err := database.MigrateUp()
+		if err != nil {
+			utils.LogError("Migration failed after %d applied: %v", count, err)
+			return 1
+		}
+		utils.LogInfo("✅ Applied %d migration(s)", count)
+	case "down":
+		count, err := database.MigrateDown(steps)
+		if err != nil {
+			utils.LogError("Revert failed after %d reverted: %v", count, err)
+			return 1
+		}
+		utils.LogInfo("✅ Reverted %d migration(s)", count)
+	case "status":
+		statuses, err := database.GetMigrationStatus()
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if st.Modified {
+				state += " (modified since applied)"
+			}
+			fmt.Printf("%04d_%-30s %s\n", st.Version, st.Name, state)
+		}
+		if err != nil {
+			utils.LogError("Migration status: %v", err)
+			return 1
+		}
+	default:
+		utils.LogError("Unknown migrate action: %s (available: up, down, status)", action)
+		return 2
+	}
+	return 0
+}
+
+// runImport loads an exchange export into prices and prints its validation report.
+// Running backends see the new candles through price notifications.
+func runImport(database *db.SQLStore, path, format, columns, symbol string, limit int) error {
+	mapping, err := datafeed.ParseColumnMapping(columns)
+	if err != nil {
+		return err
+	}
+	disableMonotonic(database)
+	feed := datafeed.NewReplayFeed(path, symbol, database.Store().Prices)
+	feed.Format = format
+	feed.Columns = mapping
+	if err := feed.LoadAndStore(); err != nil {
+		return err
+	}
+	printImportReport(feed.Report, limit)
+	if feed.Quarantined > 0 {
+		fmt.Printf("\n%d candles failed validation and were quarantined (GET /api/quarantine)\n", feed.Quarantined)
+	}
+	return nil
+}
+
+// printImportReport prints the accepted and rejected row counts and the first rejected rows
+func printImportReport(report *datafeed.ImportReport, limit int) {
+	fmt.Printf("Format %s: %d rows accepted, %d rejected\n", report.Format, report.Accepted, report.Rejected)
+	if report.Rejected == 0 {
+		return
+	}
+
+	fmt.Printf("\n%-40s %6s  %-40s %s\n", "SOURCE", "LINE", "REASON", "ROW")
+	for i, row := range report.Rows {
+		if i == limit {
+			fmt.Printf("... %d more\n", report.Rejected-limit)
+			break
+		}
+		fmt.Printf("%-40s %6d  %-40s %s\n", row.Source, row.Line, row.Reason, row.Raw)
+	}
+}
+
+// printDriftReport prints a summary of recent balance reconciliation and the latest drifted balances
+func printDriftReport(database *db.SQLStore, config *utils.Config, limit int, since time.Duration) error {
+	decimals := config.TokenDecimals()
+
+	summary, err := database.GetDriftSummary(time.Now().Add(-since))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Balance checks in the last %s: %d checked, %d drifted, total |drift| %s\n",
+		since, summary.Checks, summary.Drifted, utils.FormatUnits(summary.TotalDrift, decimals))
+
+	checks, err := database.GetDriftedBalanceChecks(limit)
+	if err != nil {
+		return err
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+
+	fmt.Printf("\n%-20s %-42s %10s %22s %22s %s\n", "CHECKED AT", "USER", "BLOCK", "INDEXED", "CHAIN", "DRIFT")
+	for _, c := range checks {
+		fmt.Printf("%-20s %-42s %10d %22s %22s %s\n",
+			c.CheckedAt.Format("2006-01-02 15:04:05"), c.UserAddress, c.BlockNumber,
+			utils.FormatUnits(c.IndexedBalance, decimals), utils.FormatUnits(c.ChainBalance, decimals),
+			utils.FormatUnits(c.Drift, decimals))
+	}
+	return nil
+}
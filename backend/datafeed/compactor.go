@@ -0,0 +1,66 @@
+package datafeed
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"spikeshield/db"
+	"spikeshield/utils"
+)
+
+// Compactor periodically downsamples candles that are older than the retention window
+type Compactor struct {
+	RawAge         time.Duration // full-resolution candles are kept this long
+	RollupInterval time.Duration // size of a downsampled candle
+	Every          time.Duration // time between runs
+
+	retention db.RetentionStore
+}
+
+// NewCompactor creates a compactor; intervals are in seconds, like the rest of the config
+func NewCompactor(rawDays, rollupInterval, compactInterval int, retention db.RetentionStore) (*Compactor, error) {
+	if rawDays <= 0 || rollupInterval <= 0 || compactInterval <= 0 {
+		return nil, fmt.Errorf("retention raw_days, rollup_interval and compact_interval must be positive")
+	}
+	return &Compactor{
+		RawAge:         time.Duration(rawDays) * 24 * time.Hour,
+		RollupInterval: time.Duration(rollupInterval) * time.Second,
+		Every:          time.Duration(compactInterval) * time.Second,
+		retention:      retention,
+	}, nil
+}
+
+// RunOnce compacts everything older than RawAge
+func (c *Compactor) RunOnce() (*db.CompactionResult, error) {
+	res, err := c.retention.CompactPrices(time.Now().Add(-c.RawAge), c.RollupInterval)
+	if res != nil {
+		utils.LogInfo("🗜️  Compacted prices before %s into %s candles: %d rolled up, %d deleted, %d kept for spikes, %d partition(s) dropped",
+			res.Cutoff.Format(time.RFC3339), c.RollupInterval, res.RolledUp, res.Deleted, res.Kept, res.DroppedPartitions)
+	}
+	return res, err
+}
+
+// Start runs compaction immediately and then every c.Every until ctx is done
+func (c *Compactor) Start(ctx context.Context) error {
+	utils.LogInfo("Starting price compaction: keep %v raw, %v rollups, every %v", c.RawAge, c.RollupInterval, c.Every)
+
+	ticker := time.NewTicker(c.Every)
+	defer ticker.Stop()
+
+	if _, err := c.RunOnce(); err != nil {
+		utils.LogError("Price compaction failed: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			utils.LogInfo("Price compaction stopped")
+			return nil
+		case <-ticker.C:
+			if _, err := c.RunOnce(); err != nil {
+				utils.LogError("Price compaction failed: %v", err)
+			}
+		}
+	}
+}
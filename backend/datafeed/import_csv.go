@@ -0,0 +1,261 @@
+package datafeed
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"spikeshield/db"
+)
+
+// ColumnMapping maps candle fields to CSV columns, by header name (case-insensitive)
+// or by 0-based index. With only indexes the file is read without a header. Volume
+// may be left empty for files without one.
+type ColumnMapping map[string]string
+
+// candleFields are the keys of a ColumnMapping
+var candleFields = []string{"timestamp", "open", "high", "low", "close", "volume"}
+
+// defaultColumns is the layout of the bundled CSVs
+var defaultColumns = ColumnMapping{
+	"timestamp": "timestamp", "open": "open", "high": "high", "low": "low", "close": "close", "volume": "volume",
+}
+
+// ParseColumnMapping parses "timestamp=time,open=o,high=h,low=l,close=c,volume=v"
+func ParseColumnMapping(s string) (ColumnMapping, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	columns := make(ColumnMapping)
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("invalid column mapping %q (expected field=column)", pair)
+		}
+		if !isCandleField(field) {
+			return nil, fmt.Errorf("unknown candle field %q (expected one of %s)", field, strings.Join(candleFields, ", "))
+		}
+		columns[field] = strings.TrimSpace(column)
+	}
+	for _, field := range candleFields[:5] {
+		if columns[field] == "" {
+			return nil, fmt.Errorf("column mapping is missing %s", field)
+		}
+	}
+	return columns, nil
+}
+
+func isCandleField(field string) bool {
+	for _, f := range candleFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// csvImporter reads OHLCV CSVs with a header, locating columns through a ColumnMapping.
+// Without a mapping, files whose header does not name the default columns are read
+// positionally as timestamp, open, high, low, close, volume.
+type csvImporter struct {
+	columns ColumnMapping
+}
+
+func (ci *csvImporter) Parse(r io.Reader, source string, report *ImportReport) ([]*db.PriceData, error) {
+	reader := newCSVReader(r)
+
+	columns := ci.columns
+	if columns == nil {
+		columns = defaultColumns
+	}
+	indexes, headerless := columnIndexes(columns)
+
+	line := 0
+	if !headerless {
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV header: %w", err)
+		}
+		indexes, err = resolveHeader(header, columns)
+		if err != nil {
+			if ci.columns != nil {
+				return nil, err
+			}
+			indexes = []int{0, 1, 2, 3, 4, 5}
+		}
+	}
+
+	var candles []*db.PriceData
+	err := eachRecord(reader, &line, source, report, func(record []string) (string, bool) {
+		candle, reason := candleFromRecord(record, indexes, parseTimestamp)
+		if candle == nil {
+			return reason, false
+		}
+		candles = append(candles, candle)
+		return "", true
+	})
+	return candles, err
+}
+
+// binanceImporter reads kline CSVs from data.binance.vision: open time in milliseconds
+// (microseconds in newer spot files), then open, high, low, close, volume and columns
+// that are ignored. Older futures files start with a header, which is skipped.
+type binanceImporter struct{}
+
+func (binanceImporter) Parse(r io.Reader, source string, report *ImportReport) ([]*db.PriceData, error) {
+	reader := newCSVReader(r)
+	indexes := []int{0, 1, 2, 3, 4, 5}
+
+	line := 0
+	var candles []*db.PriceData
+	err := eachRecord(reader, &line, source, report, func(record []string) (string, bool) {
+		if line == 1 && len(record) > 0 {
+			if _, err := parseEpoch(record[0]); err != nil {
+				return "", true // header
+			}
+		}
+		candle, reason := candleFromRecord(record, indexes, parseEpoch)
+		if candle == nil {
+			return reason, false
+		}
+		candles = append(candles, candle)
+		return "", true
+	})
+	return candles, err
+}
+
+func newCSVReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // rows with a wrong column count are rejected, not fatal
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+// eachRecord feeds every CSV record to handle, which returns a rejection reason and
+// whether the row was used. Malformed rows are rejected and reading continues.
+func eachRecord(reader *csv.Reader, line *int, source string, report *ImportReport, handle func([]string) (string, bool)) error {
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			*line = parseErr.StartLine
+			report.reject(source, *line, strings.Join(record, ","), parseErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("after line %d: %w", *line, err)
+		}
+		*line, _ = reader.FieldPos(0)
+		if reason, ok := handle(record); !ok {
+			report.reject(source, *line, strings.Join(record, ","), reason)
+		}
+	}
+}
+
+// columnIndexes returns the indexes of a mapping made only of indexes
+func columnIndexes(columns ColumnMapping) ([]int, bool) {
+	indexes := make([]int, len(candleFields))
+	for i, field := range candleFields {
+		column, ok := columns[field]
+		if !ok || column == "" {
+			indexes[i] = -1
+			continue
+		}
+		n, err := strconv.Atoi(column)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		indexes[i] = n
+	}
+	return indexes, true
+}
+
+// resolveHeader finds the mapped columns in a header row
+func resolveHeader(header []string, columns ColumnMapping) ([]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	indexes := make([]int, len(candleFields))
+	for i, field := range candleFields {
+		column := columns[field]
+		if column == "" {
+			indexes[i] = -1
+			continue
+		}
+		if n, err := strconv.Atoi(column); err == nil {
+			indexes[i] = n
+			continue
+		}
+		pos, ok := positions[strings.ToLower(column)]
+		if !ok {
+			return nil, fmt.Errorf("column %q for %s not found in header %v", column, field, header)
+		}
+		indexes[i] = pos
+	}
+	return indexes, nil
+}
+
+// candleFromRecord builds a candle from the columns at indexes (timestamp, open, high,
+// low, close, volume; -1 for a missing volume), or returns why it cannot
+func candleFromRecord(record []string, indexes []int, parseTime func(string) (time.Time, error)) (*db.PriceData, string) {
+	for i, idx := range indexes {
+		if idx >= len(record) {
+			return nil, fmt.Sprintf("missing %s column (row has %d columns)", candleFields[i], len(record))
+		}
+	}
+
+	timestamp, err := parseTime(strings.TrimSpace(record[indexes[0]]))
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	values := make([]float64, 5)
+	for i := range values {
+		idx := indexes[i+1]
+		if idx < 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(record[idx]), 64)
+		if err != nil {
+			return nil, fmt.Sprintf("invalid %s %q", candleFields[i+1], record[idx])
+		}
+		values[i] = v
+	}
+
+	return &db.PriceData{
+		Timestamp: timestamp,
+		Open:      values[0],
+		High:      values[1],
+		Low:       values[2],
+		Close:     values[3],
+		Volume:    values[4],
+	}, ""
+}
+
+// parseEpoch parses a Unix timestamp in seconds, milliseconds, microseconds or
+// nanoseconds, telling them apart by magnitude
+func parseEpoch(s string) (time.Time, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}, fmt.Errorf("invalid unix timestamp %q", s)
+	}
+	switch {
+	case n < 1e11:
+		return time.Unix(n, 0).UTC(), nil
+	case n < 1e14:
+		return time.UnixMilli(n).UTC(), nil
+	case n < 1e17:
+		return time.UnixMicro(n).UTC(), nil
+	}
+	return time.Unix(0, n).UTC(), nil
+}
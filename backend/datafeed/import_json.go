@@ -0,0 +1,119 @@
+package datafeed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"spikeshield/db"
+)
+
+// coinbaseImporter reads Coinbase candles: the Exchange API's array of
+// [time, low, high, open, close, volume] with time in seconds, or the Advanced Trade
+// API's {"candles": [{"start", "low", "high", "open", "close", "volume"}]}
+type coinbaseImporter struct{}
+
+func (coinbaseImporter) Parse(r io.Reader, source string, report *ImportReport) ([]*db.PriceData, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []json.RawMessage
+	advanced := false
+	if err := json.Unmarshal(data, &rows); err != nil {
+		var envelope struct {
+			Candles []json.RawMessage `json:"candles"`
+		}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return nil, fmt.Errorf("invalid Coinbase candles JSON: %w", err)
+		}
+		rows, advanced = envelope.Candles, true
+	}
+
+	var candles []*db.PriceData
+	for i, raw := range rows {
+		var fields []string
+		if advanced {
+			var c struct {
+				Start, Low, High, Open, Close, Volume string
+			}
+			if err := json.Unmarshal(raw, &c); err != nil {
+				report.reject(source, i+1, string(raw), "expected a candle object")
+				continue
+			}
+			fields = []string{c.Start, c.Open, c.High, c.Low, c.Close, c.Volume}
+		} else {
+			values, err := jsonFields(raw)
+			if err != nil || len(values) < 6 {
+				report.reject(source, i+1, string(raw), "expected [time, low, high, open, close, volume]")
+				continue
+			}
+			fields = []string{values[0], values[3], values[2], values[1], values[4], values[5]}
+		}
+
+		candle, reason := candleFromRecord(fields, []int{0, 1, 2, 3, 4, 5}, parseEpoch)
+		if candle == nil {
+			report.reject(source, i+1, string(raw), reason)
+			continue
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// okxImporter reads OKX candles: {"code": "0", "data": [[ts, o, h, l, c, vol, ...]]}
+// with ts in milliseconds
+type okxImporter struct{}
+
+func (okxImporter) Parse(r io.Reader, source string, report *ImportReport) ([]*db.PriceData, error) {
+	var envelope struct {
+		Code string            `json:"code"`
+		Msg  string            `json:"msg"`
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("invalid OKX candles JSON: %w", err)
+	}
+	if envelope.Code != "" && envelope.Code != "0" {
+		return nil, fmt.Errorf("OKX response has error code %s: %s", envelope.Code, envelope.Msg)
+	}
+
+	var candles []*db.PriceData
+	for i, raw := range envelope.Data {
+		values, err := jsonFields(raw)
+		if err != nil || len(values) < 6 {
+			report.reject(source, i+1, string(raw), "expected [ts, open, high, low, close, volume, ...]")
+			continue
+		}
+		candle, reason := candleFromRecord(values, []int{0, 1, 2, 3, 4, 5}, parseEpoch)
+		if candle == nil {
+			report.reject(source, i+1, string(raw), reason)
+			continue
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// jsonFields decodes a JSON array of numbers or numeric strings into strings
+func jsonFields(raw json.RawMessage) ([]string, error) {
+	var values []interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, err
+	}
+	fields := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			fields[i] = strings.TrimSpace(v)
+		case float64:
+			fields[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("unexpected value %v", v)
+		}
+	}
+	return fields, nil
+}
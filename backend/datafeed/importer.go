@@ -0,0 +1,234 @@
+package datafeed
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"spikeshield/db"
+)
+
+// Import formats
+const (
+	FormatAuto     = "auto"     // detected from the content
+	FormatCSV      = "csv"      // generic OHLCV CSV, see ColumnMapping
+	FormatBinance  = "binance"  // data.binance.vision kline CSV
+	FormatCoinbase = "coinbase" // Coinbase candles JSON
+	FormatOKX      = "okx"      // OKX candles JSON
+)
+
+// maxReportedRows caps how many rejected rows a report keeps; Rejected still counts all
+const maxReportedRows = 1000
+
+// ImportLimits bounds what a compressed import may unpack to, so a small gzip or zip
+// bomb cannot exhaust memory
+type ImportLimits struct {
+	MaxBytes   int64 // decompressed size of all entries together
+	MaxEntries int   // files in a zip archive
+}
+
+// DefaultImportLimits apply until SetImportLimits is called
+var DefaultImportLimits = ImportLimits{MaxBytes: 256 << 20, MaxEntries: 100}
+
+var importLimits = DefaultImportLimits
+
+// SetImportLimits changes the limits of later imports; zero fields keep their default
+func SetImportLimits(l ImportLimits) {
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultImportLimits.MaxBytes
+	}
+	if l.MaxEntries <= 0 {
+		l.MaxEntries = DefaultImportLimits.MaxEntries
+	}
+	importLimits = l
+}
+
+// Importer parses one uncompressed exchange export into candles. Rows that cannot be
+// used are recorded in the report and skipped; an error means the input as a whole
+// could not be read.
+type Importer interface {
+	Parse(r io.Reader, source string, report *ImportReport) ([]*db.PriceData, error)
+}
+
+// RejectedRow is an input row that did not become a candle
+type RejectedRow struct {
+	Source string // file, or entry inside an archive
+	Line   int    // CSV line or JSON array index, 1-based
+	Reason string
+	Raw    string
+}
+
+// ImportReport summarizes one import
+type ImportReport struct {
+	Format   string
+	Accepted int
+	Rejected int
+	Rows     []RejectedRow // the first maxReportedRows rejected rows
+}
+
+// reject records a rejected row
+func (r *ImportReport) reject(source string, line int, raw, reason string) {
+	r.Rejected++
+	if len(r.Rows) < maxReportedRows {
+		r.Rows = append(r.Rows, RejectedRow{Source: source, Line: line, Reason: reason, Raw: raw})
+	}
+}
+
+// NewImporter returns the importer for a format. columns only applies to FormatCSV.
+func NewImporter(format string, columns ColumnMapping) (Importer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvImporter{columns: columns}, nil
+	case FormatBinance:
+		return binanceImporter{}, nil
+	case FormatCoinbase:
+		return coinbaseImporter{}, nil
+	case FormatOKX:
+		return okxImporter{}, nil
+	}
+	return nil, fmt.Errorf("unknown import format %q (available: %s, %s, %s, %s, %s)",
+		format, FormatAuto, FormatCSV, FormatBinance, FormatCoinbase, FormatOKX)
+}
+
+// ImportFile reads candles from a file, which may be gzip-compressed or a zip archive
+// of exports. Candles are returned in time order with symbol set.
+func ImportFile(path, format string, columns ColumnMapping, symbol string) ([]*db.PriceData, *ImportReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return ImportData(filepath.Base(path), data, format, columns, symbol)
+}
+
+// ImportData is ImportFile for content already in memory; name identifies it in the report
+func ImportData(name string, data []byte, format string, columns ColumnMapping, symbol string) ([]*db.PriceData, *ImportReport, error) {
+	inputs, err := decompress(name, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &ImportReport{Format: format}
+	var candles []*db.PriceData
+	for _, in := range inputs {
+		f := format
+		if f == "" || f == FormatAuto {
+			// A column mapping only makes sense for generic CSV
+			f = FormatCSV
+			if columns == nil {
+				f = detectFormat(in.data)
+			}
+		}
+		importer, err := NewImporter(f, columns)
+		if err != nil {
+			return nil, nil, err
+		}
+		report.Format = f
+
+		parsed, err := importer.Parse(bytes.NewReader(in.data), in.name, report)
+		if err != nil {
+			return nil, report, fmt.Errorf("%s: %w", in.name, err)
+		}
+		candles = append(candles, parsed...)
+	}
+
+	for _, c := range candles {
+		c.Symbol = symbol
+	}
+	sort.SliceStable(candles, func(i, j int) bool { return candles[i].Timestamp.Before(candles[j].Timestamp) })
+	report.Accepted = len(candles)
+	return candles, report, nil
+}
+
+// input is one uncompressed file
+type input struct {
+	name string
+	data []byte
+}
+
+// decompress unpacks gzip and zip input by their magic bytes, within importLimits;
+// anything else is returned as is
+func decompress(name string, data []byte) ([]input, error) {
+	limits := importLimits
+	budget := limits.MaxBytes
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip %s: %w", name, err)
+		}
+		defer zr.Close()
+		unpacked, err := readLimited(zr, &budget)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", name, err)
+		}
+		return []input{{name: strings.TrimSuffix(name, ".gz"), data: unpacked}}, nil
+
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zip %s: %w", name, err)
+		}
+		if len(zr.File) > limits.MaxEntries {
+			return nil, fmt.Errorf("zip %s has %d entries, more than the limit of %d", name, len(zr.File), limits.MaxEntries)
+		}
+		var inputs []input
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %s in %s: %w", f.Name, name, err)
+			}
+			unpacked, err := readLimited(rc, &budget)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress %s in %s: %w", f.Name, name, err)
+			}
+			inputs = append(inputs, input{name: name + ":" + f.Name, data: unpacked})
+		}
+		if len(inputs) == 0 {
+			return nil, fmt.Errorf("zip %s is empty", name)
+		}
+		return inputs, nil
+	}
+	return []input{{name: name, data: data}}, nil
+}
+
+// readLimited reads r to the end, failing once more than *budget bytes came out of it.
+// The bytes read are taken from the budget.
+func readLimited(r io.Reader, budget *int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, *budget+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > *budget {
+		return nil, fmt.Errorf("decompressed size exceeds the limit of %d MiB", importLimits.MaxBytes>>20)
+	}
+	*budget -= int64(len(data))
+	return data, nil
+}
+
+// detectFormat guesses the format from the start of an uncompressed input: JSON is
+// Coinbase unless it has OKX's "data" envelope, and CSV starting with a millisecond
+// timestamp is a headerless Binance export
+func detectFormat(data []byte) string {
+	head := bytes.TrimSpace(data[:min(len(data), 512)])
+	switch {
+	case bytes.HasPrefix(head, []byte("{")) && bytes.Contains(head, []byte(`"data"`)):
+		return FormatOKX
+	case bytes.HasPrefix(head, []byte("[")), bytes.HasPrefix(head, []byte("{")):
+		return FormatCoinbase
+	}
+	first, _, _ := strings.Cut(string(head), ",")
+	if _, err := parseEpoch(first); err == nil {
+		return FormatBinance
+	}
+	return FormatCSV
+}
@@ -2,6 +2,7 @@ package datafeed
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
@@ -22,13 +23,15 @@ type LiveFeed struct {
 	FeedAddress  common.Address
 	Symbol       string
 	PollInterval time.Duration
+
+	prices db.PriceStore
 }
 
 // Simplified AggregatorV3Interface ABI for latestRoundData
 const aggregatorABI = `[{"inputs":[],"name":"latestRoundData","outputs":[{"internalType":"uint80","name":"roundId","type":"uint80"},{"internalType":"int256","name":"answer","type":"int256"},{"internalType":"uint256","name":"startedAt","type":"uint256"},{"internalType":"uint256","name":"updatedAt","type":"uint256"},{"internalType":"uint80","name":"answeredInRound","type":"uint80"}],"stateMutability":"view","type":"function"}]`
 
 // NewLiveFeed creates a new live feed instance
-func NewLiveFeed(rpcURL, feedAddress, symbol string, pollInterval int) (*LiveFeed, error) {
+func NewLiveFeed(rpcURL, feedAddress, symbol string, pollInterval int, prices db.PriceStore) (*LiveFeed, error) {
 	client, err := ethclient.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to RPC: %w", err)
@@ -38,6 +41,7 @@ func NewLiveFeed(rpcURL, feedAddress, symbol string, pollInterval int) (*LiveFee
 		Client:       client,
 		FeedAddress:  common.HexToAddress(feedAddress),
 		Symbol:       symbol,
+		prices:       prices,
 		PollInterval: time.Duration(pollInterval) * time.Second,
 	}, nil
 }
@@ -103,7 +107,11 @@ func (lf *LiveFeed) fetchAndStore() error {
 		Volume:    0,
 	}
 
-	if err := db.InsertPrice(priceData); err != nil {
+	var quarantined *db.QuarantineError
+	if err := lf.prices.InsertPrice(priceData); errors.As(err, &quarantined) {
+		utils.LogInfo("⚠️  Price for %s at %s quarantined: %s", lf.Symbol, timestamp.Format(time.RFC3339), quarantined.Reason)
+		return nil
+	} else if err != nil {
 		return fmt.Errorf("failed to insert price: %w", err)
 	}
 
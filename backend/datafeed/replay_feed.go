@@ -1,87 +1,82 @@
 package datafeed
 
 import (
-	"encoding/csv"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
 	"spikeshield/db"
 	"spikeshield/utils"
 )
 
-// ReplayFeed reads historical price data from CSV file
+// ReplayFeed reads historical price data from an exchange export (see ImportFile)
 type ReplayFeed struct {
 	FilePath string
 	Symbol   string
+	Format   string        // import format, FormatAuto if empty
+	Columns  ColumnMapping // generic CSV column mapping, nil for the default layout
 	Start    time.Time
 	End      time.Time
+
+	// Report describes the last Load: accepted and rejected rows with reasons
+	Report *ImportReport
+	// Quarantined counts candles of the last LoadAndStore that failed validation
+	Quarantined int
+
+	prices db.PriceStore
 }
 
 // NewReplayFeed creates a new replay feed instance
-func NewReplayFeed(filePath string, symbol string) *ReplayFeed {
+func NewReplayFeed(filePath string, symbol string, prices db.PriceStore) *ReplayFeed {
 	return &ReplayFeed{
 		FilePath: filePath,
 		Symbol:   symbol,
+		Format:   FormatAuto,
+		prices:   prices,
 	}
 }
 
-// LoadAndStore reads CSV and stores price data in database
-func (rf *ReplayFeed) LoadAndStore() error {
-	file, err := os.Open(rf.FilePath)
+// Load reads the file into candles in time order. Rows that cannot be parsed are
+// skipped and listed in rf.Report.
+func (rf *ReplayFeed) Load() ([]*db.PriceData, error) {
+	candles, report, err := ImportFile(rf.FilePath, rf.Format, rf.Columns, rf.Symbol)
+	rf.Report = report
 	if err != nil {
-		return fmt.Errorf("failed to open CSV file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
+	if report.Rejected > 0 {
+		utils.LogInfo("⚠️  %s: rejected %d of %d rows (first: line %d, %s)", rf.FilePath,
+			report.Rejected, report.Rejected+report.Accepted, report.Rows[0].Line, report.Rows[0].Reason)
+	}
+	return candles, nil
+}
 
-	// Skip header row
-	_, err = reader.Read()
+// LoadAndStore reads the file and stores price data in database
+func (rf *ReplayFeed) LoadAndStore() error {
+	utils.LogInfo("Loading price data from %s", rf.FilePath)
+	candles, err := rf.Load()
 	if err != nil {
-		return fmt.Errorf("failed to read CSV header: %w", err)
+		return err
 	}
 
-	utils.LogInfo("Loading price data from %s", rf.FilePath)
 	count := 0
-
-	for {
-		record, err := reader.Read()
-		if err != nil {
-			break // End of file
-		}
-
-		timestamp, err := parseTimestamp(record[0])
-		if err != nil {
-			utils.LogError("Failed to parse timestamp: %v", err)
+	rf.Quarantined = 0
+	for _, priceData := range candles {
+		var qerr *db.QuarantineError
+		if err := rf.prices.InsertPrice(priceData); errors.As(err, &qerr) {
+			utils.LogDebug("Quarantined price at %s: %s", priceData.Timestamp.Format(time.RFC3339), qerr.Reason)
+			rf.Quarantined++
 			continue
-		}
-
-		open, _ := strconv.ParseFloat(record[1], 64)
-		high, _ := strconv.ParseFloat(record[2], 64)
-		low, _ := strconv.ParseFloat(record[3], 64)
-		close, _ := strconv.ParseFloat(record[4], 64)
-		volume, _ := strconv.ParseFloat(record[5], 64)
-
-		priceData := &db.PriceData{
-			Timestamp: timestamp,
-			Symbol:    rf.Symbol,
-			Open:      open,
-			High:      high,
-			Low:       low,
-			Close:     close,
-			Volume:    volume,
-		}
-
-		if err := db.InsertPrice(priceData); err != nil {
+		} else if err != nil {
 			utils.LogError("Failed to insert price: %v", err)
 			continue
 		}
-
 		count++
 	}
 
+	if rf.Quarantined > 0 {
+		utils.LogInfo("⚠️  Quarantined %d price records that failed validation", rf.Quarantined)
+	}
 	utils.LogInfo("Loaded %d price records", count)
 	return nil
 }
@@ -103,13 +98,9 @@ func parseTimestamp(s string) (time.Time, error) {
 		}
 	}
 
-	// Try Unix timestamp
-	if timestamp, err := strconv.ParseInt(s, 10, 64); err == nil {
-		// Check if milliseconds or seconds
-		if timestamp > 1e12 {
-			return time.Unix(0, timestamp*int64(time.Millisecond)), nil
-		}
-		return time.Unix(timestamp, 0), nil
+	// Try Unix timestamp in seconds, milliseconds, microseconds or nanoseconds
+	if t, err := parseEpoch(s); err == nil {
+		return t, nil
 	}
 
 	return time.Time{}, fmt.Errorf("unsupported timestamp format: %s", s)
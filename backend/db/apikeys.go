@@ -0,0 +1,175 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// API roles, from least to most privileged; each role may call everything the roles
+// before it can. Anonymous callers are public.
+const (
+	RolePublic   = "public"
+	RolePartner  = "partner"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Roles lists the roles in privilege order
+var Roles = []string{RolePublic, RolePartner, RoleOperator, RoleAdmin}
+
+// RoleRank orders roles by privilege; unknown roles rank below public
+func RoleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// apiKeyPrefixLen is how much of a key is kept in clear to identify it
+const apiKeyPrefixLen = 12
+
+// APIKey is a credential of the HTTP API; the key itself is only known to its holder
+type APIKey struct {
+	ID         int
+	Name       string
+	Role       string // partner, operator or admin
+	Prefix     string // first characters of the key, to tell keys apart
+	KeyHash    string // hex SHA-256 of the key
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// AuditEntry records one privileged API call, allowed or not
+type AuditEntry struct {
+	ID        int
+	APIKeyID  int    // 0 for anonymous callers and the configured admin token
+	Actor     string // key name, "admin-token" or "anonymous"
+	Role      string
+	Method    string
+	Path      string
+	Status    int
+	ClientIP  string
+	CreatedAt time.Time
+}
+
+// NewAPIKey generates a key ("ssk_" + 48 hex characters) and returns it with its
+// stored form; the key is shown once and cannot be recovered from the hash
+func NewAPIKey(name, role string) (string, *APIKey) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	key := "ssk_" + hex.EncodeToString(buf)
+	return key, &APIKey{Name: name, Role: role, Prefix: key[:apiKeyPrefixLen], KeyHash: HashToken(key)}
+}
+
+// HashToken is the form API keys and session tokens are stored and looked up by
+func HashToken(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey stores a key and sets k.ID and k.CreatedAt
+func (st *SQLStore) CreateAPIKey(k *APIKey) error {
+	query := `INSERT INTO api_keys (name, role, prefix, key_hash) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	return st.db.QueryRow(query, k.Name, k.Role, k.Prefix, k.KeyHash).Scan(&k.ID, &k.CreatedAt)
+}
+
+const apiKeyColumns = `id, name, role, prefix, key_hash, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	k := &APIKey{}
+	var lastUsed, revoked sql.NullTime
+	if err := row.Scan(&k.ID, &k.Name, &k.Role, &k.Prefix, &k.KeyHash, &k.CreatedAt, &lastUsed, &revoked); err != nil {
+		return nil, err
+	}
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		k.RevokedAt = &revoked.Time
+	}
+	return k, nil
+}
+
+// GetAPIKeyByHash returns sql.ErrNoRows for unknown keys; revoked keys are returned
+// with RevokedAt set
+func (st *SQLStore) GetAPIKeyByHash(hash string) (*APIKey, error) {
+	return scanAPIKey(st.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash))
+}
+
+// ListAPIKeys returns every key, revoked ones included, in creation order
+func (st *SQLStore) ListAPIKeys() ([]*APIKey, error) {
+	rows, err := st.db.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey disables a key for good; revoking it again keeps the first revocation time
+func (st *SQLStore) RevokeAPIKey(id int) error {
+	res, err := st.db.Exec(`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`, id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TouchAPIKey records when a key was last used
+func (st *SQLStore) TouchAPIKey(id int, at time.Time) error {
+	_, err := st.db.Exec(`UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	return err
+}
+
+// InsertAuditEntry appends to the audit log and sets e.ID and e.CreatedAt
+func (st *SQLStore) InsertAuditEntry(e *AuditEntry) error {
+	query := `INSERT INTO audit_log (api_key_id, actor, role, method, path, status, client_ip)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	keyID := sql.NullInt64{Int64: int64(e.APIKeyID), Valid: e.APIKeyID != 0}
+	return st.db.QueryRow(query, keyID, e.Actor, e.Role, e.Method, e.Path, e.Status, e.ClientIP).
+		Scan(&e.ID, &e.CreatedAt)
+}
+
+// GetAuditLog returns the latest audit entries, newest first, optionally only those
+// of one key
+func (st *SQLStore) GetAuditLog(apiKeyID, limit int) ([]*AuditEntry, error) {
+	query := `SELECT id, api_key_id, actor, role, method, path, status, client_ip, created_at FROM audit_log
+	          WHERE ($1 = 0 OR api_key_id = $1) ORDER BY id DESC LIMIT $2`
+	rows, err := st.db.Query(query, apiKeyID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*AuditEntry
+	for rows.Next() {
+		e := &AuditEntry{}
+		var keyID sql.NullInt64
+		if err := rows.Scan(&e.ID, &keyID, &e.Actor, &e.Role, &e.Method, &e.Path, &e.Status, &e.ClientIP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.APIKeyID = int(keyID.Int64)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
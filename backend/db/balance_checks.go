@@ -0,0 +1,102 @@
+package db
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// BalanceCheck is one sampled comparison of an indexed balance with balanceOf
+type BalanceCheck struct {
+	ID             int
+	TokenAddress   string
+	UserAddress    string
+	BlockNumber    uint64
+	IndexedBalance *big.Int
+	ChainBalance   *big.Int
+	Drift          *big.Int // ChainBalance - IndexedBalance
+	CheckedAt      time.Time
+}
+
+// NewBalanceCheck compares an indexed balance with the balanceOf read at block
+func NewBalanceCheck(tokenAddr, userAddr string, block uint64, indexed, chain *big.Int) *BalanceCheck {
+	return &BalanceCheck{
+		TokenAddress:   tokenAddr,
+		UserAddress:    userAddr,
+		BlockNumber:    block,
+		IndexedBalance: indexed,
+		ChainBalance:   chain,
+		Drift:          new(big.Int).Sub(chain, indexed),
+	}
+}
+
+// InsertBalanceCheck records a reconciliation result
+func (st *SQLStore) InsertBalanceCheck(c *BalanceCheck) error {
+	query := `INSERT INTO balance_checks (token_address, user_address, block_number, indexed_balance, chain_balance, drift)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, checked_at`
+	return st.db.QueryRow(query, c.TokenAddress, c.UserAddress, c.BlockNumber, c.IndexedBalance.String(), c.ChainBalance.String(),
+		c.Drift.String()).Scan(&c.ID, &c.CheckedAt)
+}
+
+// DriftSummary aggregates reconciliation results since a point in time
+type DriftSummary struct {
+	Checks     int
+	Drifted    int
+	TotalDrift *big.Int // sum of absolute drift, base units
+}
+
+// GetDriftSummary summarizes balance checks recorded since the given time
+func (st *SQLStore) GetDriftSummary(since time.Time) (*DriftSummary, error) {
+	query := `SELECT COUNT(*) FROM balance_checks WHERE checked_at >= $1`
+
+	s := &DriftSummary{TotalDrift: new(big.Int)}
+	if err := st.db.QueryRow(query, since).Scan(&s.Checks); err != nil {
+		return nil, err
+	}
+
+	// Drift is summed here rather than in SQL because SQLite has no exact NUMERIC type
+	rows, err := st.db.Query(`SELECT CAST(drift AS TEXT) FROM balance_checks WHERE checked_at >= $1 AND drift <> 0`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		drift, ok := new(big.Int).SetString(raw, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid drift %q", raw)
+		}
+		s.Drifted++
+		s.TotalDrift.Add(s.TotalDrift, drift.Abs(drift))
+	}
+	return s, rows.Err()
+}
+
+// GetDriftedBalanceChecks returns the most recent checks that found a non-zero drift
+func (st *SQLStore) GetDriftedBalanceChecks(limit int) ([]*BalanceCheck, error) {
+	query := `SELECT id, token_address, user_address, block_number, CAST(indexed_balance AS TEXT), CAST(chain_balance AS TEXT), CAST(drift AS TEXT), checked_at
+			  FROM balance_checks WHERE drift <> 0 ORDER BY checked_at DESC LIMIT $1`
+
+	rows, err := st.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []*BalanceCheck
+	for rows.Next() {
+		c := &BalanceCheck{}
+		var indexed, chain, drift string
+		if err := rows.Scan(&c.ID, &c.TokenAddress, &c.UserAddress, &c.BlockNumber, &indexed, &chain, &drift, &c.CheckedAt); err != nil {
+			return nil, err
+		}
+		c.IndexedBalance, _ = new(big.Int).SetString(indexed, 10)
+		c.ChainBalance, _ = new(big.Int).SetString(chain, 10)
+		c.Drift, _ = new(big.Int).SetString(drift, 10)
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
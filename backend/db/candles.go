@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CandleIntervals are the intervals GetCandles aggregates to, by name
+var CandleIntervals = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// Candle aggregates the candles of a symbol in [Bucket, Bucket+interval)
+type Candle struct {
+	Bucket  time.Time
+	Open    float64
+	High    float64
+	Low     float64
+	Close   float64
+	Volume  float64
+	Candles int            // source candles aggregated
+	Spikes  []*SpikeMarker // spikes detected on the source candles
+}
+
+// SpikeMarker is the part of a spike a chart marks on its candle
+type SpikeMarker struct {
+	ID                int
+	Timestamp         time.Time
+	Direction         string // DirectionUp or DirectionDown: the longer wick
+	RangeClosePercent float64
+}
+
+// bucketSQL is the SQL for the start of the interval-sized bucket of column, in Unix seconds
+func (st *SQLStore) bucketSQL(column string, seconds int) string {
+	if st.dialect == SQLite {
+		return fmt.Sprintf("CAST(strftime('%%s', %s) AS INTEGER) / %d * %d", column, seconds, seconds)
+	}
+	return fmt.Sprintf("CAST(floor(extract(epoch FROM %s) / %d) AS BIGINT) * %d", column, seconds, seconds)
+}
+
+// rollupsCover reports whether candles of interval can be built from rollups of rollup
+func rollupsCover(interval, rollup time.Duration) bool {
+	return rollup > 0 && interval >= rollup && interval%rollup == 0
+}
+
+// extend widens a candle by a later part of its bucket
+func (c *Candle) extend(high, low, close, volume float64, count int) {
+	if high > c.High {
+		c.High = high
+	}
+	if low < c.Low {
+		c.Low = low
+	}
+	c.Close = close
+	c.Volume += volume
+	c.Candles += count
+}
+
+// mergeRollups folds rollups into interval-sized candles and puts them before the
+// candles aggregated from prices, which all follow the rollups; a bucket both fall
+// into is merged
+func mergeRollups(rollups []*PriceRollup, raw []*Candle, interval time.Duration) []*Candle {
+	var candles []*Candle
+	for _, r := range rollups {
+		start := r.Bucket.UTC().Truncate(interval)
+		if n := len(candles); n == 0 || !candles[n-1].Bucket.Equal(start) {
+			candles = append(candles, &Candle{Bucket: start, Open: r.Open, High: r.High, Low: r.Low})
+		}
+		candles[len(candles)-1].extend(r.High, r.Low, r.Close, r.Volume, r.Candles)
+	}
+	for _, c := range raw {
+		if n := len(candles); n > 0 && candles[n-1].Bucket.Equal(c.Bucket) {
+			candles[n-1].extend(c.High, c.Low, c.Close, c.Volume, c.Candles)
+			continue
+		}
+		candles = append(candles, c)
+	}
+	return candles
+}
+
+// rollupWatermark returns the end of the newest rollup of a symbol at one interval,
+// zero without rollups. Everything before it was compacted.
+func (st *SQLStore) rollupWatermark(symbol string, interval time.Duration) (time.Time, error) {
+	var bucket time.Time
+	err := st.db.QueryRow(`SELECT bucket FROM price_rollups WHERE symbol = $1 AND interval_seconds = $2
+	                       ORDER BY bucket DESC LIMIT 1`, symbol, int(interval/time.Second)).Scan(&bucket)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return bucket.Add(interval), nil
+}
+
+// GetCandles aggregates the candles of a symbol with from <= timestamp < to into
+// interval-sized buckets, ascending, and attaches the spikes detected on them. Buckets
+// without candles are left out. When interval is a multiple of rollup, the range
+// compacted into rollups of that size is served from price_rollups; candles kept in
+// prices there for their spikes are already counted in the rollups.
+func (st *SQLStore) GetCandles(symbol string, interval, rollup time.Duration, from, to time.Time) ([]*Candle, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("candle interval must be at least 1s, got %s", interval)
+	}
+	seconds := int(interval / time.Second)
+
+	var rollups []*PriceRollup
+	rawFrom := from
+	if rollupsCover(interval, rollup) {
+		watermark, err := st.rollupWatermark(symbol, rollup)
+		if err != nil {
+			return nil, err
+		}
+		if watermark.After(from) {
+			end := to
+			if watermark.Before(to) {
+				end = watermark
+			}
+			if rollups, err = st.GetPriceRollups(symbol, rollup, from, end); err != nil {
+				return nil, err
+			}
+			rawFrom = watermark
+		}
+	}
+	bucket := st.bucketSQL("timestamp", seconds)
+
+	// Open and close are the first and last candle of each bucket
+	query := `SELECT bucket, MAX(CASE WHEN rn_first = 1 THEN open END), MAX(high), MIN(low),
+	                 MAX(CASE WHEN rn_last = 1 THEN close END), SUM(volume), COUNT(*)
+	          FROM (SELECT ` + bucket + ` AS bucket, open, high, low, close, volume,
+	                       ROW_NUMBER() OVER (PARTITION BY ` + bucket + ` ORDER BY timestamp, id) AS rn_first,
+	                       ROW_NUMBER() OVER (PARTITION BY ` + bucket + ` ORDER BY timestamp DESC, id DESC) AS rn_last
+	                FROM prices WHERE symbol = $1 AND timestamp >= $2 AND timestamp < $3) c
+	          GROUP BY bucket ORDER BY bucket`
+	rows, err := st.db.Query(query, symbol, rawFrom, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var raw []*Candle
+	for rows.Next() {
+		c := &Candle{}
+		var start int64
+		if err := rows.Scan(&start, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.Candles); err != nil {
+			return nil, err
+		}
+		c.Bucket = time.Unix(start, 0).UTC()
+		raw = append(raw, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	candles := mergeRollups(rollups, raw, interval)
+	byBucket := make(map[int64]*Candle, len(candles))
+	for _, c := range candles {
+		byBucket[c.Bucket.Unix()] = c
+	}
+
+	spikes, err := st.db.Query(`SELECT id, timestamp, `+wickDirection+`, range_close_percent, `+bucket+`
+	                            FROM spikes WHERE symbol = $1 AND timestamp >= $2 AND timestamp < $3 AND voided_at IS NULL
+	                            ORDER BY timestamp, id`, symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer spikes.Close()
+	for spikes.Next() {
+		m := &SpikeMarker{}
+		var start int64
+		if err := spikes.Scan(&m.ID, &m.Timestamp, &m.Direction, &m.RangeClosePercent, &start); err != nil {
+			return nil, err
+		}
+		if c, ok := byBucket[start]; ok {
+			c.Spikes = append(c.Spikes, m)
+		}
+	}
+	return candles, spikes.Err()
+}
+
+// aggregateCandles is GetCandles for rollups, candles and spikes already in memory, all
+// ascending; the candles follow the rollups
+func aggregateCandles(rollups []*PriceRollup, prices []*PriceData, spikes []*Spike, interval time.Duration) []*Candle {
+	var candles []*Candle
+	byBucket := make(map[time.Time]*Candle)
+	for _, p := range prices {
+		start := p.Timestamp.UTC().Truncate(interval)
+		c, ok := byBucket[start]
+		if !ok {
+			c = &Candle{Bucket: start, Open: p.Open, High: p.High, Low: p.Low}
+			byBucket[start] = c
+			candles = append(candles, c)
+		}
+		if p.High > c.High {
+			c.High = p.High
+		}
+		if p.Low < c.Low {
+			c.Low = p.Low
+		}
+		c.Close = p.Close
+		c.Volume += p.Volume
+		c.Candles++
+	}
+	candles = mergeRollups(rollups, candles, interval)
+	byBucket = make(map[time.Time]*Candle, len(candles))
+	for _, c := range candles {
+		byBucket[c.Bucket] = c
+	}
+	for _, s := range spikes {
+		if c, ok := byBucket[s.Timestamp.UTC().Truncate(interval)]; ok {
+			c.Spikes = append(c.Spikes, &SpikeMarker{
+				ID:                s.ID,
+				Timestamp:         s.Timestamp,
+				Direction:         wickDirectionOf(s.Open, s.High, s.Low, s.Close),
+				RangeClosePercent: s.RangeClosePercent,
+			})
+		}
+	}
+	return candles
+}
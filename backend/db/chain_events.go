@@ -0,0 +1,172 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ChainEvent is a decoded contract log stored in the chain_events journal
+type ChainEvent struct {
+	ID              int64
+	ContractAddress string
+	EventName       string
+	BlockNumber     uint64
+	BlockHash       string
+	BlockTime       time.Time // zero if the block timestamp was not fetched
+	TxHash          string
+	LogIndex        uint
+	Args            map[string]string
+	CreatedAt       time.Time
+	ProjectedAt     *time.Time // nil until the event has been applied to the projections
+}
+
+// chainEventColumns is the column list scanChainEvent reads
+const chainEventColumns = `id, contract_address, event_name, block_number, block_hash, block_time, tx_hash, log_index, args, created_at, projected_at`
+
+// scanChainEvent reads a chain_events row selected with chainEventColumns
+func scanChainEvent(rows *sql.Rows) (*ChainEvent, error) {
+	ev := &ChainEvent{}
+	var blockTime, projectedAt sql.NullTime
+	var args []byte
+	if err := rows.Scan(&ev.ID, &ev.ContractAddress, &ev.EventName, &ev.BlockNumber, &ev.BlockHash, &blockTime,
+		&ev.TxHash, &ev.LogIndex, &args, &ev.CreatedAt, &projectedAt); err != nil {
+		return nil, err
+	}
+	if blockTime.Valid {
+		ev.BlockTime = blockTime.Time
+	}
+	if projectedAt.Valid {
+		ev.ProjectedAt = &projectedAt.Time
+	}
+	if err := json.Unmarshal(args, &ev.Args); err != nil {
+		return nil, fmt.Errorf("failed to decode args of event %d: %w", ev.ID, err)
+	}
+	return ev, nil
+}
+
+// InsertChainEvent appends an event to the journal.
+// Returns false if the log (tx_hash, log_index) was already journaled, so callers can skip re-projecting it.
+func (st *SQLStore) InsertChainEvent(ev *ChainEvent) (bool, error) {
+	args, err := json.Marshal(ev.Args)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode event args: %w", err)
+	}
+
+	var blockTime sql.NullTime
+	if !ev.BlockTime.IsZero() {
+		blockTime = sql.NullTime{Time: ev.BlockTime, Valid: true}
+	}
+
+	query := `INSERT INTO chain_events (contract_address, event_name, block_number, block_hash, block_time, tx_hash, log_index, args)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			  ON CONFLICT (tx_hash, log_index) DO NOTHING
+			  RETURNING id`
+	err = st.db.QueryRow(query, ev.ContractAddress, ev.EventName, ev.BlockNumber, ev.BlockHash, blockTime, ev.TxHash, ev.LogIndex, args).Scan(&ev.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ForEachChainEvent calls fn for every journaled event in chain order (block number,
+// log index, then journal order for synthetic events sharing a payout's position).
+// Events are read in pages so the whole journal is never held in memory.
+func (st *SQLStore) ForEachChainEvent(fn func(*ChainEvent) error) error {
+	const pageSize = 1000
+	query := `SELECT ` + chainEventColumns + `
+			  FROM chain_events
+			  WHERE (block_number, log_index, id) > ($1, $2, $3)
+			  ORDER BY block_number, log_index, id
+			  LIMIT $4`
+
+	var lastBlock uint64
+	var lastID int64
+	lastIndex := -1
+	for {
+		rows, err := st.db.Query(query, lastBlock, lastIndex, lastID, pageSize)
+		if err != nil {
+			return err
+		}
+
+		var page []*ChainEvent
+		for rows.Next() {
+			ev, err := scanChainEvent(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			page = append(page, ev)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, ev := range page {
+			if err := fn(ev); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < pageSize {
+			return nil
+		}
+		last := page[len(page)-1]
+		lastBlock, lastIndex, lastID = last.BlockNumber, int(last.LogIndex), last.ID
+	}
+}
+
+// GetUnprojectedChainEvents returns up to limit events of a contract that were journaled
+// but not projected, in chain order
+func (st *SQLStore) GetUnprojectedChainEvents(contractAddr string, limit int) ([]*ChainEvent, error) {
+	query := `SELECT ` + chainEventColumns + `
+			  FROM chain_events
+			  WHERE contract_address = $1 AND projected_at IS NULL
+			  ORDER BY block_number, log_index, id
+			  LIMIT $2`
+	rows, err := st.db.Query(query, contractAddr, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*ChainEvent
+	for rows.Next() {
+		ev, err := scanChainEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// ProjectChainEvent runs apply against a store scoped to one transaction and marks the
+// event projected in it, so an event is either projected and marked or left untouched
+// for a retry; a crash in between cannot apply it twice
+func (st *SQLStore) ProjectChainEvent(id int64, apply func(*Store) error) error {
+	return st.inTx(func(scoped *SQLStore) error {
+		if err := apply(scoped.Store()); err != nil {
+			return err
+		}
+		_, err := scoped.db.Exec(`UPDATE chain_events SET projected_at = $1 WHERE id = $2`, time.Now().UTC(), id)
+		return err
+	})
+}
+
+// ResetProjections empties the tables derived from chain_events so they can be rebuilt,
+// and marks every event unprojected. Identities are restarted so a rebuild assigns the
+// same row IDs every time.
+func (st *SQLStore) ResetProjections() error {
+	if st.dialect == SQLite {
+		return st.resetProjectionsSQLite()
+	}
+	_, err := st.db.Exec(`TRUNCATE policies, payouts, balances RESTART IDENTITY;
+		UPDATE chain_events SET projected_at = NULL;`)
+	return err
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"spikeshield/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// migratedSQLite opens an SQLite database with every migration applied
+func migratedSQLite(t *testing.T) *SQLStore {
+	t.Helper()
+	st, _ := openSQLite(t)
+	if _, err := st.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// TestProjectChainEventRollsBack checks that a projection failing after its first write
+// leaves neither the write nor the projected mark behind
+func TestProjectChainEventRollsBack(t *testing.T) {
+	st := migratedSQLite(t)
+	ev := &ChainEvent{ContractAddress: "0x70CE", EventName: "Transfer", BlockNumber: 1, BlockHash: "0x01",
+		TxHash: "0x02", Args: map[string]string{}}
+	if _, err := st.InsertChainEvent(ev); err != nil {
+		t.Fatal(err)
+	}
+
+	credit := utils.NewAmount(big.NewInt(5), 6)
+	failed := errors.New("debit failed")
+	err := st.ProjectChainEvent(ev.ID, func(store *Store) error {
+		if err := store.Balances.UpdateBalanceDelta("0x70CE", "0xB0B", credit); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("error = %v, want %v", err, failed)
+	}
+	if b, err := st.GetBalanceForUser("0x70CE", "0xB0B"); err == nil {
+		t.Errorf("balance %s written by a failed projection", b.Balance)
+	}
+	pending, err := st.GetUnprojectedChainEvents("0x70CE", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("%d events unprojected, want 1", len(pending))
+	}
+
+	err = st.ProjectChainEvent(ev.ID, func(store *Store) error {
+		return store.Balances.UpdateBalanceDelta("0x70CE", "0xB0B", credit)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := st.GetBalanceForUser("0x70CE", "0xB0B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Balance.Raw.Cmp(credit.Raw) != 0 {
+		t.Errorf("balance = %s, want %s", b.Balance.Raw, credit.Raw)
+	}
+	if pending, _ = st.GetUnprojectedChainEvents("0x70CE", 10); len(pending) != 0 {
+		t.Errorf("%d events unprojected after a successful projection", len(pending))
+	}
+}
+
+// TestInsertPayoutIsIdempotent replays a payout with the same tx hash
+func TestInsertPayoutIsIdempotent(t *testing.T) {
+	st := migratedSQLite(t)
+	for i, want := range []bool{true, false} {
+		p := &Payout{PolicyID: 1, UserAddress: "0xA11C", Amount: utils.NewAmount(big.NewInt(100), 6), SpikeID: 7,
+			TxHash: "0x03", ExecutedAt: time.Now()}
+		inserted, err := st.InsertPayout(p)
+		if err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+		if inserted != want {
+			t.Errorf("insert %d: inserted = %t, want %t", i, inserted, want)
+		}
+	}
+}
+
+// TestInsertPolicyFromEventRebuy buys a policy, claims it, clears the user's policies
+// on-chain and buys again, which reuses the policy ID
+func TestInsertPolicyFromEventRebuy(t *testing.T) {
+	stores := map[string]*Store{"sqlite": migratedSQLite(t).Store(), "memory": NewMemoryStore().Store()}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			user := common.HexToAddress("0xA11C")
+			buy := func(block uint64, premium int64, expiry time.Time) {
+				t.Helper()
+				ev := &ChainEvent{BlockNumber: block, BlockTime: time.Unix(1700000000+int64(block)*12, 0).UTC(),
+					TxHash: fmt.Sprintf("0x%02x", block)}
+				err := store.Policies.InsertPolicyFromEvent(user, big.NewInt(0), utils.NewAmount(big.NewInt(premium), 6),
+					utils.NewAmount(big.NewInt(premium*10), 6), big.NewInt(expiry.Unix()), ev)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			buy(1, 10000000, time.Now().Add(-time.Hour))
+			first, err := store.Policies.GetPolicyByOnchainID(user.Hex(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Policies.UpdatePolicyStatus(first.ID, "claimed"); err != nil {
+				t.Fatal(err)
+			}
+
+			buy(5, 20000000, time.Now().Add(time.Hour))
+			// Projecting the first purchase again must not revert the second
+			buy(1, 10000000, time.Now().Add(-time.Hour))
+
+			p, err := store.Policies.GetPolicyByOnchainID(user.Hex(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Status != "active" || p.Premium.Raw.Int64() != 20000000 || p.CoverageAmount.Raw.Int64() != 200000000 || p.BlockNumber != 5 {
+				t.Errorf("policy %s %s/%s at block %d, want active 20.000000/200.000000 at block 5",
+					p.Status, p.Premium, p.CoverageAmount, p.BlockNumber)
+			}
+			active, err := store.Policies.GetActivePolicies()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(active) != 1 || active[0].ID != p.ID {
+				t.Errorf("active policies = %d, want the bought again policy", len(active))
+			}
+		})
+	}
+}
@@ -4,32 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"math"
 	"math/big"
-	"strings"
 	"time"
 
-	"spikeshield/contracts"
 	"spikeshield/utils"
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	_ "github.com/lib/pq"
 )
 
-var DB *sql.DB
-
-// InsertNotifier is a channel for notifying when new prices are inserted
-var InsertNotifier chan struct{}
-
-// InitNotifier initializes the insert notification channel
-func InitNotifier() {
-	InsertNotifier = make(chan struct{}, 100)
-}
-
 // PriceData represents a price record
 type PriceData struct {
 	ID        int
@@ -47,6 +30,7 @@ type Spike struct {
 	ID                int
 	Timestamp         time.Time
 	Symbol            string
+	PriceID           int
 	Open              float64
 	High              float64
 	Low               float64
@@ -54,18 +38,28 @@ type Spike struct {
 	BodyRatio         float64
 	RangeClosePercent float64
 	DetectedAt        time.Time
+	VoidedAt          *time.Time // set when the candle was corrected and no longer matches
 }
 
-// Policy represents an insurance policy
+// Voided reports whether the spike was withdrawn after its candle was corrected
+func (s *Spike) Voided() bool {
+	return s.VoidedAt != nil
+}
+
+// Policy represents an insurance policy.
+// (UserAddress, OnchainPolicyID) identifies the policy in the InsurancePool contract.
 type Policy struct {
-	ID             int
-	UserAddress    string
-	Premium        float64
-	CoverageAmount float64
-	PurchaseTime   time.Time
-	ExpiryTime     time.Time
-	Status         string
-	TxHash         string
+	ID              int
+	UserAddress     string
+	OnchainPolicyID int64
+	Premium         utils.Amount
+	CoverageAmount  utils.Amount
+	PurchaseTime    time.Time
+	ExpiryTime      time.Time
+	Status          string
+	TxHash          string
+	BlockNumber     uint64
+	LogIndex        uint
 }
 
 // Payout represents a payout record
@@ -73,7 +67,7 @@ type Payout struct {
 	ID          int
 	PolicyID    int
 	UserAddress string
-	Amount      float64
+	Amount      utils.Amount
 	SpikeID     int
 	TxHash      string
 	ExecutedAt  time.Time
@@ -84,12 +78,136 @@ type Balance struct {
 	ID           int
 	TokenAddress string
 	UserAddress  string
-	Balance      float64
+	Balance      utils.Amount
 	LastUpdated  time.Time
 }
 
-// Connect establishes database connection
-func Connect(cfg *utils.Config) error {
+// Dialect names the SQL database behind a SQLStore
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// SQLStore implements every store interface on a PostgreSQL or SQLite connection.
+// Queries are written in the SQL both accept; the few that differ branch on dialect.
+type SQLStore struct {
+	db      sqlConn
+	dialect Dialect
+	dsn     string    // used to open dedicated LISTEN connections (Postgres)
+	hub     *priceHub // announces price writes to subscribers (SQLite)
+
+	validation PriceValidation // checks InsertPrice runs before accepting a candle
+}
+
+// sqlConn passes queries through to the database, normalising arguments for the dialect
+type sqlConn struct {
+	*sql.DB
+	tx  *sql.Tx // set while the store is scoped to a transaction (see inTx)
+	utc bool    // SQLite compares timestamps as text, so they are only ordered if all are UTC
+}
+
+func (c sqlConn) args(args []interface{}) []interface{} {
+	if !c.utc {
+		return args
+	}
+	for i, a := range args {
+		switch t := a.(type) {
+		case time.Time:
+			args[i] = t.UTC()
+		case sql.NullTime:
+			t.Time = t.Time.UTC()
+			args[i] = t
+		}
+	}
+	return args
+}
+
+func (c sqlConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if c.tx != nil {
+		return c.tx.Exec(query, c.args(args)...)
+	}
+	return c.DB.Exec(query, c.args(args)...)
+}
+
+func (c sqlConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if c.tx != nil {
+		return c.tx.Query(query, c.args(args)...)
+	}
+	return c.DB.Query(query, c.args(args)...)
+}
+
+func (c sqlConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	if c.tx != nil {
+		return c.tx.QueryRow(query, c.args(args)...)
+	}
+	return c.DB.QueryRow(query, c.args(args)...)
+}
+
+func (c sqlConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if c.tx != nil {
+		return c.tx.QueryContext(ctx, query, c.args(args)...)
+	}
+	return c.DB.QueryContext(ctx, query, c.args(args)...)
+}
+
+func (c sqlConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if c.tx != nil {
+		return c.tx.QueryRowContext(ctx, query, c.args(args)...)
+	}
+	return c.DB.QueryRowContext(ctx, query, c.args(args)...)
+}
+
+// inTx runs fn with a copy of the store whose queries go through one transaction,
+// committed if fn succeeds. A store already scoped to a transaction runs fn in it, so
+// methods using inTx compose without opening a second one (which would deadlock on
+// SQLite's single connection).
+func (st *SQLStore) inTx(fn func(scoped *SQLStore) error) error {
+	if st.db.tx != nil {
+		return fn(st)
+	}
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	scoped := *st
+	scoped.db.tx = tx
+	if err := fn(&scoped); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Connect opens the database selected by database.driver (postgres by default)
+func Connect(cfg *utils.Config) (*SQLStore, error) {
+	var (
+		st  *SQLStore
+		err error
+	)
+	switch Dialect(cfg.Database.Driver) {
+	case "", Postgres:
+		st, err = connectPostgres(cfg)
+	case SQLite:
+		st, err = connectSQLite(cfg)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q (expected postgres or sqlite)", cfg.Database.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.SetPriceValidation(PriceValidation{
+		Enabled:   cfg.Validation.Enabled,
+		MaxJump:   cfg.Validation.MaxJump,
+		Monotonic: cfg.Validation.Monotonic,
+	})
+	return st, nil
+}
+
+// connectPostgres establishes the PostgreSQL connection
+func connectPostgres(cfg *utils.Config) (*SQLStore, error) {
 	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Database.Host,
 		cfg.Database.Port,
@@ -98,22 +216,74 @@ func Connect(cfg *utils.Config) error {
 		cfg.Database.DBName,
 	)
 
-	var err error
-	DB, err = sql.Open("postgres", connStr)
+	conn, err := sql.Open("postgres", connStr)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err = DB.Ping(); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	utils.LogInfo("Database connected successfully")
-	return nil
+	return NewSQLStore(conn, Postgres, connStr), nil
+}
+
+// NewSQLStore wraps an open connection; dsn is the connection string it was opened with
+func NewSQLStore(conn *sql.DB, dialect Dialect, dsn string) *SQLStore {
+	st := &SQLStore{
+		db:      sqlConn{DB: conn, utc: dialect == SQLite},
+		dialect: dialect,
+		dsn:     dsn,
+	}
+	if dialect == SQLite {
+		st.hub = newPriceHub()
+	}
+	return st
+}
+
+// Dialect reports which database the store is connected to
+func (st *SQLStore) Dialect() Dialect {
+	return st.dialect
+}
+
+// Store returns every repository backed by this connection
+func (st *SQLStore) Store() *Store {
+	return &Store{
+		Prices:     st,
+		Spikes:     st,
+		Policies:   st,
+		Payouts:    st,
+		SyncState:  st,
+		Balances:   st,
+		Events:     st,
+		Stats:      st,
+		Candles:    st,
+		Retention:  st,
+		Replays:    st,
+		Quarantine: st,
+		Webhooks:   st,
+		Auth:       st,
+		Sessions:   st,
+	}
+}
+
+// Close closes database connection
+func (st *SQLStore) Close() {
+	if st.db.DB != nil {
+		st.db.Close()
+	}
 }
 
 // InsertPrice inserts a price record
-func InsertPrice(p *PriceData) error {
+func (st *SQLStore) InsertPrice(p *PriceData) error {
+	if err := st.validatePrice(p, "prices", ""); err != nil {
+		return err
+	}
+	if st.dialect == SQLite {
+		return st.insertPriceSQLite(p)
+	}
 	query := `INSERT INTO prices (timestamp, symbol, open, high, low, close, volume)
 	          VALUES ($1, $2, $3, $4, $5, $6, $7)
 	          ON CONFLICT (timestamp, symbol)
@@ -124,61 +294,130 @@ func InsertPrice(p *PriceData) error {
 		    close = EXCLUDED.close,
 		    volume = EXCLUDED.volume
 	          RETURNING id`
-	err := DB.QueryRow(query, p.Timestamp, p.Symbol, p.Open, p.High, p.Low, p.Close, p.Volume).Scan(&p.ID)
-
-	// Notify listeners that a new price was inserted
-	if err == nil && InsertNotifier != nil {
-		select {
-		case InsertNotifier <- struct{}{}:
-		default:
-			// Channel full, skip notification
-		}
+	// The prices_notify trigger announces the row to SubscribePrices consumers
+	return st.db.QueryRow(query, p.Timestamp, p.Symbol, p.Open, p.High, p.Low, p.Close, p.Volume).Scan(&p.ID)
+}
+
+// InsertSpike inserts a spike detection record
+func (st *SQLStore) InsertSpike(s *Spike, priceID int) error {
+	query := `INSERT INTO spikes (timestamp, symbol, price_id, open, high, low, close, body_ratio, range_close_percent) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, detected_at`
+	s.PriceID = priceID
+	return st.db.QueryRow(query, s.Timestamp, s.Symbol, priceID, s.Open, s.High, s.Low, s.Close,
+		s.BodyRatio, s.RangeClosePercent).Scan(&s.ID, &s.DetectedAt)
+}
+
+// spikeColumns is the column list scanned by scanSpike
+const spikeColumns = `id, timestamp, symbol, COALESCE(price_id, 0), COALESCE(open, 0), COALESCE(high, 0), COALESCE(low, 0), COALESCE(close, 0),
+			  body_ratio, range_close_percent, detected_at, voided_at`
+
+// helper: scan a single spike row selected with spikeColumns
+func scanSpike(row rowScanner) (*Spike, error) {
+	s := &Spike{}
+	var voidedAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.Timestamp, &s.Symbol, &s.PriceID, &s.Open, &s.High, &s.Low, &s.Close,
+		&s.BodyRatio, &s.RangeClosePercent, &s.DetectedAt, &voidedAt); err != nil {
+		return nil, err
 	}
+	if voidedAt.Valid {
+		s.VoidedAt = &voidedAt.Time
+	}
+	return s, nil
+}
 
+// GetSpikeByPriceID returns the spike recorded for a candle, voided or not, or sql.ErrNoRows
+func (st *SQLStore) GetSpikeByPriceID(priceID int) (*Spike, error) {
+	return scanSpike(st.db.QueryRow(`SELECT `+spikeColumns+` FROM spikes WHERE price_id = $1`, priceID))
+}
+
+// UpdateSpike rewrites a spike from its re-evaluated candle and clears any void
+func (st *SQLStore) UpdateSpike(s *Spike) error {
+	query := `UPDATE spikes SET timestamp = $2, open = $3, high = $4, low = $5, close = $6,
+			      body_ratio = $7, range_close_percent = $8, updated_at = CURRENT_TIMESTAMP, voided_at = NULL
+			  WHERE id = $1`
+	_, err := st.db.Exec(query, s.ID, s.Timestamp, s.Open, s.High, s.Low, s.Close, s.BodyRatio, s.RangeClosePercent)
+	if err == nil {
+		s.VoidedAt = nil
+	}
 	return err
 }
 
-// InsertSpike inserts a spike detection record
-func InsertSpike(s *Spike, priceID int) error {
-	query := `INSERT INTO spikes (timestamp, symbol, price_id, body_ratio, range_close_percent) 
-			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	return DB.QueryRow(query, s.Timestamp, s.Symbol, priceID, s.BodyRatio, s.RangeClosePercent).Scan(&s.ID)
+// VoidSpike marks a spike as withdrawn; voided spikes are hidden from GetRecentSpikes
+func (st *SQLStore) VoidSpike(id int) error {
+	_, err := st.db.Exec(`UPDATE spikes SET voided_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND voided_at IS NULL`, id)
+	return err
 }
 
 // GetLatestPrice retrieves the most recent price for a symbol
-func GetLatestPrice(symbol string) (*PriceData, error) {
+func (st *SQLStore) GetLatestPrice(symbol string) (*PriceData, error) {
 	query := `SELECT id, timestamp, symbol, open, high, low, close, volume 
 			  FROM prices WHERE symbol = $1 ORDER BY timestamp DESC LIMIT 1`
 
 	p := &PriceData{}
-	err := DB.QueryRow(query, symbol).Scan(&p.ID, &p.Timestamp, &p.Symbol, &p.Open, &p.High, &p.Low, &p.Close, &p.Volume)
+	err := st.db.QueryRow(query, symbol).Scan(&p.ID, &p.Timestamp, &p.Symbol, &p.Open, &p.High, &p.Low, &p.Close, &p.Volume)
 	if err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
-// GetAllPrices retrieves prices within a time range
-func GetAllPrices(symbol string) ([]*PriceData, error) {
-	// wrapper to unified GetPrices implementation (no limit => all)
-	return GetPrices(symbol, 0)
+// GetPriceByID retrieves a single candle by row ID
+func (st *SQLStore) GetPriceByID(id int) (*PriceData, error) {
+	query := `SELECT id, timestamp, symbol, open, high, low, close, volume 
+			  FROM prices WHERE id = $1`
+
+	p := &PriceData{}
+	err := st.db.QueryRow(query, id).Scan(&p.ID, &p.Timestamp, &p.Symbol, &p.Open, &p.High, &p.Low, &p.Close, &p.Volume)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-// GetActivePolicies retrieves all active policies
-func GetActivePolicies() ([]*Policy, error) {
-	query := `SELECT id, user_address, premium, coverage_amount, purchase_time, expiry_time, status, COALESCE(tx_hash, '') 
-			  FROM policies WHERE status = 'active' AND expiry_time > NOW()`
+// policyColumns is the column list scanned by scanPolicy
+const policyColumns = `id, user_address, onchain_policy_id, CAST(premium AS TEXT), CAST(coverage_amount AS TEXT), token_decimals, purchase_time, expiry_time, status,
+			  COALESCE(tx_hash, ''), COALESCE(block_number, 0), COALESCE(log_index, 0)`
 
-	rows, err := DB.Query(query)
-	if err != nil {
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// parseAmount converts a NUMERIC(78, 0) column scanned as text into an exact amount
+func parseAmount(raw string, decimals int) (utils.Amount, error) {
+	v, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return utils.Amount{}, fmt.Errorf("invalid token amount %q", raw)
+	}
+	return utils.NewAmount(v, decimals), nil
+}
+
+// helper: scan a single policy row selected with policyColumns
+func scanPolicy(row rowScanner) (*Policy, error) {
+	p := &Policy{}
+	var premium, coverage string
+	var decimals int
+	if err := row.Scan(&p.ID, &p.UserAddress, &p.OnchainPolicyID, &premium, &coverage, &decimals, &p.PurchaseTime, &p.ExpiryTime, &p.Status,
+		&p.TxHash, &p.BlockNumber, &p.LogIndex); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
+	var err error
+	if p.Premium, err = parseAmount(premium, decimals); err != nil {
+		return nil, err
+	}
+	if p.CoverageAmount, err = parseAmount(coverage, decimals); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// helper: scan rows into []*Policy
+func scanPolicyRows(rows *sql.Rows) ([]*Policy, error) {
 	var policies []*Policy
 	for rows.Next() {
-		p := &Policy{}
-		if err := rows.Scan(&p.ID, &p.UserAddress, &p.Premium, &p.CoverageAmount, &p.PurchaseTime, &p.ExpiryTime, &p.Status, &p.TxHash); err != nil {
+		p, err := scanPolicy(rows)
+		if err != nil {
 			return nil, err
 		}
 		policies = append(policies, p)
@@ -186,113 +425,173 @@ func GetActivePolicies() ([]*Policy, error) {
 	return policies, nil
 }
 
+// GetActivePolicies retrieves all active policies
+func (st *SQLStore) GetActivePolicies() ([]*Policy, error) {
+	query := `SELECT ` + policyColumns + `
+			  FROM policies WHERE status = 'active' AND expiry_time > CURRENT_TIMESTAMP`
+
+	rows, err := st.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPolicyRows(rows)
+}
+
 // GetPoliciesForUser retrieves policies for a specific user address
-func GetPoliciesForUser(userAddr string) ([]*Policy, error) {
-	query := `SELECT id, user_address, premium, coverage_amount, purchase_time, expiry_time, status, COALESCE(tx_hash, '')
+func (st *SQLStore) GetPoliciesForUser(userAddr string) ([]*Policy, error) {
+	query := `SELECT ` + policyColumns + `
 			  FROM policies WHERE user_address = $1 ORDER BY id DESC`
 
-	rows, err := DB.Query(query, userAddr)
+	rows, err := st.db.Query(query, userAddr)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	return scanPolicyRows(rows)
+}
 
-	var policies []*Policy
-	for rows.Next() {
-		p := &Policy{}
-		if err := rows.Scan(&p.ID, &p.UserAddress, &p.Premium, &p.CoverageAmount, &p.PurchaseTime, &p.ExpiryTime, &p.Status, &p.TxHash); err != nil {
-			return nil, err
-		}
-		policies = append(policies, p)
-	}
-	return policies, nil
+// GetPolicyByOnchainID retrieves the policy stored for userPolicies[userAddr][policyID].
+// Returns sql.ErrNoRows if the policy has not been indexed yet.
+func (st *SQLStore) GetPolicyByOnchainID(userAddr string, policyID int64) (*Policy, error) {
+	query := `SELECT ` + policyColumns + `
+			  FROM policies WHERE user_address = $1 AND onchain_policy_id = $2`
+	return scanPolicy(st.db.QueryRow(query, userAddr, policyID))
 }
 
-// GetBalanceForUser returns the cached balance for a token and user
-func GetBalanceForUser(tokenAddr string, userAddr string) (*Balance, error) {
-	query := `SELECT id, token_address, user_address, balance, last_updated FROM balances WHERE token_address = $1 AND user_address = $2 LIMIT 1`
+// balanceColumns is the column list scanned by scanBalance
+const balanceColumns = `id, token_address, user_address, CAST(balance AS TEXT), token_decimals, last_updated`
 
+// helper: scan a single balance row selected with balanceColumns
+func scanBalance(row rowScanner) (*Balance, error) {
 	b := &Balance{}
-	err := DB.QueryRow(query, tokenAddr, userAddr).Scan(&b.ID, &b.TokenAddress, &b.UserAddress, &b.Balance, &b.LastUpdated)
-	if err != nil {
+	var raw string
+	var decimals int
+	if err := row.Scan(&b.ID, &b.TokenAddress, &b.UserAddress, &raw, &decimals, &b.LastUpdated); err != nil {
 		return nil, err
 	}
 
+	var err error
+	if b.Balance, err = parseAmount(raw, decimals); err != nil {
+		return nil, fmt.Errorf("balance of %s: %w", b.UserAddress, err)
+	}
 	return b, nil
 }
 
-// UpsertBalance inserts or updates the balance for a token/user
-func UpsertBalance(tokenAddr string, userAddr string, balance float64) error {
-	query := `INSERT INTO balances (token_address, user_address, balance, last_updated)
-			  VALUES ($1, $2, $3, NOW())
-			  ON CONFLICT (token_address, user_address)
-			  DO UPDATE SET balance = EXCLUDED.balance, last_updated = NOW()`
-	_, err := DB.Exec(query, tokenAddr, userAddr, balance)
-	return err
+// GetBalanceForUser returns the cached balance for a token and user
+func (st *SQLStore) GetBalanceForUser(tokenAddr string, userAddr string) (*Balance, error) {
+	query := `SELECT ` + balanceColumns + ` FROM balances WHERE token_address = $1 AND user_address = $2 LIMIT 1`
+	return scanBalance(st.db.QueryRow(query, tokenAddr, userAddr))
 }
 
-// UpdateBalanceDelta adds delta to existing balance (creates row if not exists)
-func UpdateBalanceDelta(tokenAddr string, userAddr string, delta float64) error {
+// UpdateBalanceDelta adds delta (may be negative) to the existing balance.
+// Creates the row if it does not exist.
+func (st *SQLStore) UpdateBalanceDelta(tokenAddr string, userAddr string, delta utils.Amount) error {
+	if st.dialect == SQLite {
+		return st.updateBalanceDeltaSQLite(tokenAddr, userAddr, delta)
+	}
 	// Use SQL upsert to increment existing balance or insert new
-	query := `INSERT INTO balances (token_address, user_address, balance, last_updated)
-			  VALUES ($1, $2, $3, NOW())
+	query := `INSERT INTO balances (token_address, user_address, balance, token_decimals, last_updated)
+			  VALUES ($1, $2, CAST($3 AS NUMERIC), $4, CURRENT_TIMESTAMP)
 			  ON CONFLICT (token_address, user_address)
-			  DO UPDATE SET balance = balances.balance + $3, last_updated = NOW()`
-	_, err := DB.Exec(query, tokenAddr, userAddr, delta)
+			  DO UPDATE SET balance = balances.balance + EXCLUDED.balance, last_updated = CURRENT_TIMESTAMP`
+	_, err := st.db.Exec(query, tokenAddr, userAddr, delta.Raw.String(), delta.Decimals)
 	return err
 }
 
-// InsertPayout records a payout execution
-func InsertPayout(p *Payout) error {
-	query := `INSERT INTO payouts (policy_id, user_address, amount, spike_id, tx_hash) 
-			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	return DB.QueryRow(query, p.PolicyID, p.UserAddress, p.Amount, p.SpikeID, p.TxHash).Scan(&p.ID)
+// SampleBalances returns up to n randomly chosen balance rows for a token
+func (st *SQLStore) SampleBalances(tokenAddr string, n int) ([]*Balance, error) {
+	query := `SELECT ` + balanceColumns + ` FROM balances WHERE token_address = $1 ORDER BY random() LIMIT $2`
+
+	rows, err := st.db.Query(query, tokenAddr, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []*Balance
+	for rows.Next() {
+		b, err := scanBalance(rows)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, nil
+}
+
+// InsertPayout records a payout execution.
+// Returns false if a payout with the same tx hash was already recorded, so projecting a
+// PayoutExecuted event again is a no-op.
+func (st *SQLStore) InsertPayout(p *Payout) (bool, error) {
+	query := `INSERT INTO payouts (policy_id, user_address, amount, token_decimals, spike_id, tx_hash, executed_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  ON CONFLICT (tx_hash) DO NOTHING
+			  RETURNING id`
+	err := st.db.QueryRow(query, p.PolicyID, p.UserAddress, p.Amount.Raw.String(), p.Amount.Decimals, p.SpikeID, p.TxHash, p.ExecutedAt).Scan(&p.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // UpdatePolicyStatus updates policy status
-func UpdatePolicyStatus(policyID int, status string) error {
+func (st *SQLStore) UpdatePolicyStatus(policyID int, status string) error {
 	query := `UPDATE policies SET status = $1 WHERE id = $2`
-	_, err := DB.Exec(query, status, policyID)
+	_, err := st.db.Exec(query, status, policyID)
 	return err
 }
 
-// Close closes database connection
-func Close() {
-	if DB != nil {
-		DB.Close()
-	}
+// GetSpikes returns the non-voided spikes matching q and the cursor of the next page.
+// They are ordered by detected_at unless q.Sort is "timestamp".
+func (st *SQLStore) GetSpikes(q ListQuery) ([]*Spike, string, error) {
+	b := st.newQuery()
+	b.where = append(b.where, "voided_at IS NULL")
+	return st.selectSpikes("spikes", b, &q, "detected_at", "timestamp")
 }
 
-// GetRecentSpikes retrieves recent spike detection events
-func GetRecentSpikes(limit int) ([]*Spike, error) {
-	query := `SELECT s.id, s.timestamp, s.symbol, p.open, p.high, p.low, p.close, 
-			         s.body_ratio, s.range_close_percent, s.detected_at 
-			  FROM spikes s 
-			  JOIN prices p ON s.price_id = p.id 
-			  ORDER BY s.detected_at DESC LIMIT $1`
-
-	rows, err := DB.Query(query, limit)
+// selectSpikes runs a spike list query on table, which b may already filter further
+func (st *SQLStore) selectSpikes(table string, b *queryBuilder, q *ListQuery, sortColumns ...string) ([]*Spike, string, error) {
+	column, err := q.sortColumn(sortColumns...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if q.Symbol != "" {
+		b.cond("symbol = %s", q.Symbol)
+	}
+	if q.Direction != "" {
+		b.cond(wickDirection+" = %s", q.Direction)
+	}
+	rows, err := st.db.Query(`SELECT `+spikeColumns+` FROM `+table+b.page(q, "timestamp", column, "id"), b.args...)
+	if err != nil {
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	var spikes []*Spike
 	for rows.Next() {
-		s := &Spike{}
-		if err := rows.Scan(&s.ID, &s.Timestamp, &s.Symbol, &s.Open, &s.High, &s.Low, &s.Close,
-			&s.BodyRatio, &s.RangeClosePercent, &s.DetectedAt); err != nil {
-			return nil, err
+		s, err := scanSpike(rows)
+		if err != nil {
+			return nil, "", err
 		}
 		spikes = append(spikes, s)
 	}
-	return spikes, nil
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	spikes, next := finishPage(spikes, q, column, spikeKey(column))
+	return spikes, next, nil
 }
 
-// GetRecentPrices retrieves recent price data
-func GetRecentPrices(symbol string, limit int) ([]*PriceData, error) {
-	// wrapper to unified GetPrices implementation
-	return GetPrices(symbol, limit)
+// spikeKey returns the sort value and ID of a spike ordered by column
+func spikeKey(column string) func(*Spike) (time.Time, int) {
+	if column == "timestamp" {
+		return func(s *Spike) (time.Time, int) { return s.Timestamp, s.ID }
+	}
+	return func(s *Spike) (time.Time, int) { return s.DetectedAt, s.ID }
 }
 
 // helper: scan rows into []*PriceData
@@ -308,27 +607,37 @@ func scanPriceRows(rows *sql.Rows) ([]*PriceData, error) {
 	return prices, nil
 }
 
-// GetPrices returns prices for a symbol. If limit <= 0 all rows are returned (ordered asc), otherwise returns latest `limit` rows.
-func GetPrices(symbol string, limit int) ([]*PriceData, error) {
-	if limit > 0 {
-		query := `SELECT id, timestamp, symbol, open, high, low, close, volume 
-				  FROM prices WHERE symbol = $1 ORDER BY timestamp DESC LIMIT $2`
-		rows, err := DB.Query(query, symbol, limit)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
-		return scanPriceRows(rows)
-	}
+// GetPrices returns the candles matching q, ordered by timestamp, and the cursor of the
+// next page. Symbol "" matches every symbol.
+func (st *SQLStore) GetPrices(q ListQuery) ([]*PriceData, string, error) {
+	return st.selectPrices("prices", st.newQuery(), &q)
+}
 
-	query := `SELECT id, timestamp, symbol, open, high, low, close, volume 
-			  FROM prices WHERE symbol = $1 ORDER BY timestamp`
-	rows, err := DB.Query(query, symbol)
+// selectPrices runs a candle list query on table, which b may already filter further
+func (st *SQLStore) selectPrices(table string, b *queryBuilder, q *ListQuery) ([]*PriceData, string, error) {
+	column, err := q.sortColumn("timestamp")
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if q.Symbol != "" {
+		b.cond("symbol = %s", q.Symbol)
+	}
+	if q.Direction != "" {
+		b.cond(candleDirection+" = %s", q.Direction)
+	}
+	rows, err := st.db.Query(`SELECT id, timestamp, symbol, open, high, low, close, volume FROM `+table+
+		b.page(q, "timestamp", column, "id"), b.args...)
+	if err != nil {
+		return nil, "", err
 	}
 	defer rows.Close()
-	return scanPriceRows(rows)
+
+	prices, err := scanPriceRows(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	prices, next := finishPage(prices, q, column, func(p *PriceData) (time.Time, int) { return p.Timestamp, p.ID })
+	return prices, next, nil
 }
 
 // helper: scan rows into []*Payout
@@ -336,7 +645,13 @@ func scanPayoutRows(rows *sql.Rows) ([]*Payout, error) {
 	var payouts []*Payout
 	for rows.Next() {
 		p := &Payout{}
-		if err := rows.Scan(&p.ID, &p.PolicyID, &p.UserAddress, &p.Amount, &p.SpikeID, &p.TxHash, &p.ExecutedAt); err != nil {
+		var amount string
+		var decimals int
+		if err := rows.Scan(&p.ID, &p.PolicyID, &p.UserAddress, &amount, &decimals, &p.SpikeID, &p.TxHash, &p.ExecutedAt); err != nil {
+			return nil, err
+		}
+		var err error
+		if p.Amount, err = parseAmount(amount, decimals); err != nil {
 			return nil, err
 		}
 		payouts = append(payouts, p)
@@ -344,27 +659,33 @@ func scanPayoutRows(rows *sql.Rows) ([]*Payout, error) {
 	return payouts, nil
 }
 
-// GetRecentPayouts retrieves recent payout records. If userAddress is non-empty it filters by that user.
-func GetRecentPayouts(limit int, userAddress string) ([]*Payout, error) {
-	if userAddress == "" {
-		query := `SELECT id, policy_id, user_address, amount, spike_id, COALESCE(tx_hash, ''), executed_at 
-				  FROM payouts ORDER BY executed_at DESC LIMIT $1`
-		rows, err := DB.Query(query, limit)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
-		return scanPayoutRows(rows)
+// GetPayouts returns the payouts matching q, ordered by executed_at, and the cursor of the
+// next page. Symbol and Direction select payouts by the spike that triggered them.
+func (st *SQLStore) GetPayouts(q ListQuery) ([]*Payout, string, error) {
+	column, err := q.sortColumn("executed_at")
+	if err != nil {
+		return nil, "", err
 	}
-
-	query := `SELECT id, policy_id, user_address, amount, spike_id, COALESCE(tx_hash, ''), executed_at
-			  FROM payouts WHERE user_address = $1 ORDER BY executed_at DESC LIMIT $2`
-	rows, err := DB.Query(query, userAddress, limit)
+	b := st.newQuery()
+	if q.User != "" {
+		b.cond("user_address = %s", q.User)
+	}
+	if q.Symbol != "" || q.Direction != "" {
+		b.spikeFilter(&q, "spikes")
+	}
+	rows, err := st.db.Query(`SELECT id, policy_id, user_address, CAST(amount AS TEXT), token_decimals, spike_id, COALESCE(tx_hash, ''), executed_at
+			  FROM payouts`+b.page(&q, "executed_at", column, "id"), b.args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
-	return scanPayoutRows(rows)
+
+	payouts, err := scanPayoutRows(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	payouts, next := finishPage(payouts, &q, column, func(p *Payout) (time.Time, int) { return p.ExecutedAt, p.ID })
+	return payouts, next, nil
 }
 
 // SystemStats represents system statistics
@@ -377,77 +698,92 @@ type SystemStats struct {
 }
 
 // GetSystemStats retrieves system statistics
-func GetSystemStats() (*SystemStats, error) {
+func (st *SQLStore) GetSystemStats() (*SystemStats, error) {
 	stats := &SystemStats{}
 
 	// Count spikes
-	DB.QueryRow("SELECT COUNT(*) FROM spikes").Scan(&stats.TotalSpikes)
+	st.db.QueryRow("SELECT COUNT(*) FROM spikes WHERE voided_at IS NULL").Scan(&stats.TotalSpikes)
 
 	// Count payouts
-	DB.QueryRow("SELECT COUNT(*) FROM payouts").Scan(&stats.TotalPayouts)
+	st.db.QueryRow("SELECT COUNT(*) FROM payouts").Scan(&stats.TotalPayouts)
 
 	// Count total policies
-	DB.QueryRow("SELECT COUNT(*) FROM policies").Scan(&stats.TotalPolicies)
+	st.db.QueryRow("SELECT COUNT(*) FROM policies").Scan(&stats.TotalPolicies)
 
 	// Count active policies
-	DB.QueryRow("SELECT COUNT(*) FROM policies WHERE status = 'active' AND expiry_time > NOW()").Scan(&stats.ActivePolicies)
+	st.db.QueryRow("SELECT COUNT(*) FROM policies WHERE status = 'active' AND expiry_time > CURRENT_TIMESTAMP").Scan(&stats.ActivePolicies)
 
 	// Count price records
-	DB.QueryRow("SELECT COUNT(*) FROM prices").Scan(&stats.TotalPrices)
+	st.db.QueryRow("SELECT COUNT(*) FROM prices").Scan(&stats.TotalPrices)
 
 	return stats, nil
 }
 
 // DeleteAllPrices deletes all price records
-func DeleteAllPrices() error {
+func (st *SQLStore) DeleteAllPrices() error {
 	query := `DELETE FROM prices`
-	_, err := DB.Exec(query)
+	_, err := st.db.Exec(query)
 	return err
 }
 
 // DeleteAllSpikes deletes all spike records
-func DeleteAllSpikes() error {
+func (st *SQLStore) DeleteAllSpikes() error {
 	query := `DELETE FROM spikes`
-	_, err := DB.Exec(query)
+	_, err := st.db.Exec(query)
 	return err
 }
 
-// InsertPolicyFromEvent inserts a policy purchase event
-func InsertPolicyFromEvent(userAddr common.Address, premium, coverage *big.Int, expiryTime *big.Int, txHash types.Log) error {
-	// Convert wei to USDT (6 decimals)
-	premiumFloat := new(big.Float).Quo(new(big.Float).SetInt(premium), big.NewFloat(1e6))
-	coverageFloat := new(big.Float).Quo(new(big.Float).SetInt(coverage), big.NewFloat(1e6))
+// InsertPolicyFromEvent inserts a policy from a journaled PolicyPurchased event.
+// The purchase time is the event's block timestamp. An existing row is replaced when
+// the event comes after the one it was created from, by (block_number, log_index), or
+// it has no event location (created by an on-chain sync): clearAllUserPolicies lets a
+// later purchase reuse a policy ID.
+func (st *SQLStore) InsertPolicyFromEvent(userAddr common.Address, policyID *big.Int, premium, coverage utils.Amount, expiryTime *big.Int, ev *ChainEvent) error {
 	expiry := time.Unix(expiryTime.Int64(), 0)
 
-	premiumValue, _ := premiumFloat.Float64()
-	coverageValue, _ := coverageFloat.Float64()
-
 	query := `
 		INSERT INTO policies
-		(user_address, premium, coverage_amount, purchase_time, expiry_time, status, tx_hash)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (tx_hash) DO NOTHING
+		(user_address, onchain_policy_id, premium, coverage_amount, token_decimals, purchase_time, expiry_time, status, tx_hash, block_number, log_index)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (user_address, onchain_policy_id)
+		DO UPDATE SET
+		    premium = EXCLUDED.premium,
+		    coverage_amount = EXCLUDED.coverage_amount,
+		    token_decimals = EXCLUDED.token_decimals,
+		    purchase_time = EXCLUDED.purchase_time,
+		    expiry_time = EXCLUDED.expiry_time,
+		    status = EXCLUDED.status,
+		    tx_hash = EXCLUDED.tx_hash,
+		    block_number = EXCLUDED.block_number,
+		    log_index = EXCLUDED.log_index
+		WHERE policies.block_number IS NULL
+		   OR EXCLUDED.block_number > policies.block_number
+		   OR (EXCLUDED.block_number = policies.block_number AND EXCLUDED.log_index > policies.log_index)
 	`
 
-	_, err := DB.Exec(query,
+	_, err := st.db.Exec(query,
 		userAddr.Hex(),
-		premiumValue,
-		coverageValue,
-		time.Now(),
+		policyID.Int64(),
+		premium.Raw.String(),
+		coverage.Raw.String(),
+		premium.Decimals,
+		ev.BlockTime,
 		expiry,
 		"active",
-		txHash.TxHash.Hex(),
+		ev.TxHash,
+		ev.BlockNumber,
+		ev.LogIndex,
 	)
 
 	return err
 }
 
 // GetLastSyncedBlock retrieves the last synced block number for a contract
-func GetLastSyncedBlock(contractAddr common.Address) (uint64, error) {
+func (st *SQLStore) GetLastSyncedBlock(contractAddr common.Address) (uint64, error) {
 	var lastBlock uint64
 	query := `SELECT last_synced_block FROM sync_state WHERE contract_address = $1`
 
-	err := DB.QueryRow(query, contractAddr.Hex()).Scan(&lastBlock)
+	err := st.db.QueryRow(query, contractAddr.Hex()).Scan(&lastBlock)
 	if err != nil {
 		// If no record exists, return 0 (will start from recent blocks)
 		return 0, nil
@@ -457,23 +793,23 @@ func GetLastSyncedBlock(contractAddr common.Address) (uint64, error) {
 }
 
 // UpdateLastSyncedBlock updates the last synced block number for a contract
-func UpdateLastSyncedBlock(contractAddr common.Address, blockNumber uint64) error {
+func (st *SQLStore) UpdateLastSyncedBlock(contractAddr common.Address, blockNumber uint64) error {
 	query := `
 		INSERT INTO sync_state (contract_address, last_synced_block, updated_at)
-		VALUES ($1, $2, NOW())
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
 		ON CONFLICT (contract_address)
-		DO UPDATE SET last_synced_block = $2, updated_at = NOW()
+		DO UPDATE SET last_synced_block = $2, updated_at = CURRENT_TIMESTAMP
 	`
 
-	_, err := DB.Exec(query, contractAddr.Hex(), blockNumber)
+	_, err := st.db.Exec(query, contractAddr.Hex(), blockNumber)
 	return err
 }
 
 // GetAllBalances retrieves all rows from balances table
-func GetAllBalances() ([]*Balance, error) {
-	query := `SELECT id, token_address, user_address, balance, last_updated FROM balances`
+func (st *SQLStore) GetAllBalances() ([]*Balance, error) {
+	query := `SELECT ` + balanceColumns + ` FROM balances`
 
-	rows, err := DB.Query(query)
+	rows, err := st.db.Query(query)
 	if err != nil {
 		return nil, err
 	}
@@ -481,8 +817,8 @@ func GetAllBalances() ([]*Balance, error) {
 
 	var balances []*Balance
 	for rows.Next() {
-		b := &Balance{}
-		if err := rows.Scan(&b.ID, &b.TokenAddress, &b.UserAddress, &b.Balance, &b.LastUpdated); err != nil {
+		b, err := scanBalance(rows)
+		if err != nil {
 			return nil, err
 		}
 		balances = append(balances, b)
@@ -491,10 +827,10 @@ func GetAllBalances() ([]*Balance, error) {
 }
 
 // GetDistinctPolicyUsers returns a list of distinct user addresses found in policies table
-func GetDistinctPolicyUsers() ([]string, error) {
+func (st *SQLStore) GetDistinctPolicyUsers() ([]string, error) {
 	query := `SELECT DISTINCT user_address FROM policies`
 
-	rows, err := DB.Query(query)
+	rows, err := st.db.Query(query)
 	if err != nil {
 		return nil, err
 	}
@@ -511,186 +847,19 @@ func GetDistinctPolicyUsers() ([]string, error) {
 	return users, nil
 }
 
-// UpsertPolicy inserts or updates a policy record based on user_address + purchase_time
-func UpsertPolicy(userAddr string, premium float64, coverage float64, purchaseTime time.Time, expiryTime time.Time, status string) error {
-	var policyID sql.NullInt64
-	err := DB.QueryRow(`SELECT id FROM policies WHERE user_address = $1 AND purchase_time = $2`, userAddr, purchaseTime).Scan(&policyID)
-	if err != nil && err != sql.ErrNoRows {
-		return err
-	}
-
-	if policyID.Valid {
-		// Update existing
-		_, err = DB.Exec(`UPDATE policies SET premium = $1, coverage_amount = $2, expiry_time = $3, status = $4 WHERE id = $5`,
-			premium, coverage, expiryTime, status, policyID.Int64)
-		return err
-	}
-
-	// Insert new
-	_, err = DB.Exec(`INSERT INTO policies (user_address, premium, coverage_amount, purchase_time, expiry_time, status, tx_hash)
-		VALUES ($1, $2, $3, $4, $5, $6, NULL)`, userAddr, premium, coverage, purchaseTime, expiryTime, status)
+// UpsertPolicy inserts or updates a policy record based on user_address + onchain_policy_id.
+// Event location columns are left untouched so rows created from PolicyPurchased keep them.
+func (st *SQLStore) UpsertPolicy(userAddr string, onchainPolicyID int64, premium utils.Amount, coverage utils.Amount, purchaseTime time.Time, expiryTime time.Time, status string) error {
+	query := `INSERT INTO policies (user_address, onchain_policy_id, premium, coverage_amount, token_decimals, purchase_time, expiry_time, status)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			  ON CONFLICT (user_address, onchain_policy_id)
+			  DO UPDATE SET
+			    premium = EXCLUDED.premium,
+			    coverage_amount = EXCLUDED.coverage_amount,
+			    token_decimals = EXCLUDED.token_decimals,
+			    purchase_time = EXCLUDED.purchase_time,
+			    expiry_time = EXCLUDED.expiry_time,
+			    status = EXCLUDED.status`
+	_, err := st.db.Exec(query, userAddr, onchainPolicyID, premium.Raw.String(), coverage.Raw.String(), premium.Decimals, purchaseTime, expiryTime, status)
 	return err
 }
-
-// FullSync updates balances and policies for all rows found in the DB.
-// This was previously in a separate syncer package; moved here per request.
-func FullSync(cfg *utils.Config) error {
-	if cfg == nil || cfg.RPC.URL == "" {
-		utils.LogInfo("RPC not configured; skipping full sync")
-		return nil
-	}
-
-	client, err := ethclient.Dial(cfg.RPC.URL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to RPC: %w", err)
-	}
-	defer client.Close()
-
-	// Update balances for every row in balances table
-	balances, err := GetAllBalances()
-	if err != nil {
-		utils.LogError("Failed to query balances for full sync: %v", err)
-	} else {
-		utils.LogInfo("FullSync: updating %d balance rows", len(balances))
-		for _, b := range balances {
-			if err := updateBalanceRow(client, cfg, b.TokenAddress, b.UserAddress); err != nil {
-				utils.LogError("Failed to update balance for %s %s: %v", b.UserAddress, b.TokenAddress, err)
-			}
-		}
-	}
-
-	// Update policies for every distinct user in policies table
-	users, err := GetDistinctPolicyUsers()
-	if err != nil {
-		utils.LogError("Failed to query policy users for full sync: %v", err)
-	} else {
-		utils.LogInfo("FullSync: updating policies for %d users", len(users))
-		for _, u := range users {
-			if err := syncPoliciesForUser(client, cfg, u); err != nil {
-				utils.LogError("Failed to sync policies for user %s: %v", u, err)
-			}
-		}
-	}
-
-	return nil
-}
-
-// UpsertForUser updates balances and policies for a single user (called when frontend links wallet)
-func UpsertForUser(cfg *utils.Config, userAddr string) error {
-	if cfg == nil || cfg.RPC.URL == "" {
-		utils.LogInfo("RPC not configured; skipping user upsert")
-		return nil
-	}
-
-	client, err := ethclient.Dial(cfg.RPC.URL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to RPC: %w", err)
-	}
-	defer client.Close()
-
-	if err := updateBalanceRow(client, cfg, cfg.RPC.UsdtAddress, userAddr); err != nil {
-		utils.LogError("Failed to update balance for %s %s: %v", userAddr, cfg.RPC.UsdtAddress, err)
-		return err
-	}
-
-	// Update policies for this user
-	if err := syncPoliciesForUser(client, cfg, userAddr); err != nil {
-		utils.LogError("Failed to sync policies for user %s: %v", userAddr, err)
-		return err
-	}
-
-	return nil
-}
-
-// updateBalanceRow reads on-chain ERC20 balance and upserts into DB
-func updateBalanceRow(client *ethclient.Client, cfg *utils.Config, tokenAddr string, userAddr string) error {
-	utils.LogInfo("updateBalanceRow for %s %s", userAddr, tokenAddr)
-	const erc20ABI = `[{"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"type":"function"}]`
-
-	parsed, err := abi.JSON(strings.NewReader(erc20ABI))
-	if err != nil {
-		return err
-	}
-
-	contract := bind.NewBoundContract(common.HexToAddress(tokenAddr), parsed, client, client, client)
-	var out []interface{}
-	if err := contract.Call(&bind.CallOpts{Context: context.Background()}, &out, "balanceOf", common.HexToAddress(userAddr)); err != nil {
-		return err
-	}
-
-	balanceRaw := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
-
-	decimals := cfg.RPC.UsdtDecimals
-	if decimals <= 0 {
-		decimals = 6
-	}
-
-	denom := new(big.Float).SetFloat64(math.Pow10(int(decimals)))
-	balf := new(big.Float).SetInt(balanceRaw)
-	human := new(big.Float).Quo(balf, denom)
-	humanVal, _ := human.Float64()
-
-	if err := UpsertBalance(tokenAddr, userAddr, humanVal); err != nil {
-		return err
-	}
-	return nil
-}
-
-// UpsertBalanceFromChain reads on-chain ERC20 balance for the given token and user using the provided
-// ethclient.Client and configuration, then upserts the normalized balance into the DB.
-// This exported wrapper lets other packages refresh a single user's balance for a token
-// by querying the chain instead of relying on transfer deltas.
-func UpsertBalanceFromChain(client *ethclient.Client, cfg *utils.Config, tokenAddr string, userAddr string) error {
-	return updateBalanceRow(client, cfg, tokenAddr, userAddr)
-}
-
-// syncPoliciesForUser reads user's policies on-chain and upserts into DB
-func syncPoliciesForUser(client *ethclient.Client, cfg *utils.Config, userAddr string) error {
-	poolAddr := common.HexToAddress(cfg.RPC.ContractAddress)
-	pool, err := contracts.NewInsurancePool(poolAddr, client)
-	if err != nil {
-		return err
-	}
-
-	// Get all user policies at once using typed binding for safety
-	policies, err := pool.GetUserPolicies(&bind.CallOpts{Context: context.Background()}, common.HexToAddress(userAddr))
-	if err != nil {
-		return err
-	}
-	if len(policies) == 0 {
-		return nil
-	}
-	decimals := cfg.RPC.UsdtDecimals
-
-	for _, policy := range policies {
-		userAddrOnChain := policy.User
-		premiumRaw := policy.Premium
-		coverageRaw := policy.CoverageAmount
-		purchaseRaw := policy.PurchaseTime
-		expiryRaw := policy.ExpiryTime
-		active := policy.Active
-		claimed := policy.Claimed
-
-		premiumFloat := new(big.Float).Quo(new(big.Float).SetInt(premiumRaw), new(big.Float).SetFloat64(math.Pow10(int(decimals))))
-		premiumVal, _ := premiumFloat.Float64()
-		coverageFloat := new(big.Float).Quo(new(big.Float).SetInt(coverageRaw), new(big.Float).SetFloat64(math.Pow10(int(decimals))))
-		coverageVal, _ := coverageFloat.Float64()
-
-		purchaseTime := time.Unix(purchaseRaw.Int64(), 0)
-		expiryTime := time.Unix(expiryRaw.Int64(), 0)
-
-		status := "inactive"
-		if active {
-			status = "active"
-		}
-		if claimed {
-			status = "claimed"
-		}
-
-		if err := UpsertPolicy(userAddrOnChain.Hex(), premiumVal, coverageVal, purchaseTime, expiryTime, status); err != nil {
-			utils.LogError("UpsertPolicy failed for user %s: %v", userAddrOnChain.Hex(), err)
-		}
-	}
-
-	return nil
-}
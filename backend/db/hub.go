@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"sync"
+)
+
+// priceHub fans price events out to in-process subscribers, for stores that have no
+// server-side notification like the prices_notify trigger
+type priceHub struct {
+	// mu is held for reading while sending events, so a subscription is never closed mid-send
+	mu          sync.RWMutex
+	subscribers map[chan PriceEvent]context.Context
+}
+
+func newPriceHub() *priceHub {
+	return &priceHub{subscribers: make(map[chan PriceEvent]context.Context)}
+}
+
+// publish delivers ev to every subscriber, blocking until each one accepts it or goes away
+func (h *priceHub) publish(ev PriceEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch, ctx := range h.subscribers {
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// subscribe registers a channel that is closed when ctx is done
+func (h *priceHub) subscribe(ctx context.Context) <-chan PriceEvent {
+	ch := make(chan PriceEvent, 100)
+	h.mu.Lock()
+	h.subscribers[ch] = ctx
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subscribers, ch)
+		h.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
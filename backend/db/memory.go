@@ -0,0 +1,1456 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"spikeshield/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MemoryStore implements every store interface in process memory.
+// It mirrors the unique constraints of the Postgres schema and is meant for tests and demos.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	prices     []*PriceData
+	spikes     []*Spike
+	policies   []*Policy
+	payouts    []*Payout
+	syncState  map[string]uint64
+	balances   map[balanceKey]*Balance
+	checks     []*BalanceCheck
+	events     []*ChainEvent
+	poolParams []*PoolParams
+	rollups    []*PriceRollup
+
+	replays   map[string]*ReplaySession
+	scopes    map[string]*MemoryStore // candles and spikes of each replay session
+	simulated []*SimulatedPayout
+
+	validation  PriceValidation
+	quarantined []*QuarantinedPrice
+
+	webhooks   []*Webhook
+	deliveries []*WebhookDelivery
+
+	apiKeys []*APIKey
+	audit   []*AuditEntry
+
+	nonces   map[string]*siweNonce
+	sessions []*WalletSession
+
+	nextID int
+
+	hub *priceHub
+}
+
+type siweNonce struct {
+	expiresAt time.Time
+	used      bool
+}
+
+type balanceKey struct {
+	token string
+	user  string
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		syncState: make(map[string]uint64),
+		balances:  make(map[balanceKey]*Balance),
+		hub:       newPriceHub(),
+		replays:   make(map[string]*ReplaySession),
+		scopes:    make(map[string]*MemoryStore),
+		nonces:    make(map[string]*siweNonce),
+	}
+}
+
+// Store returns every repository backed by this memory store
+func (m *MemoryStore) Store() *Store {
+	return &Store{
+		Prices:     m,
+		Spikes:     m,
+		Policies:   m,
+		Payouts:    m,
+		SyncState:  m,
+		Balances:   m,
+		Events:     m,
+		Stats:      m,
+		Candles:    m,
+		Retention:  m,
+		Replays:    m,
+		Quarantine: m,
+		Webhooks:   m,
+		Auth:       m,
+		Sessions:   m,
+	}
+}
+
+// id returns the next row ID; IDs are shared across tables, which only makes them more unique
+func (m *MemoryStore) id() int {
+	m.nextID++
+	return m.nextID
+}
+
+// InsertPrice upserts a candle by (symbol, timestamp), quarantining it if validation fails
+func (m *MemoryStore) InsertPrice(p *PriceData) error {
+	m.mu.Lock()
+	var existing, prev *PriceData
+	later := false
+	for _, row := range m.prices {
+		if row.Symbol != p.Symbol {
+			continue
+		}
+		switch {
+		case row.Timestamp.Equal(p.Timestamp):
+			existing = row
+		case row.Timestamp.After(p.Timestamp):
+			later = true
+		case prev == nil || row.Timestamp.After(prev.Timestamp):
+			prev = row
+		}
+	}
+	if m.validation.Enabled {
+		if reason := m.validation.check(p, prev, existing != nil, later); reason != "" {
+			m.quarantine(p, reason)
+			m.mu.Unlock()
+			return &QuarantineError{Reason: reason}
+		}
+	}
+	ev := PriceEvent{Symbol: p.Symbol, Op: "UPDATE"}
+	if existing == nil {
+		p.ID = m.id()
+		stored := *p
+		m.prices = append(m.prices, &stored)
+		ev.Op = "INSERT"
+	} else {
+		p.ID = existing.ID
+		*existing = *p
+	}
+	ev.ID = p.ID
+	m.mu.Unlock()
+
+	// Like the prices_notify trigger, every write is announced to every subscriber
+	m.hub.publish(ev)
+	return nil
+}
+
+// SubscribePrices delivers an event for every InsertPrice until ctx is done
+func (m *MemoryStore) SubscribePrices(ctx context.Context) (<-chan PriceEvent, error) {
+	return m.hub.subscribe(ctx), nil
+}
+
+// GetPriceByID retrieves a single candle by row ID
+func (m *MemoryStore) GetPriceByID(id int) (*PriceData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, row := range m.prices {
+		if row.ID == id {
+			p := *row
+			return &p, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetLatestPrice retrieves the most recent price for a symbol
+func (m *MemoryStore) GetLatestPrice(symbol string) (*PriceData, error) {
+	prices, _, _ := m.GetPrices(ListQuery{Symbol: symbol, Desc: true, Limit: 1})
+	if len(prices) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return prices[0], nil
+}
+
+// GetPrices returns the candles matching q and the cursor of the next page
+func (m *MemoryStore) GetPrices(q ListQuery) ([]*PriceData, string, error) {
+	column, err := q.sortColumn("timestamp")
+	if err != nil {
+		return nil, "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var prices []*PriceData
+	for _, row := range m.prices {
+		if (q.Symbol == "" || row.Symbol == q.Symbol) && (q.Direction == "" || candleDirectionOf(row.Open, row.Close) == q.Direction) {
+			p := *row
+			prices = append(prices, &p)
+		}
+	}
+	key := func(p *PriceData) (time.Time, int) { return p.Timestamp, p.ID }
+	prices, next := pageInMemory(prices, &q, column, key, func(p *PriceData) time.Time { return p.Timestamp })
+	return prices, next, nil
+}
+
+// DeleteAllPrices deletes all price records
+func (m *MemoryStore) DeleteAllPrices() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prices = nil
+	return nil
+}
+
+// CompactPrices rolls candles older than before into rollups and drops them, keeping
+// spike-referenced candles, with the same semantics as SQLStore.CompactPrices
+func (m *MemoryStore) CompactPrices(before time.Time, interval time.Duration) (*CompactionResult, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("rollup interval must be at least 1s, got %s", interval)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := &CompactionResult{Cutoff: before.UTC().Truncate(interval)}
+	referenced := make(map[int]bool)
+	for _, s := range m.spikes {
+		referenced[s.PriceID] = true
+	}
+	watermarks := make(map[string]time.Time)
+	for _, r := range m.rollups {
+		if end := r.Bucket.Add(interval); r.Interval == interval && end.After(watermarks[r.Symbol]) {
+			watermarks[r.Symbol] = end
+		}
+	}
+
+	sorted := make([]*PriceData, len(m.prices))
+	copy(sorted, m.prices)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	buckets := make(map[rollupKey]*PriceRollup)
+	var order []rollupKey
+	for _, p := range sorted {
+		if !p.Timestamp.Before(res.Cutoff) {
+			continue
+		}
+		if referenced[p.ID] {
+			res.Kept++
+			if p.Timestamp.Before(watermarks[p.Symbol]) {
+				continue
+			}
+		}
+		key := rollupKey{symbol: p.Symbol, bucket: p.Timestamp.UTC().Truncate(interval)}
+		r, ok := buckets[key]
+		if !ok {
+			r = &PriceRollup{Symbol: p.Symbol, Interval: interval, Bucket: key.bucket}
+			buckets[key] = r
+			order = append(order, key)
+		}
+		foldCandle(r, p)
+		res.RolledUp++
+	}
+
+	for _, key := range order {
+		r := buckets[key]
+		var existing *PriceRollup
+		for _, row := range m.rollups {
+			if row.Symbol == r.Symbol && row.Interval == interval && row.Bucket.Equal(r.Bucket) {
+				existing = row
+				break
+			}
+		}
+		if existing == nil {
+			r.ID = m.id()
+			m.rollups = append(m.rollups, r)
+			continue
+		}
+		// Late candles for a rolled-up bucket, as in the SQL upsert
+		if r.High > existing.High {
+			existing.High = r.High
+		}
+		if r.Low < existing.Low {
+			existing.Low = r.Low
+		}
+		existing.Volume += r.Volume
+		existing.Candles += r.Candles
+	}
+
+	kept := m.prices[:0]
+	for _, p := range m.prices {
+		if p.Timestamp.Before(res.Cutoff) && !referenced[p.ID] {
+			res.Deleted++
+			continue
+		}
+		kept = append(kept, p)
+	}
+	m.prices = kept
+	return res, nil
+}
+
+// GetCandles aggregates the candles of a symbol with from <= timestamp < to into
+// interval-sized buckets, ascending, and attaches the spikes detected on them. The
+// compacted range is served from rollups as in SQLStore.GetCandles.
+func (m *MemoryStore) GetCandles(symbol string, interval, rollup time.Duration, from, to time.Time) ([]*Candle, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("candle interval must be at least 1s, got %s", interval)
+	}
+	var rollups []*PriceRollup
+	rawFrom := from
+	if rollupsCover(interval, rollup) {
+		if watermark := m.rollupWatermark(symbol, rollup); watermark.After(from) {
+			end := to
+			if watermark.Before(to) {
+				end = watermark
+			}
+			rollups, _ = m.GetPriceRollups(symbol, rollup, from, end)
+			rawFrom = watermark
+		}
+	}
+
+	var prices []*PriceData
+	if rawFrom.Before(to) {
+		var err error
+		if prices, _, err = m.GetPrices(ListQuery{Symbol: symbol, From: rawFrom, To: to, Sort: "timestamp"}); err != nil {
+			return nil, err
+		}
+	}
+	spikes, _, err := m.GetSpikes(ListQuery{Symbol: symbol, From: from, To: to, Sort: "timestamp"})
+	if err != nil {
+		return nil, err
+	}
+	return aggregateCandles(rollups, prices, spikes, interval), nil
+}
+
+// rollupWatermark returns the end of the newest rollup of a symbol at one interval
+func (m *MemoryStore) rollupWatermark(symbol string, interval time.Duration) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var watermark time.Time
+	for _, r := range m.rollups {
+		if end := r.Bucket.Add(interval); r.Symbol == symbol && r.Interval == interval && end.After(watermark) {
+			watermark = end
+		}
+	}
+	return watermark
+}
+
+// GetPriceRollups returns the rollups of a symbol at one interval with from <= bucket < to, ascending
+func (m *MemoryStore) GetPriceRollups(symbol string, interval time.Duration, from, to time.Time) ([]*PriceRollup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rollups []*PriceRollup
+	for _, r := range m.rollups {
+		if r.Symbol == symbol && r.Interval == interval && !r.Bucket.Before(from) && r.Bucket.Before(to) {
+			row := *r
+			rollups = append(rollups, &row)
+		}
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Bucket.Before(rollups[j].Bucket) })
+	return rollups, nil
+}
+
+// InsertSpike inserts a spike detection record; price IDs are unique like spikes.price_id
+func (m *MemoryStore) InsertSpike(s *Spike, priceID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findSpike(func(row *Spike) bool { return row.PriceID == priceID }) != nil {
+		return fmt.Errorf("spike for price %d already exists", priceID)
+	}
+	s.ID = m.id()
+	s.PriceID = priceID
+	s.DetectedAt = time.Now()
+	stored := *s
+	m.spikes = append(m.spikes, &stored)
+	return nil
+}
+
+// findSpike returns the first stored spike matching keep; callers hold m.mu
+func (m *MemoryStore) findSpike(keep func(*Spike) bool) *Spike {
+	for _, row := range m.spikes {
+		if keep(row) {
+			return row
+		}
+	}
+	return nil
+}
+
+// GetSpikeByPriceID returns the spike of a candle, including a voided one, or sql.ErrNoRows
+func (m *MemoryStore) GetSpikeByPriceID(priceID int) (*Spike, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row := m.findSpike(func(row *Spike) bool { return row.PriceID == priceID })
+	if row == nil {
+		return nil, sql.ErrNoRows
+	}
+	s := *row
+	return &s, nil
+}
+
+// UpdateSpike rewrites a spike from its re-evaluated candle and clears any void
+func (m *MemoryStore) UpdateSpike(s *Spike) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row := m.findSpike(func(row *Spike) bool { return row.ID == s.ID })
+	if row == nil {
+		return nil
+	}
+	row.Timestamp, row.Open, row.High, row.Low, row.Close = s.Timestamp, s.Open, s.High, s.Low, s.Close
+	row.BodyRatio, row.RangeClosePercent = s.BodyRatio, s.RangeClosePercent
+	row.VoidedAt = nil
+	s.VoidedAt = nil
+	return nil
+}
+
+// VoidSpike marks a spike as withdrawn
+func (m *MemoryStore) VoidSpike(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row := m.findSpike(func(row *Spike) bool { return row.ID == id })
+	if row != nil && row.VoidedAt == nil {
+		now := time.Now()
+		row.VoidedAt = &now
+	}
+	return nil
+}
+
+// GetSpikes returns the non-voided spikes matching q and the cursor of the next page
+func (m *MemoryStore) GetSpikes(q ListQuery) ([]*Spike, string, error) {
+	column, err := q.sortColumn("detected_at", "timestamp")
+	if err != nil {
+		return nil, "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	spikes := make([]*Spike, 0, len(m.spikes))
+	for _, row := range m.spikes {
+		if row.Voided() || !matchSpike(row, &q) {
+			continue
+		}
+		s := *row
+		spikes = append(spikes, &s)
+	}
+	spikes, next := pageInMemory(spikes, &q, column, spikeKey(column), func(s *Spike) time.Time { return s.Timestamp })
+	return spikes, next, nil
+}
+
+// matchSpike reports whether a spike has q's symbol and direction
+func matchSpike(s *Spike, q *ListQuery) bool {
+	return (q.Symbol == "" || s.Symbol == q.Symbol) &&
+		(q.Direction == "" || wickDirectionOf(s.Open, s.High, s.Low, s.Close) == q.Direction)
+}
+
+// spikeMatcher returns whether the spike with an ID matches q's symbol and direction;
+// every ID matches when q filters on neither. The caller holds m.mu.
+func (m *MemoryStore) spikeMatcher(q *ListQuery) func(id int) bool {
+	if q.Symbol == "" && q.Direction == "" {
+		return func(int) bool { return true }
+	}
+	matching := make(map[int]bool)
+	for _, s := range m.spikes {
+		if !s.Voided() && matchSpike(s, q) {
+			matching[s.ID] = true
+		}
+	}
+	return func(id int) bool { return matching[id] }
+}
+
+// DeleteAllSpikes deletes all spike records
+func (m *MemoryStore) DeleteAllSpikes() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spikes = nil
+	return nil
+}
+
+// findPolicy returns the stored policy for (user, on-chain ID); callers hold m.mu
+func (m *MemoryStore) findPolicy(userAddr string, onchainPolicyID int64) *Policy {
+	for _, p := range m.policies {
+		if p.UserAddress == userAddr && p.OnchainPolicyID == onchainPolicyID {
+			return p
+		}
+	}
+	return nil
+}
+
+// copyPolicies returns copies of the policies matching keep
+func (m *MemoryStore) copyPolicies(keep func(*Policy) bool) []*Policy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var policies []*Policy
+	for _, row := range m.policies {
+		if keep(row) {
+			p := *row
+			policies = append(policies, &p)
+		}
+	}
+	return policies
+}
+
+// GetActivePolicies retrieves all active, unexpired policies
+func (m *MemoryStore) GetActivePolicies() ([]*Policy, error) {
+	now := time.Now()
+	return m.copyPolicies(func(p *Policy) bool {
+		return p.Status == "active" && p.ExpiryTime.After(now)
+	}), nil
+}
+
+// GetPoliciesForUser retrieves policies for a user, newest first
+func (m *MemoryStore) GetPoliciesForUser(userAddr string) ([]*Policy, error) {
+	policies := m.copyPolicies(func(p *Policy) bool { return p.UserAddress == userAddr })
+	sort.Slice(policies, func(i, j int) bool { return policies[i].ID > policies[j].ID })
+	return policies, nil
+}
+
+// GetPolicyByOnchainID returns sql.ErrNoRows if the policy has not been indexed yet
+func (m *MemoryStore) GetPolicyByOnchainID(userAddr string, policyID int64) (*Policy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.findPolicy(userAddr, policyID)
+	if p == nil {
+		return nil, sql.ErrNoRows
+	}
+	out := *p
+	return &out, nil
+}
+
+// GetDistinctPolicyUsers returns the users that hold at least one policy
+func (m *MemoryStore) GetDistinctPolicyUsers() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var users []string
+	for _, p := range m.policies {
+		if !seen[p.UserAddress] {
+			seen[p.UserAddress] = true
+			users = append(users, p.UserAddress)
+		}
+	}
+	return users, nil
+}
+
+// InsertPolicyFromEvent inserts a policy from a journaled PolicyPurchased event,
+// replacing an existing one created from an earlier event or by an on-chain sync
+func (m *MemoryStore) InsertPolicyFromEvent(userAddr common.Address, policyID *big.Int, premium, coverage utils.Amount, expiryTime *big.Int, ev *ChainEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.findPolicy(userAddr.Hex(), policyID.Int64())
+	if p == nil {
+		p = &Policy{ID: m.id(), UserAddress: userAddr.Hex(), OnchainPolicyID: policyID.Int64()}
+		m.policies = append(m.policies, p)
+	} else if p.TxHash != "" && (ev.BlockNumber < p.BlockNumber || ev.BlockNumber == p.BlockNumber && ev.LogIndex <= p.LogIndex) {
+		return nil
+	}
+	p.Premium = premium
+	p.CoverageAmount = coverage
+	p.ExpiryTime = time.Unix(expiryTime.Int64(), 0)
+	p.Status = "active"
+	p.PurchaseTime = ev.BlockTime
+	p.TxHash = ev.TxHash
+	p.BlockNumber = ev.BlockNumber
+	p.LogIndex = ev.LogIndex
+	return nil
+}
+
+// UpsertPolicy inserts or updates a policy; event location fields are left untouched
+func (m *MemoryStore) UpsertPolicy(userAddr string, onchainPolicyID int64, premium, coverage utils.Amount, purchaseTime, expiryTime time.Time, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.findPolicy(userAddr, onchainPolicyID)
+	if p == nil {
+		p = &Policy{ID: m.id(), UserAddress: userAddr, OnchainPolicyID: onchainPolicyID}
+		m.policies = append(m.policies, p)
+	}
+	p.Premium = premium
+	p.CoverageAmount = coverage
+	p.PurchaseTime = purchaseTime
+	p.ExpiryTime = expiryTime
+	p.Status = status
+	return nil
+}
+
+// UpdatePolicyStatus updates policy status
+func (m *MemoryStore) UpdatePolicyStatus(policyID int, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.policies {
+		if p.ID == policyID {
+			p.Status = status
+		}
+	}
+	return nil
+}
+
+// InsertPayout records a payout; policy IDs and tx hashes are unique like in payouts, and
+// a repeated tx hash is ignored like ON CONFLICT (tx_hash) DO NOTHING
+func (m *MemoryStore) InsertPayout(p *Payout) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, row := range m.payouts {
+		if p.TxHash != "" && row.TxHash == p.TxHash {
+			return false, nil
+		}
+		if row.PolicyID == p.PolicyID {
+			return false, fmt.Errorf("payout for policy %d already exists", p.PolicyID)
+		}
+	}
+	p.ID = m.id()
+	stored := *p
+	m.payouts = append(m.payouts, &stored)
+	return true, nil
+}
+
+// GetPayouts returns the payouts matching q and the cursor of the next page
+func (m *MemoryStore) GetPayouts(q ListQuery) ([]*Payout, string, error) {
+	column, err := q.sortColumn("executed_at")
+	if err != nil {
+		return nil, "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	spikeMatches := m.spikeMatcher(&q)
+	var payouts []*Payout
+	for _, row := range m.payouts {
+		if (q.User == "" || row.UserAddress == q.User) && spikeMatches(row.SpikeID) {
+			p := *row
+			payouts = append(payouts, &p)
+		}
+	}
+	key := func(p *Payout) (time.Time, int) { return p.ExecutedAt, p.ID }
+	payouts, next := pageInMemory(payouts, &q, column, key, func(p *Payout) time.Time { return p.ExecutedAt })
+	return payouts, next, nil
+}
+
+// GetLastSyncedBlock returns 0 when the contract has never been synced
+func (m *MemoryStore) GetLastSyncedBlock(contractAddr common.Address) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.syncState[contractAddr.Hex()], nil
+}
+
+// UpdateLastSyncedBlock updates the last synced block number for a contract
+func (m *MemoryStore) UpdateLastSyncedBlock(contractAddr common.Address, blockNumber uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.syncState[contractAddr.Hex()] = blockNumber
+	return nil
+}
+
+// GetBalanceForUser returns the indexed balance for a token and user
+func (m *MemoryStore) GetBalanceForUser(tokenAddr, userAddr string) (*Balance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.balances[balanceKey{tokenAddr, userAddr}]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	out := *b
+	return &out, nil
+}
+
+// copyBalances returns copies of the balances matching keep
+func (m *MemoryStore) copyBalances(keep func(*Balance) bool) []*Balance {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var balances []*Balance
+	for _, row := range m.balances {
+		if keep(row) {
+			b := *row
+			balances = append(balances, &b)
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].ID < balances[j].ID })
+	return balances
+}
+
+// GetAllBalances retrieves every indexed balance
+func (m *MemoryStore) GetAllBalances() ([]*Balance, error) {
+	return m.copyBalances(func(*Balance) bool { return true }), nil
+}
+
+// SampleBalances returns up to n balances for a token, oldest rows first (not random like Postgres)
+func (m *MemoryStore) SampleBalances(tokenAddr string, n int) ([]*Balance, error) {
+	balances := m.copyBalances(func(b *Balance) bool { return b.TokenAddress == tokenAddr })
+	if len(balances) > n {
+		balances = balances[:n]
+	}
+	return balances, nil
+}
+
+// UpdateBalanceDelta adds delta (may be negative), creating the row if needed
+func (m *MemoryStore) UpdateBalanceDelta(tokenAddr, userAddr string, delta utils.Amount) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := balanceKey{tokenAddr, userAddr}
+	b, ok := m.balances[key]
+	if !ok {
+		b = &Balance{ID: m.id(), TokenAddress: tokenAddr, UserAddress: userAddr, Balance: utils.NewAmount(nil, delta.Decimals)}
+		m.balances[key] = b
+	}
+	b.Balance = utils.NewAmount(new(big.Int).Add(b.Balance.Raw, delta.Raw), b.Balance.Decimals)
+	b.LastUpdated = time.Now()
+	return nil
+}
+
+// InsertBalanceCheck records a reconciliation result
+func (m *MemoryStore) InsertBalanceCheck(c *BalanceCheck) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c.ID = m.id()
+	c.CheckedAt = time.Now()
+	stored := *c
+	m.checks = append(m.checks, &stored)
+	return nil
+}
+
+// InsertChainEvent appends an event to the journal; returns false for an already journaled log
+func (m *MemoryStore) InsertChainEvent(ev *ChainEvent) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, row := range m.events {
+		if row.TxHash == ev.TxHash && row.LogIndex == ev.LogIndex {
+			return false, nil
+		}
+	}
+	ev.ID = int64(m.id())
+	ev.CreatedAt = time.Now()
+	stored := *ev
+	m.events = append(m.events, &stored)
+	return true, nil
+}
+
+// ForEachChainEvent calls fn for every journaled event in (block number, log index, id) order
+func (m *MemoryStore) ForEachChainEvent(fn func(*ChainEvent) error) error {
+	m.mu.Lock()
+	events := make([]*ChainEvent, 0, len(m.events))
+	for _, row := range m.events {
+		ev := *row
+		events = append(events, &ev)
+	}
+	m.mu.Unlock()
+
+	sortChainEvents(events)
+	for _, ev := range events {
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortChainEvents orders events by (block number, log index, id)
+func sortChainEvents(events []*ChainEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].BlockNumber != events[j].BlockNumber {
+			return events[i].BlockNumber < events[j].BlockNumber
+		}
+		if events[i].LogIndex != events[j].LogIndex {
+			return events[i].LogIndex < events[j].LogIndex
+		}
+		return events[i].ID < events[j].ID
+	})
+}
+
+// GetUnprojectedChainEvents returns up to limit unprojected events of a contract in chain order
+func (m *MemoryStore) GetUnprojectedChainEvents(contractAddr string, limit int) ([]*ChainEvent, error) {
+	m.mu.Lock()
+	var events []*ChainEvent
+	for _, row := range m.events {
+		if row.ContractAddress == contractAddr && row.ProjectedAt == nil {
+			ev := *row
+			events = append(events, &ev)
+		}
+	}
+	m.mu.Unlock()
+
+	sortChainEvents(events)
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// ProjectChainEvent runs apply and marks the event projected. The memory store has no
+// transactions, so a failing apply may leave its earlier writes behind.
+func (m *MemoryStore) ProjectChainEvent(id int64, apply func(*Store) error) error {
+	if err := apply(m.Store()); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, row := range m.events {
+		if row.ID == id {
+			now := time.Now()
+			row.ProjectedAt = &now
+		}
+	}
+	return nil
+}
+
+// ResetProjections empties policies, payouts and balances
+func (m *MemoryStore) ResetProjections() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.policies = nil
+	m.payouts = nil
+	m.balances = make(map[balanceKey]*Balance)
+	for _, row := range m.events {
+		row.ProjectedAt = nil
+	}
+	return nil
+}
+
+// InsertPoolParams appends a snapshot; event-sourced rows are idempotent on (tx hash, log index)
+func (m *MemoryStore) InsertPoolParams(p *PoolParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p.TxHash != "" {
+		for _, row := range m.poolParams {
+			if row.TxHash == p.TxHash && row.LogIndex == p.LogIndex {
+				return nil
+			}
+		}
+	}
+	p.ID = m.id()
+	p.RecordedAt = time.Now()
+	stored := *p
+	m.poolParams = append(m.poolParams, &stored)
+	return nil
+}
+
+// GetLatestPoolParams returns sql.ErrNoRows if nothing has been recorded for the contract
+func (m *MemoryStore) GetLatestPoolParams(contractAddr string) (*PoolParams, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.poolParams) - 1; i >= 0; i-- {
+		if m.poolParams[i].ContractAddress == contractAddr {
+			p := *m.poolParams[i]
+			return &p, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetSystemStats counts rows across the store
+func (m *MemoryStore) GetSystemStats() (*SystemStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := &SystemStats{
+		TotalPayouts:  len(m.payouts),
+		TotalPolicies: len(m.policies),
+		TotalPrices:   len(m.prices),
+	}
+	for _, s := range m.spikes {
+		if !s.Voided() {
+			stats.TotalSpikes++
+		}
+	}
+	now := time.Now()
+	for _, p := range m.policies {
+		if p.Status == "active" && p.ExpiryTime.After(now) {
+			stats.ActivePolicies++
+		}
+	}
+	return stats, nil
+}
+
+// CreateReplaySession records a new session; s.ID is generated if empty
+func (m *MemoryStore) CreateReplaySession(s *ReplaySession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.ID == "" {
+		s.ID = NewReplaySessionID()
+	}
+	if _, exists := m.replays[s.ID]; exists {
+		return fmt.Errorf("replay session %s already exists", s.ID)
+	}
+	if s.Status == "" {
+		s.Status = ReplayCreated
+	}
+	s.CreatedAt = time.Now()
+	stored := *s
+	m.replays[s.ID] = &stored
+	m.scopes[s.ID] = m.newScope()
+	return nil
+}
+
+// GetReplaySession returns sql.ErrNoRows if the session does not exist
+func (m *MemoryStore) GetReplaySession(id string) (*ReplaySession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.replays[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	out := *s
+	return &out, nil
+}
+
+// ListReplaySessions returns every session, newest first
+func (m *MemoryStore) ListReplaySessions() ([]*ReplaySession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := make([]*ReplaySession, 0, len(m.replays))
+	for _, s := range m.replays {
+		out := *s
+		sessions = append(sessions, &out)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+// UpdateReplaySessionStatus moves a session to status; final states set FinishedAt
+func (m *MemoryStore) UpdateReplaySessionStatus(id, status, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.replays[id]
+	if !ok {
+		return nil
+	}
+	s.Status, s.Error, s.FinishedAt = status, errMsg, nil
+	if ReplayEnded(status) {
+		now := time.Now()
+		s.FinishedAt = &now
+	}
+	return nil
+}
+
+// DeleteReplaySession removes a session and everything it produced
+func (m *MemoryStore) DeleteReplaySession(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.replays, id)
+	delete(m.scopes, id)
+	kept := m.simulated[:0]
+	for _, p := range m.simulated {
+		if p.SessionID != id {
+			kept = append(kept, p)
+		}
+	}
+	m.simulated = kept
+	return nil
+}
+
+// ReplayScope returns a separate in-memory store holding the session's candles and spikes
+func (m *MemoryStore) ReplayScope(sessionID string) (PriceStore, SpikeStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	scope, ok := m.scopes[sessionID]
+	if !ok {
+		scope = m.newScope()
+		m.scopes[sessionID] = scope
+	}
+	return scope, scope
+}
+
+// newScope creates the store of a replay session, validating like this one except for
+// the order of candles, since seeking back replays candles a forward seek skipped
+func (m *MemoryStore) newScope() *MemoryStore {
+	scope := NewMemoryStore()
+	scope.validation = m.validation
+	scope.validation.Monotonic = false
+	return scope
+}
+
+// InsertSimulatedPayout records a simulated payout; a policy pays once per session
+func (m *MemoryStore) InsertSimulatedPayout(p *SimulatedPayout) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, row := range m.simulated {
+		if row.SessionID == p.SessionID && row.PolicyID == p.PolicyID {
+			return false, nil
+		}
+	}
+	p.ID = m.id()
+	p.SimulatedAt = time.Now()
+	stored := *p
+	m.simulated = append(m.simulated, &stored)
+	return true, nil
+}
+
+// GetSimulatedPayouts returns the simulated payouts of a session matching q and the cursor
+// of the next page
+func (m *MemoryStore) GetSimulatedPayouts(sessionID string, q ListQuery) ([]*SimulatedPayout, string, error) {
+	column, err := q.sortColumn("simulated_at")
+	if err != nil {
+		return nil, "", err
+	}
+	spikeMatches := func(int) bool { return true }
+	m.mu.Lock()
+	scope, ok := m.scopes[sessionID]
+	m.mu.Unlock()
+	if ok {
+		scope.mu.Lock()
+		spikeMatches = scope.spikeMatcher(&q)
+		scope.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var payouts []*SimulatedPayout
+	for _, p := range m.simulated {
+		if p.SessionID == sessionID && (q.User == "" || p.UserAddress == q.User) && spikeMatches(p.SpikeID) {
+			out := *p
+			payouts = append(payouts, &out)
+		}
+	}
+	key := func(p *SimulatedPayout) (time.Time, int) { return p.SimulatedAt, p.ID }
+	payouts, next := pageInMemory(payouts, &q, column, key, func(p *SimulatedPayout) time.Time { return p.SimulatedAt })
+	return payouts, next, nil
+}
+
+// SetPriceValidation changes the checks InsertPrice runs; replay sessions created later inherit them
+func (m *MemoryStore) SetPriceValidation(v PriceValidation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validation = v
+}
+
+// quarantine records a rejected candle, replacing an earlier rejection of the same slot.
+// The caller holds m.mu.
+func (m *MemoryStore) quarantine(p *PriceData, reason string) {
+	q := &QuarantinedPrice{
+		Timestamp: p.Timestamp, Symbol: p.Symbol,
+		Open: p.Open, High: p.High, Low: p.Low, Close: p.Close, Volume: p.Volume,
+		Reason: reason, QuarantinedAt: time.Now(),
+	}
+	for i, row := range m.quarantined {
+		if row.Symbol == p.Symbol && row.Timestamp.Equal(p.Timestamp) {
+			q.ID = row.ID
+			m.quarantined[i] = q
+			return
+		}
+	}
+	q.ID = m.id()
+	m.quarantined = append(m.quarantined, q)
+}
+
+// GetQuarantinedPrices returns the quarantined candles of production (sessionID "") or a
+// replay session matching q and the cursor of the next page
+func (m *MemoryStore) GetQuarantinedPrices(sessionID string, q ListQuery) ([]*QuarantinedPrice, string, error) {
+	column, err := q.sortColumn("timestamp", "quarantined_at")
+	if err != nil {
+		return nil, "", err
+	}
+	source := m
+	if sessionID != "" {
+		m.mu.Lock()
+		scope, ok := m.scopes[sessionID]
+		m.mu.Unlock()
+		if !ok {
+			return nil, "", nil
+		}
+		source = scope
+	}
+
+	source.mu.Lock()
+	defer source.mu.Unlock()
+
+	var quarantined []*QuarantinedPrice
+	for _, row := range source.quarantined {
+		if (q.Symbol == "" || row.Symbol == q.Symbol) && (q.Direction == "" || candleDirectionOf(row.Open, row.Close) == q.Direction) {
+			qp := *row
+			qp.SessionID = sessionID
+			quarantined = append(quarantined, &qp)
+		}
+	}
+	quarantined, next := pageInMemory(quarantined, &q, column, quarantineKey(column), func(qp *QuarantinedPrice) time.Time { return qp.Timestamp })
+	return quarantined, next, nil
+}
+
+// CreateWebhook stores an endpoint and sets w.ID and w.CreatedAt
+func (m *MemoryStore) CreateWebhook(w *Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.ID = m.id()
+	w.CreatedAt = time.Now()
+	stored := *w
+	stored.Events = append([]string(nil), w.Events...)
+	m.webhooks = append(m.webhooks, &stored)
+	return nil
+}
+
+// GetWebhook returns sql.ErrNoRows if the endpoint does not exist
+func (m *MemoryStore) GetWebhook(id int) (*Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, w := range m.webhooks {
+		if w.ID == id {
+			out := *w
+			out.Events = append([]string(nil), w.Events...)
+			return &out, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// ListWebhooks returns every endpoint in creation order
+func (m *MemoryStore) ListWebhooks() ([]*Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhooks := make([]*Webhook, 0, len(m.webhooks))
+	for _, w := range m.webhooks {
+		out := *w
+		out.Events = append([]string(nil), w.Events...)
+		webhooks = append(webhooks, &out)
+	}
+	return webhooks, nil
+}
+
+// UpdateWebhook rewrites an endpoint's URL, secret, filter, description and active flag
+func (m *MemoryStore) UpdateWebhook(w *Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, row := range m.webhooks {
+		if row.ID == w.ID {
+			stored := *w
+			stored.Events = append([]string(nil), w.Events...)
+			stored.OwnerKeyID = row.OwnerKeyID
+			stored.CreatedAt = row.CreatedAt
+			m.webhooks[i] = &stored
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// DeleteWebhook removes an endpoint with its delivery log
+func (m *MemoryStore) DeleteWebhook(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := false
+	kept := m.webhooks[:0]
+	for _, w := range m.webhooks {
+		if w.ID == id {
+			found = true
+			continue
+		}
+		kept = append(kept, w)
+	}
+	m.webhooks = kept
+	if !found {
+		return sql.ErrNoRows
+	}
+
+	deliveries := m.deliveries[:0]
+	for _, d := range m.deliveries {
+		if d.WebhookID != id {
+			deliveries = append(deliveries, d)
+		}
+	}
+	m.deliveries = deliveries
+	return nil
+}
+
+// InsertWebhookDelivery queues a delivery and sets d.ID and d.CreatedAt
+func (m *MemoryStore) InsertWebhookDelivery(d *WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if d.Status == "" {
+		d.Status = DeliveryPending
+	}
+	d.ID = m.id()
+	d.CreatedAt = time.Now()
+	stored := *d
+	m.deliveries = append(m.deliveries, &stored)
+	return nil
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries due at now and moves their
+// next attempt to leaseUntil
+func (m *MemoryStore) ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]*WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []*WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*WebhookDelivery, 0, len(due))
+	for _, d := range due {
+		d.NextAttemptAt = leaseUntil
+		out := *d
+		claimed = append(claimed, &out)
+	}
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].ID < claimed[j].ID })
+	return claimed, nil
+}
+
+// UpdateWebhookDelivery stores the outcome of an attempt or a manual redelivery
+func (m *MemoryStore) UpdateWebhookDelivery(d *WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, row := range m.deliveries {
+		if row.ID == d.ID {
+			row.Status, row.Attempts, row.NextAttemptAt = d.Status, d.Attempts, d.NextAttemptAt
+			row.LastStatusCode, row.LastError, row.DeliveredAt = d.LastStatusCode, d.LastError, d.DeliveredAt
+			return nil
+		}
+	}
+	return nil
+}
+
+// GetWebhookDelivery returns sql.ErrNoRows if the delivery does not exist
+func (m *MemoryStore) GetWebhookDelivery(id int) (*WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range m.deliveries {
+		if d.ID == id {
+			out := *d
+			return &out, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetWebhookDeliveries returns an endpoint's latest deliveries, newest first, optionally
+// only those in status
+func (m *MemoryStore) GetWebhookDeliveries(webhookID int, status string, limit int) ([]*WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deliveries []*WebhookDelivery
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		d := m.deliveries[i]
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			out := *d
+			deliveries = append(deliveries, &out)
+		}
+	}
+	return deliveries, nil
+}
+
+// CreateAPIKey stores a key and sets k.ID and k.CreatedAt
+func (m *MemoryStore) CreateAPIKey(k *APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, row := range m.apiKeys {
+		if row.KeyHash == k.KeyHash {
+			return fmt.Errorf("duplicate api key")
+		}
+	}
+	k.ID = m.id()
+	k.CreatedAt = time.Now()
+	stored := *k
+	m.apiKeys = append(m.apiKeys, &stored)
+	return nil
+}
+
+// GetAPIKeyByHash returns sql.ErrNoRows for unknown keys
+func (m *MemoryStore) GetAPIKeyByHash(hash string) (*APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, k := range m.apiKeys {
+		if k.KeyHash == hash {
+			out := *k
+			return &out, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// ListAPIKeys returns every key, revoked ones included, in creation order
+func (m *MemoryStore) ListAPIKeys() ([]*APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]*APIKey, 0, len(m.apiKeys))
+	for _, k := range m.apiKeys {
+		out := *k
+		keys = append(keys, &out)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey disables a key for good
+func (m *MemoryStore) RevokeAPIKey(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, k := range m.apiKeys {
+		if k.ID == id {
+			if k.RevokedAt == nil {
+				now := time.Now().UTC()
+				k.RevokedAt = &now
+			}
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// TouchAPIKey records when a key was last used
+func (m *MemoryStore) TouchAPIKey(id int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, k := range m.apiKeys {
+		if k.ID == id {
+			k.LastUsedAt = &at
+		}
+	}
+	return nil
+}
+
+// InsertAuditEntry appends to the audit log and sets e.ID and e.CreatedAt
+func (m *MemoryStore) InsertAuditEntry(e *AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.ID = m.id()
+	e.CreatedAt = time.Now()
+	stored := *e
+	m.audit = append(m.audit, &stored)
+	return nil
+}
+
+// GetAuditLog returns the latest audit entries, newest first, optionally only those of one key
+func (m *MemoryStore) GetAuditLog(apiKeyID, limit int) ([]*AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []*AuditEntry
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		if e := m.audit[i]; apiKeyID == 0 || e.APIKeyID == apiKeyID {
+			out := *e
+			entries = append(entries, &out)
+		}
+	}
+	return entries, nil
+}
+
+// CreateNonce stores a sign-in nonce and drops the ones that expired
+func (m *MemoryStore) CreateNonce(nonce string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for n, row := range m.nonces {
+		if !now.Before(row.expiresAt) {
+			delete(m.nonces, n)
+		}
+	}
+	if _, ok := m.nonces[nonce]; ok {
+		return fmt.Errorf("duplicate nonce")
+	}
+	m.nonces[nonce] = &siweNonce{expiresAt: expiresAt}
+	return nil
+}
+
+// ConsumeNonce marks a nonce used, or returns sql.ErrNoRows if it is unknown, used or expired
+func (m *MemoryStore) ConsumeNonce(nonce string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.nonces[nonce]
+	if !ok || row.used || !now.Before(row.expiresAt) {
+		return sql.ErrNoRows
+	}
+	row.used = true
+	return nil
+}
+
+// CreateWalletSession stores a session, sets s.ID and s.CreatedAt, and drops the ones that expired
+func (m *MemoryStore) CreateWalletSession(s *WalletSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	kept := m.sessions[:0]
+	for _, row := range m.sessions {
+		if now.Before(row.ExpiresAt) {
+			kept = append(kept, row)
+		}
+	}
+	m.sessions = kept
+
+	s.ID = m.id()
+	s.CreatedAt = now
+	stored := *s
+	m.sessions = append(m.sessions, &stored)
+	return nil
+}
+
+// GetWalletSessionByHash returns sql.ErrNoRows for unknown tokens
+func (m *MemoryStore) GetWalletSessionByHash(hash string) (*WalletSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sessions {
+		if s.TokenHash == hash {
+			out := *s
+			return &out, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// RevokeWalletSession ends a session before it expires
+func (m *MemoryStore) RevokeWalletSession(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sessions {
+		if s.ID == id {
+			if s.RevokedAt == nil {
+				now := time.Now().UTC()
+				s.RevokedAt = &now
+			}
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
@@ -0,0 +1,342 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the versioned schema per dialect,
+// migrations/<dialect>/NNNN_name.up.sql / NNNN_name.down.sql.
+// Both dialects carry the same versions so a schema change is made to each.
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID serializes concurrent `migrate` runs (pg_advisory_xact_lock key)
+const migrationLockID = 7_310_031
+
+// Migration is one embedded schema version
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up, recorded when applied
+}
+
+// MigrationStatus is a migration together with its state in schema_migrations
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // applied with a different checksum than the embedded file
+}
+
+// schemaProbes lists the columns the queries in this package read from each table.
+// CheckSchema selects them with LIMIT 0 so a database that drifted from the
+// migrations fails at startup instead of on the first request that touches it.
+var schemaProbes = map[string]string{
+	"prices":             "id, timestamp, symbol, open, high, low, close, volume",
+	"spikes":             "id, timestamp, symbol, price_id, open, high, low, close, body_ratio, range_close_percent, detected_at, updated_at, voided_at",
+	"policies":           policyColumns,
+	"payouts":            "id, policy_id, user_address, CAST(amount AS TEXT), token_decimals, spike_id, tx_hash, executed_at",
+	"balances":           balanceColumns,
+	"sync_state":         "contract_address, last_synced_block, updated_at",
+	"chain_events":       "id, contract_address, event_name, block_number, block_hash, block_time, tx_hash, log_index, args, created_at, projected_at",
+	"pool_params":        "id, contract_address, oracle, premium_amount, coverage_amount, coverage_duration, source, block_number, tx_hash, log_index, recorded_at",
+	"price_rollups":      "id, symbol, interval_seconds, bucket, open, high, low, close, volume, candle_count",
+	"replay_sessions":    "id, symbol, source, status, error, created_at, finished_at",
+	"replay_prices":      "id, session_id, timestamp, symbol, open, high, low, close, volume",
+	"replay_spikes":      "id, session_id, timestamp, symbol, price_id, open, high, low, close, body_ratio, range_close_percent, detected_at, updated_at, voided_at",
+	"replay_payouts":     "id, session_id, spike_id, policy_id, user_address, CAST(amount AS TEXT), token_decimals, simulated_at",
+	"quarantined_prices": "id, session_id, timestamp, symbol, open, high, low, close, volume, reason, quarantined_at",
+	"webhooks":           "id, url, secret, events, description, active, owner_key_id, created_at",
+	"webhook_deliveries": "id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at",
+	"api_keys":           apiKeyColumns,
+	"audit_log":          "id, api_key_id, actor, role, method, path, status, client_ip, created_at",
+	"siwe_nonces":        "nonce, expires_at, used_at",
+	"wallet_sessions":    "id, token_hash, address, chain_id, expires_at, created_at, revoked_at",
+	"balance_checks":     "id, token_address, user_address, block_number, indexed_balance, chain_balance, drift, checked_at",
+}
+
+// LoadMigrations returns the embedded migrations of a dialect ordered by version
+func LoadMigrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, label, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.%s.sql", name, direction)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", name, err)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration version %d used by both %s and %s", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+			sum := sha256.Sum256(body)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureMigrationsTable creates the bookkeeping table if needed
+func (st *SQLStore) ensureMigrationsTable() error {
+	_, err := st.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// getAppliedMigrations reads schema_migrations keyed by version
+func getAppliedMigrations(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}) (map[int]appliedMigration, error) {
+	rows, err := q.Query(`SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// GetMigrationStatus reports every embedded migration and whether it has been applied.
+// Versions recorded in the database but missing from the binary are returned as an error.
+func (st *SQLStore) GetMigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(st.dialect)
+	if err != nil {
+		return nil, err
+	}
+	if err := st.ensureMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied, err := getAppliedMigrations(st.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		ms := MigrationStatus{Migration: m}
+		if a, ok := applied[m.Version]; ok {
+			ms.Applied = true
+			ms.AppliedAt = a.appliedAt
+			ms.Modified = a.checksum != m.Checksum
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, ms)
+	}
+
+	if len(applied) > 0 {
+		var unknown []string
+		for version, a := range applied {
+			unknown = append(unknown, fmt.Sprintf("%04d_%s", version, a.name))
+		}
+		sort.Strings(unknown)
+		return statuses, fmt.Errorf("database has migrations this binary does not know: %s", strings.Join(unknown, ", "))
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies all pending migrations in order, each in its own transaction.
+// Returns the number of migrations applied.
+func (st *SQLStore) MigrateUp() (int, error) {
+	statuses, err := st.GetMigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, ms := range statuses {
+		if ms.Applied {
+			continue
+		}
+		applied, err := st.runMigration(ms.Migration, true)
+		if err != nil {
+			return count, fmt.Errorf("migration %04d_%s failed: %w", ms.Version, ms.Name, err)
+		}
+		if applied {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// MigrateDown reverts the latest `steps` applied migrations.
+// Returns the number of migrations reverted.
+func (st *SQLStore) MigrateDown(steps int) (int, error) {
+	statuses, err := st.GetMigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(statuses) - 1; i >= 0 && count < steps; i-- {
+		ms := statuses[i]
+		if !ms.Applied {
+			continue
+		}
+		if ms.Down == "" {
+			return count, fmt.Errorf("migration %04d_%s has no down file", ms.Version, ms.Name)
+		}
+		if _, err := st.runMigration(ms.Migration, false); err != nil {
+			return count, fmt.Errorf("reverting %04d_%s failed: %w", ms.Version, ms.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// runMigration applies (up) or reverts (down) a single migration and records it in
+// schema_migrations within one transaction. Returns false if another process
+// already applied it while we waited for the lock.
+func (st *SQLStore) runMigration(m Migration, up bool) (bool, error) {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// SQLite locks the whole database for the first write of the transaction instead
+	if st.dialect == Postgres {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+			return false, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+	}
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err := tx.Exec(m.Up); err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+			m.Version, m.Name, m.Checksum); err != nil {
+			return false, err
+		}
+	} else {
+		if _, err := tx.Exec(m.Down); err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// CheckSchema verifies that every embedded migration has been applied unmodified
+// and that the columns this package queries exist
+func (st *SQLStore) CheckSchema() error {
+	statuses, err := st.GetMigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	var pending, modified []string
+	for _, ms := range statuses {
+		label := fmt.Sprintf("%04d_%s", ms.Version, ms.Name)
+		if !ms.Applied {
+			pending = append(pending, label)
+		} else if ms.Modified {
+			modified = append(modified, label)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("applied migrations differ from the embedded files: %s", strings.Join(modified, ", "))
+	}
+
+	tables := make([]string, 0, len(schemaProbes))
+	for table := range schemaProbes {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		rows, err := st.db.Query(fmt.Sprintf("SELECT %s FROM %s LIMIT 0", schemaProbes[table], table))
+		if err != nil {
+			return fmt.Errorf("table %s does not match the expected schema: %w", table, err)
+		}
+		rows.Close()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens an empty SQLite database file that is closed when the test ends
+func openSQLite(t *testing.T) (*SQLStore, *sql.DB) {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "spikeshield.db") + "?_time_format=sqlite"
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+	return NewSQLStore(conn, SQLite, dsn), conn
+}
+
+// TestMigrateFromSchemaSQL upgrades a database created by the old db/schema.sql, with rows
+// in it, to the latest version, then reverts every migration and applies them again
+func TestMigrateFromSchemaSQL(t *testing.T) {
+	st, conn := openSQLite(t)
+
+	migrations, err := LoadMigrations(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(migrations[0].Up); err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`
+		INSERT INTO policies (user_address, premium, coverage_amount, purchase_time, expiry_time, status, tx_hash)
+		VALUES ('0xA11C', 10, 100, '2024-01-01 00:00:00', '2024-01-02 00:00:00', 'active', '0x01'),
+		       ('0xA11C', 10, 100.5, '2024-01-01 01:00:00', '2024-01-02 01:00:00', 'claimed', '0x02');
+		INSERT INTO payouts (policy_id, user_address, amount, spike_id, tx_hash) VALUES (2, '0xA11C', 100.5, 1, '0x03');
+		INSERT INTO balances (token_address, user_address, balance) VALUES ('0x70CE', '0xA11C', 12.345678);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := st.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", applied, len(migrations))
+	}
+	if err := st.CheckSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []string{"100000000", "100500000"} {
+		p, err := st.GetPolicyByOnchainID("0xA11C", int64(i))
+		if err != nil {
+			t.Fatalf("policy %d: %v", i, err)
+		}
+		if p.CoverageAmount.Raw.String() != want || p.CoverageAmount.Decimals != 6 {
+			t.Errorf("policy %d: coverage %s (%d decimals), want %s (6)", i, p.CoverageAmount.Raw, p.CoverageAmount.Decimals, want)
+		}
+	}
+
+	var amount, balance string
+	if err := conn.QueryRow(`SELECT amount FROM payouts`).Scan(&amount); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.QueryRow(`SELECT balance FROM balances`).Scan(&balance); err != nil {
+		t.Fatal(err)
+	}
+	if amount != "100500000" || balance != "12345678" {
+		t.Errorf("payout amount %s, balance %s, want 100500000, 12345678", amount, balance)
+	}
+
+	reverted, err := st.MigrateDown(len(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted != len(migrations) {
+		t.Errorf("reverted %d migrations, want %d", reverted, len(migrations))
+	}
+	if _, err := st.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CheckSchema(); err != nil {
+		t.Fatal(err)
+	}
+}
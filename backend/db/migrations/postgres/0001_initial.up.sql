@@ -1,3 +1,6 @@
+-- 0001_initial: db/schema.sql as it was before migrations existed, unchanged, so databases
+-- created from it adopt this version cleanly and are upgraded by the migrations after it.
+
 -- Table: prices - stores price data from CSV or Oracle
 CREATE TABLE IF NOT EXISTS prices (
     id SERIAL PRIMARY KEY,
@@ -0,0 +1,6 @@
+ALTER TABLE policies DROP CONSTRAINT IF EXISTS policies_user_address_onchain_policy_id_key;
+ALTER TABLE policies ADD CONSTRAINT policies_user_address_purchase_time_key UNIQUE (user_address, purchase_time);
+ALTER TABLE policies ADD CONSTRAINT policies_tx_hash_key UNIQUE (tx_hash);
+ALTER TABLE policies DROP COLUMN IF EXISTS log_index;
+ALTER TABLE policies DROP COLUMN IF EXISTS block_number;
+ALTER TABLE policies DROP COLUMN IF EXISTS onchain_policy_id;
@@ -0,0 +1,23 @@
+-- 0002_policy_identity: a policy row maps one-to-one to
+-- userPolicies[user_address][onchain_policy_id] in InsurancePool, instead of being keyed by
+-- purchase time. Existing rows are numbered per user in purchase order, which is the order
+-- the contract appends them in; the next sync or `rebuild-projections` overwrites them.
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS onchain_policy_id BIGINT;
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS block_number BIGINT;
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS log_index INTEGER;
+
+UPDATE policies p
+SET onchain_policy_id = n.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY user_address ORDER BY purchase_time, id) - 1 AS position
+    FROM policies
+) n
+WHERE p.id = n.id AND p.onchain_policy_id IS NULL;
+
+ALTER TABLE policies ALTER COLUMN onchain_policy_id SET NOT NULL;
+
+-- tx_hash is NULL when the row was created by an on-chain sync rather than an event,
+-- and one transaction may buy several policies
+ALTER TABLE policies DROP CONSTRAINT IF EXISTS policies_tx_hash_key;
+ALTER TABLE policies DROP CONSTRAINT IF EXISTS policies_user_address_purchase_time_key;
+ALTER TABLE policies ADD CONSTRAINT policies_user_address_onchain_policy_id_key UNIQUE (user_address, onchain_policy_id);
@@ -0,0 +1,19 @@
+-- 0004_pool_params: history of InsurancePool oracle and policy parameters.
+-- Rows come from OracleUpdated events (tx_hash/log_index set) or from polling the
+-- contract getters, since setPolicyParams emits no event. Amounts are token base units.
+CREATE TABLE IF NOT EXISTS pool_params (
+    id SERIAL PRIMARY KEY,
+    contract_address VARCHAR(42) NOT NULL,
+    oracle VARCHAR(42) NOT NULL,
+    premium_amount NUMERIC(78, 0),
+    coverage_amount NUMERIC(78, 0),
+    coverage_duration BIGINT, -- seconds
+    source VARCHAR(20) NOT NULL, -- OracleUpdated, poll
+    block_number BIGINT NOT NULL,
+    tx_hash VARCHAR(66),
+    log_index INTEGER,
+    recorded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(tx_hash, log_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pool_params_contract ON pool_params (contract_address, id DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS balance_checks;
+ALTER TABLE balances ALTER COLUMN balance TYPE DECIMAL(38, 18) USING balance / 1000000;
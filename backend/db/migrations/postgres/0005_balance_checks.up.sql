@@ -0,0 +1,19 @@
+-- 0005_balance_checks: balances are maintained from Transfer deltas in token base units.
+-- schema.sql cached them as decimals of a 6-decimal token (rpc.usdt_decimals defaulted
+-- to 6), so existing rows are scaled by 10^6.
+ALTER TABLE balances ALTER COLUMN balance TYPE NUMERIC(78, 0) USING ROUND(balance * 1000000);
+
+-- Table: balance_checks - sampled reconciliation of indexed balances against balanceOf.
+-- drift = chain_balance - indexed_balance, in token base units.
+CREATE TABLE IF NOT EXISTS balance_checks (
+    id SERIAL PRIMARY KEY,
+    token_address VARCHAR(42) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    block_number BIGINT NOT NULL,
+    indexed_balance NUMERIC(78, 0) NOT NULL,
+    chain_balance NUMERIC(78, 0) NOT NULL,
+    drift NUMERIC(78, 0) NOT NULL,
+    checked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_checks_drift ON balance_checks (checked_at DESC) WHERE drift <> 0;
@@ -0,0 +1,6 @@
+ALTER TABLE policies ALTER COLUMN premium TYPE DECIMAL(20, 8) USING premium / POWER(10::NUMERIC, token_decimals);
+ALTER TABLE policies ALTER COLUMN coverage_amount TYPE DECIMAL(20, 8) USING coverage_amount / POWER(10::NUMERIC, token_decimals);
+ALTER TABLE payouts ALTER COLUMN amount TYPE DECIMAL(20, 8) USING amount / POWER(10::NUMERIC, token_decimals);
+ALTER TABLE balances DROP COLUMN IF EXISTS token_decimals;
+ALTER TABLE payouts DROP COLUMN IF EXISTS token_decimals;
+ALTER TABLE policies DROP COLUMN IF EXISTS token_decimals;
@@ -0,0 +1,13 @@
+-- 0006_exact_amounts: policy and payout amounts are exact integers in token base units,
+-- with the token's decimals stored next to them. schema.sql stored them as decimals of
+-- USDT (6 decimals), so existing rows are scaled by 10^6.
+ALTER TABLE policies ALTER COLUMN premium TYPE NUMERIC(78, 0) USING ROUND(premium * 1000000);
+ALTER TABLE policies ALTER COLUMN coverage_amount TYPE NUMERIC(78, 0) USING ROUND(coverage_amount * 1000000);
+ALTER TABLE payouts ALTER COLUMN amount TYPE NUMERIC(78, 0) USING ROUND(amount * 1000000);
+
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS token_decimals SMALLINT NOT NULL DEFAULT 6;
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS token_decimals SMALLINT NOT NULL DEFAULT 6;
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS token_decimals SMALLINT NOT NULL DEFAULT 6;
+ALTER TABLE policies ALTER COLUMN token_decimals DROP DEFAULT;
+ALTER TABLE payouts ALTER COLUMN token_decimals DROP DEFAULT;
+ALTER TABLE balances ALTER COLUMN token_decimals DROP DEFAULT;
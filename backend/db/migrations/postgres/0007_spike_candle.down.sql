@@ -0,0 +1,4 @@
+ALTER TABLE spikes DROP COLUMN IF EXISTS open;
+ALTER TABLE spikes DROP COLUMN IF EXISTS high;
+ALTER TABLE spikes DROP COLUMN IF EXISTS low;
+ALTER TABLE spikes DROP COLUMN IF EXISTS close;
@@ -0,0 +1,11 @@
+-- 0007_spike_candle: spikes keep the OHLC of the candle they were detected on,
+-- so a spike still reads correctly after its prices row is updated.
+ALTER TABLE spikes ADD COLUMN IF NOT EXISTS open DECIMAL(20, 8);
+ALTER TABLE spikes ADD COLUMN IF NOT EXISTS high DECIMAL(20, 8);
+ALTER TABLE spikes ADD COLUMN IF NOT EXISTS low DECIMAL(20, 8);
+ALTER TABLE spikes ADD COLUMN IF NOT EXISTS close DECIMAL(20, 8);
+
+UPDATE spikes s
+SET open = p.open, high = p.high, low = p.low, close = p.close
+FROM prices p
+WHERE s.price_id = p.id AND s.close IS NULL;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS prices_notify ON prices;
+DROP FUNCTION IF EXISTS notify_price_insert();
@@ -0,0 +1,14 @@
+-- 0008_price_notify: announce every written candle on the price_inserts channel so
+-- detection sees rows from any writer (other backends, external loaders, psql).
+-- Payload: {"id": <prices.id>, "symbol": "<symbol>", "op": "INSERT" | "UPDATE"}
+CREATE OR REPLACE FUNCTION notify_price_insert() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('price_inserts', json_build_object('id', NEW.id, 'symbol', NEW.symbol, 'op', TG_OP)::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prices_notify ON prices;
+CREATE TRIGGER prices_notify
+    AFTER INSERT OR UPDATE ON prices
+    FOR EACH ROW EXECUTE FUNCTION notify_price_insert();
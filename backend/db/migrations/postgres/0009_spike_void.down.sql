@@ -0,0 +1,2 @@
+ALTER TABLE spikes DROP COLUMN IF EXISTS voided_at;
+ALTER TABLE spikes DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_prices_timestamp;
+DROP TABLE IF EXISTS price_rollups;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS replay_payouts;
+DROP TABLE IF EXISTS replay_spikes;
+DROP TABLE IF EXISTS replay_prices;
+DROP TABLE IF EXISTS replay_sessions;
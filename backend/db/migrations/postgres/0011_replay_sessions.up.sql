@@ -0,0 +1,56 @@
+-- 0011_replay_sessions: replays write to their own tables, keyed by session, so replaying a
+-- CSV never touches production prices, spikes or payouts. Payouts are only simulated.
+CREATE TABLE IF NOT EXISTS replay_sessions (
+    id VARCHAR(32) PRIMARY KEY,
+    symbol VARCHAR(20) NOT NULL,
+    source VARCHAR(255) NOT NULL, -- CSV the session replays
+    status VARCHAR(20) NOT NULL DEFAULT 'created', -- created, running, finished, failed
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS replay_prices (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(32) NOT NULL REFERENCES replay_sessions (id),
+    timestamp TIMESTAMP NOT NULL,
+    symbol VARCHAR(20) NOT NULL,
+    open DECIMAL(20, 8),
+    high DECIMAL(20, 8),
+    low DECIMAL(20, 8),
+    close DECIMAL(20, 8) NOT NULL,
+    volume DECIMAL(20, 8),
+    UNIQUE(session_id, symbol, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS replay_spikes (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(32) NOT NULL REFERENCES replay_sessions (id),
+    timestamp TIMESTAMP NOT NULL,
+    symbol VARCHAR(20) NOT NULL,
+    price_id INTEGER NOT NULL UNIQUE, -- replay_prices.id
+    open DECIMAL(20, 8),
+    high DECIMAL(20, 8),
+    low DECIMAL(20, 8),
+    close DECIMAL(20, 8),
+    body_ratio DECIMAL(5, 4) NOT NULL,
+    range_close_percent DECIMAL(5, 4) NOT NULL,
+    detected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP,
+    voided_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_replay_spikes_session ON replay_spikes (session_id);
+
+-- Table: replay_payouts - what ExecutePayout would have paid; a policy pays once per session
+CREATE TABLE IF NOT EXISTS replay_payouts (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(32) NOT NULL REFERENCES replay_sessions (id),
+    spike_id INTEGER NOT NULL, -- replay_spikes.id
+    policy_id INTEGER NOT NULL, -- policies.id
+    user_address VARCHAR(42) NOT NULL,
+    amount NUMERIC(78, 0) NOT NULL, -- token base units
+    token_decimals SMALLINT NOT NULL,
+    simulated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(session_id, policy_id)
+);
@@ -0,0 +1,31 @@
+-- 0013_webhooks: partner endpoints notified of spikes and payouts. Every event matching an
+-- endpoint's filter becomes a row in webhook_deliveries, which doubles as the delivery log:
+-- it is retried with exponential backoff until it succeeds or is dead-lettered.
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(128) NOT NULL, -- HMAC-SHA256 key of the X-SpikeShield-Signature header
+    events TEXT NOT NULL DEFAULT '*', -- comma-separated event types, '*' for all
+    description TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id VARCHAR(128) NOT NULL, -- stable per event, e.g. spike.detected:42, for receiver idempotency
+    event_type VARCHAR(50) NOT NULL,
+    payload TEXT NOT NULL, -- the exact signed body
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, succeeded, dead
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+-- The dispatcher polls for due pending deliveries; the log is read per endpoint
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
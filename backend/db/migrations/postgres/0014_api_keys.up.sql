@@ -0,0 +1,30 @@
+-- 0014_api_keys: API keys with a role each (partner, operator, admin; anonymous callers
+-- are public). Only the SHA-256 of a key is stored; prefix identifies it in listings
+-- and logs. Privileged calls are recorded in audit_log, with denied attempts.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    role VARCHAR(20) NOT NULL, -- partner, operator, admin
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE, -- hex SHA-256 of the key
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id SERIAL PRIMARY KEY,
+    api_key_id INTEGER, -- NULL for anonymous callers and the configured admin token
+    actor VARCHAR(100) NOT NULL, -- key name, "admin-token" or "anonymous"
+    role VARCHAR(20) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at);
+
+-- Endpoints registered by a partner key are only visible to that key (NULL: admin-owned)
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS owner_key_id INTEGER;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_chain_events_unprojected;
+ALTER TABLE chain_events DROP COLUMN IF EXISTS projected_at;
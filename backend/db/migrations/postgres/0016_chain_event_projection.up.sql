@@ -0,0 +1,6 @@
+-- 0016_chain_event_projection: projected_at is set once an event has been applied to the
+-- projections, so events whose projection failed are retried from the journal instead of
+-- being skipped as already seen. Events journaled before this migration were projected.
+ALTER TABLE chain_events ADD COLUMN IF NOT EXISTS projected_at TIMESTAMP;
+UPDATE chain_events SET projected_at = created_at;
+CREATE INDEX IF NOT EXISTS idx_chain_events_unprojected ON chain_events (block_number, log_index) WHERE projected_at IS NULL;
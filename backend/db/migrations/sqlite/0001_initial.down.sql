@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS sync_state;
+DROP TABLE IF EXISTS balances;
+DROP TABLE IF EXISTS payouts;
+DROP TABLE IF EXISTS policies;
+DROP TABLE IF EXISTS spikes;
+DROP TABLE IF EXISTS prices;
@@ -0,0 +1,71 @@
+-- 0001_initial: the tables of migrations/postgres/0001_initial.up.sql (db/schema.sql as it
+-- was before migrations existed) for SQLite. Timestamps are stored as UTC text, which
+-- orders correctly as strings.
+
+-- Table: prices - stores price data from CSV or Oracle
+CREATE TABLE IF NOT EXISTS prices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp TIMESTAMP NOT NULL,
+    symbol VARCHAR(20) NOT NULL,
+    open REAL,
+    high REAL,
+    low REAL,
+    close REAL NOT NULL,
+    volume REAL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(symbol, timestamp)
+);
+
+-- Table: spikes - records detected price spikes
+CREATE TABLE IF NOT EXISTS spikes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp TIMESTAMP NOT NULL,
+    symbol VARCHAR(20) NOT NULL,
+    price_id INTEGER UNIQUE,
+    body_ratio REAL NOT NULL,
+    range_close_percent REAL NOT NULL,
+    detected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table: policies - stores user insurance policies
+CREATE TABLE IF NOT EXISTS policies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_address VARCHAR(42) NOT NULL,
+    premium REAL NOT NULL,
+    coverage_amount REAL NOT NULL,
+    purchase_time TIMESTAMP NOT NULL,
+    expiry_time TIMESTAMP NOT NULL,
+    status VARCHAR(20) DEFAULT 'active', -- active, expired, claimed
+    tx_hash VARCHAR(66) UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_address, purchase_time)
+);
+
+-- Table: payouts - logs payout executions
+CREATE TABLE IF NOT EXISTS payouts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    policy_id INTEGER UNIQUE,
+    user_address VARCHAR(42) NOT NULL,
+    amount REAL NOT NULL,
+    spike_id INTEGER,
+    tx_hash VARCHAR(66) UNIQUE,
+    executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table: balances - caches ERC20 token balances per address
+CREATE TABLE IF NOT EXISTS balances (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_address VARCHAR(42) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    balance REAL NOT NULL,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(token_address, user_address)
+);
+
+-- Table: sync_state - tracks last synced block for event listener
+CREATE TABLE IF NOT EXISTS sync_state (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    contract_address VARCHAR(42) NOT NULL UNIQUE,
+    last_synced_block BIGINT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
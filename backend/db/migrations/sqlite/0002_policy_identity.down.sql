@@ -0,0 +1,19 @@
+CREATE TABLE policies_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_address VARCHAR(42) NOT NULL,
+    premium REAL NOT NULL,
+    coverage_amount REAL NOT NULL,
+    purchase_time TIMESTAMP NOT NULL,
+    expiry_time TIMESTAMP NOT NULL,
+    status VARCHAR(20) DEFAULT 'active', -- active, expired, claimed
+    tx_hash VARCHAR(66) UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_address, purchase_time)
+);
+
+INSERT INTO policies_old (id, user_address, premium, coverage_amount, purchase_time, expiry_time, status, tx_hash, created_at)
+SELECT id, user_address, premium, coverage_amount, purchase_time, expiry_time, status, tx_hash, created_at
+FROM policies;
+
+DROP TABLE policies;
+ALTER TABLE policies_old RENAME TO policies;
@@ -0,0 +1,25 @@
+-- 0002_policy_identity: see migrations/postgres/0002_policy_identity.up.sql. SQLite cannot
+-- drop the old unique constraints, so the table is rebuilt.
+CREATE TABLE policies_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_address VARCHAR(42) NOT NULL,
+    onchain_policy_id BIGINT NOT NULL,
+    premium REAL NOT NULL,
+    coverage_amount REAL NOT NULL,
+    purchase_time TIMESTAMP NOT NULL, -- block timestamp of the purchase
+    expiry_time TIMESTAMP NOT NULL,
+    status VARCHAR(20) DEFAULT 'active', -- active, expired, claimed
+    tx_hash VARCHAR(66), -- NULL when the row was created by an on-chain sync rather than an event
+    block_number BIGINT,
+    log_index INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_address, onchain_policy_id)
+);
+
+INSERT INTO policies_new (id, user_address, onchain_policy_id, premium, coverage_amount, purchase_time, expiry_time, status, tx_hash, created_at)
+SELECT id, user_address, ROW_NUMBER() OVER (PARTITION BY user_address ORDER BY purchase_time, id) - 1,
+       premium, coverage_amount, purchase_time, expiry_time, status, tx_hash, created_at
+FROM policies;
+
+DROP TABLE policies;
+ALTER TABLE policies_new RENAME TO policies;
@@ -0,0 +1,17 @@
+-- 0003_chain_events: append-only journal of every decoded contract log.
+-- policies, payouts and balances are projections of this table (see `rebuild-projections`).
+CREATE TABLE IF NOT EXISTS chain_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    contract_address VARCHAR(42) NOT NULL,
+    event_name VARCHAR(64) NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    block_time TIMESTAMP, -- NULL for token logs, whose projection does not need it
+    tx_hash VARCHAR(66) NOT NULL,
+    log_index INTEGER NOT NULL,
+    args TEXT NOT NULL, -- decoded event arguments as JSON; integers as decimal strings, addresses as hex
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(tx_hash, log_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chain_events_position ON chain_events (block_number, log_index);
@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS balance_checks;
+
+CREATE TABLE balances_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_address VARCHAR(42) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    balance REAL NOT NULL,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(token_address, user_address)
+);
+
+INSERT INTO balances_old (id, token_address, user_address, balance, last_updated)
+SELECT id, token_address, user_address, CAST(balance AS REAL) / 1000000, last_updated
+FROM balances;
+
+DROP TABLE balances;
+ALTER TABLE balances_old RENAME TO balances;
@@ -0,0 +1,33 @@
+-- 0005_balance_checks: see migrations/postgres/0005_balance_checks.up.sql. Token amounts
+-- are TEXT holding decimal integers, because SQLite's NUMERIC affinity would round
+-- anything beyond 64 bits; arithmetic on them is done in Go.
+CREATE TABLE balances_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_address VARCHAR(42) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    balance TEXT NOT NULL, -- token base units
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(token_address, user_address)
+);
+
+INSERT INTO balances_new (id, token_address, user_address, balance, last_updated)
+SELECT id, token_address, user_address, CAST(CAST(ROUND(balance * 1000000) AS INTEGER) AS TEXT), last_updated
+FROM balances;
+
+DROP TABLE balances;
+ALTER TABLE balances_new RENAME TO balances;
+
+-- Table: balance_checks - sampled reconciliation of indexed balances against balanceOf.
+-- drift = chain_balance - indexed_balance, in token base units.
+CREATE TABLE IF NOT EXISTS balance_checks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_address VARCHAR(42) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    block_number BIGINT NOT NULL,
+    indexed_balance TEXT NOT NULL,
+    chain_balance TEXT NOT NULL,
+    drift TEXT NOT NULL,
+    checked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_checks_drift ON balance_checks (checked_at DESC) WHERE drift <> '0';
@@ -0,0 +1,44 @@
+ALTER TABLE balances DROP COLUMN token_decimals;
+
+CREATE TABLE payouts_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    policy_id INTEGER UNIQUE,
+    user_address VARCHAR(42) NOT NULL,
+    amount REAL NOT NULL,
+    spike_id INTEGER,
+    tx_hash VARCHAR(66) UNIQUE,
+    executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO payouts_old (id, policy_id, user_address, amount, spike_id, tx_hash, executed_at)
+SELECT id, policy_id, user_address, CAST(amount AS REAL) / POWER(10, token_decimals), spike_id, tx_hash, executed_at
+FROM payouts;
+
+DROP TABLE payouts;
+ALTER TABLE payouts_old RENAME TO payouts;
+
+CREATE TABLE policies_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_address VARCHAR(42) NOT NULL,
+    onchain_policy_id BIGINT NOT NULL,
+    premium REAL NOT NULL,
+    coverage_amount REAL NOT NULL,
+    purchase_time TIMESTAMP NOT NULL, -- block timestamp of the purchase
+    expiry_time TIMESTAMP NOT NULL,
+    status VARCHAR(20) DEFAULT 'active', -- active, expired, claimed
+    tx_hash VARCHAR(66), -- NULL when the row was created by an on-chain sync rather than an event
+    block_number BIGINT,
+    log_index INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_address, onchain_policy_id)
+);
+
+INSERT INTO policies_old (id, user_address, onchain_policy_id, premium, coverage_amount,
+    purchase_time, expiry_time, status, tx_hash, block_number, log_index, created_at)
+SELECT id, user_address, onchain_policy_id,
+       CAST(premium AS REAL) / POWER(10, token_decimals), CAST(coverage_amount AS REAL) / POWER(10, token_decimals),
+       purchase_time, expiry_time, status, tx_hash, block_number, log_index, created_at
+FROM policies;
+
+DROP TABLE policies;
+ALTER TABLE policies_old RENAME TO policies;
@@ -0,0 +1,48 @@
+-- 0006_exact_amounts: see migrations/postgres/0006_exact_amounts.up.sql. The amount
+-- columns change from REAL to TEXT holding decimal integers, so the tables are rebuilt.
+CREATE TABLE policies_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_address VARCHAR(42) NOT NULL,
+    onchain_policy_id BIGINT NOT NULL,
+    premium TEXT NOT NULL, -- token base units
+    coverage_amount TEXT NOT NULL, -- token base units
+    token_decimals SMALLINT NOT NULL,
+    purchase_time TIMESTAMP NOT NULL, -- block timestamp of the purchase
+    expiry_time TIMESTAMP NOT NULL,
+    status VARCHAR(20) DEFAULT 'active', -- active, expired, claimed
+    tx_hash VARCHAR(66), -- NULL when the row was created by an on-chain sync rather than an event
+    block_number BIGINT,
+    log_index INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_address, onchain_policy_id)
+);
+
+INSERT INTO policies_new (id, user_address, onchain_policy_id, premium, coverage_amount, token_decimals,
+    purchase_time, expiry_time, status, tx_hash, block_number, log_index, created_at)
+SELECT id, user_address, onchain_policy_id,
+       CAST(CAST(ROUND(premium * 1000000) AS INTEGER) AS TEXT), CAST(CAST(ROUND(coverage_amount * 1000000) AS INTEGER) AS TEXT), 6,
+       purchase_time, expiry_time, status, tx_hash, block_number, log_index, created_at
+FROM policies;
+
+DROP TABLE policies;
+ALTER TABLE policies_new RENAME TO policies;
+
+CREATE TABLE payouts_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    policy_id INTEGER UNIQUE,
+    user_address VARCHAR(42) NOT NULL,
+    amount TEXT NOT NULL, -- token base units
+    token_decimals SMALLINT NOT NULL,
+    spike_id INTEGER,
+    tx_hash VARCHAR(66) UNIQUE,
+    executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO payouts_new (id, policy_id, user_address, amount, token_decimals, spike_id, tx_hash, executed_at)
+SELECT id, policy_id, user_address, CAST(CAST(ROUND(amount * 1000000) AS INTEGER) AS TEXT), 6, spike_id, tx_hash, executed_at
+FROM payouts;
+
+DROP TABLE payouts;
+ALTER TABLE payouts_new RENAME TO payouts;
+
+ALTER TABLE balances ADD COLUMN token_decimals SMALLINT NOT NULL DEFAULT 6;
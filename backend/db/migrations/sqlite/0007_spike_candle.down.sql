@@ -0,0 +1,4 @@
+ALTER TABLE spikes DROP COLUMN open;
+ALTER TABLE spikes DROP COLUMN high;
+ALTER TABLE spikes DROP COLUMN low;
+ALTER TABLE spikes DROP COLUMN close;
@@ -0,0 +1,11 @@
+-- 0007_spike_candle: spikes keep the OHLC of the candle they were detected on,
+-- so a spike still reads correctly after its prices row is updated.
+ALTER TABLE spikes ADD COLUMN open REAL;
+ALTER TABLE spikes ADD COLUMN high REAL;
+ALTER TABLE spikes ADD COLUMN low REAL;
+ALTER TABLE spikes ADD COLUMN close REAL;
+
+UPDATE spikes
+SET open = p.open, high = p.high, low = p.low, close = p.close
+FROM prices AS p
+WHERE spikes.price_id = p.id AND spikes.close IS NULL;
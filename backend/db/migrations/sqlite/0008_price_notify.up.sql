@@ -0,0 +1,3 @@
+-- 0008_price_notify: SQLite has no NOTIFY. Price writes are announced in-process by
+-- SQLStore.InsertPrice instead; this version exists to keep both dialects numbered alike.
+SELECT 1;
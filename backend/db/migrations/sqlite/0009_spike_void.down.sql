@@ -0,0 +1,2 @@
+ALTER TABLE spikes DROP COLUMN voided_at;
+ALTER TABLE spikes DROP COLUMN updated_at;
@@ -0,0 +1,4 @@
+-- 0009_spike_void: a spike whose candle is corrected so it no longer matches is voided
+-- rather than deleted, because payouts reference spike IDs on-chain.
+ALTER TABLE spikes ADD COLUMN updated_at TIMESTAMP;
+ALTER TABLE spikes ADD COLUMN voided_at TIMESTAMP;
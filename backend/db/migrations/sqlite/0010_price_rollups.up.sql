@@ -0,0 +1,19 @@
+-- 0010_price_rollups: candles older than the retention window are downsampled into
+-- price_rollups and removed from prices (see SQLStore.CompactPrices). Candles a spike
+-- references are never removed, so spikes stay auditable against their source candle.
+CREATE TABLE IF NOT EXISTS price_rollups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    symbol VARCHAR(20) NOT NULL,
+    interval_seconds INTEGER NOT NULL,
+    bucket TIMESTAMP NOT NULL, -- start of the interval, UTC
+    open REAL NOT NULL,
+    high REAL NOT NULL,
+    low REAL NOT NULL,
+    close REAL NOT NULL,
+    volume REAL NOT NULL,
+    candle_count INTEGER NOT NULL, -- source candles folded into this row
+    UNIQUE(symbol, interval_seconds, bucket)
+);
+
+-- Retention scans and deletes by age
+CREATE INDEX IF NOT EXISTS idx_prices_timestamp ON prices (timestamp);
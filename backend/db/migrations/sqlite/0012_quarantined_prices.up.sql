@@ -0,0 +1,19 @@
+-- 0012_quarantined_prices: candles that failed ingest validation (inverted high/low, negative
+-- volume, out-of-order timestamp, jump from the previous close). They never reach prices or
+-- replay_prices, so detection cannot turn a bad tick into a wick.
+CREATE TABLE IF NOT EXISTS quarantined_prices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id VARCHAR(32) NOT NULL DEFAULT '', -- replay session, '' for production
+    timestamp TIMESTAMP NOT NULL,
+    symbol VARCHAR(20) NOT NULL,
+    open REAL,
+    high REAL,
+    low REAL,
+    close REAL,
+    volume REAL,
+    reason TEXT NOT NULL,
+    quarantined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(session_id, symbol, timestamp)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quarantined_prices_quarantined_at ON quarantined_prices (quarantined_at);
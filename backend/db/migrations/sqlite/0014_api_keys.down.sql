@@ -0,0 +1,3 @@
+ALTER TABLE webhooks DROP COLUMN owner_key_id;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS api_keys;
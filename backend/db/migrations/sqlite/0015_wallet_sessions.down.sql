@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS wallet_sessions;
+DROP TABLE IF EXISTS siwe_nonces;
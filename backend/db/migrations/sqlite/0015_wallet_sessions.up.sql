@@ -0,0 +1,22 @@
+-- 0015_wallet_sessions: Sign-In With Ethereum (EIP-4361). A nonce is issued per sign-in
+-- and can be used once; a verified signature opens a session bound to the wallet, whose
+-- token (only its SHA-256 is stored) authorizes the wallet-scoped endpoints.
+CREATE TABLE IF NOT EXISTS siwe_nonces (
+    nonce VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS wallet_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash CHAR(64) NOT NULL UNIQUE, -- hex SHA-256 of the session token
+    address VARCHAR(42) NOT NULL, -- checksummed, as signed
+    chain_id BIGINT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- Expired nonces and sessions are pruned as new ones are issued
+CREATE INDEX IF NOT EXISTS idx_siwe_nonces_expires_at ON siwe_nonces (expires_at);
+CREATE INDEX IF NOT EXISTS idx_wallet_sessions_expires_at ON wallet_sessions (expires_at);
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"spikeshield/utils"
+
+	"github.com/lib/pq"
+)
+
+// priceChannel is the NOTIFY channel written by the prices_notify trigger
+const priceChannel = "price_inserts"
+
+// listenerPingInterval keeps an idle LISTEN connection checked, as pq recommends
+const listenerPingInterval = 90 * time.Second
+
+// SubscribePrices opens a dedicated LISTEN connection and delivers an event for every
+// candle inserted or updated by any writer. After a reconnect, candles inserted while the
+// connection was down are replayed from the last seen ID; updates in that window are lost.
+// The channel is closed when ctx is done.
+//
+// SQLite has no NOTIFY; there only writes made through this store are delivered.
+func (st *SQLStore) SubscribePrices(ctx context.Context) (<-chan PriceEvent, error) {
+	if st.dialect == SQLite {
+		return st.hub.subscribe(ctx), nil
+	}
+	listener := pq.NewListener(st.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			utils.LogError("Price listener connection event %d: %v", ev, err)
+		}
+	})
+	if err := listener.Listen(priceChannel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to LISTEN %s: %w", priceChannel, err)
+	}
+
+	// Start replay after the newest existing row so a reconnect does not resend history
+	var lastID int
+	if err := st.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM prices`).Scan(&lastID); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	events := make(chan PriceEvent, 100)
+	go func() {
+		defer close(events)
+		defer listener.Close()
+
+		send := func(ev PriceEvent) bool {
+			if ev.ID > lastID {
+				lastID = ev.ID
+			}
+			select {
+			case events <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				if n == nil {
+					// pq sends nil after re-establishing the connection
+					utils.LogInfo("Price listener reconnected, replaying inserts after ID %d", lastID)
+					missed, err := st.pricesAfter(ctx, lastID)
+					if err != nil {
+						utils.LogError("Failed to replay missed price inserts: %v", err)
+					}
+					for _, ev := range missed {
+						if !send(ev) {
+							return
+						}
+					}
+					continue
+				}
+				var ev PriceEvent
+				if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+					utils.LogError("Invalid %s payload %q: %v", priceChannel, n.Extra, err)
+					continue
+				}
+				if !send(ev) {
+					return
+				}
+			case <-time.After(listenerPingInterval):
+				if err := listener.Ping(); err != nil {
+					utils.LogError("Price listener ping failed: %v", err)
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// pricesAfter lists candles with an ID greater than afterID as INSERT events
+func (st *SQLStore) pricesAfter(ctx context.Context, afterID int) ([]PriceEvent, error) {
+	rows, err := st.db.QueryContext(ctx, `SELECT id, symbol FROM prices WHERE id > $1 ORDER BY id`, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []PriceEvent
+	for rows.Next() {
+		ev := PriceEvent{Op: "INSERT"}
+		if err := rows.Scan(&ev.ID, &ev.Symbol); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
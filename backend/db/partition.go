@@ -0,0 +1,239 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Partitioning modes for the prices table (Postgres only)
+const (
+	PartitionNative    = "native"    // declarative monthly range partitions on timestamp
+	PartitionTimescale = "timescale" // TimescaleDB hypertable
+)
+
+// partitionsAhead is how many months past the current one always have a partition,
+// so new candles never land in prices_default
+const partitionsAhead = 2
+
+// PartitionPrices converts prices into a partitioned table in one transaction. Existing
+// rows, IDs and the prices_notify trigger are carried over. Both modes widen the primary
+// key to (id, timestamp), which partitioning requires; IDs stay unique through the sequence.
+func (st *SQLStore) PartitionPrices(mode string) error {
+	if st.dialect != Postgres {
+		return fmt.Errorf("partitioning is only supported on postgres")
+	}
+	if current, err := st.pricesPartitioning(); err != nil {
+		return err
+	} else if current != "" {
+		return fmt.Errorf("prices is already partitioned (%s)", current)
+	}
+
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	switch mode {
+	case PartitionNative:
+		err = convertNative(tx)
+	case PartitionTimescale:
+		err = convertTimescale(tx)
+	default:
+		err = fmt.Errorf("unknown partitioning mode %q (expected %s or %s)", mode, PartitionNative, PartitionTimescale)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// convertNative rebuilds prices as a range-partitioned table with one partition per month
+func convertNative(tx *sql.Tx) error {
+	stmts := []string{
+		`LOCK TABLE prices IN ACCESS EXCLUSIVE MODE`,
+		`ALTER TABLE prices RENAME TO prices_unpartitioned`,
+		// Free the names the new table's constraints and indexes will take
+		`ALTER TABLE prices_unpartitioned RENAME CONSTRAINT prices_pkey TO prices_unpartitioned_pkey`,
+		`ALTER TABLE prices_unpartitioned RENAME CONSTRAINT prices_symbol_timestamp_key TO prices_unpartitioned_symbol_timestamp_key`,
+		`ALTER INDEX IF EXISTS idx_prices_timestamp RENAME TO idx_prices_unpartitioned_timestamp`,
+		// Keep the sequence when the old table is dropped
+		`ALTER SEQUENCE prices_id_seq OWNED BY NONE`,
+		`CREATE TABLE prices (
+		    id INTEGER NOT NULL DEFAULT nextval('prices_id_seq'),
+		    timestamp TIMESTAMP NOT NULL,
+		    symbol VARCHAR(20) NOT NULL,
+		    open DECIMAL(20, 8),
+		    high DECIMAL(20, 8),
+		    low DECIMAL(20, 8),
+		    close DECIMAL(20, 8) NOT NULL,
+		    volume DECIMAL(20, 8),
+		    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		    PRIMARY KEY (id, timestamp),
+		    UNIQUE(symbol, timestamp)
+		) PARTITION BY RANGE (timestamp)`,
+		`ALTER SEQUENCE prices_id_seq OWNED BY prices.id`,
+		`CREATE INDEX idx_prices_id ON prices (id)`,
+		`CREATE INDEX idx_prices_timestamp ON prices (timestamp)`,
+		`CREATE TABLE prices_default PARTITION OF prices DEFAULT`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", firstLine(stmt), err)
+		}
+	}
+
+	// One partition per month from the oldest candle through partitionsAhead months from now
+	var oldest sql.NullTime
+	if err := tx.QueryRow(`SELECT MIN(timestamp) FROM prices_unpartitioned`).Scan(&oldest); err != nil {
+		return err
+	}
+	from := time.Now().UTC()
+	if oldest.Valid && oldest.Time.Before(from) {
+		from = oldest.Time
+	}
+	if err := createMonthlyPartitions(tx, from, time.Now().UTC().AddDate(0, partitionsAhead, 0)); err != nil {
+		return err
+	}
+
+	stmts = []string{
+		`INSERT INTO prices (id, timestamp, symbol, open, high, low, close, volume, created_at)
+		 SELECT id, timestamp, symbol, open, high, low, close, volume, created_at FROM prices_unpartitioned`,
+		`DROP TABLE prices_unpartitioned`,
+		`CREATE TRIGGER prices_notify AFTER INSERT OR UPDATE ON prices
+		 FOR EACH ROW EXECUTE FUNCTION notify_price_insert()`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", firstLine(stmt), err)
+		}
+	}
+	return nil
+}
+
+// convertTimescale turns prices into a hypertable with weekly chunks, migrating existing rows
+func convertTimescale(tx *sql.Tx) error {
+	stmts := []string{
+		`CREATE EXTENSION IF NOT EXISTS timescaledb`,
+		`ALTER TABLE prices DROP CONSTRAINT prices_pkey`,
+		`ALTER TABLE prices ADD PRIMARY KEY (id, timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_prices_id ON prices (id)`,
+		`SELECT create_hypertable('prices', 'timestamp', chunk_time_interval => INTERVAL '7 days', migrate_data => true)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", firstLine(stmt), err)
+		}
+	}
+	return nil
+}
+
+// pricesPartitioning reports how prices is partitioned: native, timescale or "" for a plain table
+func (st *SQLStore) pricesPartitioning() (string, error) {
+	var native bool
+	if err := st.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = 'prices'::regclass)`).Scan(&native); err != nil {
+		return "", err
+	}
+	if native {
+		return PartitionNative, nil
+	}
+
+	var timescale bool
+	if err := st.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')`).Scan(&timescale); err != nil || !timescale {
+		return "", err
+	}
+	var hypertable bool
+	if err := st.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'prices')`).Scan(&hypertable); err != nil {
+		return "", err
+	}
+	if hypertable {
+		return PartitionTimescale, nil
+	}
+	return "", nil
+}
+
+// maintainPartitions keeps natively partitioned prices ready for new candles and drops
+// monthly partitions that end before cutoff and were emptied by compaction. Spike-referenced
+// candles keep their partition alive. Returns the number of partitions dropped.
+func (st *SQLStore) maintainPartitions(cutoff time.Time) (int, error) {
+	mode, err := st.pricesPartitioning()
+	if err != nil || mode != PartitionNative {
+		return 0, err
+	}
+
+	tx, err := st.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if err := createMonthlyPartitions(tx, now, now.AddDate(0, partitionsAhead, 0)); err != nil {
+		return 0, err
+	}
+
+	// Monthly partitions are named prices_pYYYYMM, so their end is the following month
+	rows, err := tx.Query(`SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+	                       WHERE i.inhparent = 'prices'::regclass AND c.relname LIKE 'prices_p%'`)
+	if err != nil {
+		return 0, err
+	}
+	var expired []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		start, err := time.Parse("200601", strings.TrimPrefix(name, "prices_p"))
+		if err != nil {
+			continue
+		}
+		if !start.AddDate(0, 1, 0).After(cutoff) {
+			expired = append(expired, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	dropped := 0
+	for _, name := range expired {
+		var empty bool
+		if err := tx.QueryRow(fmt.Sprintf(`SELECT NOT EXISTS (SELECT 1 FROM %s)`, name)).Scan(&empty); err != nil {
+			return dropped, err
+		}
+		if !empty {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DROP TABLE %s`, name)); err != nil {
+			return dropped, err
+		}
+		dropped++
+	}
+	return dropped, tx.Commit()
+}
+
+// createMonthlyPartitions creates the prices_pYYYYMM partitions covering [from, to]
+func createMonthlyPartitions(tx *sql.Tx, from, to time.Time) error {
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(to) {
+		next := month.AddDate(0, 1, 0)
+		stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS prices_p%s PARTITION OF prices FOR VALUES FROM ('%s') TO ('%s')`,
+			month.Format("200601"), month.Format("2006-01-02"), next.Format("2006-01-02"))
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create partition for %s: %w", month.Format("2006-01"), err)
+		}
+		month = next
+	}
+	return nil
+}
+
+// firstLine shortens a statement for error messages
+func firstLine(stmt string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(stmt), "\n")
+	return line
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql"
+	"math/big"
+	"time"
+)
+
+// PoolParams is a snapshot of the InsurancePool oracle and policy parameters
+type PoolParams struct {
+	ID               int
+	ContractAddress  string
+	Oracle           string
+	PremiumAmount    *big.Int // base units; nil if unknown
+	CoverageAmount   *big.Int // base units; nil if unknown
+	CoverageDuration int64    // seconds; 0 if unknown
+	Source           string   // "OracleUpdated" or "poll"
+	BlockNumber      uint64
+	TxHash           string
+	LogIndex         uint
+	RecordedAt       time.Time
+}
+
+// SameAs reports whether two snapshots hold the same oracle and parameters
+func (p *PoolParams) SameAs(other *PoolParams) bool {
+	return p.Oracle == other.Oracle &&
+		bigEqual(p.PremiumAmount, other.PremiumAmount) &&
+		bigEqual(p.CoverageAmount, other.CoverageAmount) &&
+		p.CoverageDuration == other.CoverageDuration
+}
+
+// InsertPoolParams appends a snapshot to the pool_params history.
+// Event-sourced rows are idempotent on (tx_hash, log_index).
+func (st *SQLStore) InsertPoolParams(p *PoolParams) error {
+	var txHash sql.NullString
+	var logIndex sql.NullInt64
+	if p.TxHash != "" {
+		txHash = sql.NullString{String: p.TxHash, Valid: true}
+		logIndex = sql.NullInt64{Int64: int64(p.LogIndex), Valid: true}
+	}
+	var duration sql.NullInt64
+	if p.CoverageDuration > 0 {
+		duration = sql.NullInt64{Int64: p.CoverageDuration, Valid: true}
+	}
+
+	query := `INSERT INTO pool_params (contract_address, oracle, premium_amount, coverage_amount, coverage_duration, source, block_number, tx_hash, log_index)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			  ON CONFLICT (tx_hash, log_index) DO NOTHING`
+	_, err := st.db.Exec(query, p.ContractAddress, p.Oracle, nullBig(p.PremiumAmount), nullBig(p.CoverageAmount), duration,
+		p.Source, p.BlockNumber, txHash, logIndex)
+	return err
+}
+
+// GetLatestPoolParams returns the most recent snapshot for a contract, or sql.ErrNoRows if none was recorded
+func (st *SQLStore) GetLatestPoolParams(contractAddr string) (*PoolParams, error) {
+	query := `SELECT id, contract_address, oracle, CAST(premium_amount AS TEXT), CAST(coverage_amount AS TEXT), COALESCE(coverage_duration, 0),
+			         source, block_number, COALESCE(tx_hash, ''), COALESCE(log_index, 0), recorded_at
+			  FROM pool_params WHERE contract_address = $1 ORDER BY id DESC LIMIT 1`
+
+	p := &PoolParams{}
+	var premium, coverage sql.NullString
+	err := st.db.QueryRow(query, contractAddr).Scan(&p.ID, &p.ContractAddress, &p.Oracle, &premium, &coverage, &p.CoverageDuration,
+		&p.Source, &p.BlockNumber, &p.TxHash, &p.LogIndex, &p.RecordedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.PremiumAmount = parseNullBig(premium)
+	p.CoverageAmount = parseNullBig(coverage)
+	return p, nil
+}
+
+// nullBig converts an optional integer to a NUMERIC query argument
+func nullBig(v *big.Int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return v.String()
+}
+
+// parseNullBig converts a scanned NUMERIC column back to an integer
+func parseNullBig(v sql.NullString) *big.Int {
+	if !v.Valid {
+		return nil
+	}
+	n, ok := new(big.Int).SetString(v.String, 10)
+	if !ok {
+		return nil
+	}
+	return n
+}
+
+// bigEqual compares two optional integers
+func bigEqual(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directions a list can be filtered by: for candles whether they closed up or down, for
+// spikes (and the payouts they triggered) which wick was longer
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// ListQuery filters, orders and pages a list. Every field is optional; a zero Limit
+// returns every matching row.
+type ListQuery struct {
+	Symbol    string
+	Direction string    // DirectionUp or DirectionDown
+	User      string    // payouts only: the insured wallet
+	From      time.Time // inclusive
+	To        time.Time // exclusive
+	Sort      string    // time column to order by; each list has a default
+	Desc      bool
+	Limit     int
+	After     *Cursor // continue after the last row of the previous page
+}
+
+// Cursor is the position of the last row of a page: its sort value and ID, which breaks ties
+type Cursor struct {
+	Sort  string    `json:"s"`
+	Value time.Time `json:"v"`
+	ID    int       `json:"i"`
+	Desc  bool      `json:"d,omitempty"`
+}
+
+// QueryError is returned for list queries the store cannot run, e.g. an unknown sort
+// column; it is the caller's mistake rather than a storage failure
+type QueryError struct {
+	Reason string
+}
+
+func (e *QueryError) Error() string { return e.Reason }
+
+// Encode returns the opaque form of the cursor given to clients
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, &QueryError{Reason: "invalid cursor"}
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.Sort == "" {
+		return nil, &QueryError{Reason: "invalid cursor"}
+	}
+	return c, nil
+}
+
+// sortColumn resolves q.Sort against the columns a list can be ordered by (the first is
+// the default) and checks the cursor was issued for the same order
+func (q *ListQuery) sortColumn(columns ...string) (string, error) {
+	column := columns[0]
+	if q.Sort != "" {
+		column = ""
+		for _, c := range columns {
+			if c == q.Sort {
+				column = c
+			}
+		}
+		if column == "" {
+			return "", &QueryError{Reason: fmt.Sprintf("cannot sort by %q (expected %s)", q.Sort, strings.Join(columns, " or "))}
+		}
+	}
+	if q.After != nil && (q.After.Sort != column || q.After.Desc != q.Desc) {
+		return "", &QueryError{Reason: "cursor belongs to a different sort order"}
+	}
+	if q.Direction != "" && q.Direction != DirectionUp && q.Direction != DirectionDown {
+		return "", &QueryError{Reason: fmt.Sprintf("invalid direction %q (expected up or down)", q.Direction)}
+	}
+	return column, nil
+}
+
+// queryBuilder collects the conditions and numbered arguments of a list query
+type queryBuilder struct {
+	where  []string
+	args   []interface{}
+	sqlite bool
+}
+
+// newQuery starts a list query for the store's dialect
+func (st *SQLStore) newQuery() *queryBuilder {
+	return &queryBuilder{sqlite: st.dialect == SQLite}
+}
+
+// time wraps a timestamp expression so it compares by instant. SQLite compares text, and
+// CURRENT_TIMESTAMP defaults are stored without the fraction and offset Go writes.
+func (b *queryBuilder) time(expr string) string {
+	if b.sqlite {
+		return "julianday(" + expr + ")"
+	}
+	return expr
+}
+
+// arg adds an argument and returns its placeholder
+func (b *queryBuilder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// cond adds a condition; %s verbs in it are replaced by placeholders of args
+func (b *queryBuilder) cond(format string, args ...interface{}) {
+	placeholders := make([]interface{}, len(args))
+	for i, a := range args {
+		placeholders[i] = b.arg(a)
+	}
+	b.where = append(b.where, fmt.Sprintf(format, placeholders...))
+}
+
+// page adds q's time window and cursor on timeColumn and sortColumn, and returns the
+// WHERE, ORDER BY and LIMIT clauses. One row more than the limit is fetched to tell
+// whether another page follows.
+func (b *queryBuilder) page(q *ListQuery, timeColumn, sortColumn, idColumn string) string {
+	if !q.From.IsZero() {
+		b.where = append(b.where, b.time(timeColumn)+" >= "+b.time(b.arg(q.From)))
+	}
+	if !q.To.IsZero() {
+		b.where = append(b.where, b.time(timeColumn)+" < "+b.time(b.arg(q.To)))
+	}
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	sortColumn = b.time(sortColumn)
+	if q.After != nil {
+		v, id := b.time(b.arg(q.After.Value)), b.arg(q.After.ID)
+		b.where = append(b.where, fmt.Sprintf("(%s %s %s OR (%s = %s AND %s %s %s))",
+			sortColumn, cmp, v, sortColumn, v, idColumn, cmp, id))
+	}
+
+	clause := ""
+	if len(b.where) > 0 {
+		clause = " WHERE " + strings.Join(b.where, " AND ")
+	}
+	clause += fmt.Sprintf(" ORDER BY %s %s, %s %s", sortColumn, dir, idColumn, dir)
+	if q.Limit > 0 {
+		clause += " LIMIT " + b.arg(q.Limit+1)
+	}
+	return clause
+}
+
+// spikeFilter restricts payouts to those whose spike_id is a spike of table matching
+// q.Symbol and q.Direction. Extra conditions (a session) go in spikeWhere.
+func (b *queryBuilder) spikeFilter(q *ListQuery, table string, spikeWhere ...string) {
+	where := spikeWhere
+	if q.Symbol != "" {
+		where = append(where, "symbol = "+b.arg(q.Symbol))
+	}
+	if q.Direction != "" {
+		where = append(where, wickDirection+" = "+b.arg(q.Direction))
+	}
+	b.where = append(b.where, "spike_id IN (SELECT id FROM "+table+" WHERE "+strings.Join(where, " AND ")+")")
+}
+
+// wickDirection is the SQL for the direction of a spike's longer wick
+const wickDirection = `CASE WHEN high - CASE WHEN open > close THEN open ELSE close END >
+	CASE WHEN open < close THEN open ELSE close END - low THEN 'up' ELSE 'down' END`
+
+// candleDirection is the SQL for whether a candle closed up or down
+const candleDirection = `CASE WHEN close >= open THEN 'up' ELSE 'down' END`
+
+// wickDirectionOf is wickDirection for a candle in memory
+func wickDirectionOf(open, high, low, close float64) string {
+	if high-math.Max(open, close) > math.Min(open, close)-low {
+		return DirectionUp
+	}
+	return DirectionDown
+}
+
+// candleDirectionOf is candleDirection for a candle in memory
+func candleDirectionOf(open, close float64) string {
+	if close >= open {
+		return DirectionUp
+	}
+	return DirectionDown
+}
+
+// finishPage trims the extra row fetched by page and returns the cursor of the next page,
+// or "" on the last one. key returns a row's sort value and ID.
+func finishPage[T any](rows []T, q *ListQuery, sortColumn string, key func(T) (time.Time, int)) ([]T, string) {
+	if q.Limit <= 0 || len(rows) <= q.Limit {
+		return rows, ""
+	}
+	rows = rows[:q.Limit]
+	v, id := key(rows[len(rows)-1])
+	return rows, (&Cursor{Sort: sortColumn, Value: v, ID: id, Desc: q.Desc}).Encode()
+}
+
+// pageInMemory applies q's time window, order, cursor and limit to rows already filtered
+// on everything else, as page and finishPage do in SQL
+func pageInMemory[T any](rows []T, q *ListQuery, sortColumn string, key func(T) (time.Time, int), at func(T) time.Time) ([]T, string) {
+	less := func(ti time.Time, ii int, tj time.Time, ij int) bool {
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return ii < ij
+	}
+
+	kept := rows[:0]
+	for _, row := range rows {
+		t := at(row)
+		if (!q.From.IsZero() && t.Before(q.From)) || (!q.To.IsZero() && !t.Before(q.To)) {
+			continue
+		}
+		if q.After != nil {
+			v, id := key(row)
+			after := less(q.After.Value, q.After.ID, v, id)
+			if q.Desc {
+				after = less(v, id, q.After.Value, q.After.ID)
+			}
+			if !after {
+				continue
+			}
+		}
+		kept = append(kept, row)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		vi, ii := key(kept[i])
+		vj, ij := key(kept[j])
+		if q.Desc {
+			return less(vj, ij, vi, ii)
+		}
+		return less(vi, ii, vj, ij)
+	})
+	if q.Limit > 0 && len(kept) > q.Limit+1 {
+		kept = kept[:q.Limit+1]
+	}
+	return finishPage(kept, q, sortColumn, key)
+}
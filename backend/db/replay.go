@@ -0,0 +1,328 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"spikeshield/utils"
+)
+
+// Replay session states
+const (
+	ReplayCreated  = "created"
+	ReplayRunning  = "running"
+	ReplayPaused   = "paused"
+	ReplayFinished = "finished"
+	ReplayStopped  = "stopped"
+	ReplayFailed   = "failed"
+)
+
+// ReplayEnded reports whether status is final; ended sessions have FinishedAt set
+func ReplayEnded(status string) bool {
+	return status == ReplayFinished || status == ReplayStopped || status == ReplayFailed
+}
+
+// ReplaySession is one isolated replay of a CSV. Its candles, spikes and simulated
+// payouts live in the replay_* tables and never mix with production data.
+type ReplaySession struct {
+	ID         string
+	Symbol     string
+	Source     string
+	Status     string
+	Error      string
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// SimulatedPayout is a payout a replayed spike would have triggered
+type SimulatedPayout struct {
+	ID          int
+	SessionID   string
+	SpikeID     int // replay spike ID
+	PolicyID    int
+	UserAddress string
+	Amount      utils.Amount
+	SimulatedAt time.Time
+}
+
+// NewReplaySessionID returns a random session ID
+func NewReplaySessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// replayScope implements PriceStore and SpikeStore on the replay_* rows of one session
+type replayScope struct {
+	st      *SQLStore
+	session string
+	hub     *priceHub
+}
+
+// ReplayScope returns price and spike stores confined to one session, so the detector can
+// run against a replay exactly as it does against production. Scopes are built on demand
+// and hold no rows, so nothing outlives the session; subscribers only see candles written
+// through the same scope, as the replay manager's detector does.
+func (st *SQLStore) ReplayScope(sessionID string) (PriceStore, SpikeStore) {
+	scope := &replayScope{st: st, session: sessionID, hub: newPriceHub()}
+	return scope, scope
+}
+
+// CreateReplaySession records a new session; s.ID is generated if empty
+func (st *SQLStore) CreateReplaySession(s *ReplaySession) error {
+	if s.ID == "" {
+		s.ID = NewReplaySessionID()
+	}
+	if s.Status == "" {
+		s.Status = ReplayCreated
+	}
+	query := `INSERT INTO replay_sessions (id, symbol, source, status) VALUES ($1, $2, $3, $4) RETURNING created_at`
+	return st.db.QueryRow(query, s.ID, s.Symbol, s.Source, s.Status).Scan(&s.CreatedAt)
+}
+
+const replaySessionColumns = `id, symbol, source, status, COALESCE(error, ''), created_at, finished_at`
+
+func scanReplaySession(row rowScanner) (*ReplaySession, error) {
+	s := &ReplaySession{}
+	var finishedAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.Symbol, &s.Source, &s.Status, &s.Error, &s.CreatedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		s.FinishedAt = &finishedAt.Time
+	}
+	return s, nil
+}
+
+// GetReplaySession returns sql.ErrNoRows if the session does not exist
+func (st *SQLStore) GetReplaySession(id string) (*ReplaySession, error) {
+	return scanReplaySession(st.db.QueryRow(`SELECT `+replaySessionColumns+` FROM replay_sessions WHERE id = $1`, id))
+}
+
+// ListReplaySessions returns every session, newest first
+func (st *SQLStore) ListReplaySessions() ([]*ReplaySession, error) {
+	rows, err := st.db.Query(`SELECT ` + replaySessionColumns + ` FROM replay_sessions ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*ReplaySession
+	for rows.Next() {
+		s, err := scanReplaySession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// UpdateReplaySessionStatus moves a session to status; final states set finished_at
+func (st *SQLStore) UpdateReplaySessionStatus(id, status, errMsg string) error {
+	query := `UPDATE replay_sessions SET status = $2, error = NULLIF($3, ''),
+			      finished_at = CASE WHEN $2 IN ('finished', 'stopped', 'failed') THEN CURRENT_TIMESTAMP ELSE NULL END
+			  WHERE id = $1`
+	_, err := st.db.Exec(query, id, status, errMsg)
+	return err
+}
+
+// DeleteReplaySession removes a session and everything it produced
+func (st *SQLStore) DeleteReplaySession(id string) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"replay_payouts", "replay_spikes", "replay_prices", "quarantined_prices"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE session_id = $1`, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM replay_sessions WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertSimulatedPayout records a simulated payout. Returns false if the policy was
+// already paid in this session.
+func (st *SQLStore) InsertSimulatedPayout(p *SimulatedPayout) (bool, error) {
+	query := `INSERT INTO replay_payouts (session_id, spike_id, policy_id, user_address, amount, token_decimals)
+			  VALUES ($1, $2, $3, $4, $5, $6)
+			  ON CONFLICT (session_id, policy_id) DO NOTHING
+			  RETURNING id, simulated_at`
+	err := st.db.QueryRow(query, p.SessionID, p.SpikeID, p.PolicyID, p.UserAddress, p.Amount.Raw.String(), p.Amount.Decimals).
+		Scan(&p.ID, &p.SimulatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetSimulatedPayouts returns the simulated payouts of a session matching q, ordered by
+// simulated_at, and the cursor of the next page. Symbol and Direction select payouts by
+// the session spike that triggered them.
+func (st *SQLStore) GetSimulatedPayouts(sessionID string, q ListQuery) ([]*SimulatedPayout, string, error) {
+	column, err := q.sortColumn("simulated_at")
+	if err != nil {
+		return nil, "", err
+	}
+	b := st.newQuery()
+	session := b.arg(sessionID)
+	b.where = append(b.where, "session_id = "+session)
+	if q.User != "" {
+		b.cond("user_address = %s", q.User)
+	}
+	if q.Symbol != "" || q.Direction != "" {
+		b.spikeFilter(&q, "replay_spikes", "session_id = "+session)
+	}
+	query := `SELECT id, session_id, spike_id, policy_id, user_address, CAST(amount AS TEXT), token_decimals, simulated_at
+			  FROM replay_payouts` + b.page(&q, "simulated_at", column, "id")
+	rows, err := st.db.Query(query, b.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var payouts []*SimulatedPayout
+	for rows.Next() {
+		p := &SimulatedPayout{}
+		var amount string
+		var decimals int
+		if err := rows.Scan(&p.ID, &p.SessionID, &p.SpikeID, &p.PolicyID, &p.UserAddress, &amount, &decimals, &p.SimulatedAt); err != nil {
+			return nil, "", err
+		}
+		if p.Amount, err = parseAmount(amount, decimals); err != nil {
+			return nil, "", err
+		}
+		payouts = append(payouts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	payouts, next := finishPage(payouts, &q, column, func(p *SimulatedPayout) (time.Time, int) { return p.SimulatedAt, p.ID })
+	return payouts, next, nil
+}
+
+// InsertPrice upserts a candle of the session and announces it to the session's subscribers
+func (r *replayScope) InsertPrice(p *PriceData) error {
+	if err := r.st.validatePrice(p, "replay_prices", r.session); err != nil {
+		return err
+	}
+	var exists bool
+	if err := r.st.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM replay_prices WHERE session_id = $1 AND symbol = $2 AND timestamp = $3)`,
+		r.session, p.Symbol, p.Timestamp).Scan(&exists); err != nil {
+		return err
+	}
+	query := `INSERT INTO replay_prices (session_id, timestamp, symbol, open, high, low, close, volume)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	          ON CONFLICT (session_id, symbol, timestamp)
+	          DO UPDATE SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low,
+	                        close = EXCLUDED.close, volume = EXCLUDED.volume
+	          RETURNING id`
+	if err := r.st.db.QueryRow(query, r.session, p.Timestamp, p.Symbol, p.Open, p.High, p.Low, p.Close, p.Volume).Scan(&p.ID); err != nil {
+		return err
+	}
+
+	ev := PriceEvent{ID: p.ID, Symbol: p.Symbol, Op: "INSERT"}
+	if exists {
+		ev.Op = "UPDATE"
+	}
+	r.hub.publish(ev)
+	return nil
+}
+
+// GetLatestPrice retrieves the most recent candle of the session
+func (r *replayScope) GetLatestPrice(symbol string) (*PriceData, error) {
+	query := `SELECT id, timestamp, symbol, open, high, low, close, volume
+			  FROM replay_prices WHERE session_id = $1 AND symbol = $2 ORDER BY timestamp DESC LIMIT 1`
+	p := &PriceData{}
+	err := r.st.db.QueryRow(query, r.session, symbol).Scan(&p.ID, &p.Timestamp, &p.Symbol, &p.Open, &p.High, &p.Low, &p.Close, &p.Volume)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPriceByID retrieves a candle of the session by row ID
+func (r *replayScope) GetPriceByID(id int) (*PriceData, error) {
+	query := `SELECT id, timestamp, symbol, open, high, low, close, volume
+			  FROM replay_prices WHERE session_id = $1 AND id = $2`
+	p := &PriceData{}
+	err := r.st.db.QueryRow(query, r.session, id).Scan(&p.ID, &p.Timestamp, &p.Symbol, &p.Open, &p.High, &p.Low, &p.Close, &p.Volume)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPrices returns the candles of the session matching q, like SQLStore.GetPrices
+func (r *replayScope) GetPrices(q ListQuery) ([]*PriceData, string, error) {
+	b := r.st.newQuery()
+	b.cond("session_id = %s", r.session)
+	return r.st.selectPrices("replay_prices", b, &q)
+}
+
+// DeleteAllPrices deletes the candles of the session only
+func (r *replayScope) DeleteAllPrices() error {
+	_, err := r.st.db.Exec(`DELETE FROM replay_prices WHERE session_id = $1`, r.session)
+	return err
+}
+
+// SubscribePrices delivers an event for every candle written through this scope until ctx is done
+func (r *replayScope) SubscribePrices(ctx context.Context) (<-chan PriceEvent, error) {
+	return r.hub.subscribe(ctx), nil
+}
+
+// InsertSpike records a spike of the session
+func (r *replayScope) InsertSpike(s *Spike, priceID int) error {
+	query := `INSERT INTO replay_spikes (session_id, timestamp, symbol, price_id, open, high, low, close, body_ratio, range_close_percent)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, detected_at`
+	s.PriceID = priceID
+	return r.st.db.QueryRow(query, r.session, s.Timestamp, s.Symbol, priceID, s.Open, s.High, s.Low, s.Close,
+		s.BodyRatio, s.RangeClosePercent).Scan(&s.ID, &s.DetectedAt)
+}
+
+// GetSpikeByPriceID returns the spike of a session candle, voided or not, or sql.ErrNoRows
+func (r *replayScope) GetSpikeByPriceID(priceID int) (*Spike, error) {
+	return scanSpike(r.st.db.QueryRow(`SELECT `+spikeColumns+` FROM replay_spikes WHERE session_id = $1 AND price_id = $2`, r.session, priceID))
+}
+
+// UpdateSpike rewrites a session spike from its re-evaluated candle and clears any void
+func (r *replayScope) UpdateSpike(s *Spike) error {
+	query := `UPDATE replay_spikes SET timestamp = $3, open = $4, high = $5, low = $6, close = $7,
+			      body_ratio = $8, range_close_percent = $9, updated_at = CURRENT_TIMESTAMP, voided_at = NULL
+			  WHERE session_id = $1 AND id = $2`
+	_, err := r.st.db.Exec(query, r.session, s.ID, s.Timestamp, s.Open, s.High, s.Low, s.Close, s.BodyRatio, s.RangeClosePercent)
+	if err == nil {
+		s.VoidedAt = nil
+	}
+	return err
+}
+
+// VoidSpike marks a session spike as withdrawn
+func (r *replayScope) VoidSpike(id int) error {
+	_, err := r.st.db.Exec(`UPDATE replay_spikes SET voided_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+	                        WHERE session_id = $1 AND id = $2 AND voided_at IS NULL`, r.session, id)
+	return err
+}
+
+// GetSpikes returns the non-voided spikes of the session matching q, ordered by timestamp
+// unless q.Sort is "detected_at"
+func (r *replayScope) GetSpikes(q ListQuery) ([]*Spike, string, error) {
+	b := r.st.newQuery()
+	b.cond("session_id = %s", r.session)
+	b.where = append(b.where, "voided_at IS NULL")
+	return r.st.selectSpikes("replay_spikes", b, &q, "timestamp", "detected_at")
+}
+
+// DeleteAllSpikes deletes the spikes of the session only
+func (r *replayScope) DeleteAllSpikes() error {
+	_, err := r.st.db.Exec(`DELETE FROM replay_spikes WHERE session_id = $1`, r.session)
+	return err
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PriceRollup is a downsampled candle covering [Bucket, Bucket+Interval)
+type PriceRollup struct {
+	ID       int
+	Symbol   string
+	Interval time.Duration
+	Bucket   time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   float64
+	Candles  int // source candles folded into this rollup
+}
+
+// CompactionResult reports what one CompactPrices run did
+type CompactionResult struct {
+	Cutoff            time.Time
+	RolledUp          int // candles folded into rollups
+	Deleted           int // candles removed from prices
+	Kept              int // candles older than the cutoff kept because a spike references them
+	DroppedPartitions int // empty prices partitions dropped (native partitioning only)
+}
+
+// rollupKey identifies a rollup bucket while compacting
+type rollupKey struct {
+	symbol string
+	bucket time.Time
+}
+
+// foldCandle adds a candle to a rollup. Candles are folded in timestamp order, so the
+// first sets the open and the last the close.
+func foldCandle(r *PriceRollup, p *PriceData) {
+	if r.Candles == 0 {
+		r.Open, r.High, r.Low = p.Open, p.High, p.Low
+	}
+	if p.High > r.High {
+		r.High = p.High
+	}
+	if p.Low < r.Low {
+		r.Low = p.Low
+	}
+	r.Close = p.Close
+	r.Volume += p.Volume
+	r.Candles++
+}
+
+// CompactPrices downsamples candles older than `before` (truncated to the interval)
+// into interval-sized rollups and deletes them from prices. Candles referenced by a
+// spike are kept. Candles that arrive late for an already rolled-up bucket widen its
+// range and volume but do not move its open or close.
+func (st *SQLStore) CompactPrices(before time.Time, interval time.Duration) (*CompactionResult, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("rollup interval must be at least 1s, got %s", interval)
+	}
+	res := &CompactionResult{Cutoff: before.UTC().Truncate(interval)}
+	seconds := int(interval / time.Second)
+
+	symbols, err := st.symbolsBefore(res.Cutoff)
+	if err != nil {
+		return nil, err
+	}
+	for _, symbol := range symbols {
+		if err := st.compactSymbol(symbol, res, interval, seconds); err != nil {
+			return res, fmt.Errorf("failed to compact %s: %w", symbol, err)
+		}
+	}
+
+	if st.dialect == Postgres {
+		dropped, err := st.maintainPartitions(res.Cutoff)
+		res.DroppedPartitions = dropped
+		if err != nil {
+			return res, fmt.Errorf("failed to maintain partitions: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// symbolsBefore lists the symbols that have candles older than cutoff
+func (st *SQLStore) symbolsBefore(cutoff time.Time) ([]string, error) {
+	rows, err := st.db.Query(`SELECT DISTINCT symbol FROM prices WHERE timestamp < $1`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols, rows.Err()
+}
+
+// compactSymbol rolls up and prunes one symbol in a single transaction
+func (st *SQLStore) compactSymbol(symbol string, res *CompactionResult, interval time.Duration, seconds int) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Everything before the newest bucket was rolled up by an earlier run; of those
+	// candles only spike-referenced ones are left, and they must not be counted twice
+	var watermark time.Time
+	err = tx.QueryRow(`SELECT bucket FROM price_rollups WHERE symbol = $1 AND interval_seconds = $2
+	                   ORDER BY bucket DESC LIMIT 1`, symbol, seconds).Scan(&watermark)
+	if err == nil {
+		watermark = watermark.Add(interval)
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT p.id, p.timestamp, p.open, p.high, p.low, p.close, p.volume,
+	                              EXISTS (SELECT 1 FROM spikes s WHERE s.price_id = p.id)
+	                       FROM prices p WHERE p.symbol = $1 AND p.timestamp < $2
+	                       ORDER BY p.timestamp`, symbol, res.Cutoff)
+	if err != nil {
+		return err
+	}
+	buckets := make(map[rollupKey]*PriceRollup)
+	var order []rollupKey
+	rolled, kept := 0, 0
+	for rows.Next() {
+		p := &PriceData{Symbol: symbol}
+		var referenced bool
+		if err := rows.Scan(&p.ID, &p.Timestamp, &p.Open, &p.High, &p.Low, &p.Close, &p.Volume, &referenced); err != nil {
+			rows.Close()
+			return err
+		}
+		if referenced {
+			kept++
+			if p.Timestamp.Before(watermark) {
+				continue
+			}
+		}
+		key := rollupKey{symbol: symbol, bucket: p.Timestamp.UTC().Truncate(interval)}
+		r, ok := buckets[key]
+		if !ok {
+			r = &PriceRollup{Symbol: symbol, Interval: interval, Bucket: key.bucket}
+			buckets[key] = r
+			order = append(order, key)
+		}
+		foldCandle(r, p)
+		rolled++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range order {
+		r := buckets[key]
+		_, err := tx.Exec(`INSERT INTO price_rollups (symbol, interval_seconds, bucket, open, high, low, close, volume, candle_count)
+		                   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		                   ON CONFLICT (symbol, interval_seconds, bucket) DO UPDATE SET
+		                     high = CASE WHEN EXCLUDED.high > price_rollups.high THEN EXCLUDED.high ELSE price_rollups.high END,
+		                     low = CASE WHEN EXCLUDED.low < price_rollups.low THEN EXCLUDED.low ELSE price_rollups.low END,
+		                     volume = price_rollups.volume + EXCLUDED.volume,
+		                     candle_count = price_rollups.candle_count + EXCLUDED.candle_count`,
+			r.Symbol, seconds, r.Bucket, r.Open, r.High, r.Low, r.Close, r.Volume, r.Candles)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM prices WHERE symbol = $1 AND timestamp < $2
+	                        AND NOT EXISTS (SELECT 1 FROM spikes WHERE spikes.price_id = prices.id)`, symbol, res.Cutoff)
+	if err != nil {
+		return err
+	}
+	deleted, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	res.RolledUp += rolled
+	res.Kept += kept
+	res.Deleted += int(deleted)
+	return nil
+}
+
+// GetPriceRollups returns the rollups of a symbol at one interval with from <= bucket < to, ascending
+func (st *SQLStore) GetPriceRollups(symbol string, interval time.Duration, from, to time.Time) ([]*PriceRollup, error) {
+	query := `SELECT id, bucket, open, high, low, close, volume, candle_count FROM price_rollups
+	          WHERE symbol = $1 AND interval_seconds = $2 AND bucket >= $3 AND bucket < $4 ORDER BY bucket`
+	rows, err := st.db.Query(query, symbol, int(interval/time.Second), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollups []*PriceRollup
+	for rows.Next() {
+		r := &PriceRollup{Symbol: symbol, Interval: interval}
+		if err := rows.Scan(&r.ID, &r.Bucket, &r.Open, &r.High, &r.Low, &r.Close, &r.Volume, &r.Candles); err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, r)
+	}
+	return rollups, rows.Err()
+}
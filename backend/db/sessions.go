@@ -0,0 +1,98 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// WalletSession is a Sign-In With Ethereum session: its token proves control of Address
+type WalletSession struct {
+	ID        int
+	TokenHash string // hex SHA-256 of the token
+	Address   string // checksummed
+	ChainID   int64
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// Valid reports whether the session can still be used at now
+func (s *WalletSession) Valid(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// NewWalletSession generates a session token ("sess_" + 64 hex characters) and returns
+// it with its stored form
+func NewWalletSession(address string, chainID int64, expiresAt time.Time) (string, *WalletSession) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	token := "sess_" + hex.EncodeToString(buf)
+	return token, &WalletSession{TokenHash: HashToken(token), Address: address, ChainID: chainID, ExpiresAt: expiresAt}
+}
+
+// CreateNonce stores a sign-in nonce and drops the ones that expired
+func (st *SQLStore) CreateNonce(nonce string, expiresAt time.Time) error {
+	if _, err := st.db.Exec(`DELETE FROM siwe_nonces WHERE expires_at <= $1`, time.Now().UTC()); err != nil {
+		return err
+	}
+	_, err := st.db.Exec(`INSERT INTO siwe_nonces (nonce, expires_at) VALUES ($1, $2)`, nonce, expiresAt)
+	return err
+}
+
+// ConsumeNonce marks a nonce used; it returns sql.ErrNoRows if the nonce is unknown,
+// already used or expired at now
+func (st *SQLStore) ConsumeNonce(nonce string, now time.Time) error {
+	res, err := st.db.Exec(`UPDATE siwe_nonces SET used_at = $2 WHERE nonce = $1 AND used_at IS NULL AND expires_at > $2`,
+		nonce, now)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateWalletSession stores a session, sets s.ID and s.CreatedAt, and drops the ones
+// that expired
+func (st *SQLStore) CreateWalletSession(s *WalletSession) error {
+	if _, err := st.db.Exec(`DELETE FROM wallet_sessions WHERE expires_at <= $1`, time.Now().UTC()); err != nil {
+		return err
+	}
+	query := `INSERT INTO wallet_sessions (token_hash, address, chain_id, expires_at) VALUES ($1, $2, $3, $4)
+	          RETURNING id, created_at`
+	return st.db.QueryRow(query, s.TokenHash, s.Address, s.ChainID, s.ExpiresAt).Scan(&s.ID, &s.CreatedAt)
+}
+
+// GetWalletSessionByHash returns sql.ErrNoRows for unknown tokens; expired and revoked
+// sessions are returned as they are
+func (st *SQLStore) GetWalletSessionByHash(hash string) (*WalletSession, error) {
+	s := &WalletSession{}
+	var revoked sql.NullTime
+	query := `SELECT id, token_hash, address, chain_id, expires_at, created_at, revoked_at FROM wallet_sessions
+	          WHERE token_hash = $1`
+	err := st.db.QueryRow(query, hash).Scan(&s.ID, &s.TokenHash, &s.Address, &s.ChainID, &s.ExpiresAt, &s.CreatedAt, &revoked)
+	if err != nil {
+		return nil, err
+	}
+	if revoked.Valid {
+		s.RevokedAt = &revoked.Time
+	}
+	return s, nil
+}
+
+// RevokeWalletSession ends a session before it expires
+func (st *SQLStore) RevokeWalletSession(id int) error {
+	res, err := st.db.Exec(`UPDATE wallet_sessions SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`, id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+
+	"spikeshield/utils"
+
+	_ "modernc.org/sqlite"
+)
+
+// connectSQLite opens (creating if needed) the database file at database.path.
+// One connection is shared by all callers: SQLite allows a single writer, and queuing
+// in database/sql is friendlier than SQLITE_BUSY errors.
+func connectSQLite(cfg *utils.Config) (*SQLStore, error) {
+	path := cfg.Database.Path
+	if path == "" {
+		return nil, fmt.Errorf("database.path is required for the sqlite driver")
+	}
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	conn.SetMaxOpenConns(1)
+
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+
+	utils.LogInfo("SQLite database %s opened successfully", path)
+	return NewSQLStore(conn, SQLite, dsn), nil
+}
+
+// insertPriceSQLite upserts a candle and announces it to subscribers, the in-process
+// counterpart of the prices_notify trigger
+func (st *SQLStore) insertPriceSQLite(p *PriceData) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ts := p.Timestamp.UTC()
+	ev := PriceEvent{Symbol: p.Symbol, Op: "UPDATE"}
+	err = tx.QueryRow(`SELECT id FROM prices WHERE symbol = $1 AND timestamp = $2`, p.Symbol, ts).Scan(&p.ID)
+	switch {
+	case err == sql.ErrNoRows:
+		ev.Op = "INSERT"
+		err = tx.QueryRow(`INSERT INTO prices (timestamp, symbol, open, high, low, close, volume)
+		                   VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			ts, p.Symbol, p.Open, p.High, p.Low, p.Close, p.Volume).Scan(&p.ID)
+	case err == nil:
+		_, err = tx.Exec(`UPDATE prices SET open = $2, high = $3, low = $4, close = $5, volume = $6 WHERE id = $1`,
+			p.ID, p.Open, p.High, p.Low, p.Close, p.Volume)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	ev.ID = p.ID
+	st.hub.publish(ev)
+	return nil
+}
+
+// updateBalanceDeltaSQLite adds delta in Go, since balances are stored as text
+func (st *SQLStore) updateBalanceDeltaSQLite(tokenAddr, userAddr string, delta utils.Amount) error {
+	return st.inTx(func(scoped *SQLStore) error {
+		balance := new(big.Int)
+		var raw string
+		err := scoped.db.QueryRow(`SELECT balance FROM balances WHERE token_address = $1 AND user_address = $2`, tokenAddr, userAddr).Scan(&raw)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return err
+		default:
+			if _, ok := balance.SetString(raw, 10); !ok {
+				return fmt.Errorf("invalid balance %q for %s", raw, userAddr)
+			}
+		}
+		balance.Add(balance, delta.Raw)
+
+		_, err = scoped.db.Exec(`INSERT INTO balances (token_address, user_address, balance, token_decimals, last_updated)
+		                         VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		                         ON CONFLICT (token_address, user_address)
+		                         DO UPDATE SET balance = EXCLUDED.balance, last_updated = CURRENT_TIMESTAMP`,
+			tokenAddr, userAddr, balance.String(), delta.Decimals)
+		return err
+	})
+}
+
+// resetProjectionsSQLite is TRUNCATE ... RESTART IDENTITY for SQLite
+func (st *SQLStore) resetProjectionsSQLite() error {
+	_, err := st.db.Exec(`DELETE FROM policies;
+		DELETE FROM payouts;
+		DELETE FROM balances;
+		DELETE FROM sqlite_sequence WHERE name IN ('policies', 'payouts', 'balances');
+		UPDATE chain_events SET projected_at = NULL;`)
+	return err
+}
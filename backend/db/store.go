@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"spikeshield/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PriceStore persists OHLCV candles
+type PriceStore interface {
+	// InsertPrice upserts a candle by (symbol, timestamp) and sets p.ID. With validation
+	// enabled, a candle that fails it is quarantined and a *QuarantineError returned.
+	InsertPrice(p *PriceData) error
+	GetLatestPrice(symbol string) (*PriceData, error)
+	// GetPriceByID returns sql.ErrNoRows if the candle does not exist
+	GetPriceByID(id int) (*PriceData, error)
+	// GetPrices returns the candles matching q and the cursor of the next page ("" on the
+	// last one); a zero ListQuery returns every candle ascending
+	GetPrices(q ListQuery) ([]*PriceData, string, error)
+	DeleteAllPrices() error
+	// SubscribePrices delivers an event for every candle written by any writer until ctx is done
+	SubscribePrices(ctx context.Context) (<-chan PriceEvent, error)
+}
+
+// PriceEvent identifies a candle that was inserted or updated
+type PriceEvent struct {
+	ID     int    `json:"id"`
+	Symbol string `json:"symbol"`
+	Op     string `json:"op"` // INSERT or UPDATE
+}
+
+// SpikeStore persists detected spikes
+type SpikeStore interface {
+	InsertSpike(s *Spike, priceID int) error
+	// GetSpikeByPriceID returns the spike of a candle, including a voided one, or sql.ErrNoRows
+	GetSpikeByPriceID(priceID int) (*Spike, error)
+	// UpdateSpike rewrites a spike from its re-evaluated candle and clears any void
+	UpdateSpike(s *Spike) error
+	VoidSpike(id int) error
+	// GetSpikes returns the non-voided spikes matching q and the cursor of the next page
+	GetSpikes(q ListQuery) ([]*Spike, string, error)
+	DeleteAllSpikes() error
+}
+
+// PolicyStore persists policies projected from the InsurancePool contract
+type PolicyStore interface {
+	GetActivePolicies() ([]*Policy, error)
+	GetPoliciesForUser(userAddr string) ([]*Policy, error)
+	// GetPolicyByOnchainID returns sql.ErrNoRows if the policy has not been indexed yet
+	GetPolicyByOnchainID(userAddr string, policyID int64) (*Policy, error)
+	GetDistinctPolicyUsers() ([]string, error)
+	InsertPolicyFromEvent(userAddr common.Address, policyID *big.Int, premium, coverage utils.Amount, expiryTime *big.Int, ev *ChainEvent) error
+	UpsertPolicy(userAddr string, onchainPolicyID int64, premium, coverage utils.Amount, purchaseTime, expiryTime time.Time, status string) error
+	UpdatePolicyStatus(policyID int, status string) error
+}
+
+// PayoutStore persists executed payouts
+type PayoutStore interface {
+	// InsertPayout returns false if a payout with the same tx hash was already recorded
+	InsertPayout(p *Payout) (bool, error)
+	// GetPayouts returns the payouts matching q and the cursor of the next page
+	GetPayouts(q ListQuery) ([]*Payout, string, error)
+}
+
+// SyncStateStore tracks the last block each listener has processed
+type SyncStateStore interface {
+	// GetLastSyncedBlock returns 0 when the contract has never been synced
+	GetLastSyncedBlock(contractAddr common.Address) (uint64, error)
+	UpdateLastSyncedBlock(contractAddr common.Address, blockNumber uint64) error
+}
+
+// BalanceStore persists indexed ERC20 balances and their reconciliation results
+type BalanceStore interface {
+	GetBalanceForUser(tokenAddr, userAddr string) (*Balance, error)
+	GetAllBalances() ([]*Balance, error)
+	SampleBalances(tokenAddr string, n int) ([]*Balance, error)
+	// UpdateBalanceDelta adds delta (may be negative), creating the row if needed
+	UpdateBalanceDelta(tokenAddr, userAddr string, delta utils.Amount) error
+	InsertBalanceCheck(c *BalanceCheck) error
+}
+
+// ChainEventStore persists the chain event journal and the pool parameter history
+type ChainEventStore interface {
+	// InsertChainEvent returns false if the event (tx hash, log index) was already journaled
+	InsertChainEvent(ev *ChainEvent) (bool, error)
+	// ForEachChainEvent calls fn for every event in (block number, log index, id) order
+	ForEachChainEvent(fn func(*ChainEvent) error) error
+	// GetUnprojectedChainEvents returns the oldest events of a contract not yet projected
+	GetUnprojectedChainEvents(contractAddr string, limit int) ([]*ChainEvent, error)
+	// ProjectChainEvent runs apply with a Store whose writes commit together with marking
+	// event id projected, or not at all
+	ProjectChainEvent(id int64, apply func(*Store) error) error
+	// ResetProjections empties the tables derived from the journal (policies, payouts,
+	// balances) and marks every event unprojected
+	ResetProjections() error
+	InsertPoolParams(p *PoolParams) error
+	// GetLatestPoolParams returns sql.ErrNoRows if nothing has been recorded for the contract
+	GetLatestPoolParams(contractAddr string) (*PoolParams, error)
+}
+
+// RetentionStore downsamples old candles so prices does not grow forever
+type RetentionStore interface {
+	// CompactPrices folds candles older than before (truncated to interval) into
+	// interval-sized rollups and deletes them, keeping candles referenced by a spike
+	CompactPrices(before time.Time, interval time.Duration) (*CompactionResult, error)
+	GetPriceRollups(symbol string, interval time.Duration, from, to time.Time) ([]*PriceRollup, error)
+}
+
+// ReplayStore manages replay sessions, which are isolated from production data
+type ReplayStore interface {
+	// CreateReplaySession sets s.ID if it is empty
+	CreateReplaySession(s *ReplaySession) error
+	// GetReplaySession returns sql.ErrNoRows if the session does not exist
+	GetReplaySession(id string) (*ReplaySession, error)
+	ListReplaySessions() ([]*ReplaySession, error)
+	// UpdateReplaySessionStatus sets FinishedAt when status is final (see ReplayEnded)
+	UpdateReplaySessionStatus(id, status, errMsg string) error
+	// DeleteReplaySession removes the session with its candles, spikes and payouts
+	DeleteReplaySession(id string) error
+	// ReplayScope returns price and spike stores that only see one session
+	ReplayScope(sessionID string) (PriceStore, SpikeStore)
+	// InsertSimulatedPayout returns false if the policy was already paid in the session
+	InsertSimulatedPayout(p *SimulatedPayout) (bool, error)
+	GetSimulatedPayouts(sessionID string, q ListQuery) ([]*SimulatedPayout, string, error)
+}
+
+// QuarantineStore lists candles that failed ingest validation (see PriceValidation)
+type QuarantineStore interface {
+	// GetQuarantinedPrices returns production candles if sessionID is empty, otherwise those
+	// of a replay session, matching q, and the cursor of the next page
+	GetQuarantinedPrices(sessionID string, q ListQuery) ([]*QuarantinedPrice, string, error)
+}
+
+// WebhookStore persists partner endpoints and the log of deliveries to them
+type WebhookStore interface {
+	// CreateWebhook sets w.ID and w.CreatedAt
+	CreateWebhook(w *Webhook) error
+	// GetWebhook returns sql.ErrNoRows if the endpoint does not exist
+	GetWebhook(id int) (*Webhook, error)
+	ListWebhooks() ([]*Webhook, error)
+	// UpdateWebhook returns sql.ErrNoRows if the endpoint does not exist
+	UpdateWebhook(w *Webhook) error
+	// DeleteWebhook removes the endpoint with its deliveries, or returns sql.ErrNoRows
+	DeleteWebhook(id int) error
+	// InsertWebhookDelivery sets d.ID and d.CreatedAt; an empty status means pending
+	InsertWebhookDelivery(d *WebhookDelivery) error
+	// ClaimDueDeliveries leases up to limit pending deliveries due at now until leaseUntil
+	ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]*WebhookDelivery, error)
+	UpdateWebhookDelivery(d *WebhookDelivery) error
+	// GetWebhookDelivery returns sql.ErrNoRows if the delivery does not exist
+	GetWebhookDelivery(id int) (*WebhookDelivery, error)
+	// GetWebhookDeliveries filters by status when it is non-empty; newest first
+	GetWebhookDeliveries(webhookID int, status string, limit int) ([]*WebhookDelivery, error)
+}
+
+// AuthStore persists API keys and the audit log of privileged calls
+type AuthStore interface {
+	// CreateAPIKey sets k.ID and k.CreatedAt
+	CreateAPIKey(k *APIKey) error
+	// GetAPIKeyByHash returns sql.ErrNoRows for unknown keys
+	GetAPIKeyByHash(hash string) (*APIKey, error)
+	ListAPIKeys() ([]*APIKey, error)
+	// RevokeAPIKey returns sql.ErrNoRows if the key does not exist
+	RevokeAPIKey(id int) error
+	TouchAPIKey(id int, at time.Time) error
+	// InsertAuditEntry sets e.ID and e.CreatedAt
+	InsertAuditEntry(e *AuditEntry) error
+	// GetAuditLog filters by key when apiKeyID is non-zero; newest first
+	GetAuditLog(apiKeyID, limit int) ([]*AuditEntry, error)
+}
+
+// SessionStore persists Sign-In With Ethereum nonces and wallet sessions
+type SessionStore interface {
+	CreateNonce(nonce string, expiresAt time.Time) error
+	// ConsumeNonce returns sql.ErrNoRows if the nonce is unknown, used or expired
+	ConsumeNonce(nonce string, now time.Time) error
+	// CreateWalletSession sets s.ID and s.CreatedAt
+	CreateWalletSession(s *WalletSession) error
+	// GetWalletSessionByHash returns sql.ErrNoRows for unknown tokens
+	GetWalletSessionByHash(hash string) (*WalletSession, error)
+	// RevokeWalletSession returns sql.ErrNoRows if the session does not exist
+	RevokeWalletSession(id int) error
+}
+
+// StatsStore aggregates counts across tables
+type StatsStore interface {
+	GetSystemStats() (*SystemStats, error)
+}
+
+// CandleStore aggregates candles for charts
+type CandleStore interface {
+	// GetCandles returns interval-sized candles of a symbol with from <= timestamp < to,
+	// ascending, with the spikes detected on them. Compacted ranges come from the rollups
+	// of size rollup if interval is a multiple of it; 0 reads prices only.
+	GetCandles(symbol string, interval, rollup time.Duration, from, to time.Time) ([]*Candle, error)
+}
+
+// Store bundles the repositories a backend instance works with
+type Store struct {
+	Prices     PriceStore
+	Spikes     SpikeStore
+	Policies   PolicyStore
+	Payouts    PayoutStore
+	SyncState  SyncStateStore
+	Balances   BalanceStore
+	Events     ChainEventStore
+	Stats      StatsStore
+	Candles    CandleStore
+	Retention  RetentionStore
+	Replays    ReplayStore
+	Quarantine QuarantineStore
+	Webhooks   WebhookStore
+	Auth       AuthStore
+	Sessions   SessionStore
+}
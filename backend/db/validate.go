@@ -0,0 +1,203 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+// PriceValidation configures the checks InsertPrice runs before accepting a candle.
+// Candles that fail are stored in quarantined_prices instead and never reach detection.
+type PriceValidation struct {
+	Enabled bool
+	// MaxJump is the largest move of open or close from the previous candle's close, as a
+	// ratio of that close (0.2 = 20%). Wicks (high and low) are not limited. 0 disables it.
+	MaxJump float64
+	// Monotonic rejects new candles older than the latest stored one. It suits a live feed
+	// only: imports and replay sessions load history out of order, so they never apply it.
+	Monotonic bool
+}
+
+// QuarantinedPrice is a candle InsertPrice rejected
+type QuarantinedPrice struct {
+	ID            int
+	SessionID     string // replay session, "" for production
+	Timestamp     time.Time
+	Symbol        string
+	Open          float64
+	High          float64
+	Low           float64
+	Close         float64
+	Volume        float64
+	Reason        string
+	QuarantinedAt time.Time
+}
+
+// QuarantineError is returned by InsertPrice for a candle that failed validation and
+// was quarantined. The candle is not stored and p.ID is not set.
+type QuarantineError struct {
+	Reason string
+}
+
+func (e *QuarantineError) Error() string {
+	return "price quarantined: " + e.Reason
+}
+
+// CheckCandle reports why a candle is inconsistent on its own, or "" if it is not:
+// high must be at least max(open, close), low at most min(open, close), prices positive
+// and volume non-negative. The detector skips candles that fail it.
+func CheckCandle(p *PriceData) string {
+	switch {
+	case math.IsNaN(p.Open) || math.IsNaN(p.High) || math.IsNaN(p.Low) || math.IsNaN(p.Close) || math.IsNaN(p.Volume):
+		return "NaN value"
+	case p.Low <= 0 || p.Open <= 0 || p.Close <= 0:
+		return fmt.Sprintf("non-positive price (open %g, low %g, close %g)", p.Open, p.Low, p.Close)
+	case p.High < math.Max(p.Open, p.Close):
+		return fmt.Sprintf("high %g below max(open, close) %g", p.High, math.Max(p.Open, p.Close))
+	case p.Low > math.Min(p.Open, p.Close):
+		return fmt.Sprintf("low %g above min(open, close) %g", p.Low, math.Min(p.Open, p.Close))
+	case p.Volume < 0:
+		return fmt.Sprintf("negative volume %g", p.Volume)
+	}
+	return ""
+}
+
+// check validates p against its neighbours: prev is the latest candle before it (nil if
+// none), exists whether p updates a stored candle, later whether a newer candle exists.
+// With Monotonic, new candles must come after every stored one; updates may always
+// correct older candles.
+func (v PriceValidation) check(p, prev *PriceData, exists, later bool) string {
+	if reason := CheckCandle(p); reason != "" {
+		return reason
+	}
+	if v.Monotonic && !exists && later {
+		return fmt.Sprintf("timestamp %s is not after the latest candle", p.Timestamp.UTC().Format(time.RFC3339))
+	}
+	if v.MaxJump > 0 && prev != nil && prev.Close > 0 {
+		for _, side := range []struct {
+			name  string
+			value float64
+		}{{"open", p.Open}, {"close", p.Close}} {
+			jump := math.Abs(side.value-prev.Close) / prev.Close
+			if jump > v.MaxJump {
+				return fmt.Sprintf("%s %g moved %.1f%% from previous close %g (max %.1f%%)",
+					side.name, side.value, jump*100, prev.Close, v.MaxJump*100)
+			}
+		}
+	}
+	return ""
+}
+
+// validatePrice checks a candle bound for table (prices or replay_prices, where sessionID
+// selects the session) and quarantines it if it fails. It returns a *QuarantineError for
+// a quarantined candle.
+func (st *SQLStore) validatePrice(p *PriceData, table, sessionID string) error {
+	if !st.validation.Enabled {
+		return nil
+	}
+	v := st.validation
+	scope, args := `symbol = $1`, []interface{}{p.Symbol, p.Timestamp}
+	if table == "replay_prices" {
+		// Seeking back replays candles a forward seek skipped
+		v.Monotonic = false
+		scope, args = `session_id = $3 AND symbol = $1`, append(args, sessionID)
+	}
+
+	var prev *PriceData
+	row := &PriceData{}
+	err := st.db.QueryRow(`SELECT id, timestamp, symbol, open, high, low, close, volume FROM `+table+`
+	                       WHERE `+scope+` AND timestamp < $2 ORDER BY timestamp DESC LIMIT 1`, args...).
+		Scan(&row.ID, &row.Timestamp, &row.Symbol, &row.Open, &row.High, &row.Low, &row.Close, &row.Volume)
+	switch {
+	case err == nil:
+		prev = row
+	case err != sql.ErrNoRows:
+		return err
+	}
+
+	var exists, later bool
+	err = st.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE `+scope+` AND timestamp = $2),
+	                             EXISTS (SELECT 1 FROM `+table+` WHERE `+scope+` AND timestamp > $2)`, args...).Scan(&exists, &later)
+	if err != nil {
+		return err
+	}
+
+	reason := v.check(p, prev, exists, later)
+	if reason == "" {
+		return nil
+	}
+	return st.quarantinePrice(p, sessionID, reason)
+}
+
+// quarantinePrice stores a rejected candle; a later rejection of the same slot replaces it
+func (st *SQLStore) quarantinePrice(p *PriceData, sessionID, reason string) error {
+	_, err := st.db.Exec(`INSERT INTO quarantined_prices (session_id, timestamp, symbol, open, high, low, close, volume, reason)
+	                      VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	                      ON CONFLICT (session_id, symbol, timestamp) DO UPDATE SET
+	                        open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close,
+	                        volume = EXCLUDED.volume, reason = EXCLUDED.reason, quarantined_at = CURRENT_TIMESTAMP`,
+		sessionID, p.Timestamp, p.Symbol, p.Open, p.High, p.Low, p.Close, p.Volume, reason)
+	if err != nil {
+		return fmt.Errorf("failed to quarantine price: %w", err)
+	}
+	return &QuarantineError{Reason: reason}
+}
+
+// SetPriceValidation changes the checks InsertPrice runs
+func (st *SQLStore) SetPriceValidation(v PriceValidation) {
+	st.validation = v
+}
+
+// Validation returns the checks InsertPrice runs
+func (st *SQLStore) Validation() PriceValidation {
+	return st.validation
+}
+
+// GetQuarantinedPrices returns the quarantined candles of production (sessionID "") or a
+// replay session matching q, ordered by timestamp unless q.Sort is "quarantined_at", and
+// the cursor of the next page
+func (st *SQLStore) GetQuarantinedPrices(sessionID string, q ListQuery) ([]*QuarantinedPrice, string, error) {
+	column, err := q.sortColumn("timestamp", "quarantined_at")
+	if err != nil {
+		return nil, "", err
+	}
+	b := st.newQuery()
+	b.cond("session_id = %s", sessionID)
+	if q.Symbol != "" {
+		b.cond("symbol = %s", q.Symbol)
+	}
+	if q.Direction != "" {
+		b.cond(candleDirection+" = %s", q.Direction)
+	}
+	query := `SELECT id, session_id, timestamp, symbol, open, high, low, close, volume, reason, quarantined_at
+	          FROM quarantined_prices` + b.page(&q, "timestamp", column, "id")
+	rows, err := st.db.Query(query, b.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var quarantined []*QuarantinedPrice
+	for rows.Next() {
+		qp := &QuarantinedPrice{}
+		if err := rows.Scan(&qp.ID, &qp.SessionID, &qp.Timestamp, &qp.Symbol, &qp.Open, &qp.High, &qp.Low, &qp.Close,
+			&qp.Volume, &qp.Reason, &qp.QuarantinedAt); err != nil {
+			return nil, "", err
+		}
+		quarantined = append(quarantined, qp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	quarantined, next := finishPage(quarantined, &q, column, quarantineKey(column))
+	return quarantined, next, nil
+}
+
+// quarantineKey returns the sort value and ID of a quarantined candle ordered by column
+func quarantineKey(column string) func(*QuarantinedPrice) (time.Time, int) {
+	if column == "quarantined_at" {
+		return func(q *QuarantinedPrice) (time.Time, int) { return q.QuarantinedAt, q.ID }
+	}
+	return func(q *QuarantinedPrice) (time.Time, int) { return q.Timestamp, q.ID }
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func candle(minute int, open, high, low, close float64) *PriceData {
+	return &PriceData{
+		Timestamp: time.Date(2024, 1, 1, 0, minute, 0, 0, time.UTC),
+		Symbol:    "BTCUSDT",
+		Open:      open,
+		High:      high,
+		Low:       low,
+		Close:     close,
+		Volume:    1,
+	}
+}
+
+func TestPriceValidationCheck(t *testing.T) {
+	prev := candle(0, 100, 101, 99, 100)
+	withVolume := func(p *PriceData, v float64) *PriceData { p.Volume = v; return p }
+
+	tests := []struct {
+		name   string
+		v      PriceValidation
+		p      *PriceData
+		prev   *PriceData
+		exists bool
+		later  bool
+		want   string // substring of the reason; empty if the candle passes
+	}{
+		{name: "consistent", p: candle(1, 100, 102, 98, 101), prev: prev},
+		{name: "doji", p: candle(1, 100, 100, 100, 100)},
+		{name: "high below open", p: candle(1, 100, 99.5, 98, 99), want: "high 99.5 below max(open, close)"},
+		{name: "high below close", p: candle(1, 99, 99.5, 98, 100), want: "below max(open, close) 100"},
+		{name: "low above close", p: candle(1, 100, 101, 99.5, 99), want: "low 99.5 above min(open, close)"},
+		{name: "zero low", p: candle(1, 100, 101, 0, 100), want: "non-positive price"},
+		{name: "negative open", p: candle(1, -1, 101, 1, 100), want: "non-positive price"},
+		{name: "NaN close", p: candle(1, 100, 101, 99, math.NaN()), want: "NaN value"},
+		{name: "negative volume", p: withVolume(candle(1, 100, 101, 99, 100), -1), want: "negative volume"},
+
+		{name: "jump within limit", v: PriceValidation{MaxJump: 0.2}, p: candle(1, 100, 125, 95, 119), prev: prev},
+		{name: "open jump", v: PriceValidation{MaxJump: 0.2}, p: candle(1, 130, 131, 125, 126), prev: prev, want: "open 130 moved 30.0%"},
+		{name: "close jump", v: PriceValidation{MaxJump: 0.2}, p: candle(1, 100, 100, 70, 75), prev: prev, want: "close 75 moved 25.0%"},
+		{name: "wicks are not limited", v: PriceValidation{MaxJump: 0.2}, p: candle(1, 100, 300, 10, 100), prev: prev},
+		{name: "no previous candle", v: PriceValidation{MaxJump: 0.2}, p: candle(1, 500, 501, 499, 500)},
+		{name: "jump check off", p: candle(1, 500, 501, 499, 500), prev: prev},
+
+		{name: "monotonic off accepts older candles", p: candle(1, 100, 101, 99, 100), later: true},
+		{name: "monotonic rejects older new candle", v: PriceValidation{Monotonic: true}, p: candle(1, 100, 101, 99, 100), later: true,
+			want: "timestamp 2024-01-01T00:01:00Z is not after the latest candle"},
+		{name: "monotonic accepts corrections", v: PriceValidation{Monotonic: true}, p: candle(1, 100, 101, 99, 100), exists: true, later: true},
+		{name: "monotonic accepts the newest candle", v: PriceValidation{Monotonic: true}, p: candle(1, 100, 101, 99, 100), prev: prev},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.v.check(tt.p, tt.prev, tt.exists, tt.later)
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("check = %q, want the candle to pass", got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestInsertPriceMonotonic checks that the monotonic rule quarantines out-of-order
+// candles only when enabled, and never in replay sessions
+func TestInsertPriceMonotonic(t *testing.T) {
+	for _, monotonic := range []bool{false, true} {
+		m := NewMemoryStore()
+		m.SetPriceValidation(PriceValidation{Enabled: true, Monotonic: monotonic})
+		if err := m.InsertPrice(candle(5, 100, 101, 99, 100)); err != nil {
+			t.Fatal(err)
+		}
+
+		err := m.InsertPrice(candle(1, 100, 101, 99, 100))
+		var qe *QuarantineError
+		if quarantined := errors.As(err, &qe); quarantined != monotonic {
+			t.Errorf("monotonic=%t: out-of-order insert returned %v", monotonic, err)
+		}
+		// Correcting a stored candle is always allowed
+		if err := m.InsertPrice(candle(5, 100, 102, 99, 101)); err != nil {
+			t.Errorf("monotonic=%t: correction returned %v", monotonic, err)
+		}
+
+		prices, _ := m.ReplayScope("session")
+		if err := prices.InsertPrice(candle(5, 100, 101, 99, 100)); err != nil {
+			t.Fatal(err)
+		}
+		if err := prices.InsertPrice(candle(1, 100, 101, 99, 100)); err != nil {
+			t.Errorf("monotonic=%t: replay session quarantined a seek back: %v", monotonic, err)
+		}
+	}
+}
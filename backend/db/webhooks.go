@@ -0,0 +1,222 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"   // waiting for its first or next attempt
+	DeliverySucceeded = "succeeded" // the endpoint answered 2xx
+	DeliveryDead      = "dead"      // out of attempts; only a manual redelivery retries it
+)
+
+// Webhook is a partner endpoint notified of the event types it subscribed to
+type Webhook struct {
+	ID          int
+	URL         string
+	Secret      string   // HMAC key; never returned by the API after creation
+	Events      []string // event types, "*" for all
+	Description string
+	Active      bool
+	OwnerKeyID  int // API key that registered it; 0 for admin-managed endpoints
+	CreatedAt   time.Time
+}
+
+// Accepts reports whether the endpoint wants events of eventType
+func (w *Webhook) Accepts(eventType string) bool {
+	for _, e := range w.Events {
+		if e == "*" || e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent to one endpoint, with the outcome of its last attempt
+type WebhookDelivery struct {
+	ID             int
+	WebhookID      int
+	EventID        string // same for every endpoint and attempt, for receiver idempotency
+	EventType      string
+	Payload        string // the signed JSON body
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode int // 0 if the request failed before a response
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+// CreateWebhook stores an endpoint and sets w.ID and w.CreatedAt
+func (st *SQLStore) CreateWebhook(w *Webhook) error {
+	query := `INSERT INTO webhooks (url, secret, events, description, active, owner_key_id) VALUES ($1, $2, $3, $4, $5, $6)
+	          RETURNING id, created_at`
+	owner := sql.NullInt64{Int64: int64(w.OwnerKeyID), Valid: w.OwnerKeyID != 0}
+	return st.db.QueryRow(query, w.URL, w.Secret, strings.Join(w.Events, ","), w.Description, w.Active, owner).
+		Scan(&w.ID, &w.CreatedAt)
+}
+
+const webhookColumns = `id, url, secret, events, description, active, owner_key_id, created_at`
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	w := &Webhook{}
+	var events string
+	var owner sql.NullInt64
+	if err := row.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.Description, &w.Active, &owner, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+	w.Events = strings.Split(events, ",")
+	w.OwnerKeyID = int(owner.Int64)
+	return w, nil
+}
+
+// GetWebhook returns sql.ErrNoRows if the endpoint does not exist
+func (st *SQLStore) GetWebhook(id int) (*Webhook, error) {
+	return scanWebhook(st.db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+}
+
+// ListWebhooks returns every endpoint in creation order
+func (st *SQLStore) ListWebhooks() ([]*Webhook, error) {
+	rows, err := st.db.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// UpdateWebhook rewrites an endpoint's URL, secret, filter, description and active flag
+func (st *SQLStore) UpdateWebhook(w *Webhook) error {
+	query := `UPDATE webhooks SET url = $2, secret = $3, events = $4, description = $5, active = $6 WHERE id = $1`
+	res, err := st.db.Exec(query, w.ID, w.URL, w.Secret, strings.Join(w.Events, ","), w.Description, w.Active)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteWebhook removes an endpoint with its delivery log
+func (st *SQLStore) DeleteWebhook(id int) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = $1`, id); err != nil {
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// InsertWebhookDelivery queues a delivery and sets d.ID and d.CreatedAt
+func (st *SQLStore) InsertWebhookDelivery(d *WebhookDelivery) error {
+	if d.Status == "" {
+		d.Status = DeliveryPending
+	}
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at)
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	return st.db.QueryRow(query, d.WebhookID, d.EventID, d.EventType, d.Payload, d.Status, d.NextAttemptAt).
+		Scan(&d.ID, &d.CreatedAt)
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	last_status_code, last_error, created_at, delivered_at`
+
+func scanWebhookDelivery(row rowScanner) (*WebhookDelivery, error) {
+	d := &WebhookDelivery{}
+	var deliveredAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &deliveredAt); err != nil {
+		return nil, err
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return d, nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]*WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries due at now and moves their
+// next attempt to leaseUntil, so another dispatcher does not send them meanwhile. The
+// outer condition is re-checked after a concurrent claim, which then skips the row.
+func (st *SQLStore) ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]*WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries SET next_attempt_at = $2
+	          WHERE status = 'pending' AND next_attempt_at <= $1 AND id IN (
+	              SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= $1
+	              ORDER BY next_attempt_at, id LIMIT $3)
+	          RETURNING ` + webhookDeliveryColumns
+	rows, err := st.db.Query(query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery stores the outcome of an attempt or a manual redelivery
+func (st *SQLStore) UpdateWebhookDelivery(d *WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries SET status = $2, attempts = $3, next_attempt_at = $4,
+	              last_status_code = $5, last_error = $6, delivered_at = $7
+	          WHERE id = $1`
+	_, err := st.db.Exec(query, d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt)
+	return err
+}
+
+// GetWebhookDelivery returns sql.ErrNoRows if the delivery does not exist
+func (st *SQLStore) GetWebhookDelivery(id int) (*WebhookDelivery, error) {
+	return scanWebhookDelivery(st.db.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id))
+}
+
+// GetWebhookDeliveries returns an endpoint's latest deliveries, newest first, optionally
+// only those in status
+func (st *SQLStore) GetWebhookDeliveries(webhookID int, status string, limit int) ([]*WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+	          WHERE webhook_id = $1 AND ($2 = '' OR status = $2) ORDER BY id DESC LIMIT $3`
+	rows, err := st.db.Query(query, webhookID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
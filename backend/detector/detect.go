@@ -1,10 +1,12 @@
 package detector
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
 	"spikeshield/db"
+	"spikeshield/events"
 	"spikeshield/utils"
 )
 
@@ -13,68 +15,178 @@ type Detector struct {
 	ThresholdPercent float64 // Minimum range percentage for spike detection
 	BodyRatioMax     float64 // Maximum body/range ratio (smaller = longer wick)
 	Symbol           string
+
+	prices db.PriceStore
+	spikes db.SpikeStore
+	events *events.Bus // receives evaluated candles and spike changes, nil for none
+}
+
+// Outcome is what evaluating a candle did to its spike record
+type Outcome int
+
+const (
+	Unchanged Outcome = iota // no spike before or after
+	Created                  // the candle became a spike for the first time
+	Updated                  // the candle was already a spike and its values were refreshed
+	Voided                   // the candle was a spike but no longer matches
+	Restored                 // a voided spike matches again
+)
+
+// String returns the outcome name for logs
+func (o Outcome) String() string {
+	switch o {
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	case Voided:
+		return "voided"
+	case Restored:
+		return "restored"
+	default:
+		return "unchanged"
+	}
+}
+
+// NewSpike reports whether the outcome is a spike that payouts have not seen yet. A
+// restored spike keeps its ID and was already paid out and announced when it was created.
+func (o Outcome) NewSpike() bool {
+	return o == Created
 }
 
 // NewDetector creates a new detector instance
-func NewDetector(symbol string, thresholdPercent float64, bodyRatioMax float64) *Detector {
+func NewDetector(symbol string, thresholdPercent float64, bodyRatioMax float64, prices db.PriceStore, spikes db.SpikeStore) *Detector {
 	return &Detector{
 		ThresholdPercent: thresholdPercent,
 		BodyRatioMax:     bodyRatioMax,
 		Symbol:           symbol,
+		prices:           prices,
+		spikes:           spikes,
 	}
 }
 
-// CheckForSpike analyzes recent prices and detects if a spike (long wick) occurred
+// SetEvents publishes every evaluated candle on prices:SYMBOL and every spike change on
+// spikes to bus
+func (d *Detector) SetEvents(bus *events.Bus) {
+	d.events = bus
+}
+
+// CheckForSpike analyzes the latest candle and reports a newly detected spike (long wick).
 // A spike is characterized by:
 // 1. Small body: abs(open-close)/(high-low) <= body_ratio_max (default 0.3)
 // 2. Large range: (high-low)/close >= threshold_percent (default 0.1 = 10%)
 func (d *Detector) CheckForSpike() (*db.Spike, error) {
 	// Get latest price
-	latest, err := db.GetLatestPrice(d.Symbol)
+	latest, err := d.prices.GetLatestPrice(d.Symbol)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get latest price: %w", err)
 	}
 
+	spike, outcome, err := d.evaluate(latest)
+	if err != nil || !outcome.NewSpike() {
+		return nil, err
+	}
+	return spike, nil
+}
+
+// CheckPrice evaluates exactly the candle with the given row ID, e.g. the one named by a
+// price notification. Re-evaluating an updated candle creates, updates or voids its spike.
+func (d *Detector) CheckPrice(priceID int) (*db.Spike, Outcome, error) {
+	candle, err := d.prices.GetPriceByID(priceID)
+	if err != nil {
+		return nil, Unchanged, fmt.Errorf("failed to get price %d: %w", priceID, err)
+	}
+	d.events.Publish(events.PricesTopic(candle.Symbol), "price", candle)
+
+	spike, outcome, err := d.evaluate(candle)
+	if err == nil && outcome != Unchanged {
+		d.events.Publish(events.TopicSpikes, "spike."+outcome.String(), spike)
+	}
+	return spike, outcome, err
+}
+
+// match applies the spike rule to a candle. It returns nil if the candle is not a spike.
+func (d *Detector) match(candle *db.PriceData) *db.Spike {
 	// Calculate body size relative to total range
-	bodySize := abs(latest.Open - latest.Close)
-	totalRange := latest.High - latest.Low
+	bodySize := abs(candle.Open - candle.Close)
+	totalRange := candle.High - candle.Low
 
 	// Avoid division by zero
-	if totalRange == 0 || latest.Close == 0 {
-		return nil, nil
+	if totalRange == 0 || candle.Close == 0 {
+		return nil
 	}
 
 	bodyRatio := bodySize / totalRange      // Small body means long wick
-	rangeRatio := totalRange / latest.Close // Range as ratio of close
+	rangeRatio := totalRange / candle.Close // Range as ratio of close
 
 	utils.LogDebug("Spike check: open=$%.2f, high=$%.2f, low=$%.2f, close=$%.2f, bodyRatio=%.4f, rangeRatio=%.4f",
-		latest.Open, latest.High, latest.Low, latest.Close, bodyRatio, rangeRatio)
+		candle.Open, candle.High, candle.Low, candle.Close, bodyRatio, rangeRatio)
 
 	// Detect spike: small body (< bodyRatioMax) AND large range (>= threshold)
-	if bodyRatio <= d.BodyRatioMax && rangeRatio >= d.ThresholdPercent {
-		spike := &db.Spike{
-			Timestamp:         latest.Timestamp,
-			Symbol:            d.Symbol,
-			Open:              latest.Open,
-			High:              latest.High,
-			Low:               latest.Low,
-			Close:             latest.Close,
-			BodyRatio:         bodyRatio,
-			RangeClosePercent: rangeRatio,
+	if bodyRatio > d.BodyRatioMax || rangeRatio < d.ThresholdPercent {
+		return nil
+	}
+	return &db.Spike{
+		Timestamp:         candle.Timestamp,
+		Symbol:            candle.Symbol,
+		PriceID:           candle.ID,
+		Open:              candle.Open,
+		High:              candle.High,
+		Low:               candle.Low,
+		Close:             candle.Close,
+		BodyRatio:         bodyRatio,
+		RangeClosePercent: rangeRatio,
+	}
+}
+
+// evaluate reconciles the spike record of a candle with the spike rule
+func (d *Detector) evaluate(candle *db.PriceData) (*db.Spike, Outcome, error) {
+	existing, err := d.spikes.GetSpikeByPriceID(candle.ID)
+	if err == sql.ErrNoRows {
+		existing = nil
+	} else if err != nil {
+		return nil, Unchanged, fmt.Errorf("failed to get spike for price %d: %w", candle.ID, err)
+	}
+
+	// A candle that is inconsistent on its own (e.g. stored before validation was enabled)
+	// never matches, so a spike detected on it is voided
+	var spike *db.Spike
+	if db.CheckCandle(candle) == "" {
+		spike = d.match(candle)
+	}
+	switch {
+	case spike == nil && (existing == nil || existing.Voided()):
+		return nil, Unchanged, nil
+
+	case spike == nil:
+		// The candle was corrected and no longer qualifies
+		if err := d.spikes.VoidSpike(existing.ID); err != nil {
+			return nil, Unchanged, fmt.Errorf("failed to void spike %d: %w", existing.ID, err)
 		}
+		utils.LogInfo("↩️  Spike %d voided: price %d of %s no longer matches", existing.ID, candle.ID, candle.Symbol)
+		return existing, Voided, nil
 
+	case existing == nil:
 		// Save spike to database
-		if err := db.InsertSpike(spike, latest.ID); err != nil {
-			return nil, fmt.Errorf("failed to insert spike: %w", err)
+		if err := d.spikes.InsertSpike(spike, candle.ID); err != nil {
+			return nil, Unchanged, fmt.Errorf("failed to insert spike: %w", err)
 		}
-
-		utils.LogInfo("🚨 SPIKE DETECTED! %s had %.2f%% range (body ratio: %.2f%%) - High: $%.2f, Low: $%.2f",
-			d.Symbol, rangeRatio*100, bodyRatio*100, latest.High, latest.Low)
-
-		return spike, nil
+		utils.LogInfo("🚨 SPIKE DETECTED! %s at %s had %.2f%% range (body ratio: %.2f%%) - High: $%.2f, Low: $%.2f",
+			candle.Symbol, candle.Timestamp.Format(time.RFC3339), spike.RangeClosePercent*100, spike.BodyRatio*100, candle.High, candle.Low)
+		return spike, Created, nil
+
+	default:
+		spike.ID = existing.ID
+		if err := d.spikes.UpdateSpike(spike); err != nil {
+			return nil, Unchanged, fmt.Errorf("failed to update spike %d: %w", existing.ID, err)
+		}
+		if existing.Voided() {
+			utils.LogInfo("🚨 Spike %d restored: price %d of %s matches again", spike.ID, candle.ID, candle.Symbol)
+			return spike, Restored, nil
+		}
+		utils.LogInfo("Spike %d updated from corrected price %d", spike.ID, candle.ID)
+		return spike, Updated, nil
 	}
-
-	return nil, nil
 }
 
 // abs returns absolute value of float64
@@ -107,11 +219,12 @@ func (d *Detector) ContinuousMonitor(checkInterval time.Duration, onSpike func(*
 }
 
 // DetectAllInRange analyzes all price data in a time range (for replay mode)
-// Detects spikes: candles with long wicks (small body, large range)
+// Detects spikes: candles with long wicks (small body, large range).
+// Safe to re-run: every candle's spike record is created, updated or voided as needed.
 func (d *Detector) DetectAllInRange() ([]*db.Spike, error) {
 	utils.LogInfo("Analyzing price data for spikes for symbol %s", d.Symbol)
 
-	prices, err := db.GetAllPrices(d.Symbol)
+	prices, _, err := d.prices.GetPrices(db.ListQuery{Symbol: d.Symbol})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get prices: %w", err)
 	}
@@ -124,39 +237,13 @@ func (d *Detector) DetectAllInRange() ([]*db.Spike, error) {
 
 	// Scan through all candles looking for spikes
 	for _, candle := range prices {
-		bodySize := abs(candle.Open - candle.Close)
-		totalRange := candle.High - candle.Low
-
-		// Skip if no range or invalid data
-		if totalRange == 0 || candle.Close == 0 {
+		spike, outcome, err := d.evaluate(candle)
+		if err != nil {
+			utils.LogError("Failed to evaluate price %d: %v", candle.ID, err)
 			continue
 		}
-
-		// Calculate body ratio and range ratio
-		bodyRatio := bodySize / totalRange
-		rangeRatio := totalRange / candle.Close
-
-		// Detect spike: small body AND large range
-		if bodyRatio <= d.BodyRatioMax && rangeRatio >= d.ThresholdPercent {
-			spike := &db.Spike{
-				Timestamp:         candle.Timestamp,
-				Symbol:            d.Symbol,
-				Open:              candle.Open,
-				High:              candle.High,
-				Low:               candle.Low,
-				Close:             candle.Close,
-				BodyRatio:         bodyRatio,
-				RangeClosePercent: rangeRatio,
-			}
-
-			if err := db.InsertSpike(spike, candle.ID); err != nil {
-				utils.LogError("Failed to insert spike: %v", err)
-				continue
-			}
-
+		if outcome != Unchanged && outcome != Voided {
 			spikes = append(spikes, spike)
-			utils.LogInfo("Spike detected at %s: %.2f%% range (body: %.2f%%) - High: $%.2f, Low: $%.2f, Close: $%.2f",
-				spike.Timestamp.Format(time.RFC3339), rangeRatio*100, bodyRatio*100, candle.High, candle.Low, candle.Close)
 		}
 	}
 
@@ -0,0 +1,161 @@
+package eventlistener
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"spikeshield/contracts"
+	"spikeshield/db"
+	"spikeshield/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// stateLogIndex places a policy read from chain state after every log of the block it
+// was read at, since the state already reflects them
+const stateLogIndex = math.MaxInt32
+
+// FullSync re-reads the policies of every user found in the database from the chain.
+// Policies that differ from their projection are journaled as PolicyBackfilled events
+// and projected, like the policies backfilled by the listener. Balances are not touched:
+// they only change through journaled Transfer deltas.
+func FullSync(cfg *utils.Config, store *db.Store) error {
+	if cfg == nil || cfg.RPC.URL == "" {
+		utils.LogInfo("RPC not configured; skipping full sync")
+		return nil
+	}
+
+	client, err := ethclient.Dial(cfg.RPC.URL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RPC: %w", err)
+	}
+	defer client.Close()
+
+	// Update policies for every distinct user in policies table
+	users, err := store.Policies.GetDistinctPolicyUsers()
+	if err != nil {
+		utils.LogError("Failed to query policy users for full sync: %v", err)
+	} else {
+		utils.LogInfo("FullSync: updating policies for %d users", len(users))
+		for _, u := range users {
+			if err := syncPoliciesForUser(client, cfg, store, u); err != nil {
+				utils.LogError("Failed to sync policies for user %s: %v", u, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// UpsertForUser re-reads a single user's policies from the chain (called when frontend
+// links wallet); see FullSync
+func UpsertForUser(cfg *utils.Config, store *db.Store, userAddr string) error {
+	if cfg == nil || cfg.RPC.URL == "" {
+		utils.LogInfo("RPC not configured; skipping user upsert")
+		return nil
+	}
+
+	client, err := ethclient.Dial(cfg.RPC.URL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RPC: %w", err)
+	}
+	defer client.Close()
+
+	// Update policies for this user
+	if err := syncPoliciesForUser(client, cfg, store, userAddr); err != nil {
+		utils.LogError("Failed to sync policies for user %s: %v", userAddr, err)
+		return err
+	}
+
+	return nil
+}
+
+// syncPoliciesForUser reads user's policies on-chain at the latest block and journals
+// those whose projection is missing or out of date
+func syncPoliciesForUser(client *ethclient.Client, cfg *utils.Config, store *db.Store, userAddr string) error {
+	ctx := context.Background()
+	poolAddr := common.HexToAddress(cfg.RPC.ContractAddress)
+	pool, err := contracts.NewInsurancePool(poolAddr, client)
+	if err != nil {
+		return err
+	}
+
+	// Pin the read to one block, which positions the events in the journal
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get latest block: %w", err)
+	}
+
+	// Get all user policies at once using typed binding for safety
+	user := common.HexToAddress(userAddr)
+	policies, err := pool.GetUserPolicies(&bind.CallOpts{Context: ctx, BlockNumber: head.Number}, user)
+	if err != nil {
+		return err
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+	projector := NewProjector(store, cfg.TokenDecimals())
+
+	// The index in getUserPolicies is the on-chain policy ID
+	for i, policy := range policies {
+		status := "inactive"
+		if policy.Active {
+			status = "active"
+		}
+		if policy.Claimed {
+			status = "claimed"
+		}
+
+		policyID := big.NewInt(int64(i))
+		current, err := store.Policies.GetPolicyByOnchainID(policy.User.Hex(), policyID.Int64())
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && current.Status == status &&
+			current.Premium.Raw.Cmp(policy.Premium) == 0 &&
+			current.CoverageAmount.Raw.Cmp(policy.CoverageAmount) == 0 &&
+			current.ExpiryTime.Equal(time.Unix(policy.ExpiryTime.Int64(), 0)) {
+			continue // Projection is up to date
+		}
+
+		ev := &db.ChainEvent{
+			ContractAddress: poolAddr.Hex(),
+			EventName:       policyBackfilled,
+			BlockNumber:     head.Number.Uint64(),
+			BlockHash:       head.Hash().Hex(),
+			BlockTime:       time.Unix(int64(head.Time), 0).UTC(),
+			// Not a transaction: a stable key, so each block's read is journaled once
+			TxHash:   crypto.Keccak256Hash([]byte(fmt.Sprintf("%s:%s:%s:%d", policyBackfilled, policy.User.Hex(), policyID, head.Number.Uint64()))).Hex(),
+			LogIndex: stateLogIndex,
+			Args: map[string]string{
+				"user":         policy.User.Hex(),
+				"policyId":     policyID.String(),
+				"premium":      policy.Premium.String(),
+				"coverage":     policy.CoverageAmount.String(),
+				"purchaseTime": policy.PurchaseTime.String(),
+				"expiryTime":   policy.ExpiryTime.String(),
+				"status":       status,
+			},
+		}
+		inserted, err := store.Events.InsertChainEvent(ev)
+		if err != nil {
+			utils.LogError("Failed to journal policy %d of user %s: %v", i, policy.User.Hex(), err)
+			continue
+		}
+		if inserted {
+			if err := projector.Project(ev); err != nil {
+				utils.LogError("Failed to project policy %d of user %s: %v", i, policy.User.Hex(), err)
+			}
+		}
+	}
+
+	return nil
+}
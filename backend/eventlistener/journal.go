@@ -0,0 +1,98 @@
+package eventlistener
+
+import (
+	"fmt"
+	"math/big"
+
+	"spikeshield/db"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// decodeLog decodes a contract log into a chain_events journal entry.
+// Returns nil if the log's event is not part of contractABI.
+func decodeLog(contractABI abi.ABI, vLog types.Log) (*db.ChainEvent, error) {
+	if len(vLog.Topics) == 0 {
+		return nil, nil
+	}
+
+	event, err := contractABI.EventByID(vLog.Topics[0])
+	if err != nil {
+		return nil, nil
+	}
+
+	// Non-indexed arguments come from data, indexed ones from topics[1:]
+	values := make(map[string]interface{})
+	if err := event.Inputs.UnpackIntoMap(values, vLog.Data); err != nil {
+		return nil, fmt.Errorf("failed to unpack %s event: %w", event.Name, err)
+	}
+
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(values, indexed, vLog.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("failed to parse %s topics: %w", event.Name, err)
+	}
+
+	args := make(map[string]string, len(values))
+	for name, v := range values {
+		args[name] = formatArg(v)
+	}
+
+	return &db.ChainEvent{
+		ContractAddress: vLog.Address.Hex(),
+		EventName:       event.Name,
+		BlockNumber:     vLog.BlockNumber,
+		BlockHash:       vLog.BlockHash.Hex(),
+		TxHash:          vLog.TxHash.Hex(),
+		LogIndex:        vLog.Index,
+		Args:            args,
+	}, nil
+}
+
+// formatArg renders a decoded ABI value in the journal's string form
+func formatArg(v interface{}) string {
+	switch val := v.(type) {
+	case *big.Int:
+		return val.String()
+	case common.Address:
+		return val.Hex()
+	case common.Hash:
+		return val.Hex()
+	case []byte:
+		return hexutil.Encode(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// eventBigArg reads an integer argument from a journaled event
+func eventBigArg(ev *db.ChainEvent, name string) (*big.Int, error) {
+	raw, ok := ev.Args[name]
+	if !ok {
+		return nil, fmt.Errorf("%s event %d has no %q argument", ev.EventName, ev.ID, name)
+	}
+	v, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return nil, fmt.Errorf("%s event %d has invalid %q argument: %s", ev.EventName, ev.ID, name, raw)
+	}
+	return v, nil
+}
+
+// eventAddressArg reads an address argument from a journaled event
+func eventAddressArg(ev *db.ChainEvent, name string) (common.Address, error) {
+	raw, ok := ev.Args[name]
+	if !ok {
+		return common.Address{}, fmt.Errorf("%s event %d has no %q argument", ev.EventName, ev.ID, name)
+	}
+	if !common.IsHexAddress(raw) {
+		return common.Address{}, fmt.Errorf("%s event %d has invalid %q argument: %s", ev.EventName, ev.ID, name, raw)
+	}
+	return common.HexToAddress(raw), nil
+}
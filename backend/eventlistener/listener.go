@@ -2,19 +2,23 @@ package eventlistener
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
-	"math"
 	"math/big"
 	"time"
 
 	"spikeshield/contracts"
 	"spikeshield/db"
+	"spikeshield/events"
 	"spikeshield/utils"
+	"spikeshield/webhooks"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
@@ -22,36 +26,37 @@ import (
 type EventListener struct {
 	client          *ethclient.Client
 	contractAddress common.Address
+	contract        *contracts.InsurancePool
 	contractABI     abi.ABI
+	projector       *Projector
+	store           *db.Store
 	pollInterval    time.Duration
+
+	// blockTimes caches header timestamps for the chunk currently being processed
+	blockTimes map[uint64]time.Time
+
+	// onOracleUpdated is notified when the contract's oracle changes
+	onOracleUpdated func(newOracle common.Address)
 }
 
 // TokenListener listens to ERC20 Transfer events for a specific token and updates balances
 type TokenListener struct {
 	client       *ethclient.Client
 	tokenAddress common.Address
+	token        *contracts.MockUSDT
 	contractABI  abi.ABI
+	projector    *Projector
+	store        *db.Store
 	pollInterval time.Duration
 	decimals     int
-}
 
-// PolicyPurchasedEvent represents the PolicyPurchased event from the contract
-type PolicyPurchasedEvent struct {
-	PolicyId   *big.Int
-	Premium    *big.Int
-	Coverage   *big.Int
-	ExpiryTime *big.Int
-}
-
-// PayoutExecutedEvent represents the PayoutExecuted event from the contract
-type PayoutExecutedEvent struct {
-	PolicyId *big.Int
-	SpikeId  *big.Int
-	Amount   *big.Int
+	// Sampled balanceOf reconciliation; disabled when reconcileInterval is 0
+	reconcileInterval time.Duration
+	reconcileSample   int
 }
 
 // NewEventListener creates a new event listener instance
-func NewEventListener(rpcURL, contractAddr string, pollInterval time.Duration) (*EventListener, error) {
+func NewEventListener(rpcURL, contractAddr string, pollInterval time.Duration, decimals int, store *db.Store) (*EventListener, error) {
 	client, err := ethclient.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Ethereum client: %w", err)
@@ -64,16 +69,26 @@ func NewEventListener(rpcURL, contractAddr string, pollInterval time.Duration) (
 	}
 	contractABI := *parsedABI
 
+	contractAddress := common.HexToAddress(contractAddr)
+	contract, err := contracts.NewInsurancePool(contractAddress, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create contract instance: %w", err)
+	}
+
 	return &EventListener{
 		client:          client,
-		contractAddress: common.HexToAddress(contractAddr),
+		contractAddress: contractAddress,
+		contract:        contract,
 		contractABI:     contractABI,
+		projector:       NewProjector(store, decimals),
+		store:           store,
 		pollInterval:    pollInterval,
+		blockTimes:      make(map[uint64]time.Time),
 	}, nil
 }
 
 // NewTokenListener creates a listener for an ERC20 token Transfer events
-func NewTokenListener(rpcURL, tokenAddr string, pollInterval time.Duration, decimals int) (*TokenListener, error) {
+func NewTokenListener(rpcURL, tokenAddr string, pollInterval time.Duration, decimals int, store *db.Store) (*TokenListener, error) {
 	client, err := ethclient.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Ethereum client: %w", err)
@@ -85,15 +100,47 @@ func NewTokenListener(rpcURL, tokenAddr string, pollInterval time.Duration, deci
 	}
 	contractABI := *parsedABI
 
+	tokenAddress := common.HexToAddress(tokenAddr)
+	token, err := contracts.NewMockUSDT(tokenAddress, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token instance: %w", err)
+	}
+
 	return &TokenListener{
 		client:       client,
-		tokenAddress: common.HexToAddress(tokenAddr),
+		tokenAddress: tokenAddress,
+		token:        token,
 		contractABI:  contractABI,
+		projector:    NewProjector(store, decimals),
+		store:        store,
 		pollInterval: pollInterval,
 		decimals:     decimals,
 	}, nil
 }
 
+// EnableReconciliation makes the listener compare `sample` random indexed balances
+// with balanceOf every `interval`, recording drift in balance_checks. Must be called before Start.
+func (tl *TokenListener) EnableReconciliation(interval time.Duration, sample int) {
+	tl.reconcileInterval = interval
+	tl.reconcileSample = sample
+}
+
+// SetOracleHandler registers a callback invoked when an oracle change is indexed.
+// Must be called before Start.
+func (el *EventListener) SetOracleHandler(fn func(newOracle common.Address)) {
+	el.onOracleUpdated = fn
+}
+
+// SetEvents publishes indexed payouts on payouts:ADDRESS. Must be called before Start.
+func (el *EventListener) SetEvents(bus *events.Bus) {
+	el.projector.SetEvents(bus)
+}
+
+// SetWebhooks notifies partner webhooks of indexed payouts. Must be called before Start.
+func (el *EventListener) SetWebhooks(d *webhooks.Dispatcher) {
+	el.projector.SetWebhooks(d)
+}
+
 // Start begins listening for contract events
 func (el *EventListener) Start(ctx context.Context) error {
 	utils.LogInfo("🎧 Event listener started for contract: %s", el.contractAddress.Hex())
@@ -141,6 +188,15 @@ func (tl *TokenListener) Start(ctx context.Context) error {
 	ticker := time.NewTicker(tl.pollInterval)
 	defer ticker.Stop()
 
+	// Reconciliation runs on the same goroutine as syncing, so balances are
+	// never mid-update while they are compared with the chain
+	var reconcileC <-chan time.Time
+	if tl.reconcileInterval > 0 && tl.reconcileSample > 0 {
+		reconcileTicker := time.NewTicker(tl.reconcileInterval)
+		defer reconcileTicker.Stop()
+		reconcileC = reconcileTicker.C
+	}
+
 	// Initial sync
 	if err := tl.syncEvents(ctx); err != nil {
 		utils.LogError("Initial token event sync failed: %v", err)
@@ -156,14 +212,26 @@ func (tl *TokenListener) Start(ctx context.Context) error {
 			if err := tl.syncEvents(ctx); err != nil {
 				utils.LogError("Token event sync failed: %v", err)
 			}
+		case <-reconcileC:
+			if err := tl.reconcile(ctx); err != nil {
+				utils.LogError("Balance reconciliation failed: %v", err)
+			}
 		}
 	}
 }
 
+// retryBatch is how many unprojected journal events a sync retries
+const retryBatch = 100
+
+// policyBackfilled is the synthetic journal event of a policy read from chain state
+const policyBackfilled = "PolicyBackfilled"
+
 // syncEvents fetches and processes new events since last sync
 func (el *EventListener) syncEvents(ctx context.Context) error {
+	el.retryUnprojected()
+
 	// Get last synced block
-	lastBlock, err := db.GetLastSyncedBlock(el.contractAddress)
+	lastBlock, err := el.store.SyncState.GetLastSyncedBlock(el.contractAddress)
 	if err != nil {
 		return fmt.Errorf("failed to get last synced block: %w", err)
 	}
@@ -201,17 +269,77 @@ func (el *EventListener) syncEvents(ctx context.Context) error {
 	}
 
 	// Update last synced block
-	if err := db.UpdateLastSyncedBlock(el.contractAddress, currentBlock); err != nil {
+	if err := el.store.SyncState.UpdateLastSyncedBlock(el.contractAddress, currentBlock); err != nil {
 		return fmt.Errorf("failed to update sync state: %w", err)
 	}
 
+	// Policy parameter changes emit no event, so compare the getters against the history
+	if err := el.syncPoolParams(ctx, currentBlock); err != nil {
+		utils.LogError("Failed to sync pool parameters: %v", err)
+	}
+
+	return nil
+}
+
+// syncPoolParams reads the oracle and policy parameters at the given block and
+// appends them to the pool_params history when they differ from the last snapshot
+func (el *EventListener) syncPoolParams(ctx context.Context, blockNumber uint64) error {
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(blockNumber)}
+
+	oracle, err := el.contract.Oracle(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read oracle: %w", err)
+	}
+	premium, err := el.contract.PremiumAmount(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read premiumAmount: %w", err)
+	}
+	coverage, err := el.contract.CoverageAmount(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read coverageAmount: %w", err)
+	}
+	duration, err := el.contract.CoverageDuration(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read coverageDuration: %w", err)
+	}
+
+	current := &db.PoolParams{
+		ContractAddress:  el.contractAddress.Hex(),
+		Oracle:           oracle.Hex(),
+		PremiumAmount:    premium,
+		CoverageAmount:   coverage,
+		CoverageDuration: duration.Int64(),
+		Source:           "poll",
+		BlockNumber:      blockNumber,
+	}
+
+	latest, err := el.store.Events.GetLatestPoolParams(current.ContractAddress)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if latest != nil && latest.SameAs(current) {
+		return nil
+	}
+
+	utils.LogInfo("⚙️  Pool parameters at block %d: oracle=%s premium=%s coverage=%s duration=%ds",
+		blockNumber, current.Oracle, premium.String(), coverage.String(), current.CoverageDuration)
+	if err := el.store.Events.InsertPoolParams(current); err != nil {
+		return err
+	}
+
+	// Covers oracle rotations whose event fell outside the sync window
+	if latest != nil && latest.Oracle != current.Oracle && el.onOracleUpdated != nil {
+		el.onOracleUpdated(oracle)
+	}
 	return nil
 }
 
 // syncEvents fetches and processes Transfer events for the token
 func (tl *TokenListener) syncEvents(ctx context.Context) error {
+	tl.retryUnprojected()
+
 	// Get last synced block
-	lastBlock, err := db.GetLastSyncedBlock(tl.tokenAddress)
+	lastBlock, err := tl.store.SyncState.GetLastSyncedBlock(tl.tokenAddress)
 	if err != nil {
 		return fmt.Errorf("failed to get last synced block: %w", err)
 	}
@@ -249,7 +377,7 @@ func (tl *TokenListener) syncEvents(ctx context.Context) error {
 	}
 
 	// Update last synced block
-	if err := db.UpdateLastSyncedBlock(tl.tokenAddress, currentBlock); err != nil {
+	if err := tl.store.SyncState.UpdateLastSyncedBlock(tl.tokenAddress, currentBlock); err != nil {
 		return fmt.Errorf("failed to update sync state: %w", err)
 	}
 
@@ -269,8 +397,11 @@ func (el *EventListener) fetchAndProcessEvents(ctx context.Context, fromBlock, t
 		return err
 	}
 
+	// Block timestamps are only needed while processing this chunk
+	el.blockTimes = make(map[uint64]time.Time)
+
 	for _, vLog := range logs {
-		if err := el.processLog(vLog); err != nil {
+		if err := el.processLog(ctx, vLog); err != nil {
 			utils.LogError("Failed to process log (tx: %s, index: %d): %v", vLog.TxHash.Hex(), vLog.Index, err)
 			continue
 		}
@@ -313,147 +444,165 @@ func (tl *TokenListener) fetchAndProcessEvents(ctx context.Context, fromBlock, t
 // FullResync removed: full log-scan approach deprecated in favor of on-chain
 // enumeration helpers added into contracts (getHoldersBalances / getAllPolicyOwners)
 
-// processLog processes a single log entry
-func (el *EventListener) processLog(vLog types.Log) error {
-	eventSignature := vLog.Topics[0].Hex()
-
-	switch eventSignature {
-	case el.contractABI.Events["PolicyPurchased"].ID.Hex():
-		utils.LogInfo("Detected PolicyPurchased event in tx %s", vLog.TxHash.Hex())
-		return el.handlePolicyPurchased(vLog)
-	case el.contractABI.Events["PayoutExecuted"].ID.Hex():
-		utils.LogInfo("Detected PayoutExecuted event in tx %s", vLog.TxHash.Hex())
-		return el.handlePayoutExecuted(vLog)
-	default:
-		// Unknown event, skip
-		return nil
+// blockTimestamp returns the timestamp of the given block, using the per-chunk cache
+func (el *EventListener) blockTimestamp(ctx context.Context, blockNumber uint64) (time.Time, error) {
+	if t, ok := el.blockTimes[blockNumber]; ok {
+		return t, nil
 	}
-}
-
-// processLog handles token Transfer events
-func (tl *TokenListener) processLog(vLog types.Log) error {
-	eventSignature := vLog.Topics[0].Hex()
 
-	if eventSignature == tl.contractABI.Events["Transfer"].ID.Hex() {
-		return tl.handleTransfer(vLog)
+	header, err := el.client.HeaderByNumber(ctx, new(big.Int).SetUint64(blockNumber))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get header for block %d: %w", blockNumber, err)
 	}
-	return nil
+
+	t := time.Unix(int64(header.Time), 0)
+	el.blockTimes[blockNumber] = t
+	return t, nil
 }
 
-// handleTransfer processes Transfer events and updates balances in DB
-func (tl *TokenListener) handleTransfer(vLog types.Log) error {
-	// Parse indexed from and to addresses from topics
-	if len(vLog.Topics) < 3 {
-		return fmt.Errorf("invalid Transfer log topics")
+// processLog journals a single log entry and applies it to the projections
+func (el *EventListener) processLog(ctx context.Context, vLog types.Log) error {
+	ev, err := decodeLog(el.contractABI, vLog)
+	if err != nil || ev == nil {
+		// Unknown event, skip
+		return err
 	}
 
-	from := common.HexToAddress(vLog.Topics[1].Hex())
-	to := common.HexToAddress(vLog.Topics[2].Hex())
-
-	// Parse non-indexed data (value)
-	var event struct {
-		Value *big.Int
-	}
-	if err := tl.contractABI.UnpackIntoInterface(&event, "Transfer", vLog.Data); err != nil {
-		return fmt.Errorf("failed to unpack Transfer event: %w", err)
+	// The contract uses block.timestamp for purchase and payout times
+	ev.BlockTime, err = el.blockTimestamp(ctx, vLog.BlockNumber)
+	if err != nil {
+		return err
 	}
 
-	// Convert value to float using token decimals
-	denom := math.Pow10(tl.decimals)
-	amountFloat := new(big.Float).Quo(new(big.Float).SetInt(event.Value), new(big.Float).SetFloat64(denom))
-	amountVal, _ := amountFloat.Float64()
+	// Journaled ahead of the payout, so a rebuild projects the policy before linking to it
+	if ev.EventName == "PayoutExecuted" {
+		if err := el.backfillPolicy(ctx, ev); err != nil {
+			utils.LogError("Failed to backfill claimed policy for tx %s: %v", ev.TxHash, err)
+		}
+	}
 
-	utils.LogInfo("🔁 Transfer: from=%s to=%s amount=%f", from.Hex(), to.Hex(), amountVal)
+	inserted, err := el.store.Events.InsertChainEvent(ev)
+	if err != nil {
+		return fmt.Errorf("failed to journal %s event: %w", ev.EventName, err)
+	}
+	if !inserted {
+		// Already journaled by an earlier sync of this range; retryUnprojected projects
+		// it if that failed
+		return nil
+	}
 
-	tokenHex := tl.tokenAddress.Hex()
+	utils.LogInfo("Detected %s event in tx %s", ev.EventName, ev.TxHash)
+	return el.project(ev)
+}
 
-	// Update 'from' balance (skip zero address)
-	zero := common.Address{}
-	if from != zero {
-		if err := db.UpsertBalanceFromChain(tl.client, utils.AppConfig, tokenHex, from.Hex()); err != nil {
-			utils.LogError("Failed to refresh on-chain balance for from=%s: %v", from.Hex(), err)
-		}
+// project applies a journaled event and notifies the oracle watcher of oracle changes
+func (el *EventListener) project(ev *db.ChainEvent) error {
+	if err := el.projector.Project(ev); err != nil {
+		return err
 	}
 
-	// Update 'to' balance (skip zero address)
-	if to != zero {
-		if err := db.UpsertBalanceFromChain(tl.client, utils.AppConfig, tokenHex, to.Hex()); err != nil {
-			utils.LogError("Failed to refresh on-chain balance for to=%s: %v", to.Hex(), err)
+	if ev.EventName == "OracleUpdated" && el.onOracleUpdated != nil {
+		newOracle, err := eventAddressArg(ev, "newOracle")
+		if err != nil {
+			return err
 		}
+		el.onOracleUpdated(newOracle)
 	}
-
 	return nil
 }
 
-// handlePolicyPurchased processes PolicyPurchased events
-func (el *EventListener) handlePolicyPurchased(vLog types.Log) error {
-	var event PolicyPurchasedEvent
-
-	// Parse indexed user address from topics[1]
-	user := common.HexToAddress(vLog.Topics[1].Hex())
+// processLog journals token Transfer events and applies them to balances
+func (tl *TokenListener) processLog(vLog types.Log) error {
+	ev, err := decodeLog(tl.contractABI, vLog)
+	if err != nil || ev == nil || ev.EventName != "Transfer" {
+		return err
+	}
 
-	// Parse non-indexed data
-	err := el.contractABI.UnpackIntoInterface(&event, "PolicyPurchased", vLog.Data)
+	inserted, err := tl.store.Events.InsertChainEvent(ev)
 	if err != nil {
-		return fmt.Errorf("failed to unpack PolicyPurchased event: %w", err)
+		return fmt.Errorf("failed to journal Transfer event: %w", err)
+	}
+	if !inserted {
+		return nil
 	}
 
-	utils.LogInfo("📝 PolicyPurchased: user=%s, policyId=%s, premium=%s, coverage=%s",
-		user.Hex(), event.PolicyId.String(), event.Premium.String(), event.Coverage.String())
-
-	// Store event in database
-	return db.InsertPolicyFromEvent(user, event.Premium, event.Coverage, event.ExpiryTime, vLog)
+	return tl.projector.Project(ev)
 }
 
-// handlePayoutExecuted processes PayoutExecuted events
-func (el *EventListener) handlePayoutExecuted(vLog types.Log) error {
-	var event PayoutExecutedEvent
-
-	// Parse indexed user address from topics[1]
-	user := common.HexToAddress(vLog.Topics[1].Hex())
-
-	// Parse non-indexed data
-	err := el.contractABI.UnpackIntoInterface(&event, "PayoutExecuted", vLog.Data)
+// retryUnprojected projects the journaled events whose projection failed on an earlier
+// sync. The sync block has moved past them, so the journal is the only place to find them.
+func (el *EventListener) retryUnprojected() {
+	pending, err := el.store.Events.GetUnprojectedChainEvents(el.contractAddress.Hex(), retryBatch)
 	if err != nil {
-		return fmt.Errorf("failed to unpack PayoutExecuted event: %w", err)
+		utils.LogError("Failed to read unprojected events: %v", err)
+		return
 	}
+	for _, ev := range pending {
+		if err := el.project(ev); err != nil {
+			utils.LogError("Failed to project %s event %d (tx: %s, index: %d): %v", ev.EventName, ev.ID, ev.TxHash, ev.LogIndex, err)
+		}
+	}
+}
 
-	// Convert wei to USDT (6 decimals)
-	amountFloat := new(big.Float).Quo(new(big.Float).SetInt(event.Amount), big.NewFloat(1e6))
-	amountValue, _ := amountFloat.Float64()
-
-	utils.LogInfo("💰 PayoutExecuted: user=%s, policyId=%s, spikeId=%s, amount=%s (%.2f USDT)",
-		user.Hex(), event.PolicyId.String(), event.SpikeId.String(), event.Amount.String(), amountValue)
-
-	// Find the most recent active policy for this user
-	var dbPolicyId int
-	policyQuery := `
-		SELECT id FROM policies
-		WHERE user_address = $1 AND status = 'active'
-		ORDER BY id DESC LIMIT 1
-	`
-	err = db.DB.QueryRow(policyQuery, user.Hex()).Scan(&dbPolicyId)
+// retryUnprojected projects the journaled Transfer events whose projection failed
+func (tl *TokenListener) retryUnprojected() {
+	pending, err := tl.store.Events.GetUnprojectedChainEvents(tl.tokenAddress.Hex(), retryBatch)
 	if err != nil {
-		utils.LogError("Active policy not found for user %s, storing payout without policy link: %v", user.Hex(), err)
-		dbPolicyId = 0
-	} else {
-		// Update policy status to 'claimed'
-		updateQuery := `UPDATE policies SET status = 'claimed' WHERE id = $1`
-		_, err = db.DB.Exec(updateQuery, dbPolicyId)
-		if err != nil {
-			utils.LogError("Failed to update policy status: %v", err)
+		utils.LogError("Failed to read unprojected token events: %v", err)
+		return
+	}
+	for _, ev := range pending {
+		if err := tl.projector.Project(ev); err != nil {
+			utils.LogError("Failed to project Transfer event %d (tx: %s, index: %d): %v", ev.ID, ev.TxHash, ev.LogIndex, err)
 		}
 	}
+}
 
-	// Insert payout
-	payout := &db.Payout{
-		PolicyID:    dbPolicyId,
-		UserAddress: user.Hex(),
-		Amount:      amountValue,
-		SpikeID:     int(event.SpikeId.Int64()),
-		TxHash:      vLog.TxHash.Hex(),
-		ExecutedAt:  time.Now(),
+// backfillPolicy journals the policy claimed by a PayoutExecuted event, read from chain
+// state, when its PolicyPurchased event was emitted before the listener's sync window.
+// The synthetic PolicyBackfilled event shares the payout's position and is journaled
+// first, so a projection rebuild restores the policy before linking the payout to it.
+func (el *EventListener) backfillPolicy(ctx context.Context, ev *db.ChainEvent) error {
+	user, err := eventAddressArg(ev, "user")
+	if err != nil {
+		return err
+	}
+	policyID, err := eventBigArg(ev, "policyId")
+	if err != nil {
+		return err
+	}
+
+	_, err = el.store.Policies.GetPolicyByOnchainID(user.Hex(), policyID.Int64())
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	onchain, err := el.contract.GetPolicy(&bind.CallOpts{Context: ctx}, user, policyID)
+	if err != nil {
+		return fmt.Errorf("failed to get on-chain policy %s: %w", policyID.String(), err)
+	}
+
+	backfill := &db.ChainEvent{
+		ContractAddress: ev.ContractAddress,
+		EventName:       policyBackfilled,
+		BlockNumber:     ev.BlockNumber,
+		BlockHash:       ev.BlockHash,
+		BlockTime:       ev.BlockTime,
+		// Not a transaction: a stable key, so the policy is journaled once
+		TxHash:   crypto.Keccak256Hash([]byte(fmt.Sprintf("%s:%s:%s", policyBackfilled, user.Hex(), policyID))).Hex(),
+		LogIndex: ev.LogIndex,
+		Args: map[string]string{
+			"user":         user.Hex(),
+			"policyId":     policyID.String(),
+			"premium":      onchain.Premium.String(),
+			"coverage":     onchain.CoverageAmount.String(),
+			"purchaseTime": onchain.PurchaseTime.String(),
+			"expiryTime":   onchain.ExpiryTime.String(),
+		},
+	}
+	inserted, err := el.store.Events.InsertChainEvent(backfill)
+	if err != nil || !inserted {
+		return err
 	}
-	err = db.InsertPayout(payout)
-	return err
+	return el.projector.Project(backfill)
 }
@@ -0,0 +1,299 @@
+package eventlistener
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"time"
+
+	"spikeshield/db"
+	"spikeshield/events"
+	"spikeshield/utils"
+	"spikeshield/webhooks"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Projector applies journaled chain events to the tables derived from them
+// (policies, payouts, balances, pool_params). It only reads the event and earlier
+// projections, so replaying the journal in order always produces the same result.
+type Projector struct {
+	store    *db.Store
+	decimals int                  // decimals of the pool's token, stored with every amount
+	events   *events.Bus          // receives projected payouts, nil for none (e.g. rebuilds)
+	webhooks *webhooks.Dispatcher // notified of projected payouts, nil for none
+}
+
+// NewProjector creates a projector writing to store. decimals <= 0 defaults to USDT's 6.
+func NewProjector(store *db.Store, decimals int) *Projector {
+	if decimals <= 0 {
+		decimals = utils.DefaultTokenDecimals
+	}
+	return &Projector{store: store, decimals: decimals}
+}
+
+// SetEvents publishes every projected payout as "payout.executed" to bus
+func (p *Projector) SetEvents(bus *events.Bus) {
+	p.events = bus
+}
+
+// SetWebhooks emits every projected payout as a "payout.executed" webhook event
+func (p *Projector) SetWebhooks(d *webhooks.Dispatcher) {
+	p.webhooks = d
+}
+
+// Project applies a journaled event and marks it projected in the same transaction. An
+// event whose projection fails stays unprojected, with none of its writes, and is retried
+// (see retryUnprojected). Payouts are announced once the transaction has committed.
+func (p *Projector) Project(ev *db.ChainEvent) error {
+	var payout *db.Payout
+	err := p.store.Events.ProjectChainEvent(ev.ID, func(store *db.Store) error {
+		var err error
+		payout, err = p.apply(store, ev)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	p.announcePayout(payout, ev)
+	return nil
+}
+
+// Apply projects a single journaled event without marking it. Events without a
+// projection are ignored.
+func (p *Projector) Apply(ev *db.ChainEvent) error {
+	payout, err := p.apply(p.store, ev)
+	if err != nil {
+		return err
+	}
+	p.announcePayout(payout, ev)
+	return nil
+}
+
+// apply writes the projection of ev to store. Returns the payout it recorded, if any.
+func (p *Projector) apply(store *db.Store, ev *db.ChainEvent) (*db.Payout, error) {
+	switch ev.EventName {
+	case "PolicyPurchased":
+		return nil, p.applyPolicyPurchased(store, ev)
+	case policyBackfilled:
+		return nil, p.applyPolicyBackfilled(store, ev)
+	case "PayoutExecuted":
+		return p.applyPayoutExecuted(store, ev)
+	case "OracleUpdated":
+		return nil, p.applyOracleUpdated(store, ev)
+	case "Transfer":
+		return nil, p.applyTransfer(store, ev)
+	default:
+		return nil, nil
+	}
+}
+
+// announcePayout publishes a newly recorded payout and queues its webhooks
+func (p *Projector) announcePayout(payout *db.Payout, ev *db.ChainEvent) {
+	if payout == nil {
+		return
+	}
+	p.events.Publish(events.PayoutsTopic(payout.UserAddress), "payout.executed", payout)
+	eventID := fmt.Sprintf("%s:%s:%d", webhooks.EventPayoutExecuted, ev.TxHash, ev.LogIndex)
+	if err := p.webhooks.Emit(webhooks.EventPayoutExecuted, eventID, payout); err != nil {
+		utils.LogError("Failed to queue payout webhooks: %v", err)
+	}
+}
+
+// RebuildProjections empties policies, payouts and balances and replays the whole
+// chain_events journal into them. Events that fail to project stay unprojected, for the
+// listeners to retry. Returns the number of events replayed.
+func RebuildProjections(store *db.Store, decimals int) (int, error) {
+	if err := store.Events.ResetProjections(); err != nil {
+		return 0, fmt.Errorf("failed to reset projections: %w", err)
+	}
+
+	p := NewProjector(store, decimals)
+	count := 0
+	err := store.Events.ForEachChainEvent(func(ev *db.ChainEvent) error {
+		if err := p.Project(ev); err != nil {
+			utils.LogError("Failed to project %s event %d (tx: %s, index: %d): %v", ev.EventName, ev.ID, ev.TxHash, ev.LogIndex, err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("failed to read chain_events: %w", err)
+	}
+	return count, nil
+}
+
+// applyPolicyPurchased inserts the purchased policy
+func (p *Projector) applyPolicyPurchased(store *db.Store, ev *db.ChainEvent) error {
+	user, err := eventAddressArg(ev, "user")
+	if err != nil {
+		return err
+	}
+	policyID, err := eventBigArg(ev, "policyId")
+	if err != nil {
+		return err
+	}
+	premium, err := eventBigArg(ev, "premium")
+	if err != nil {
+		return err
+	}
+	coverage, err := eventBigArg(ev, "coverage")
+	if err != nil {
+		return err
+	}
+	expiryTime, err := eventBigArg(ev, "expiryTime")
+	if err != nil {
+		return err
+	}
+
+	utils.LogInfo("📝 PolicyPurchased: user=%s, policyId=%s, premium=%s, coverage=%s",
+		user.Hex(), policyID.String(), premium.String(), coverage.String())
+
+	return store.Policies.InsertPolicyFromEvent(user, policyID, utils.NewAmount(premium, p.decimals), utils.NewAmount(coverage, p.decimals), expiryTime, ev)
+}
+
+// applyPolicyBackfilled inserts or updates a policy read from chain state (see
+// backfillPolicy and syncPoliciesForUser)
+func (p *Projector) applyPolicyBackfilled(store *db.Store, ev *db.ChainEvent) error {
+	user, err := eventAddressArg(ev, "user")
+	if err != nil {
+		return err
+	}
+	values := make(map[string]*big.Int)
+	for _, name := range []string{"policyId", "premium", "coverage", "purchaseTime", "expiryTime"} {
+		if values[name], err = eventBigArg(ev, name); err != nil {
+			return err
+		}
+	}
+
+	// Backfills of a claimed policy carry no status: the payout journaled after them claims it
+	status := ev.Args["status"]
+	if status == "" {
+		status = "active"
+	}
+
+	utils.LogInfo("📝 PolicyBackfilled: user=%s, policyId=%s, status=%s", user.Hex(), values["policyId"].String(), status)
+
+	return store.Policies.UpsertPolicy(user.Hex(), values["policyId"].Int64(),
+		utils.NewAmount(values["premium"], p.decimals), utils.NewAmount(values["coverage"], p.decimals),
+		time.Unix(values["purchaseTime"].Int64(), 0), time.Unix(values["expiryTime"].Int64(), 0), status)
+}
+
+// applyPayoutExecuted records the payout and marks the claimed policy
+func (p *Projector) applyPayoutExecuted(store *db.Store, ev *db.ChainEvent) (*db.Payout, error) {
+	user, err := eventAddressArg(ev, "user")
+	if err != nil {
+		return nil, err
+	}
+	policyID, err := eventBigArg(ev, "policyId")
+	if err != nil {
+		return nil, err
+	}
+	spikeID, err := eventBigArg(ev, "spikeId")
+	if err != nil {
+		return nil, err
+	}
+	amount, err := eventBigArg(ev, "amount")
+	if err != nil {
+		return nil, err
+	}
+
+	amountValue := utils.NewAmount(amount, p.decimals)
+
+	utils.LogInfo("💰 PayoutExecuted: user=%s, policyId=%s, spikeId=%s, amount=%s (%s USDT)",
+		user.Hex(), policyID.String(), spikeID.String(), amount.String(), amountValue)
+
+	// Link the payout to the exact policy that was claimed
+	var dbPolicyId int
+	policy, err := store.Policies.GetPolicyByOnchainID(user.Hex(), policyID.Int64())
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		utils.LogError("Policy %s not found for user %s, storing payout without policy link", policyID.String(), user.Hex())
+	} else {
+		dbPolicyId = policy.ID
+		// A failed statement aborts the projection's transaction, so it cannot just be logged
+		if err := store.Policies.UpdatePolicyStatus(policy.ID, "claimed"); err != nil {
+			return nil, fmt.Errorf("failed to update policy status: %w", err)
+		}
+	}
+
+	payout := &db.Payout{
+		PolicyID:    dbPolicyId,
+		UserAddress: user.Hex(),
+		Amount:      amountValue,
+		SpikeID:     int(spikeID.Int64()),
+		TxHash:      ev.TxHash,
+		ExecutedAt:  ev.BlockTime,
+	}
+	if payout.ExecutedAt.IsZero() {
+		payout.ExecutedAt = time.Now()
+	}
+	inserted, err := store.Payouts.InsertPayout(payout)
+	if err != nil || !inserted {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// applyOracleUpdated appends the new oracle to the pool_params history,
+// carrying over the last known policy parameters
+func (p *Projector) applyOracleUpdated(store *db.Store, ev *db.ChainEvent) error {
+	newOracle, err := eventAddressArg(ev, "newOracle")
+	if err != nil {
+		return err
+	}
+
+	params := &db.PoolParams{ContractAddress: ev.ContractAddress}
+	latest, err := store.Events.GetLatestPoolParams(ev.ContractAddress)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if latest != nil {
+		params.PremiumAmount = latest.PremiumAmount
+		params.CoverageAmount = latest.CoverageAmount
+		params.CoverageDuration = latest.CoverageDuration
+	}
+	params.Oracle = newOracle.Hex()
+	params.Source = "OracleUpdated"
+	params.BlockNumber = ev.BlockNumber
+	params.TxHash = ev.TxHash
+	params.LogIndex = ev.LogIndex
+
+	utils.LogInfo("🔑 OracleUpdated: contract=%s newOracle=%s", ev.ContractAddress, params.Oracle)
+
+	return store.Events.InsertPoolParams(params)
+}
+
+// applyTransfer moves the transferred value between the sender's and receiver's balances
+func (p *Projector) applyTransfer(store *db.Store, ev *db.ChainEvent) error {
+	from, err := eventAddressArg(ev, "from")
+	if err != nil {
+		return err
+	}
+	to, err := eventAddressArg(ev, "to")
+	if err != nil {
+		return err
+	}
+	value, err := eventBigArg(ev, "value")
+	if err != nil {
+		return err
+	}
+
+	utils.LogInfo("🔁 Transfer: from=%s to=%s amount=%s", from.Hex(), to.Hex(), utils.FormatUnits(value, p.decimals))
+
+	// Balances move by the exact base-unit value; mints and burns only touch one side (skip zero address)
+	zero := common.Address{}
+	if from != zero {
+		if err := store.Balances.UpdateBalanceDelta(ev.ContractAddress, from.Hex(), utils.NewAmount(new(big.Int).Neg(value), p.decimals)); err != nil {
+			return fmt.Errorf("failed to debit %s: %w", from.Hex(), err)
+		}
+	}
+	if to != zero {
+		if err := store.Balances.UpdateBalanceDelta(ev.ContractAddress, to.Hex(), utils.NewAmount(value, p.decimals)); err != nil {
+			return fmt.Errorf("failed to credit %s: %w", to.Hex(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package eventlistener
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"spikeshield/db"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Addresses in the checksummed form the projector stores
+var (
+	testPool  = common.HexToAddress("0xaa").Hex()
+	testToken = common.HexToAddress("0xbb").Hex()
+	alice     = common.HexToAddress("0xa11c").Hex()
+	bob       = common.HexToAddress("0xb0b").Hex()
+	zeroAddr  = common.Address{}.Hex()
+)
+
+// chainEvent builds a journaled event at block/index with args given as name, value pairs
+func chainEvent(contract, name string, block uint64, index uint, args ...string) *db.ChainEvent {
+	ev := &db.ChainEvent{
+		ContractAddress: contract,
+		EventName:       name,
+		BlockNumber:     block,
+		BlockTime:       time.Unix(1700000000+int64(block)*12, 0).UTC(),
+		TxHash:          crypto.Keccak256Hash([]byte(fmt.Sprintf("%s:%s:%d:%d", contract, name, block, index))).Hex(),
+		LogIndex:        index,
+		Args:            make(map[string]string),
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		ev.Args[args[i]] = args[i+1]
+	}
+	return ev
+}
+
+func purchased(block uint64, user, policyID string) *db.ChainEvent {
+	return chainEvent(testPool, "PolicyPurchased", block, 0, "user", user, "policyId", policyID,
+		"premium", "10000000", "coverage", "100000000", "expiryTime", "1800000000")
+}
+
+func backfilled(block uint64, index uint, user, policyID string) *db.ChainEvent {
+	return chainEvent(testPool, policyBackfilled, block, index, "user", user, "policyId", policyID,
+		"premium", "10000000", "coverage", "100000000", "purchaseTime", "1700000000", "expiryTime", "1800000000")
+}
+
+// synced is the PolicyBackfilled event syncPoliciesForUser journals for a policy read at block
+func synced(block uint64, user, policyID, status string) *db.ChainEvent {
+	return chainEvent(testPool, policyBackfilled, block, stateLogIndex, "user", user, "policyId", policyID,
+		"premium", "10000000", "coverage", "100000000", "purchaseTime", "1700000000", "expiryTime", "1800000000",
+		"status", status)
+}
+
+func payout(block uint64, index uint, user, policyID string) *db.ChainEvent {
+	return chainEvent(testPool, "PayoutExecuted", block, index, "user", user, "policyId", policyID,
+		"spikeId", "7", "amount", "100000000")
+}
+
+func transfer(block uint64, from, to, value string) *db.ChainEvent {
+	return chainEvent(testToken, "Transfer", block, 0, "from", from, "to", to, "value", value)
+}
+
+// snapshot renders the projections of the test wallets so two stores can be compared
+func snapshot(t *testing.T, store *db.Store) string {
+	t.Helper()
+	var b strings.Builder
+	for _, user := range []string{alice, bob} {
+		policies, err := store.Policies.GetPoliciesForUser(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range policies {
+			fmt.Fprintf(&b, "policy %s #%d %s/%s %s\n", p.UserAddress, p.OnchainPolicyID, p.Premium, p.CoverageAmount, p.Status)
+		}
+		if bal, err := store.Balances.GetBalanceForUser(testToken, user); err == nil {
+			fmt.Fprintf(&b, "balance %s %s\n", user, bal.Balance)
+		}
+	}
+	payouts, _, err := store.Payouts.GetPayouts(db.ListQuery{Sort: "executed_at"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range payouts {
+		fmt.Fprintf(&b, "payout %s %s linked=%t\n", p.UserAddress, p.Amount, p.PolicyID != 0)
+	}
+	if params, err := store.Events.GetLatestPoolParams(testPool); err == nil {
+		fmt.Fprintf(&b, "oracle %s\n", params.Oracle)
+	}
+	return b.String()
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  []*db.ChainEvent
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "purchase",
+			events: []*db.ChainEvent{purchased(1, alice, "1")},
+			want:   "policy " + alice + " #1 10.000000/100.000000 active\n",
+		},
+		{
+			name:   "payout claims the policy",
+			events: []*db.ChainEvent{purchased(1, alice, "1"), payout(2, 0, alice, "1")},
+			want: "policy " + alice + " #1 10.000000/100.000000 claimed\n" +
+				"payout " + alice + " 100.000000 linked=true\n",
+		},
+		{
+			name:   "backfilled policy links the payout",
+			events: []*db.ChainEvent{backfilled(2, 3, alice, "4"), payout(2, 3, alice, "4")},
+			want: "policy " + alice + " #4 10.000000/100.000000 claimed\n" +
+				"payout " + alice + " 100.000000 linked=true\n",
+		},
+		{
+			name:   "synced policy state",
+			events: []*db.ChainEvent{purchased(1, alice, "1"), synced(5, alice, "1", "inactive")},
+			want:   "policy " + alice + " #1 10.000000/100.000000 inactive\n",
+		},
+		{
+			name:   "payout without policy",
+			events: []*db.ChainEvent{payout(2, 0, bob, "9")},
+			want:   "payout " + bob + " 100.000000 linked=false\n",
+		},
+		{
+			name: "transfers",
+			events: []*db.ChainEvent{
+				transfer(1, zeroAddr, alice, "5000000"),
+				transfer(2, alice, bob, "1250000"),
+			},
+			want: "balance " + alice + " 3.750000\nbalance " + bob + " 1.250000\n",
+		},
+		{
+			name:   "oracle update",
+			events: []*db.ChainEvent{chainEvent(testPool, "OracleUpdated", 1, 0, "newOracle", bob)},
+			want:   "oracle " + bob + "\n",
+		},
+		{
+			name:   "unknown event is ignored",
+			events: []*db.ChainEvent{chainEvent(testPool, "Paused", 1, 0)},
+		},
+		{
+			name:    "missing argument",
+			events:  []*db.ChainEvent{chainEvent(testPool, "PayoutExecuted", 1, 0, "user", alice)},
+			wantErr: `no "policyId" argument`,
+		},
+		{
+			name:    "invalid address",
+			events:  []*db.ChainEvent{transfer(1, "nobody", alice, "1")},
+			wantErr: `invalid "from" argument`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := db.NewMemoryStore().Store()
+			p := NewProjector(store, 6)
+			var err error
+			for _, ev := range tt.events {
+				if err = p.Apply(ev); err != nil {
+					break
+				}
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := snapshot(t, store); got != tt.want {
+				t.Errorf("projections:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRebuildProjections replays the journal into a fresh store and compares it with
+// the projections built live, in the order the listeners journal and project events
+func TestRebuildProjections(t *testing.T) {
+	journal := []*db.ChainEvent{
+		purchased(1, alice, "1"),
+		transfer(1, zeroAddr, alice, "5000000"),
+		// backfillPolicy journals the policy before the payout that needed it
+		backfilled(3, 2, bob, "2"),
+		payout(3, 2, bob, "2"),
+		payout(4, 0, alice, "1"),
+		transfer(5, alice, bob, "2000000"),
+		// syncPoliciesForUser journals chain state read at the latest block
+		synced(5, bob, "3", "active"),
+		chainEvent(testPool, "OracleUpdated", 6, 0, "newOracle", bob),
+	}
+
+	live := db.NewMemoryStore().Store()
+	p := NewProjector(live, 6)
+	for _, ev := range journal {
+		if _, err := live.Events.InsertChainEvent(ev); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Project(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A backfilled policy shares its payout's block and log index; journal order breaks the tie
+	rebuilt := db.NewMemoryStore().Store()
+	for _, journaled := range journal {
+		ev := *journaled
+		ev.ID, ev.ProjectedAt = 0, nil
+		if _, err := rebuilt.Events.InsertChainEvent(&ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count, err := RebuildProjections(rebuilt, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(journal) {
+		t.Errorf("replayed %d events, want %d", count, len(journal))
+	}
+	if got, want := snapshot(t, rebuilt), snapshot(t, live); got != want {
+		t.Errorf("rebuilt projections:\n%s\nwant:\n%s", got, want)
+	}
+	for _, contract := range []string{testPool, testToken} {
+		pending, err := rebuilt.Events.GetUnprojectedChainEvents(contract, retryBatch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != 0 {
+			t.Errorf("%s: %d events left unprojected", contract, len(pending))
+		}
+	}
+}
+
+// TestRebuildKeepsFailedEventsUnprojected checks that an event that cannot be projected
+// is left for the listeners to retry instead of aborting the rebuild
+func TestRebuildKeepsFailedEventsUnprojected(t *testing.T) {
+	store := db.NewMemoryStore().Store()
+	bad := chainEvent(testPool, "PayoutExecuted", 2, 0, "user", alice)
+	for _, ev := range []*db.ChainEvent{purchased(1, alice, "1"), bad} {
+		if _, err := store.Events.InsertChainEvent(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := RebuildProjections(store, 6); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := store.Events.GetUnprojectedChainEvents(testPool, retryBatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].EventName != "PayoutExecuted" {
+		t.Fatalf("unprojected = %v, want the PayoutExecuted event", pending)
+	}
+}
+
+// TestProjectPayoutTwice re-projects a PayoutExecuted event, as a retry after a lost
+// projected mark would, and expects one payout and no error
+func TestProjectPayoutTwice(t *testing.T) {
+	store := db.NewMemoryStore().Store()
+	p := NewProjector(store, 6)
+	for _, ev := range []*db.ChainEvent{purchased(1, alice, "1"), payout(2, 0, alice, "1")} {
+		if _, err := store.Events.InsertChainEvent(ev); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Project(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Project(payout(2, 0, alice, "1")); err != nil {
+		t.Fatalf("re-projecting the payout: %v", err)
+	}
+	payouts, _, err := store.Payouts.GetPayouts(db.ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payouts) != 1 {
+		t.Errorf("%d payouts, want 1", len(payouts))
+	}
+}
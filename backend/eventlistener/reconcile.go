@@ -0,0 +1,60 @@
+package eventlistener
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"spikeshield/db"
+	"spikeshield/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// reconcile compares a random sample of indexed balances with balanceOf at the last
+// synced block. Every comparison is recorded in balance_checks; drift usually means the
+// holder received tokens before the journal starts.
+func (tl *TokenListener) reconcile(ctx context.Context) error {
+	block, err := tl.store.SyncState.GetLastSyncedBlock(tl.tokenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to get last synced block: %w", err)
+	}
+	if block == 0 {
+		return nil // Nothing indexed yet
+	}
+
+	tokenHex := tl.tokenAddress.Hex()
+	sample, err := tl.store.Balances.SampleBalances(tokenHex, tl.reconcileSample)
+	if err != nil {
+		return fmt.Errorf("failed to sample balances: %w", err)
+	}
+
+	// Read at the synced block so transfers not yet indexed don't show up as drift
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(block)}
+
+	drifted := 0
+	for _, b := range sample {
+		chainBalance, err := tl.token.BalanceOf(opts, common.HexToAddress(b.UserAddress))
+		if err != nil {
+			utils.LogError("balanceOf failed for %s: %v", b.UserAddress, err)
+			continue
+		}
+
+		check := db.NewBalanceCheck(tokenHex, b.UserAddress, block, b.Balance.Raw, chainBalance)
+
+		if check.Drift.Sign() != 0 {
+			drifted++
+			utils.LogError("Balance drift for %s at block %d: indexed=%s chain=%s drift=%s",
+				b.UserAddress, block, b.Balance,
+				utils.FormatUnits(chainBalance, tl.decimals), utils.FormatUnits(check.Drift, tl.decimals))
+		}
+
+		if err := tl.store.Balances.InsertBalanceCheck(check); err != nil {
+			utils.LogError("Failed to record balance check for %s: %v", b.UserAddress, err)
+		}
+	}
+
+	utils.LogInfo("Reconciled %d sampled balances at block %d (%d drifted)", len(sample), block, drifted)
+	return nil
+}
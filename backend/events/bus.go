@@ -0,0 +1,180 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Topics. Prices and payouts are scoped by symbol and wallet address; "*" in place of
+// either matches every symbol or address.
+const (
+	TopicSpikes  = "spikes"
+	pricesPrefix = "prices:"
+	payoutPrefix = "payouts:"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before events are dropped
+const subscriberBuffer = 256
+
+// Event is one message on the bus
+type Event struct {
+	Seq   uint64 // increases by one per published event, across topics
+	Topic string
+	Type  string // e.g. "price", "spike.created", "payout.mined"
+	Data  interface{}
+	Time  time.Time
+}
+
+// PricesTopic is the topic of a symbol's candles
+func PricesTopic(symbol string) string {
+	return pricesPrefix + strings.ToUpper(symbol)
+}
+
+// PayoutsTopic is the topic of the payouts to a wallet
+func PayoutsTopic(address string) string {
+	return payoutPrefix + strings.ToLower(address)
+}
+
+// ParseTopic validates a topic a client asked for and returns its canonical form
+func ParseTopic(topic string) (string, error) {
+	topic = strings.TrimSpace(topic)
+	switch {
+	case topic == TopicSpikes:
+		return topic, nil
+	case strings.HasPrefix(topic, pricesPrefix):
+		symbol := strings.TrimPrefix(topic, pricesPrefix)
+		if symbol == "" || len(symbol) > 20 {
+			return "", fmt.Errorf("invalid topic %q (expected prices:SYMBOL)", topic)
+		}
+		return PricesTopic(symbol), nil
+	case strings.HasPrefix(topic, payoutPrefix):
+		address := strings.TrimPrefix(topic, payoutPrefix)
+		if address != "*" && !common.IsHexAddress(address) {
+			return "", fmt.Errorf("invalid topic %q (expected payouts:0x...)", topic)
+		}
+		return PayoutsTopic(address), nil
+	}
+	return "", fmt.Errorf("unknown topic %q (expected prices:SYMBOL, spikes or payouts:ADDRESS)", topic)
+}
+
+// Bus fans events out to in-process subscribers by topic. Publishing never blocks: a
+// subscriber that falls more than subscriberBuffer events behind misses the rest until
+// it catches up. A nil *Bus discards everything, so publishers need no checks.
+type Bus struct {
+	seq atomic.Uint64
+
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish delivers an event to every subscriber of topic
+func (b *Bus) Publish(topic, eventType string, data interface{}) {
+	if b == nil {
+		return
+	}
+	ev := Event{Seq: b.seq.Add(1), Topic: topic, Type: eventType, Data: data, Time: time.Now().UTC()}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		if !sub.matches(topic) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Subscription receives the events of its topics on C until its context is done, when C
+// is closed
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	topics map[string]struct{}
+}
+
+// Subscribe registers a subscription to topics (see ParseTopic); more can be added later
+func (b *Bus) Subscribe(ctx context.Context, topics ...string) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, topics: make(map[string]struct{})}
+	sub.Add(topics...)
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subscribers, sub)
+		b.mu.Unlock()
+		close(ch)
+	}()
+	return sub
+}
+
+// Add subscribes to more topics
+func (s *Subscription) Add(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		s.topics[t] = struct{}{}
+	}
+}
+
+// Remove unsubscribes from topics
+func (s *Subscription) Remove(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		delete(s.topics, t)
+	}
+}
+
+// Topics returns the subscribed topics
+func (s *Subscription) Topics() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	topics := make([]string, 0, len(s.topics))
+	for t := range s.topics {
+		topics = append(topics, t)
+	}
+	return topics
+}
+
+// Dropped returns how many events were dropped because the subscriber fell behind
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// matches reports whether the subscription wants an event published on topic
+func (s *Subscription) matches(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.topics[topic]; ok {
+		return true
+	}
+	if prefix, _, ok := strings.Cut(topic, ":"); ok {
+		_, ok := s.topics[prefix+":*"]
+		return ok
+	}
+	return false
+}
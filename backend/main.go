@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -13,15 +15,25 @@ import (
 	"spikeshield/db"
 	"spikeshield/detector"
 	"spikeshield/eventlistener"
+	"spikeshield/events"
+	"spikeshield/ratelimit"
+	"spikeshield/replay"
 	"spikeshield/utils"
+	"spikeshield/webhooks"
 )
 
 func main() {
+	// Maintenance subcommands (e.g. `rebuild-projections`) run once and exit
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	// Parse command line flags
 	mode := flag.String("mode", "live", "Mode: replay or live")
 	symbol := flag.String("symbol", "BTCUSDT", "Trading symbol")
 	configPath := flag.String("config", "config.yaml", "Path to config file")
 	apiPort := flag.String("api-port", "8080", "API server port")
+	replayFile := flag.String("replay-file", "", "Replay mode: load this CSV in-process instead of waiting for an external writer")
 	flag.Parse()
 
 	utils.LogInfo("🚀 SpikeShield Starting...")
@@ -35,33 +47,111 @@ func main() {
 	}
 
 	// Connect to database
-	if err := db.Connect(config); err != nil {
+	database, err := db.Connect(config)
+	if err != nil {
 		utils.LogError("Failed to connect to database: %v", err)
 		os.Exit(1)
 	}
-	defer db.Close()
+	defer database.Close()
+	store := database.Store()
+	if *mode != "live" {
+		disableMonotonic(database)
+	}
 
-	// Initialize insert notification channel
-	db.InitNotifier()
+	// Refuse to start against a schema this binary was not built for
+	if config.Database.AutoMigrate {
+		if count, err := database.MigrateUp(); err != nil {
+			utils.LogError("Failed to apply migrations: %v", err)
+			os.Exit(1)
+		} else if count > 0 {
+			utils.LogInfo("Applied %d migration(s)", count)
+		}
+	}
+	if err := database.CheckSchema(); err != nil {
+		utils.LogError("Database schema check failed: %v (run `spikeshield migrate up`)", err)
+		os.Exit(1)
+	}
 
-	// Kick off a background full-sync (update balances & policies for DB users)
+	// Kick off a background full-sync (update policies for DB users)
 	go func() {
 		utils.LogInfo("Starting full DB -> on-chain sync (background)...")
-		if err := db.FullSync(config); err != nil {
+		if err := eventlistener.FullSync(config, store); err != nil {
 			utils.LogError("Full sync failed: %v", err)
 		} else {
 			utils.LogInfo("Full sync completed")
 		}
 	}()
 
+	// Detection, payouts and the event listener publish here for the API's real-time streams
+	bus := events.NewBus()
+
+	// Partner webhooks are queued in the database and sent in the background
+	var hooks *webhooks.Dispatcher
+	if config.Webhooks.Enabled {
+		allowed, err := webhooks.ParseNetworks(config.Webhooks.AllowedNetworks)
+		if err != nil {
+			utils.LogError("Invalid webhooks.allowed_networks: %v", err)
+			os.Exit(1)
+		}
+		hooks = webhooks.NewDispatcher(store.Webhooks, webhooks.Options{
+			MaxAttempts:     config.Webhooks.MaxAttempts,
+			BaseBackoff:     time.Duration(config.Webhooks.BaseBackoff) * time.Second,
+			MaxBackoff:      time.Duration(config.Webhooks.MaxBackoff) * time.Second,
+			Timeout:         time.Duration(config.Webhooks.Timeout) * time.Second,
+			AllowedNetworks: allowed,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go hooks.Start(ctx)
+	}
+
 	// Start API server in background
-	apiServer := api.NewServer(":" + *apiPort)
+	setImportLimits(config)
+	replays := replay.NewManager(store, config.Detector.ThresholdPercent, config.Detector.BodyRatioMax, config.Replay.UploadDir)
+	if err := replays.AbandonInterrupted(); err != nil {
+		utils.LogError("Failed to close interrupted replay sessions: %v", err)
+	}
+	v1Deprecated := parseConfigDate("api.v1_deprecated", config.API.V1Deprecated)
+	v1Sunset := parseConfigDate("api.v1_sunset", config.API.V1Sunset)
+	if !v1Deprecated.IsZero() && !v1Sunset.IsZero() && v1Sunset.Before(v1Deprecated) {
+		utils.LogError("api.v1_sunset %s is before api.v1_deprecated %s", config.API.V1Sunset, config.API.V1Deprecated)
+		os.Exit(1)
+	}
+	apiServer := api.NewServer(":"+*apiPort, store, replays, bus, hooks, api.Options{
+		AdminToken:   config.API.AdminToken,
+		CORSOrigins:  config.API.CORSOrigins,
+		SIWEDomains:  config.API.SIWEDomains,
+		SIWEChainID:  config.API.SIWEChainID,
+		SessionTTL:   time.Duration(config.API.SessionTTL) * time.Second,
+		IPLimit:      ratelimit.Limit(config.RateLimit.IP),
+		KeyLimit:     ratelimit.Limit(config.RateLimit.Key),
+		WalletLimit:  ratelimit.Limit(config.RateLimit.Wallet),
+		SyncWorkers:  config.RateLimit.SyncWorkers,
+		SyncQueue:    config.RateLimit.SyncQueue,
+		V1Deprecated: v1Deprecated,
+		V1Sunset:     v1Sunset,
+
+		TrustedProxies: config.RateLimit.TrustedProxies,
+		RollupInterval: time.Duration(config.Retention.RollupInterval) * time.Second,
+	})
 	go func() {
 		if err := apiServer.Start(); err != nil {
 			utils.LogError("API server error: %v", err)
 		}
 	}()
 
+	// Create payout service
+	payoutSvc, err := api.NewPayoutService(config.RPC.URL, config.RPC.ContractAddress, config.RPC.PrivateKey, store.Policies)
+	if err != nil {
+		utils.LogError("Failed to create payout service: %v", err)
+		// Continue without payout service for demo
+		payoutSvc = nil
+	}
+	if payoutSvc != nil {
+		payoutSvc.SetEvents(bus)
+		defer payoutSvc.Close()
+	}
+
 	// Start event and token listeners in background if enabled
 	if config.EventListener.Enabled {
 		pollInterval := time.Duration(config.EventListener.PollInterval) * time.Second
@@ -79,10 +169,16 @@ func main() {
 		defer cancel()
 
 		// Create and start event listener
-		evListener, err := eventlistener.NewEventListener(config.RPC.URL, config.RPC.ContractAddress, pollInterval)
+		evListener, err := eventlistener.NewEventListener(config.RPC.URL, config.RPC.ContractAddress, pollInterval, config.TokenDecimals(), store)
 		if err != nil {
 			utils.LogError("Failed to create event listener: %v", err)
 		} else {
+			// Pause/resume payouts as soon as an oracle rotation is indexed
+			if payoutSvc != nil {
+				evListener.SetOracleHandler(payoutSvc.HandleOracleUpdated)
+			}
+			evListener.SetEvents(bus)
+			evListener.SetWebhooks(hooks)
 			utils.LogInfo("Starting event listener (poll interval: %ds)", config.EventListener.PollInterval)
 			managed = append(managed, evListener)
 			go func() {
@@ -94,10 +190,16 @@ func main() {
 
 		// Create and start token listener for USDT independently (same importance)
 		if config.RPC.UsdtAddress != "" {
-			tokenListener, err := eventlistener.NewTokenListener(config.RPC.URL, config.RPC.UsdtAddress, pollInterval, config.RPC.UsdtDecimals)
+			tokenListener, err := eventlistener.NewTokenListener(config.RPC.URL, config.RPC.UsdtAddress, pollInterval, config.TokenDecimals(), store)
 			if err != nil {
 				utils.LogError("Failed to create token listener: %v", err)
 			} else {
+				if config.EventListener.ReconcileInterval > 0 {
+					tokenListener.EnableReconciliation(
+						time.Duration(config.EventListener.ReconcileInterval)*time.Second,
+						config.EventListener.ReconcileSample,
+					)
+				}
 				utils.LogInfo("Starting token listener for USDT (poll interval: %ds)", config.EventListener.PollInterval)
 				managed = append(managed, tokenListener)
 				go func() {
@@ -116,23 +218,29 @@ func main() {
 		}()
 	}
 
-	// Create detector
-	det := detector.NewDetector(*symbol, config.Detector.ThresholdPercent, config.Detector.BodyRatioMax)
-
-	// Create payout service
-	payoutSvc, err := api.NewPayoutService(config.RPC.URL, config.RPC.ContractAddress, config.RPC.PrivateKey)
-	if err != nil {
-		utils.LogError("Failed to create payout service: %v", err)
-		// Continue without payout service for demo
-		payoutSvc = nil
-	}
-	if payoutSvc != nil {
-		defer payoutSvc.Close()
+	// Downsample old candles in the background
+	if config.Retention.Enabled {
+		compactor, err := datafeed.NewCompactor(config.Retention.RawDays, config.Retention.RollupInterval,
+			config.Retention.CompactInterval, store.Retention)
+		if err != nil {
+			utils.LogError("Failed to create price compactor: %v", err)
+		} else {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go compactor.Start(ctx)
+		}
 	}
 
-	// Spike callback - triggers payout when spike is detected
+	// Create detector
+	det := detector.NewDetector(*symbol, config.Detector.ThresholdPercent, config.Detector.BodyRatioMax, store.Prices, store.Spikes)
+	det.SetEvents(bus)
+
+	// Spike callback - triggers payout when spike is detected (live mode only)
 	onSpikeDetected := func(spike *db.Spike) {
 		utils.LogInfo("🔔 Spike callback triggered")
+		if err := hooks.Emit(webhooks.EventSpikeDetected, fmt.Sprintf("%s:%d", webhooks.EventSpikeDetected, spike.ID), spike); err != nil {
+			utils.LogError("Failed to queue spike webhooks: %v", err)
+		}
 		if payoutSvc != nil {
 			if err := payoutSvc.ExecutePayout(spike); err != nil {
 				utils.LogError("Failed to execute payout: %v", err)
@@ -145,39 +253,55 @@ func main() {
 	// Run based on mode
 	switch *mode {
 	case "replay":
-		runReplayMode(det, onSpikeDetected)
+		runReplayMode(det, store, replays, *replayFile, onSpikeDetected)
 	case "live":
-		runLiveMode(config, *symbol, det, onSpikeDetected)
+		runLiveMode(config, *symbol, store.Prices, det, onSpikeDetected)
 	default:
 		utils.LogError("Invalid mode: %s (use 'replay' or 'live')", *mode)
 		os.Exit(1)
 	}
 }
 
-// monitorDatabaseInserts listens for new rows in the database and triggers detection
-func monitorDatabaseInserts(det *detector.Detector, callback func(*db.Spike)) {
-	utils.LogInfo("👀 Monitoring database for new inserts via channel...")
+// monitorDatabaseInserts listens for new rows in the database (from any writer) and triggers detection.
+func monitorDatabaseInserts(det *detector.Detector, prices db.PriceStore, callback func(*db.Spike)) {
+	utils.LogInfo("👀 Monitoring database for new inserts via price notifications...")
 
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := prices.SubscribePrices(ctx)
+	if err != nil {
+		utils.LogError("Failed to subscribe to price inserts: %v", err)
+		return
+	}
+
 	utils.LogInfo("Monitoring active. Press Ctrl+C to stop")
 
 	// Listen for insert notifications
 	for {
 		select {
-		case <-db.InsertNotifier:
-			utils.LogInfo("📥 New data inserted, checking for spikes...")
-			// Run detection on latest price
-			spike, err := det.CheckForSpike()
+		case ev, ok := <-events:
+			if !ok {
+				utils.LogError("Price notifications stopped")
+				return
+			}
+			if ev.Symbol != det.Symbol {
+				continue
+			}
+			utils.LogInfo("📥 Price %d (%s) written, checking for spikes...", ev.ID, ev.Op)
+			// Run detection on exactly the notified candle; updates may also update or void its spike
+			spike, outcome, err := det.CheckPrice(ev.ID)
 			if err != nil {
 				utils.LogError("Detection failed: %v", err)
 				continue
 			}
 
-			// Trigger callback if spike detected
-			if spike != nil {
+			// Trigger callback only for spikes payouts have not seen yet
+			if outcome.NewSpike() {
 				callback(spike)
 			}
 
@@ -188,17 +312,51 @@ func monitorDatabaseInserts(det *detector.Detector, callback func(*db.Spike)) {
 	}
 }
 
-// runReplayMode waits for external script to insert data row by row
-func runReplayMode(det *detector.Detector, callback func(*db.Spike)) {
+// runReplayMode replays replayFile as an isolated session (replay_* tables, simulated
+// payouts), or waits for an external script to insert historical candles into prices and
+// runs detection and callback on them like live mode.
+func runReplayMode(det *detector.Detector, store *db.Store, replays *replay.Manager, replayFile string, callback func(*db.Spike)) {
 	utils.LogInfo("📊 Running in REPLAY mode")
-	utils.LogInfo("Waiting for external script to insert data from CSV...")
 
-	// Just monitor database inserts (external script will feed data)
-	monitorDatabaseInserts(det, callback)
+	if replayFile == "" {
+		utils.LogInfo("Waiting for external script to insert data from CSV...")
+		monitorDatabaseInserts(det, store.Prices, callback)
+		return
+	}
+
+	session, err := replays.Start(det.Symbol, replayFile, replay.Options{})
+	if err != nil {
+		utils.LogError("Replay failed: %v", err)
+		os.Exit(1)
+	}
+	utils.LogInfo("Replaying %s as session %s (GET /api/replay/sessions/%s)", replayFile, session.ID, session.ID)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		replays.Wait(session.ID)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if finished, err := store.Replays.GetReplaySession(session.ID); err == nil && finished.Status == db.ReplayFailed {
+			utils.LogError("Replay %s failed: %s", session.ID, finished.Error)
+		}
+		// Keep the API up so the session's spikes and simulated payouts can be inspected
+		utils.LogInfo("Replay done. Press Ctrl+C to stop")
+		<-sigChan
+	case <-sigChan:
+		if err := replays.Stop(session.ID); err != nil && err != replay.ErrNotActive {
+			utils.LogError("Failed to stop replay %s: %v", session.ID, err)
+		}
+	}
+	utils.LogInfo("Shutting down...")
 }
 
 // runLiveMode monitors real-time price from Chainlink
-func runLiveMode(config *utils.Config, symbol string, det *detector.Detector, callback func(*db.Spike)) {
+func runLiveMode(config *utils.Config, symbol string, prices db.PriceStore, det *detector.Detector, callback func(*db.Spike)) {
 	utils.LogInfo("⚡ Running in LIVE mode")
 
 	// Create live feed
@@ -207,6 +365,7 @@ func runLiveMode(config *utils.Config, symbol string, det *detector.Detector, ca
 		config.Chainlink.BtcUsdFeed,
 		symbol,
 		config.Chainlink.UpdateInterval,
+		prices,
 	)
 	if err != nil {
 		utils.LogError("Failed to create live feed: %v", err)
@@ -228,8 +387,37 @@ func runLiveMode(config *utils.Config, symbol string, det *detector.Detector, ca
 	utils.LogInfo("Live feed started, now monitoring database...")
 
 	// Monitor database inserts (same as replay mode)
-	monitorDatabaseInserts(det, callback)
+	monitorDatabaseInserts(det, prices, callback)
 
 	// Cancel live feed context on exit
 	cancel()
 }
+
+// setImportLimits bounds what compressed exchange exports may unpack to
+func setImportLimits(config *utils.Config) {
+	datafeed.SetImportLimits(datafeed.ImportLimits{
+		MaxBytes:   int64(config.Replay.MaxImportMB) << 20,
+		MaxEntries: config.Replay.MaxArchiveEntries,
+	})
+}
+
+// disableMonotonic lets the store accept candles older than the latest one, as loading
+// history (imports, replay mode) does
+func disableMonotonic(database *db.SQLStore) {
+	v := database.Validation()
+	v.Monotonic = false
+	database.SetPriceValidation(v)
+}
+
+// parseConfigDate parses a YYYY-MM-DD setting, exiting on an invalid one; empty is zero
+func parseConfigDate(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		utils.LogError("Invalid %s %q (expected YYYY-MM-DD)", name, value)
+		os.Exit(1)
+	}
+	return t
+}
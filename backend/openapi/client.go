@@ -0,0 +1,507 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+// initialisms are written in upper case in Go names
+var initialisms = map[string]string{"id": "ID", "url": "URL", "api": "API", "ip": "IP"}
+
+// goName turns a property or operation name into an exported Go identifier
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		if upper, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// generator writes the client package
+type generator struct {
+	doc     *Document
+	buf     bytes.Buffer
+	imports map[string]bool
+	skipped []string
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// GenerateClient writes a Go client package for every JSON operation of the document of
+// version. Streaming operations (Server-Sent Events, WebSocket) are left out.
+func (d *Document) GenerateClient(pkg, version string) ([]byte, error) {
+	if _, ok := d.Components.Schemas["Error"]; !ok {
+		return nil, fmt.Errorf("the document has no Error schema")
+	}
+	g := &generator{doc: d, imports: map[string]bool{
+		"bytes": true, "context": true, "encoding/json": true, "fmt": true, "io": true,
+		"net/http": true, "net/url": true, "strings": true,
+	}}
+
+	var body generator
+	body.doc, body.imports = d, g.imports
+	if err := body.types(); err != nil {
+		return nil, err
+	}
+	for _, route := range d.Routes() {
+		if err := body.operation(route); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", route.Method, route.Path, err)
+		}
+	}
+
+	g.printf("// Code generated by `spikeshield openapi client`; DO NOT EDIT.\n\n")
+	g.printf("// Package %s is a Go client for the SpikeShield API %s, generated from openapi/%s.json.\n", pkg, version, version)
+	if len(body.skipped) > 0 {
+		g.printf("// Streaming operations are not generated: %s.\n", strings.Join(body.skipped, ", "))
+	}
+	g.printf("package %s\n\nimport (\n", pkg)
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		g.printf("%q\n", imp)
+	}
+	g.printf(")\n\n%s\n", clientRuntime)
+	g.buf.Write(body.buf.Bytes())
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated client does not compile: %w", err)
+	}
+	return src, nil
+}
+
+// clientRuntime is the fixed part of the client
+const clientRuntime = `// Client calls the SpikeShield API
+type Client struct {
+	BaseURL    string       // e.g. http://localhost:8080
+	Token      string       // API key or wallet session token, sent as a bearer token
+	HTTPClient *http.Client // http.DefaultClient if nil
+}
+
+// New returns a client for the API at baseURL; token may be empty for public endpoints
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: baseURL, Token: token}
+}
+
+// APIError is a response with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Body       *Error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("spikeshield: %d %s: %s (request %s)", e.StatusCode, e.Body.Code, e.Body.Error, e.Body.RequestID)
+}
+
+// do sends a request and decodes a 2xx JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out interface{}) error {
+	u := strings.TrimRight(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: &Error{}}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr.Body); err != nil || apiErr.Body.Error == "" {
+			apiErr.Body.Error = resp.Status
+		}
+		if apiErr.Body.RequestID == "" {
+			apiErr.Body.RequestID = resp.Header.Get("X-Request-ID")
+		}
+		return apiErr
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jsonBody encodes a request body; a nil body is sent empty
+func jsonBody(v interface{}, isNil bool) (io.Reader, string, error) {
+	if isNil {
+		return nil, "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, "", err
+	}
+	return bytes.NewReader(data), "application/json", nil
+}
+`
+
+// types writes a Go type for every component schema
+func (g *generator) types() error {
+	names := make([]string, 0, len(g.doc.Components.Schemas))
+	for name := range g.doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := g.doc.Components.Schemas[name]
+		g.printf("// %s is the %s schema of the API\n", name, name)
+		if s.Description != "" {
+			g.printf("//\n// %s\n", s.Description)
+		}
+		if s.Type != "object" {
+			return fmt.Errorf("schema %s: only object components are supported", name)
+		}
+		if len(s.Properties) == 0 {
+			g.printf("type %s map[string]interface{}\n\n", name)
+			continue
+		}
+		g.printf("type %s struct {\n", name)
+		for _, prop := range orderedProperties(s) {
+			p := s.Properties[prop]
+			if p.Format == "binary" {
+				continue // sent as a file part, not a field
+			}
+			typ, err := g.goType(p)
+			if err != nil {
+				return fmt.Errorf("schema %s.%s: %w", name, prop, err)
+			}
+			tag := prop
+			if !contains(s.Required, prop) {
+				tag += ",omitempty"
+			}
+			g.printf("%s %s `json:%q`", goName(prop), typ, tag)
+			if p.Description != "" {
+				g.printf(" // %s", p.Description)
+			}
+			g.printf("\n")
+		}
+		g.printf("}\n\n")
+	}
+	return nil
+}
+
+// orderedProperties lists the properties of an object schema: required ones in their
+// declared order, then the others by name
+func orderedProperties(s *Schema) []string {
+	props := append([]string(nil), s.Required...)
+	var optional []string
+	for name := range s.Properties {
+		if !contains(s.Required, name) {
+			optional = append(optional, name)
+		}
+	}
+	sort.Strings(optional)
+	return append(props, optional...)
+}
+
+// goType maps a schema to a Go type; nullable scalars and references are pointers
+func (g *generator) goType(s *Schema) (string, error) {
+	nullable := s.Nullable
+	if len(s.AllOf) == 1 {
+		s = s.AllOf[0]
+		nullable = true
+	}
+	if s.Ref != "" {
+		name := RefName(s)
+		target, err := g.doc.Resolve(s)
+		if err != nil {
+			return "", err
+		}
+		if len(target.Properties) == 0 {
+			return name, nil // a map
+		}
+		return "*" + name, nil
+	}
+
+	var typ string
+	switch s.Type {
+	case "string":
+		typ = "string"
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			typ = "time.Time"
+		}
+	case "integer":
+		typ = "int"
+		if s.Format == "int64" {
+			typ = "int64"
+		}
+	case "number":
+		typ = "float64"
+	case "boolean":
+		typ = "bool"
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.goType(s.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if len(s.Properties) > 0 {
+			return "", fmt.Errorf("inline objects are not supported; use a component")
+		}
+		return "map[string]interface{}", nil
+	default:
+		return "", fmt.Errorf("unsupported type %q", s.Type)
+	}
+	if nullable {
+		typ = "*" + typ
+	}
+	return typ, nil
+}
+
+// operation writes the method of one operation, and its parameter struct
+func (g *generator) operation(route Route) error {
+	ok, status := successResponse(route.Operation)
+	var media *MediaType
+	if ok != nil {
+		media = ok.Content["application/json"]
+	}
+	// Streams answer 101 (WebSocket) or with another media type
+	if media == nil || media.Schema == nil || media.Schema.Ref == "" {
+		g.skipped = append(g.skipped, route.OperationID)
+		return nil
+	}
+	if route.OperationID == "" {
+		return fmt.Errorf("no operationId")
+	}
+	name := goName(route.OperationID)
+	result := RefName(media.Schema)
+	resultType, err := g.goType(media.Schema)
+	if err != nil {
+		return err
+	}
+
+	var pathParams, queryParams []*Parameter
+	for _, p := range route.Parameters {
+		switch p.In {
+		case "path":
+			pathParams = append(pathParams, p)
+		case "query":
+			queryParams = append(queryParams, p)
+		default:
+			return fmt.Errorf("parameter %s: unsupported location %q", p.Name, p.In)
+		}
+	}
+
+	args := []string{"ctx context.Context"}
+	for _, p := range pathParams {
+		typ, err := g.goType(p.Schema)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+		args = append(args, p.Name+" "+typ)
+	}
+	if len(queryParams) > 0 {
+		if err := g.params(name+"Params", route, queryParams); err != nil {
+			return err
+		}
+		args = append(args, "params *"+name+"Params")
+	}
+
+	var bodySchema, bodyType string
+	if route.RequestBody != nil {
+		if len(route.RequestBody.Content) != 1 {
+			return fmt.Errorf("request bodies must have one media type")
+		}
+		for mediaType := range route.RequestBody.Content {
+			bodyType = mediaType
+		}
+		bodySchema = RefName(route.RequestBody.Content[bodyType].Schema)
+		if bodySchema == "" {
+			return fmt.Errorf("request bodies must reference a component")
+		}
+		switch bodyType {
+		case "application/json":
+			args = append(args, "body *"+bodySchema)
+		case "multipart/form-data":
+			args = append(args, "filename string", "file io.Reader", "body *"+bodySchema)
+		default:
+			return fmt.Errorf("unsupported request body %s", bodyType)
+		}
+	}
+
+	g.printf("// %s calls %s %s: %s.\n", name, route.Method, route.Path, strings.TrimSuffix(route.Summary, "."))
+	if route.Description != "" {
+		g.printf("// %s\n", route.Description)
+	}
+	if status != "200" {
+		g.printf("// It answers %s on success.\n", status)
+	}
+	g.printf("func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), resultType)
+
+	pathExpr, err := g.pathExpr(route.Path, pathParams)
+	if err != nil {
+		return err
+	}
+	query := "nil"
+	if len(queryParams) > 0 {
+		query = "params.values()"
+	}
+	send := "contentType, reader"
+	switch bodyType {
+	case "":
+		send = `"", nil`
+	case "application/json":
+		g.printf("reader, contentType, err := jsonBody(body, body == nil)\nif err != nil {\nreturn nil, err\n}\n")
+	case "multipart/form-data":
+		if err := g.multipart(bodySchema); err != nil {
+			return err
+		}
+	}
+
+	if strings.HasPrefix(resultType, "*") {
+		g.printf("out := &%s{}\n", result)
+		g.printf("if err := c.do(ctx, %q, %s, %s, %s, out); err != nil {\nreturn nil, err\n}\nreturn out, nil\n}\n\n",
+			route.Method, pathExpr, query, send)
+	} else {
+		g.printf("var out %s\n", resultType)
+		g.printf("if err := c.do(ctx, %q, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\nreturn out, nil\n}\n\n",
+			route.Method, pathExpr, query, send)
+	}
+	return nil
+}
+
+// successResponse returns the first 2xx response of an operation and its status
+func successResponse(op *Operation) (*Response, string) {
+	var statuses []string
+	for status := range op.Responses {
+		if strings.HasPrefix(status, "2") {
+			statuses = append(statuses, status)
+		}
+	}
+	sort.Strings(statuses)
+	if len(statuses) == 0 {
+		return nil, ""
+	}
+	return op.Responses[statuses[0]], statuses[0]
+}
+
+// pathExpr is the Go expression for a path with its parameters filled in
+func (g *generator) pathExpr(path string, params []*Parameter) (string, error) {
+	var parts []string
+	rest := path
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest, "}")
+		if end < start {
+			return "", fmt.Errorf("malformed path %s", path)
+		}
+		name := rest[start+1 : end]
+		var param *Parameter
+		for _, p := range params {
+			if p.Name == name {
+				param = p
+			}
+		}
+		if param == nil {
+			return "", fmt.Errorf("path parameter %s is not declared", name)
+		}
+		parts = append(parts, fmt.Sprintf("%q", rest[:start]))
+		if param.Schema.Type == "integer" {
+			g.imports["strconv"] = true
+			parts = append(parts, fmt.Sprintf("strconv.Itoa(%s)", name))
+		} else {
+			parts = append(parts, fmt.Sprintf("url.PathEscape(%s)", name))
+		}
+		rest = rest[end+1:]
+	}
+	if rest != "" {
+		parts = append(parts, fmt.Sprintf("%q", rest))
+	}
+	return strings.Join(parts, " + "), nil
+}
+
+// params writes the query parameter struct of an operation and its encoder; zero
+// fields are left out of the query
+func (g *generator) params(name string, route Route, params []*Parameter) error {
+	g.printf("// %s are the query parameters of %s %s\ntype %s struct {\n", name, route.Method, route.Path, name)
+	for _, p := range params {
+		typ, err := g.goType(p.Schema)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+		g.printf("%s %s", goName(p.Name), typ)
+		comment := p.Description
+		if p.Required {
+			comment = strings.TrimSpace("Required. " + comment)
+		}
+		if comment != "" {
+			g.printf(" // %s", comment)
+		}
+		g.printf("\n")
+	}
+	g.printf("}\n\nfunc (p *%s) values() url.Values {\nif p == nil {\nreturn nil\n}\nq := url.Values{}\n", name)
+	for _, p := range params {
+		field := "p." + goName(p.Name)
+		switch {
+		case p.Schema.Type == "string" && p.Schema.Format == "date-time":
+			g.printf("if !%s.IsZero() {\nq.Set(%q, %s.Format(time.RFC3339))\n}\n", field, p.Name, field)
+		case p.Schema.Type == "string":
+			g.printf("if %s != \"\" {\nq.Set(%q, %s)\n}\n", field, p.Name, field)
+		case p.Schema.Type == "integer":
+			g.imports["strconv"] = true
+			g.printf("if %s != 0 {\nq.Set(%q, strconv.Itoa(%s))\n}\n", field, p.Name, field)
+		case p.Schema.Type == "array":
+			g.printf("for _, v := range %s {\nq.Add(%q, v)\n}\n", field, p.Name)
+		default:
+			return fmt.Errorf("parameter %s: unsupported type %q", p.Name, p.Schema.Type)
+		}
+	}
+	g.printf("return q\n}\n\n")
+	return nil
+}
+
+// multipart writes the encoding of a multipart body: one file part and string fields
+func (g *generator) multipart(schema string) error {
+	s := g.doc.Components.Schemas[schema]
+	g.imports["mime/multipart"] = true
+	g.printf("var buf bytes.Buffer\nw := multipart.NewWriter(&buf)\n")
+	for _, prop := range orderedProperties(s) {
+		p := s.Properties[prop]
+		switch {
+		case p.Format == "binary":
+			g.printf("part, err := w.CreateFormFile(%q, filename)\nif err != nil {\nreturn nil, err\n}\n", prop)
+			g.printf("if _, err := io.Copy(part, file); err != nil {\nreturn nil, err\n}\n")
+		case p.Type == "string":
+			g.printf("if body != nil && body.%s != \"\" {\nif err := w.WriteField(%q, body.%s); err != nil {\nreturn nil, err\n}\n}\n",
+				goName(prop), prop, goName(prop))
+		default:
+			return fmt.Errorf("multipart field %s must be a string or a file", prop)
+		}
+	}
+	g.printf("if err := w.Close(); err != nil {\nreturn nil, err\n}\nreader, contentType := io.Reader(&buf), w.FormDataContentType()\n")
+	return nil
+}
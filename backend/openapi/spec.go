@@ -0,0 +1,170 @@
+// Package openapi holds the OpenAPI 3 documents of the HTTP API, one per version, a
+// validator that checks responses against them and a generator for the Go client in
+// spikeshield/client.
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Versions lists the API versions, oldest first
+var Versions = []string{"v1", "v2"}
+
+// specs holds the document of each version, served at /api/<version>/openapi.json
+//
+//go:embed v1.json v2.json
+var specs embed.FS
+
+// Spec returns the document of a version as served
+func Spec(version string) ([]byte, error) {
+	data, err := specs.ReadFile(version + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no OpenAPI document for API version %q", version)
+	}
+	return data, nil
+}
+
+// Document is the part of an OpenAPI 3 document the validator and generator use
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Components holds the reusable schemas and responses
+type Components struct {
+	Schemas   map[string]*Schema   `json:"schemas"`
+	Responses map[string]*Response `json:"responses"`
+}
+
+// Operation is one method on one path
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body of an operation, by media type
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is one status of an operation, or a reference to a shared one
+type Response struct {
+	Ref         string                `json:"$ref"`
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema the API document uses
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []string           `json:"enum"`
+	Items                *Schema            `json:"items"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties bool               `json:"additionalProperties"`
+	AllOf                []*Schema          `json:"allOf"`
+}
+
+// Load parses the document of a version
+func Load(version string) (*Document, error) {
+	data, err := Spec(version)
+	if err != nil {
+		return nil, err
+	}
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid %s.json: %w", version, err)
+	}
+	return &doc, nil
+}
+
+// Route is an operation with its method and path
+type Route struct {
+	Method string // upper case
+	Path   string // with {param} placeholders
+	*Operation
+}
+
+// Routes lists every operation, sorted by path and method
+func (d *Document) Routes() []Route {
+	var routes []Route
+	for path, methods := range d.Paths {
+		for method, op := range methods {
+			routes = append(routes, Route{Method: strings.ToUpper(method), Path: path, Operation: op})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Operation returns the operation for method on path, or nil
+func (d *Document) Operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Response returns the response an operation declares for status, resolving references
+func (d *Document) Response(op *Operation, status int) (*Response, error) {
+	r, ok := op.Responses[fmt.Sprint(status)]
+	if !ok {
+		return nil, fmt.Errorf("status %d not declared", status)
+	}
+	if r.Ref != "" {
+		shared, ok := d.Components.Responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]
+		if !ok {
+			return nil, fmt.Errorf("unknown response %s", r.Ref)
+		}
+		r = shared
+	}
+	return r, nil
+}
+
+// Resolve follows a schema reference
+func (d *Document) Resolve(s *Schema) (*Schema, error) {
+	for s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		next, ok := d.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		s = next
+	}
+	return s, nil
+}
+
+// RefName is the component name of a schema reference, "" for inline schemas
+func RefName(s *Schema) string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
@@ -0,0 +1,126 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ValidateJSON checks a JSON body against a schema of the document
+func (d *Document) ValidateJSON(s *Schema, body []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return d.Validate(s, value)
+}
+
+// Validate checks a decoded JSON value against a schema. Objects with properties are
+// closed unless they allow additionalProperties, so fields a handler adds without
+// documenting them are reported too.
+func (d *Document) Validate(s *Schema, value interface{}) error {
+	return d.validate(s, value, "$")
+}
+
+func (d *Document) validate(s *Schema, value interface{}, path string) error {
+	nullable := s.Nullable
+	s, err := d.Resolve(s)
+	if err != nil {
+		return err
+	}
+	nullable = nullable || s.Nullable
+	if value == nil {
+		if nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", path)
+	}
+	for _, part := range s.AllOf {
+		if err := d.validate(part, value, path); err != nil {
+			return err
+		}
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, value)
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %v", path, str, s.Enum)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: %q is not an RFC 3339 timestamp", path, str)
+			}
+		}
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %T", path, s.Type, value)
+		}
+		if s.Type == "integer" && num != math.Trunc(num) {
+			return fmt.Errorf("%s: %v is not an integer", path, num)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, value)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, value)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, value)
+		}
+		for _, name := range s.Required {
+			if _, ok := fields[name]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if len(s.Properties) > 0 && !s.AdditionalProperties {
+					return fmt.Errorf("%s: undocumented field %q", path, name)
+				}
+				continue
+			}
+			if err := d.validate(prop, fields[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", path, s.Type)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+// Package ratelimit implements keyed token-bucket rate limits for the HTTP API
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that refilled completely are forgotten
+const sweepInterval = time.Minute
+
+// Limit allows Rate requests per second on average and bursts of up to Burst requests.
+// A zero Rate disables the limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Limiter keeps one token bucket per key (a client IP, an API key, a wallet). Buckets
+// start full and idle ones are dropped, so memory follows the number of active clients.
+// A nil *Limiter allows everything.
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter enforcing limit per key, or nil if the limit is disabled
+func NewLimiter(limit Limit) *Limiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &Limiter{limit: limit, buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it returns false and
+// how long until the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	now := time.Now()
+	burst := float64(l.limit.Burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+}
+
+// sweep drops the buckets that would be full by now, which is how new ones start
+func (l *Limiter) sweep(now time.Time) {
+	full := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// rewind moves a key's bucket back in time, as if d had passed since its last request
+func (l *Limiter) rewind(key string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[key]; ok {
+		b.last = b.last.Add(-d)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   Limit
+		idle    time.Duration // time passed after draining the burst
+		allowed int           // requests allowed after idle
+		wait    time.Duration // approximate Retry-After once limited
+	}{
+		{name: "burst", limit: Limit{Rate: 1, Burst: 3}, allowed: 0, wait: time.Second},
+		{name: "burst defaults to 1", limit: Limit{Rate: 10}, allowed: 0, wait: 100 * time.Millisecond},
+		{name: "one token refills", limit: Limit{Rate: 1, Burst: 3}, idle: time.Second, allowed: 1, wait: time.Second},
+		{name: "partial refill", limit: Limit{Rate: 2, Burst: 5}, idle: 1250 * time.Millisecond, allowed: 2, wait: 250 * time.Millisecond},
+		{name: "refill capped at burst", limit: Limit{Rate: 5, Burst: 2}, idle: time.Hour, allowed: 2, wait: 200 * time.Millisecond},
+		{name: "slow rate", limit: Limit{Rate: 0.1, Burst: 1}, idle: 5 * time.Second, allowed: 0, wait: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.limit)
+			burst := tt.limit.Burst
+			if burst < 1 {
+				burst = 1
+			}
+			for i := 0; i < burst; i++ {
+				if ok, _ := l.Allow("k"); !ok {
+					t.Fatalf("request %d of the burst was limited", i+1)
+				}
+			}
+			l.rewind("k", tt.idle)
+			for i := 0; i < tt.allowed; i++ {
+				if ok, _ := l.Allow("k"); !ok {
+					t.Fatalf("request %d after %s was limited", i+1, tt.idle)
+				}
+			}
+			ok, wait := l.Allow("k")
+			if ok {
+				t.Fatalf("request %d after %s was allowed", tt.allowed+1, tt.idle)
+			}
+			if diff := wait - tt.wait; diff < -50*time.Millisecond || diff > 50*time.Millisecond {
+				t.Errorf("wait = %s, want about %s", wait, tt.wait)
+			}
+		})
+	}
+}
+
+func TestKeysAreIndependent(t *testing.T) {
+	l := NewLimiter(Limit{Rate: 1, Burst: 1})
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("a was limited")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("a was allowed past its burst")
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatal("b was limited by a's bucket")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	for _, limit := range []Limit{{}, {Rate: 0, Burst: 10}, {Rate: -1, Burst: 1}} {
+		l := NewLimiter(limit)
+		if l != nil {
+			t.Errorf("NewLimiter(%+v) = %v, want nil", limit, l)
+		}
+		for i := 0; i < 100; i++ {
+			if ok, _ := l.Allow("k"); !ok {
+				t.Fatalf("a disabled limiter limited request %d", i+1)
+			}
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l := NewLimiter(Limit{Rate: 1, Burst: 10})
+	l.Allow("idle")
+	l.Allow("busy")
+	l.rewind("idle", 10*time.Second) // refilled completely
+	l.rewind("busy", 5*time.Second)
+
+	l.mu.Lock()
+	l.lastSweep = l.lastSweep.Add(-2 * sweepInterval)
+	l.mu.Unlock()
+	l.Allow("other")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("a full bucket survived the sweep")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("a refilling bucket was swept")
+	}
+}
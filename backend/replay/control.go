@@ -0,0 +1,237 @@
+package replay
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"spikeshield/db"
+)
+
+// Progress describes where an active session is
+type Progress struct {
+	SessionID   string
+	Status      string
+	Speed       float64
+	Position    int // index of the next candle to replay
+	Total       int
+	Percent     float64
+	Timestamp   *time.Time // candle time of the last replayed candle
+	Spikes      int        // new spikes detected so far
+	Quarantined int        // candles that failed validation, skipped by detection
+}
+
+// run is the playback state of one active session
+type run struct {
+	session *db.ReplaySession
+	candles []*db.PriceData
+	opts    Options
+	wake    chan struct{} // signalled whenever a control changes the state
+	done    chan struct{} // closed when the replay goroutine exits
+
+	mu      sync.Mutex
+	pos     int // next candle to replay
+	last    int // last replayed candle, -1 after a seek so the next candle is not delayed
+	paused  bool
+	stopped bool
+	spikes  int
+	bad     int // quarantined candles
+}
+
+func newRun(session *db.ReplaySession, candles []*db.PriceData, opts Options) *run {
+	return &run{
+		session: session,
+		candles: candles,
+		opts:    opts,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		last:    -1,
+		paused:  opts.Paused,
+	}
+}
+
+// signal wakes the replay goroutine without blocking
+func (r *run) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next blocks until the next candle is due and returns its index, or -1 once every
+// candle was replayed. Candles are spaced by their timestamp gap divided by the speed.
+func (r *run) next() (int, error) {
+	for {
+		r.mu.Lock()
+		if r.stopped {
+			r.mu.Unlock()
+			return -1, errStopped
+		}
+		if r.paused {
+			r.mu.Unlock()
+			<-r.wake
+			continue
+		}
+		if r.pos >= len(r.candles) {
+			r.mu.Unlock()
+			return -1, nil
+		}
+		pos := r.pos
+		var delay time.Duration
+		if r.opts.Speed > 0 && r.last >= 0 {
+			delay = time.Duration(float64(r.candles[pos].Timestamp.Sub(r.candles[r.last].Timestamp)) / r.opts.Speed)
+		}
+		r.mu.Unlock()
+
+		if delay <= 0 {
+			return pos, nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+			return pos, nil
+		case <-r.wake:
+			// Paused, stopped or moved while waiting: re-evaluate
+			timer.Stop()
+		}
+	}
+}
+
+// advance records that the candle at pos was replayed, unless a seek moved playback meanwhile
+func (r *run) advance(pos int, newSpike, quarantined bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if newSpike {
+		r.spikes++
+	}
+	if quarantined {
+		r.bad++
+	}
+	if r.pos == pos {
+		r.pos++
+		r.last = pos
+	}
+}
+
+func (r *run) progress() *Progress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := &Progress{
+		SessionID:   r.session.ID,
+		Status:      db.ReplayRunning,
+		Speed:       r.opts.Speed,
+		Position:    r.pos,
+		Total:       len(r.candles),
+		Percent:     100 * float64(r.pos) / float64(len(r.candles)),
+		Spikes:      r.spikes,
+		Quarantined: r.bad,
+	}
+	if r.paused {
+		p.Status = db.ReplayPaused
+	}
+	if r.last >= 0 {
+		ts := r.candles[r.last].Timestamp
+		p.Timestamp = &ts
+	}
+	return p
+}
+
+// active returns the run of a session being replayed
+func (m *Manager) active(id string) (*run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.runs[id]
+	if !ok {
+		return nil, ErrNotActive
+	}
+	return r, nil
+}
+
+// Progress reports the position of an active session
+func (m *Manager) Progress(id string) (*Progress, error) {
+	r, err := m.active(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.progress(), nil
+}
+
+// Pause holds a session after the candle being replayed
+func (m *Manager) Pause(id string) (*Progress, error) {
+	return m.setPaused(id, true)
+}
+
+// Resume continues a paused session
+func (m *Manager) Resume(id string) (*Progress, error) {
+	return m.setPaused(id, false)
+}
+
+func (m *Manager) setPaused(id string, paused bool) (*Progress, error) {
+	r, err := m.active(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Holding the lock orders this status write before the final one
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return nil, ErrNotActive
+	}
+	status := db.ReplayRunning
+	if paused {
+		status = db.ReplayPaused
+	}
+	if err := m.store.Replays.UpdateReplaySessionStatus(id, status, ""); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.paused = paused
+	r.mu.Unlock()
+	r.signal()
+	return r.progress(), nil
+}
+
+// Seek moves playback to the first candle at or after t. Candles in between are skipped,
+// not replayed; seeking back replays candles again, which updates them in place.
+func (m *Manager) Seek(id string, t time.Time) (*Progress, error) {
+	r, err := m.active(id)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return nil, ErrNotActive
+	}
+	r.pos = sort.Search(len(r.candles), func(i int) bool { return !r.candles[i].Timestamp.Before(t) })
+	r.last = -1
+	r.mu.Unlock()
+	r.signal()
+	return r.progress(), nil
+}
+
+// Wait blocks until the replay goroutine of a session exits; it returns at once for a
+// session that is not being replayed
+func (m *Manager) Wait(id string) {
+	if r, err := m.active(id); err == nil {
+		<-r.done
+	}
+}
+
+// Stop ends a session early and waits for its replay goroutine to exit
+func (m *Manager) Stop(id string) error {
+	r, err := m.active(id)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+	r.signal()
+	<-r.done
+	return nil
+}
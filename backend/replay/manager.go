@@ -0,0 +1,209 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"spikeshield/datafeed"
+	"spikeshield/db"
+	"spikeshield/detector"
+	"spikeshield/utils"
+)
+
+// ErrNotActive is returned when controlling a session that is not being replayed
+var ErrNotActive = errors.New("replay session is not active")
+
+// errStopped ends a run that was stopped through Stop
+var errStopped = errors.New("replay stopped")
+
+// Options controls how a session is played back
+type Options struct {
+	// Speed scales the time between candles: 1 is real time, 60 plays an hour per minute.
+	// 0 replays as fast as possible.
+	Speed float64
+	// Paused starts the session paused at the first candle
+	Paused bool
+}
+
+// Manager runs replay sessions. Each session gets its own candles and spikes through
+// db.ReplayStore.ReplayScope, and spikes are paid out only as simulated payouts, so a
+// replay never touches production prices, spikes or the chain.
+type Manager struct {
+	store            *db.Store
+	thresholdPercent float64
+	bodyRatioMax     float64
+	uploadDir        string
+
+	mu   sync.Mutex
+	runs map[string]*run // sessions being replayed
+}
+
+// NewManager creates a replay manager using the production detector thresholds.
+// Uploaded CSVs are kept in uploadDir (default "uploads").
+func NewManager(store *db.Store, thresholdPercent, bodyRatioMax float64, uploadDir string) *Manager {
+	if uploadDir == "" {
+		uploadDir = "uploads"
+	}
+	return &Manager{
+		store:            store,
+		thresholdPercent: thresholdPercent,
+		bodyRatioMax:     bodyRatioMax,
+		uploadDir:        uploadDir,
+		runs:             make(map[string]*run),
+	}
+}
+
+// AbandonInterrupted fails sessions left running or paused by a previous process
+func (m *Manager) AbandonInterrupted() error {
+	sessions, err := m.store.Replays.ListReplaySessions()
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if s.Status != db.ReplayRunning && s.Status != db.ReplayPaused {
+			continue
+		}
+		if err := m.store.Replays.UpdateReplaySessionStatus(s.ID, db.ReplayFailed, "interrupted by restart"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start creates a session for the CSV and replays it in the background
+func (m *Manager) Start(symbol, csvPath string, opts Options) (*db.ReplaySession, error) {
+	if opts.Speed < 0 {
+		return nil, fmt.Errorf("speed must not be negative, got %g", opts.Speed)
+	}
+	candles, err := datafeed.NewReplayFeed(csvPath, symbol, nil).Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(candles) == 0 {
+		return nil, fmt.Errorf("%s has no candles", csvPath)
+	}
+	// Pacing and seeking walk the candles in time order
+	sort.SliceStable(candles, func(i, j int) bool { return candles[i].Timestamp.Before(candles[j].Timestamp) })
+
+	session := &db.ReplaySession{Symbol: symbol, Source: csvPath}
+	if opts.Paused {
+		session.Status = db.ReplayPaused
+	}
+	if err := m.store.Replays.CreateReplaySession(session); err != nil {
+		return nil, fmt.Errorf("failed to create replay session: %w", err)
+	}
+
+	r := newRun(session, candles, opts)
+	m.mu.Lock()
+	m.runs[session.ID] = r
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.runs, session.ID)
+			m.mu.Unlock()
+			close(r.done)
+		}()
+		if err := m.run(r); err != nil {
+			utils.LogError("Replay %s failed: %v", session.ID, err)
+		}
+	}()
+	return session, nil
+}
+
+// run replays the session to completion and records the final status
+func (m *Manager) run(r *run) error {
+	if !r.opts.Paused {
+		if err := m.store.Replays.UpdateReplaySessionStatus(r.session.ID, db.ReplayRunning, ""); err != nil {
+			return err
+		}
+	}
+	err := m.replay(r)
+
+	// Controls see the run as stopped from here on, so no pause can follow the final status
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+
+	switch {
+	case errors.Is(err, errStopped):
+		utils.LogInfo("⏹️  Replay %s stopped", r.session.ID)
+		return m.store.Replays.UpdateReplaySessionStatus(r.session.ID, db.ReplayStopped, "")
+	case err != nil:
+		m.store.Replays.UpdateReplaySessionStatus(r.session.ID, db.ReplayFailed, err.Error())
+		return err
+	}
+	return m.store.Replays.UpdateReplaySessionStatus(r.session.ID, db.ReplayFinished, "")
+}
+
+// replay inserts the candles into the session scope one by one, running detection on
+// each exactly as the production monitor does for a notified candle
+func (m *Manager) replay(r *run) error {
+	prices, spikes := m.store.Replays.ReplayScope(r.session.ID)
+	det := detector.NewDetector(r.session.Symbol, m.thresholdPercent, m.bodyRatioMax, prices, spikes)
+
+	utils.LogInfo("📊 Replay %s: %d candles from %s", r.session.ID, len(r.candles), r.session.Source)
+	for {
+		pos, err := r.next()
+		if err != nil {
+			return err
+		}
+		if pos < 0 {
+			break
+		}
+
+		candle := r.candles[pos]
+		var quarantined *db.QuarantineError
+		if err := prices.InsertPrice(candle); errors.As(err, &quarantined) {
+			utils.LogDebug("Replay %s: candle %s quarantined: %s", r.session.ID, candle.Timestamp, quarantined.Reason)
+			r.advance(pos, false, true)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to insert candle %s: %w", candle.Timestamp, err)
+		}
+		spike, outcome, err := det.CheckPrice(candle.ID)
+		if err != nil {
+			return fmt.Errorf("detection failed on candle %s: %w", candle.Timestamp, err)
+		}
+		if outcome.NewSpike() {
+			if err := m.simulatePayouts(r.session, spike); err != nil {
+				return err
+			}
+		}
+		r.advance(pos, outcome.NewSpike(), false)
+	}
+	p := r.progress()
+	utils.LogInfo("✅ Replay %s finished: %d candles, %d spikes, %d quarantined", r.session.ID, len(r.candles), p.Spikes, p.Quarantined)
+	return nil
+}
+
+// simulatePayouts records the payout every active policy would receive for the spike.
+// Like the on-chain payout, a policy pays at most once per session.
+func (m *Manager) simulatePayouts(session *db.ReplaySession, spike *db.Spike) error {
+	policies, err := m.store.Policies.GetActivePolicies()
+	if err != nil {
+		return fmt.Errorf("failed to load active policies: %w", err)
+	}
+
+	paid := 0
+	for _, policy := range policies {
+		inserted, err := m.store.Replays.InsertSimulatedPayout(&db.SimulatedPayout{
+			SessionID:   session.ID,
+			SpikeID:     spike.ID,
+			PolicyID:    policy.ID,
+			UserAddress: policy.UserAddress,
+			Amount:      policy.CoverageAmount,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record simulated payout for policy %d: %w", policy.ID, err)
+		}
+		if inserted {
+			paid++
+		}
+	}
+	utils.LogInfo("💸 Replay %s: spike %d would pay %d policies", session.ID, spike.ID, paid)
+	return nil
+}
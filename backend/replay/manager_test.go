@@ -0,0 +1,80 @@
+package replay
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"spikeshield/db"
+	"spikeshield/utils"
+)
+
+// TestReplayIsolation replays a CSV with a spike and checks that production prices,
+// spikes and payouts are untouched while the session records its own
+func TestReplayIsolation(t *testing.T) {
+	store := db.NewMemoryStore().Store()
+	coverage := utils.NewAmount(big.NewInt(100000000), 6)
+	err := store.Policies.UpsertPolicy("0x000000000000000000000000000000000000A11C", 1, utils.NewAmount(big.NewInt(10000000), 6),
+		coverage, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "active")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csv := filepath.Join(t.TempDir(), "wick.csv")
+	err = os.WriteFile(csv, []byte("timestamp,open,high,low,close,volume\n"+
+		"2024-01-01T00:00:00Z,100,101,99,100.5,10\n"+
+		"2024-01-01T00:01:00Z,100.5,121,95,101,10\n"+ // long wick, small body
+		"2024-01-01T00:02:00Z,101,102,100,101.5,10\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(store, 0.1, 0.3, t.TempDir())
+	session, err := m.Start("BTCUSDT", csv, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Wait(session.ID)
+
+	finished, err := store.Replays.GetReplaySession(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finished.Status != db.ReplayFinished {
+		t.Fatalf("session %s: %s", finished.Status, finished.Error)
+	}
+
+	prices, _, err := store.Prices.GetPrices(db.ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spikes, _, err := store.Spikes.GetSpikes(db.ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payouts, _, err := store.Payouts.GetPayouts(db.ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 0 || len(spikes) != 0 || len(payouts) != 0 {
+		t.Errorf("production tables changed: %d prices, %d spikes, %d payouts", len(prices), len(spikes), len(payouts))
+	}
+
+	replayed, _ := store.Replays.ReplayScope(session.ID)
+	sessionPrices, _, err := replayed.GetPrices(db.ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulated, _, err := store.Replays.GetSimulatedPayouts(session.ID, db.ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessionPrices) != 3 {
+		t.Errorf("session has %d candles, want 3", len(sessionPrices))
+	}
+	if len(simulated) != 1 || simulated[0].Amount.Raw.Cmp(coverage.Raw) != 0 {
+		t.Errorf("simulated payouts = %+v, want one of the coverage", simulated)
+	}
+}
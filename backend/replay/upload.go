@@ -0,0 +1,109 @@
+package replay
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"spikeshield/datafeed"
+	"spikeshield/db"
+)
+
+// uploadName matches the names SaveUpload hands out, so clients cannot point a session
+// at arbitrary files
+var uploadName = regexp.MustCompile(`^[0-9a-f]{32}\.csv$`)
+
+// ErrInvalidUpload is returned by UploadPath for a name SaveUpload cannot have handed out
+var ErrInvalidUpload = errors.New("invalid upload name")
+
+// ErrUploadNotFound is returned by UploadPath for a valid name with no upload behind it
+var ErrUploadNotFound = errors.New("upload not found")
+
+// Upload is an export converted to a replayable CSV
+type Upload struct {
+	File    string // name to pass when starting a session
+	Candles int
+	From    time.Time
+	To      time.Time
+	Report  *datafeed.ImportReport
+}
+
+// SaveUpload imports an exchange export (any format datafeed.ImportData reads, optionally
+// gzip or zip compressed) and keeps its candles as a CSV in the upload directory. name is
+// the client's file name, used in the report. If no row is usable the returned Upload
+// only carries the report.
+func (m *Manager) SaveUpload(name string, src io.Reader, format string, columns datafeed.ColumnMapping) (*Upload, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	candles, report, err := datafeed.ImportData(name, data, format, columns, "")
+	if err != nil {
+		return &Upload{Report: report}, err
+	}
+	if len(candles) == 0 {
+		return &Upload{Report: report}, fmt.Errorf("no valid candles in upload")
+	}
+
+	if err := os.MkdirAll(m.uploadDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	u := &Upload{
+		File:    db.NewReplaySessionID() + ".csv",
+		Candles: len(candles),
+		From:    candles[0].Timestamp,
+		To:      candles[len(candles)-1].Timestamp,
+		Report:  report,
+	}
+	if err := writeCandles(filepath.Join(m.uploadDir, u.File), candles); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// writeCandles saves candles in the default CSV layout
+func writeCandles(path string, candles []*db.PriceData) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	w := csv.NewWriter(file)
+	w.Write([]string{"timestamp", "open", "high", "low", "close", "volume"})
+	for _, c := range candles {
+		w.Write([]string{
+			c.Timestamp.UTC().Format(time.RFC3339),
+			formatFloat(c.Open), formatFloat(c.High), formatFloat(c.Low), formatFloat(c.Close), formatFloat(c.Volume),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// UploadPath resolves a name returned by SaveUpload to its file
+func (m *Manager) UploadPath(name string) (string, error) {
+	if !uploadName.MatchString(name) {
+		return "", fmt.Errorf("%w %q", ErrInvalidUpload, name)
+	}
+	path := filepath.Join(m.uploadDir, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %s", ErrUploadNotFound, name)
+	} else if err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,189 @@
+// Package siwe parses and verifies Sign-In With Ethereum (EIP-4361) messages signed
+// with personal_sign (EIP-191) by an externally owned account.
+package siwe
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const headerSuffix = " wants you to sign in with your Ethereum account:"
+
+// Message is a parsed EIP-4361 message
+type Message struct {
+	Domain         string // host[:port] of the site asking for the signature
+	Address        string // EIP-55 checksummed
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// NewNonce returns a random alphanumeric nonce (32 hex characters)
+func NewNonce() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Parse reads a message in the EIP-4361 text format
+func Parse(text string) (*Message, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) < 4 {
+		return nil, fmt.Errorf("message too short")
+	}
+
+	m := &Message{}
+	domain, ok := strings.CutSuffix(lines[0], headerSuffix)
+	if !ok || domain == "" {
+		return nil, fmt.Errorf("first line must be %q", "<domain>"+headerSuffix)
+	}
+	// An optional scheme may precede the domain
+	if _, host, ok := strings.Cut(domain, "://"); ok {
+		domain = host
+	}
+	m.Domain = domain
+
+	m.Address = lines[1]
+	if !common.IsHexAddress(m.Address) || common.HexToAddress(m.Address).Hex() != m.Address {
+		return nil, fmt.Errorf("address %q is not an EIP-55 checksummed address", m.Address)
+	}
+	if lines[2] != "" {
+		return nil, fmt.Errorf("expected an empty line after the address")
+	}
+
+	// An optional statement, then the fields
+	i := 3
+	var statement []string
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "URI: "); i++ {
+		if lines[i] != "" {
+			statement = append(statement, lines[i])
+		}
+	}
+	m.Statement = strings.Join(statement, "\n")
+
+	fields := make(map[string]string)
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		if line == "Resources:" {
+			for i++; i < len(lines) && strings.HasPrefix(lines[i], "- "); i++ {
+				m.Resources = append(m.Resources, strings.TrimPrefix(lines[i], "- "))
+			}
+			i--
+			continue
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		if _, dup := fields[key]; dup {
+			return nil, fmt.Errorf("duplicate field %q", key)
+		}
+		fields[key] = value
+	}
+
+	var err error
+	for _, key := range []string{"URI", "Version", "Chain ID", "Nonce", "Issued At"} {
+		if fields[key] == "" {
+			return nil, fmt.Errorf("missing field %q", key)
+		}
+	}
+	m.URI = fields["URI"]
+	if m.Version = fields["Version"]; m.Version != "1" {
+		return nil, fmt.Errorf("unsupported version %q", m.Version)
+	}
+	if m.ChainID, err = strconv.ParseInt(fields["Chain ID"], 10, 64); err != nil || m.ChainID <= 0 {
+		return nil, fmt.Errorf("invalid chain ID %q", fields["Chain ID"])
+	}
+	if m.Nonce = fields["Nonce"]; len(m.Nonce) < 8 || !isAlphanumeric(m.Nonce) {
+		return nil, fmt.Errorf("nonce must be at least 8 alphanumeric characters")
+	}
+	if m.IssuedAt, err = time.Parse(time.RFC3339, fields["Issued At"]); err != nil {
+		return nil, fmt.Errorf("invalid Issued At: %w", err)
+	}
+	if m.ExpirationTime, err = optionalTime(fields, "Expiration Time"); err != nil {
+		return nil, err
+	}
+	if m.NotBefore, err = optionalTime(fields, "Not Before"); err != nil {
+		return nil, err
+	}
+	m.RequestID = fields["Request ID"]
+	return m, nil
+}
+
+func optionalTime(fields map[string]string, key string) (*time.Time, error) {
+	value, ok := fields[key]
+	if !ok {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return &t, nil
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckTime rejects a message that is expired, not yet valid, or issued more than skew
+// in the future at now
+func (m *Message) CheckTime(now time.Time, skew time.Duration) error {
+	if m.IssuedAt.After(now.Add(skew)) {
+		return fmt.Errorf("message issued in the future")
+	}
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return fmt.Errorf("message expired")
+	}
+	if m.NotBefore != nil && now.Add(skew).Before(*m.NotBefore) {
+		return fmt.Errorf("message not valid yet")
+	}
+	return nil
+}
+
+// VerifySignature checks that the 65-byte hex signature of text, the exact message that
+// was signed, was made by m.Address
+func (m *Message) VerifySignature(text, signature string) error {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return fmt.Errorf("signature must be 65 bytes of 0x-prefixed hex")
+	}
+	// Wallets return v as 27/28; recovery expects 0/1
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(text)), sig)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != common.HexToAddress(m.Address) {
+		return fmt.Errorf("signature does not match address %s", m.Address)
+	}
+	return nil
+}
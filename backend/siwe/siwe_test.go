@@ -0,0 +1,195 @@
+package siwe
+
+import (
+	"crypto/ecdsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testAddress = "0x90F8bf6A479f320ead074411a4B0e7944Ea8c9C1"
+
+// message builds a valid message, with lines replaced or removed by edit
+func message(edit func(lines []string) []string) string {
+	lines := []string{
+		"app.example.com" + headerSuffix,
+		testAddress,
+		"",
+		"Sign in to SpikeShield",
+		"",
+		"URI: https://app.example.com/login",
+		"Version: 1",
+		"Chain ID: 8453",
+		"Nonce: 32891756abcdef",
+		"Issued At: 2024-05-01T12:00:00Z",
+		"Expiration Time: 2024-05-01T12:10:00Z",
+		"Resources:",
+		"- https://app.example.com/terms",
+		"- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq",
+	}
+	if edit != nil {
+		lines = edit(lines)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// replace returns an edit setting line i
+func replace(i int, line string) func([]string) []string {
+	return func(lines []string) []string {
+		lines[i] = line
+		return lines
+	}
+}
+
+// remove returns an edit dropping line i
+func remove(i int) func([]string) []string {
+	return func(lines []string) []string {
+		return append(lines[:i], lines[i+1:]...)
+	}
+}
+
+func TestParse(t *testing.T) {
+	m, err := Parse(message(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Date(2024, 5, 1, 12, 10, 0, 0, time.UTC)
+	switch {
+	case m.Domain != "app.example.com", m.Address != testAddress, m.Statement != "Sign in to SpikeShield",
+		m.URI != "https://app.example.com/login", m.Version != "1", m.ChainID != 8453, m.Nonce != "32891756abcdef",
+		!m.IssuedAt.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+		m.ExpirationTime == nil || !m.ExpirationTime.Equal(expires), m.NotBefore != nil, len(m.Resources) != 2:
+		t.Fatalf("Parse = %+v", m)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string // substring of the error; empty if the message parses
+	}{
+		{name: "scheme before the domain", text: message(replace(0, "https://app.example.com"+headerSuffix))},
+		{name: "CRLF line endings", text: strings.ReplaceAll(message(nil), "\n", "\r\n")},
+		{name: "no statement", text: message(func(l []string) []string { return append(l[:3], l[4:]...) })},
+		{name: "not before and request ID", text: message(func(l []string) []string {
+			return append(l[:11], "Not Before: 2024-05-01T12:00:00Z", "Request ID: req-1")
+		})},
+		{name: "too short", text: "app.example.com" + headerSuffix + "\n" + testAddress, want: "too short"},
+		{name: "wrong header", text: message(replace(0, "app.example.com wants you to sign in")), want: "first line must be"},
+		{name: "empty domain", text: message(replace(0, headerSuffix)), want: "first line must be"},
+		{name: "lowercase address", text: message(replace(1, strings.ToLower(testAddress))), want: "EIP-55"},
+		{name: "not an address", text: message(replace(1, "0x1234")), want: "EIP-55"},
+		{name: "no empty line", text: message(replace(2, "hello")), want: "empty line"},
+		{name: "missing URI", text: message(remove(5)), want: `missing field "URI"`},
+		{name: "missing nonce", text: message(remove(8)), want: `missing field "Nonce"`},
+		{name: "missing issued at", text: message(remove(9)), want: `missing field "Issued At"`},
+		{name: "unsupported version", text: message(replace(6, "Version: 2")), want: "unsupported version"},
+		{name: "zero chain ID", text: message(replace(7, "Chain ID: 0")), want: "invalid chain ID"},
+		{name: "non-numeric chain ID", text: message(replace(7, "Chain ID: base")), want: "invalid chain ID"},
+		{name: "short nonce", text: message(replace(8, "Nonce: abc123")), want: "nonce must be"},
+		{name: "non-alphanumeric nonce", text: message(replace(8, "Nonce: abcd-1234-efgh")), want: "nonce must be"},
+		{name: "invalid issued at", text: message(replace(9, "Issued At: yesterday")), want: "invalid Issued At"},
+		{name: "invalid expiration", text: message(replace(10, "Expiration Time: 2024-05-01")), want: "invalid Expiration Time"},
+		{name: "duplicate field", text: message(replace(10, "Nonce: 32891756abcdef")), want: `duplicate field "Nonce"`},
+		{name: "malformed line", text: message(replace(10, "Expiration Time")), want: "malformed line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.text)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTime(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2024, 5, 1, 12, minute, 0, 0, time.UTC) }
+	ptr := func(t time.Time) *time.Time { return &t }
+	const skew = time.Minute
+
+	tests := []struct {
+		name string
+		msg  Message
+		now  time.Time
+		want string
+	}{
+		{name: "valid", msg: Message{IssuedAt: at(0), ExpirationTime: ptr(at(10))}, now: at(5)},
+		{name: "no expiration", msg: Message{IssuedAt: at(0)}, now: at(59)},
+		{name: "issued within skew", msg: Message{IssuedAt: at(1)}, now: at(0)},
+		{name: "issued in the future", msg: Message{IssuedAt: at(5)}, now: at(0), want: "future"},
+		{name: "expired", msg: Message{IssuedAt: at(0), ExpirationTime: ptr(at(10))}, now: at(10), want: "expired"},
+		{name: "not before within skew", msg: Message{IssuedAt: at(0), NotBefore: ptr(at(6))}, now: at(5)},
+		{name: "not valid yet", msg: Message{IssuedAt: at(0), NotBefore: ptr(at(10))}, now: at(5), want: "not valid yet"},
+	}
+	for _, tt := range tests {
+		err := tt.msg.CheckTime(tt.now, skew)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, err := crypto.HexToECDSA("4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := crypto.GenerateKey()
+	text := message(nil)
+	m, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer := crypto.PubkeyToAddress(key.PublicKey).Hex(); signer != testAddress {
+		t.Fatalf("test key signs as %s, want %s", signer, testAddress)
+	}
+
+	// sign returns a personal_sign signature, with v as 27/28 like wallets unless raw
+	sign := func(k *ecdsa.PrivateKey, text string, raw bool) string {
+		sig, err := crypto.Sign(accounts.TextHash([]byte(text)), k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !raw {
+			sig[crypto.RecoveryIDOffset] += 27
+		}
+		return hexutil.Encode(sig)
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		signature string
+		want      string
+	}{
+		{name: "wallet signature", text: text, signature: sign(key, text, false)},
+		{name: "recovery ID 0/1", text: text, signature: sign(key, text, true)},
+		{name: "other signer", text: text, signature: sign(other, text, false), want: "does not match"},
+		{name: "other message", text: text, signature: sign(key, text+" ", false), want: "does not match"},
+		{name: "not hex", text: text, signature: "0xzz", want: "65 bytes"},
+		{name: "no 0x prefix", text: text, signature: strings.TrimPrefix(sign(key, text, false), "0x"), want: "65 bytes"},
+		{name: "too short", text: text, signature: sign(key, text, false)[:100], want: "65 bytes"},
+	}
+	for _, tt := range tests {
+		err := m.VerifySignature(tt.text, tt.signature)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
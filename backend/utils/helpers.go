@@ -14,11 +14,17 @@ import (
 // Config represents the application configuration
 type Config struct {
 	Database struct {
+		// Driver selects the store: postgres (default) or sqlite
+		Driver   string `yaml:"driver"`
 		Host     string `yaml:"host"`
 		Port     int    `yaml:"port"`
 		User     string `yaml:"user"`
 		Password string `yaml:"password"`
 		DBName   string `yaml:"dbname"`
+		// Path is the SQLite database file, used when driver is sqlite
+		Path string `yaml:"path"`
+		// AutoMigrate applies pending migrations at startup instead of refusing to start
+		AutoMigrate bool `yaml:"auto_migrate"`
 	} `yaml:"database"`
 
 	RPC struct {
@@ -40,13 +46,86 @@ type Config struct {
 	} `yaml:"chainlink"`
 
 	EventListener struct {
-		Enabled      bool `yaml:"enabled"`
-		PollInterval int  `yaml:"poll_interval"`
+		Enabled           bool `yaml:"enabled"`
+		PollInterval      int  `yaml:"poll_interval"`
+		ReconcileInterval int  `yaml:"reconcile_interval"`
+		ReconcileSample   int  `yaml:"reconcile_sample"`
 	} `yaml:"eventlistener"`
 
+	Validation struct {
+		Enabled bool    `yaml:"enabled"`
+		MaxJump float64 `yaml:"max_jump"` // max open/close move from the previous close (0.2 = 20%), 0 disables
+		// Monotonic quarantines new candles older than the latest stored one (live mode only)
+		Monotonic bool `yaml:"monotonic"`
+	} `yaml:"validation"`
+
+	Webhooks struct {
+		Enabled     bool `yaml:"enabled"`
+		MaxAttempts int  `yaml:"max_attempts"` // attempts before a delivery is dead-lettered
+		BaseBackoff int  `yaml:"base_backoff"` // seconds before the first retry, doubled after each failure
+		MaxBackoff  int  `yaml:"max_backoff"`  // longest delay between attempts, in seconds
+		Timeout     int  `yaml:"timeout"`      // per request, in seconds
+		// AllowedNetworks are internal CIDRs (or IPs) endpoints may target; loopback, private
+		// and link-local addresses are refused otherwise
+		AllowedNetworks []string `yaml:"allowed_networks"`
+	} `yaml:"webhooks"`
+
+	API struct {
+		// AdminToken is accepted as an admin API key, e.g. to issue the first keys; empty disables it
+		AdminToken string `yaml:"admin_token"`
+		// CORSOrigins lists the browser origins allowed to call the API, "*" for any
+		CORSOrigins []string `yaml:"cors_origins"`
+		// SIWEDomains lists the domains Sign-In With Ethereum messages may name; empty accepts any
+		SIWEDomains []string `yaml:"siwe_domains"`
+		// SIWEChainID is the chain sign-in messages must name; 0 accepts any
+		SIWEChainID int64 `yaml:"siwe_chain_id"`
+		// SessionTTL is how long a wallet session lasts, in seconds
+		SessionTTL int `yaml:"session_ttl"`
+		// V1Deprecated is the date (YYYY-MM-DD) API v1 was deprecated, announced in the
+		// Deprecation header of its responses; empty announces none
+		V1Deprecated string `yaml:"v1_deprecated"`
+		// V1Sunset is the date (YYYY-MM-DD) API v1 is planned to be removed, announced in
+		// the Sunset header of its responses; empty announces none
+		V1Sunset string `yaml:"v1_sunset"`
+	} `yaml:"api"`
+
+	RateLimit struct {
+		IP     RateLimit `yaml:"ip"`     // per client IP, for callers without an API key
+		Key    RateLimit `yaml:"key"`    // per API key
+		Wallet RateLimit `yaml:"wallet"` // per wallet, on endpoints that call the chain
+		// SyncWorkers and SyncQueue bound the background wallet syncs of /api/wallet/link
+		SyncWorkers int `yaml:"sync_workers"`
+		SyncQueue   int `yaml:"sync_queue"`
+		// TrustedProxies are the proxies (IPs or CIDRs) whose X-Forwarded-For is believed;
+		// empty keys the IP limit on the connection's address
+		TrustedProxies []string `yaml:"trusted_proxies"`
+	} `yaml:"rate_limit"`
+
+	Replay struct {
+		UploadDir string `yaml:"upload_dir"` // where uploaded replay CSVs are kept
+		// Compressed imports may unpack to at most MaxImportMB in at most MaxArchiveEntries
+		// zip entries; 0 keeps the defaults (256 MiB, 100 entries)
+		MaxImportMB       int `yaml:"max_import_mb"`
+		MaxArchiveEntries int `yaml:"max_archive_entries"`
+	} `yaml:"replay"`
+
+	Retention struct {
+		Enabled         bool `yaml:"enabled"`
+		RawDays         int  `yaml:"raw_days"`         // keep full-resolution candles this many days
+		RollupInterval  int  `yaml:"rollup_interval"`  // seconds per downsampled candle
+		CompactInterval int  `yaml:"compact_interval"` // seconds between compaction runs
+	} `yaml:"retention"`
+
 	Mode string `yaml:"mode"`
 }
 
+// RateLimit is a token bucket: Rate requests per second on average, bursts of up to
+// Burst. A zero rate disables it.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
 var AppConfig *Config
 
 // LoadConfig loads configuration from YAML file
@@ -78,7 +157,12 @@ func LogInfo(format string, args ...interface{}) {
 
 // LogError prints error level log with clickable file:line in VSCode terminal
 func LogError(format string, args ...interface{}) {
-	_, file, line, ok := runtime.Caller(1)
+	logError(2, format, args...)
+}
+
+// logError logs with the file:line of the caller depth frames up
+func logError(depth int, format string, args ...interface{}) {
+	_, file, line, ok := runtime.Caller(depth)
 	if !ok {
 		log.Printf("[ERROR] "+format, args...)
 		return
@@ -0,0 +1,44 @@
+package utils
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the API request it serves
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, "" if it has none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID prefixes a log format with the request ID of ctx, if any
+func withRequestID(ctx context.Context, format string, args []interface{}) (string, []interface{}) {
+	id := RequestID(ctx)
+	if id == "" {
+		return format, args
+	}
+	return "[req %s] " + format, append([]interface{}{id}, args...)
+}
+
+// LogInfoCtx is LogInfo tagged with the request ID of ctx, so the lines of one API call
+// can be found together
+func LogInfoCtx(ctx context.Context, format string, args ...interface{}) {
+	format, args = withRequestID(ctx, format, args)
+	LogInfo(format, args...)
+}
+
+// LogErrorCtx is LogError tagged with the request ID of ctx
+func LogErrorCtx(ctx context.Context, format string, args ...interface{}) {
+	format, args = withRequestID(ctx, format, args)
+	logError(2, format, args...)
+}
+
+// LogDebugCtx is LogDebug tagged with the request ID of ctx
+func LogDebugCtx(ctx context.Context, format string, args ...interface{}) {
+	format, args = withRequestID(ctx, format, args)
+	LogDebug(format, args...)
+}
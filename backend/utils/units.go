@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// DefaultTokenDecimals is used when the token's decimals are not configured (USDT)
+const DefaultTokenDecimals = 6
+
+// TokenDecimals returns the configured USDT decimals, falling back to DefaultTokenDecimals
+func (c *Config) TokenDecimals() int {
+	if c == nil || c.RPC.UsdtDecimals <= 0 {
+		return DefaultTokenDecimals
+	}
+	return c.RPC.UsdtDecimals
+}
+
+// Amount is an exact token amount: an integer number of base units plus the token's decimals.
+// It marshals to JSON as an exact decimal string (e.g. "100.000000") so no precision is lost
+// in clients that parse numbers as float64.
+type Amount struct {
+	Raw      *big.Int
+	Decimals int
+}
+
+// NewAmount creates an Amount from base units
+func NewAmount(raw *big.Int, decimals int) Amount {
+	if raw == nil {
+		raw = new(big.Int)
+	}
+	return Amount{Raw: raw, Decimals: decimals}
+}
+
+// String formats the amount with FormatUnits
+func (a Amount) String() string {
+	return FormatUnits(a.Raw, a.Decimals)
+}
+
+// MarshalJSON encodes the amount as a decimal string
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// FormatUnits renders an integer amount of token base units as an exact decimal string,
+// e.g. FormatUnits(1234500, 6) == "1.234500". No float conversion is involved.
+func FormatUnits(raw *big.Int, decimals int) string {
+	if raw == nil {
+		raw = new(big.Int)
+	}
+	if decimals <= 0 {
+		return raw.String()
+	}
+
+	digits := new(big.Int).Abs(raw).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	whole, frac := digits[:len(digits)-decimals], digits[len(digits)-decimals:]
+	sign := ""
+	if raw.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + whole + "." + frac
+}
+
+// ParseUnits converts a decimal string such as "12.5" into base units.
+// It fails rather than rounds when s has more fractional digits than decimals.
+func ParseUnits(s string, decimals int) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > decimals {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", s, decimals)
+	}
+
+	digits := whole + frac + strings.Repeat("0", decimals-len(frac))
+	raw, ok := new(big.Int).SetString(digits, 10)
+	if !ok || strings.ContainsAny(digits, "+-") {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if neg {
+		raw.Neg(raw)
+	}
+	return raw, nil
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		raw      string
+		decimals int
+		want     string
+	}{
+		{"0", 6, "0.000000"},
+		{"1", 6, "0.000001"},
+		{"1234500", 6, "1.234500"},
+		{"100000000", 6, "100.000000"},
+		{"-1234500", 6, "-1.234500"},
+		{"-1", 6, "-0.000001"},
+		{"123456789012345678901234567890", 18, "123456789012.345678901234567890"},
+		{"42", 0, "42"},
+		{"-42", 0, "-42"},
+	}
+	for _, tt := range tests {
+		raw, _ := new(big.Int).SetString(tt.raw, 10)
+		if got := FormatUnits(raw, tt.decimals); got != tt.want {
+			t.Errorf("FormatUnits(%s, %d) = %q, want %q", tt.raw, tt.decimals, got, tt.want)
+		}
+	}
+	if got := FormatUnits(nil, 2); got != "0.00" {
+		t.Errorf("FormatUnits(nil, 2) = %q, want 0.00", got)
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		in       string
+		decimals int
+		want     string // base units; empty if parsing fails
+	}{
+		{"12.5", 6, "12500000"},
+		{"1.234500", 6, "1234500"},
+		{"0.000001", 6, "1"},
+		{".5", 6, "500000"},
+		{"7.", 6, "7000000"},
+		{"100", 6, "100000000"},
+		{" 3.25 ", 2, "325"},
+		{"+1.5", 1, "15"},
+		{"-1.5", 6, "-1500000"},
+		{"42", 0, "42"},
+		{"123456789012.345678901234567890", 18, "123456789012345678901234567890"},
+		{"0.0000001", 6, ""}, // more decimals than the token: rejected, not rounded
+		{"1.5", 0, ""},
+		{"", 6, ""},
+		{".", 6, ""},
+		{"-", 6, ""},
+		{"abc", 6, ""},
+		{"1e3", 6, ""},
+		{"1.2.3", 6, ""},
+		{"--1", 6, ""},
+		{"1.-5", 6, ""},
+		{"0x10", 6, ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseUnits(tt.in, tt.decimals)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseUnits(%q, %d) = %s, want an error", tt.in, tt.decimals, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseUnits(%q, %d): %v", tt.in, tt.decimals, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseUnits(%q, %d) = %s, want %s", tt.in, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+// TestUnitsRoundTrip checks that formatting and parsing back is exact
+func TestUnitsRoundTrip(t *testing.T) {
+	for _, raw := range []string{"0", "1", "999999", "1000000", "-250000", "18446744073709551617"} {
+		v, _ := new(big.Int).SetString(raw, 10)
+		back, err := ParseUnits(FormatUnits(v, 6), 6)
+		if err != nil {
+			t.Errorf("%s: %v", raw, err)
+			continue
+		}
+		if back.Cmp(v) != 0 {
+			t.Errorf("%s: round trip gave %s", raw, back)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	mrand "math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"spikeshield/db"
+	"spikeshield/utils"
+)
+
+// Event types partners can subscribe to
+const (
+	EventSpikeDetected  = "spike.detected"  // a new (or restored) spike from the detection callback
+	EventPayoutExecuted = "payout.executed" // a PayoutExecuted event was mined and indexed
+	EventPing           = "ping"            // sent on request to test an endpoint, ignores filters
+)
+
+// EventTypes are the types a filter may name, besides "*"
+var EventTypes = []string{EventSpikeDetected, EventPayoutExecuted}
+
+// Request headers of every delivery. The signature is "sha256=" followed by the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the endpoint's secret.
+const (
+	HeaderEvent     = "X-SpikeShield-Event"
+	HeaderEventID   = "X-SpikeShield-Event-Id"
+	HeaderDelivery  = "X-SpikeShield-Delivery"
+	HeaderTimestamp = "X-SpikeShield-Timestamp"
+	HeaderSignature = "X-SpikeShield-Signature"
+)
+
+// Options tunes delivery. Zero values take the defaults below.
+type Options struct {
+	MaxAttempts  int           // attempts before a delivery is dead-lettered (default 8)
+	BaseBackoff  time.Duration // delay after the first failure, doubled after each (default 30s)
+	MaxBackoff   time.Duration // longest delay between attempts (default 6h)
+	Timeout      time.Duration // per request (default 10s)
+	PollInterval time.Duration // how often due deliveries are looked up (default 2s)
+	// AllowedNetworks are internal networks endpoints may still target; loopback, private,
+	// link-local and unspecified addresses are refused otherwise
+	AllowedNetworks []*net.IPNet
+}
+
+const (
+	claimBatch  = 50
+	concurrency = 4    // deliveries sent at once
+	maxLogError = 1000 // bytes of a failed response body kept in the log
+)
+
+// Dispatcher queues events as deliveries and sends them, retrying failures with
+// exponential backoff. Deliveries live in the database, so they survive restarts.
+// A nil *Dispatcher drops events, so emitters need no checks.
+type Dispatcher struct {
+	store  db.WebhookStore
+	client *http.Client
+	opts   Options
+	wake   chan struct{}
+}
+
+// NewDispatcher creates a dispatcher; call Start to send deliveries
+func NewDispatcher(store db.WebhookStore, opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 6 * time.Hour
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	d := &Dispatcher{
+		store: store,
+		opts:  opts,
+		wake:  make(chan struct{}, 1),
+	}
+	dialer := &net.Dialer{Timeout: opts.Timeout, Control: d.dialControl}
+	d.client = &http.Client{
+		Timeout: opts.Timeout,
+		// No proxy, so every connection goes through dialControl
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: opts.Timeout, MaxIdleConnsPerHost: concurrency},
+		// A redirect counts as a failed delivery rather than re-posting the payload elsewhere
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return d
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// Sign returns the signature header value of a body sent at timestamp (Unix seconds)
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// payload is the JSON body of a delivery
+func payload(eventType, eventID string, data interface{}) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         eventID,
+		"type":       eventType,
+		"created_at": time.Now().UTC().Format(time.RFC3339),
+		"data":       data,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	return string(body), nil
+}
+
+// Emit queues an event for every active endpoint whose filter accepts eventType. eventID
+// identifies the event to receivers and should be stable, e.g. "spike.detected:42".
+func (d *Dispatcher) Emit(eventType, eventID string, data interface{}) error {
+	if d == nil {
+		return nil
+	}
+	webhooks, err := d.store.ListWebhooks()
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	body, err := payload(eventType, eventID, data)
+	if err != nil {
+		return err
+	}
+
+	queued := 0
+	for _, w := range webhooks {
+		if !w.Active || !w.Accepts(eventType) {
+			continue
+		}
+		if _, err := d.enqueue(w.ID, eventType, eventID, body); err != nil {
+			return err
+		}
+		queued++
+	}
+	if queued > 0 {
+		utils.LogInfo("🪝 Queued %s event %s for %d webhook(s)", eventType, eventID, queued)
+		d.signal()
+	}
+	return nil
+}
+
+// Send queues an event for one endpoint regardless of its filter and active flag
+func (d *Dispatcher) Send(w *db.Webhook, eventType, eventID string, data interface{}) (*db.WebhookDelivery, error) {
+	body, err := payload(eventType, eventID, data)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := d.enqueue(w.ID, eventType, eventID, body)
+	if err != nil {
+		return nil, err
+	}
+	d.signal()
+	return delivery, nil
+}
+
+// Redeliver queues a delivery again from its first attempt, e.g. after it was dead-lettered
+func (d *Dispatcher) Redeliver(delivery *db.WebhookDelivery) error {
+	delivery.Status = db.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	if err := d.store.UpdateWebhookDelivery(delivery); err != nil {
+		return err
+	}
+	d.signal()
+	return nil
+}
+
+func (d *Dispatcher) enqueue(webhookID int, eventType, eventID, body string) (*db.WebhookDelivery, error) {
+	delivery := &db.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       body,
+		NextAttemptAt: time.Now(),
+	}
+	if err := d.store.InsertWebhookDelivery(delivery); err != nil {
+		return nil, fmt.Errorf("failed to queue webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// signal wakes Start without blocking
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start sends due deliveries until ctx is done
+func (d *Dispatcher) Start(ctx context.Context) {
+	utils.LogInfo("🪝 Webhook dispatcher started (max %d attempts, backoff %s..%s)",
+		d.opts.MaxAttempts, d.opts.BaseBackoff, d.opts.MaxBackoff)
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && d.dispatchDue(ctx) == claimBatch {
+			// A full batch: more may be due
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// dispatchDue sends one batch of due deliveries and returns its size
+func (d *Dispatcher) dispatchDue(ctx context.Context) int {
+	now := time.Now()
+	// The lease outlives every attempt of the batch, so a crash only delays a retry
+	lease := now.Add(d.opts.Timeout*claimBatch/concurrency + time.Minute)
+	due, err := d.store.ClaimDueDeliveries(now, lease, claimBatch)
+	if err != nil {
+		utils.LogError("Failed to load due webhook deliveries: %v", err)
+		return 0
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, delivery := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery *db.WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			d.attempt(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(due)
+}
+
+// attempt sends a delivery once and records the outcome
+func (d *Dispatcher) attempt(ctx context.Context, delivery *db.WebhookDelivery) {
+	webhook, err := d.store.GetWebhook(delivery.WebhookID)
+	if err == sql.ErrNoRows {
+		return // deleted with its deliveries meanwhile
+	}
+	if err != nil {
+		utils.LogError("Failed to load webhook %d: %v", delivery.WebhookID, err)
+		return // retried once the lease expires
+	}
+
+	if !webhook.Active && delivery.EventType != EventPing {
+		// Disabled after the event was queued: park it until it is redelivered
+		delivery.Status, delivery.LastError = db.DeliveryDead, "webhook is disabled"
+		if err := d.store.UpdateWebhookDelivery(delivery); err != nil {
+			utils.LogError("Failed to record webhook delivery %d: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	status, err := d.post(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		return // shutting down: the attempt is repeated once the lease expires
+	}
+	delivery.Attempts++
+	delivery.LastStatusCode = status
+	switch {
+	case err == nil:
+		now := time.Now()
+		delivery.Status, delivery.LastError, delivery.DeliveredAt = db.DeliverySucceeded, "", &now
+		utils.LogDebug("Webhook delivery %d (%s) to %s succeeded", delivery.ID, delivery.EventType, webhook.URL)
+	case delivery.Attempts >= d.opts.MaxAttempts:
+		delivery.Status, delivery.LastError = db.DeliveryDead, err.Error()
+		utils.LogError("☠️  Webhook delivery %d (%s) to %s dead-lettered after %d attempts: %v",
+			delivery.ID, delivery.EventType, webhook.URL, delivery.Attempts, err)
+	default:
+		delay := d.backoff(delivery.Attempts)
+		delivery.Status, delivery.LastError = db.DeliveryPending, err.Error()
+		delivery.NextAttemptAt = time.Now().Add(delay)
+		utils.LogInfo("Webhook delivery %d (%s) to %s failed (attempt %d/%d), retrying in %s: %v",
+			delivery.ID, delivery.EventType, webhook.URL, delivery.Attempts, d.opts.MaxAttempts, delay.Round(time.Second), err)
+	}
+	if err := d.store.UpdateWebhookDelivery(delivery); err != nil {
+		utils.LogError("Failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// post sends the signed payload; any response other than 2xx is an error
+func (d *Dispatcher) post(ctx context.Context, webhook *db.Webhook, delivery *db.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SpikeShield-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return resp.StatusCode, nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxLogError))
+	return resp.StatusCode, fmt.Errorf("endpoint answered %s: %s", resp.Status, bytes.TrimSpace(snippet))
+}
+
+// backoff returns the delay after the given number of failed attempts: BaseBackoff
+// doubled per earlier failure, capped at MaxBackoff, with ±20% jitter so endpoints
+// recovering from an outage are not hit by every retry at once
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := float64(d.opts.BaseBackoff) * math.Pow(2, float64(attempts-1))
+	if delay > float64(d.opts.MaxBackoff) {
+		delay = float64(d.opts.MaxBackoff)
+	}
+	return time.Duration(delay * (0.8 + 0.4*mrand.Float64()))
+}
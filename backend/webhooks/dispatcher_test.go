@@ -0,0 +1,213 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"spikeshield/db"
+)
+
+func TestSign(t *testing.T) {
+	const want = "sha256=a94cea056df1fbb92eadafcf2c5cd541dbe0c6ef736e4748202dd53f86694a3e"
+	body := []byte(`{"id":"evt"}`)
+	if got := Sign("whsec_test", 1700000000, body); got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+	}{
+		{"other secret", "whsec_other", 1700000000, `{"id":"evt"}`},
+		{"other timestamp", "whsec_test", 1700000001, `{"id":"evt"}`},
+		{"other body", "whsec_test", 1700000000, `{"id":"evt2"}`},
+	}
+	for _, tt := range tests {
+		if Sign(tt.secret, tt.timestamp, []byte(tt.body)) == want {
+			t.Errorf("%s: signature did not change", tt.name)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, Options{BaseBackoff: time.Second, MaxBackoff: time.Minute})
+	tests := []struct {
+		attempts int
+		nominal  time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute}, // 64s capped
+		{30, time.Minute},
+	}
+	for _, tt := range tests {
+		lo, hi := time.Duration(float64(tt.nominal)*0.8), time.Duration(float64(tt.nominal)*1.2)
+		for i := 0; i < 100; i++ {
+			if got := d.backoff(tt.attempts); got < lo || got > hi {
+				t.Fatalf("backoff(%d) = %s, want %s ±20%%", tt.attempts, got, tt.nominal)
+			}
+		}
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "10.0.0.0/8", want: "10.0.0.0/8"},
+		{in: "10.1.2.3/16", want: "10.1.0.0/16"},
+		{in: "192.168.1.5", want: "192.168.1.5/32"},
+		{in: "fd00::/8", want: "fd00::/8"},
+		{in: "::1", want: "::1/128"},
+		{in: "internal.example", wantErr: true},
+		{in: "10.0.0.0/33", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		networks, err := ParseNetworks([]string{tt.in})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseNetworks(%q) = %v, want an error", tt.in, networks)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseNetworks(%q): %v", tt.in, err)
+			continue
+		}
+		if got := networks[0].String(); got != tt.want {
+			t.Errorf("ParseNetworks(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	allowed, err := ParseNetworks([]string{"10.20.0.0/16", "fd12::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := NewDispatcher(nil, Options{})
+	restricted := NewDispatcher(nil, Options{AllowedNetworks: allowed})
+
+	tests := []struct {
+		ip               string
+		open, restricted bool
+	}{
+		{"203.0.113.10", true, true},
+		{"8.8.8.8", true, true},
+		{"2001:4860:4860::8888", true, true},
+		{"127.0.0.1", false, false},
+		{"::1", false, false},
+		{"10.0.0.1", false, false},
+		{"10.20.3.4", false, true},
+		{"172.16.0.1", false, false},
+		{"192.168.1.1", false, false},
+		{"169.254.169.254", false, false},
+		{"fe80::1", false, false},
+		{"fd12::1", false, true},
+		{"fd12::2", false, false},
+		{"0.0.0.0", false, false},
+		{"0.1.2.3", false, false},
+		{"100.64.0.1", false, false},
+		{"100.127.255.254", false, false},
+		{"100.128.0.1", true, true},
+		{"::ffff:100.64.0.1", false, false},
+		{"::", false, false},
+		{"224.0.0.1", false, false},
+		{"::ffff:127.0.0.1", false, false},
+	}
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if got := open.allowed(ip); got != tt.open {
+			t.Errorf("allowed(%s) = %t, want %t", tt.ip, got, tt.open)
+		}
+		if got := restricted.allowed(ip); got != tt.restricted {
+			t.Errorf("allowed(%s) with allowed networks = %t, want %t", tt.ip, got, tt.restricted)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	d := NewDispatcher(nil, Options{})
+	tests := []struct {
+		url      string
+		internal bool // rejected as an internal target
+		invalid  bool // rejected as malformed
+	}{
+		{url: "https://203.0.113.10/hook"},
+		{url: "http://203.0.113.10:8080/hook"},
+		{url: "http://127.0.0.1/hook", internal: true},
+		{url: "http://[::1]:9000/", internal: true},
+		{url: "http://169.254.169.254/latest/meta-data", internal: true},
+		{url: "https://10.0.0.5/hook", internal: true},
+		{url: "http://0.0.0.0:8080/hook", internal: true},
+		{url: "http://100.100.100.200/latest/meta-data", internal: true},
+		{url: "ftp://203.0.113.10/hook", invalid: true},
+		{url: "/relative", invalid: true},
+		{url: "https://", invalid: true},
+	}
+	for _, tt := range tests {
+		err := d.CheckURL(context.Background(), tt.url)
+		switch {
+		case tt.internal:
+			if !errors.Is(err, ErrInternalTarget) {
+				t.Errorf("CheckURL(%s) = %v, want ErrInternalTarget", tt.url, err)
+			}
+		case tt.invalid:
+			if err == nil || errors.Is(err, ErrInternalTarget) {
+				t.Errorf("CheckURL(%s) = %v, want an invalid url error", tt.url, err)
+			}
+		case err != nil:
+			t.Errorf("CheckURL(%s): %v", tt.url, err)
+		}
+	}
+}
+
+// TestPost sends a delivery to a local endpoint, which the dialer refuses unless
+// loopback is an allowed network, and verifies the signature as a receiver would
+func TestPost(t *testing.T) {
+	const secret = "whsec_test"
+	var verified bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		verified = r.Header.Get(HeaderSignature) == Sign(secret, timestamp, body) &&
+			r.Header.Get(HeaderEvent) == EventSpikeDetected && r.Header.Get(HeaderEventID) == "evt-1"
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	webhook := &db.Webhook{ID: 1, URL: srv.URL, Secret: secret, Active: true}
+	delivery := &db.WebhookDelivery{ID: 7, WebhookID: 1, EventID: "evt-1", EventType: EventSpikeDetected, Payload: `{"id":"evt-1"}`}
+
+	refused := NewDispatcher(nil, Options{})
+	if _, err := refused.post(context.Background(), webhook, delivery); !errors.Is(err, ErrInternalTarget) {
+		t.Fatalf("post to loopback = %v, want ErrInternalTarget", err)
+	}
+
+	loopback, _ := ParseNetworks([]string{"127.0.0.0/8"})
+	d := NewDispatcher(nil, Options{AllowedNetworks: loopback})
+	status, err := d.post(context.Background(), webhook, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", status)
+	}
+	if !verified {
+		t.Error("the endpoint could not verify the delivery's headers and signature")
+	}
+}
@@ -0,0 +1,115 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrInternalTarget is returned for endpoints resolving to an internal address
+var ErrInternalTarget = errors.New("webhooks may not target internal addresses")
+
+// internalNetworks are internal ranges net.IP has no predicate for: "this network"
+// (0.0.0.0/8, which Linux connects to the local host) and carrier-grade NAT (RFC 6598)
+var internalNetworks = mustParseNetworks("0.0.0.0/8", "100.64.0.0/10")
+
+// internalIP reports whether ip is loopback, private (RFC 1918, fc00::/7), link-local
+// (including cloud metadata at 169.254.169.254), multicast, unspecified or in internalNetworks
+func internalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range internalNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// mustParseNetworks is ParseNetworks for constant networks
+func mustParseNetworks(values ...string) []*net.IPNet {
+	networks, err := ParseNetworks(values)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
+
+// ParseNetworks parses CIDRs, or single IPs, for Options.AllowedNetworks
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid network %q (expected a CIDR or an IP)", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q (expected a CIDR or an IP)", v)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
+
+// allowed reports whether deliveries may connect to ip: any public address, and internal
+// ones in Options.AllowedNetworks
+func (d *Dispatcher) allowed(ip net.IP) bool {
+	if !internalIP(ip) {
+		return true
+	}
+	for _, n := range d.opts.AllowedNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL validates an endpoint URL as it is registered: absolute http(s), with a host
+// resolving only to addresses deliveries may reach
+func (d *Dispatcher) CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q (expected http:// or https://)", raw)
+	}
+	host := u.Hostname()
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s", host)
+	}
+	for _, ip := range ips {
+		if !d.allowed(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrInternalTarget, host, ip)
+		}
+	}
+	return nil
+}
+
+// dialControl refuses connections to addresses deliveries may not reach. It sees the
+// resolved address of every connection, so a host that resolves to an internal address
+// after it was registered (DNS rebinding) is still refused.
+func (d *Dispatcher) dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !d.allowed(ip) {
+		return fmt.Errorf("%w: %s", ErrInternalTarget, host)
+	}
+	return nil
+}
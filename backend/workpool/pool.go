@@ -0,0 +1,85 @@
+// Package workpool runs background jobs on a fixed number of workers with a bounded
+// queue, skipping jobs whose key is already queued or running
+package workpool
+
+import (
+	"errors"
+	"sync"
+
+	"spikeshield/utils"
+)
+
+// ErrFull is returned when the queue cannot take another job
+var ErrFull = errors.New("work queue is full")
+
+type job struct {
+	key string
+	fn  func()
+}
+
+// Pool executes submitted jobs in the background
+type Pool struct {
+	jobs chan job
+
+	mu      sync.Mutex
+	pending map[string]struct{} // queued or running
+}
+
+// New starts workers goroutines consuming a queue of up to queueSize jobs
+func New(workers, queueSize int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	p := &Pool{jobs: make(chan job, queueSize), pending: make(map[string]struct{})}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues fn under key. It returns false without queueing if a job with the same
+// key is still queued or running, and ErrFull if the queue is full.
+func (p *Pool) Submit(key string, fn func()) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.pending[key]; ok {
+		return false, nil
+	}
+	select {
+	case p.jobs <- job{key: key, fn: fn}:
+		p.pending[key] = struct{}{}
+		return true, nil
+	default:
+		return false, ErrFull
+	}
+}
+
+// Pending returns the number of jobs queued or running
+func (p *Pool) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+func (p *Pool) work() {
+	for j := range p.jobs {
+		p.run(j)
+	}
+}
+
+// run executes a job; a panicking job is logged and does not take its worker down
+func (p *Pool) run(j job) {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.LogError("Background job %s panicked: %v", j.key, r)
+		}
+		p.mu.Lock()
+		delete(p.pending, j.key)
+		p.mu.Unlock()
+	}()
+	j.fn()
+}
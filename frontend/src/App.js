@@ -23,20 +23,23 @@ function App() {
   
   // Backend API data
   const [spikes, setSpikes] = useState([]);
-  const [prices, setPrices] = useState([]);
+  const [chartVersion, setChartVersion] = useState(0);
   const [payouts, setPayouts] = useState([]);
   const [stats, setStats] = useState(null);
   const [apiStatus, setApiStatus] = useState('checking');
 
-  // Check API health
+  // Check API health; data is loaded whenever the API comes (back) online
   useEffect(() => {
+    let online = false;
     const checkAPI = async () => {
       try {
         await apiService.healthCheck();
         setApiStatus('online');
-        loadBackendData();
+        if (!online) loadBackendData();
+        online = true;
       } catch (err) {
         setApiStatus('offline');
+        online = false;
         console.error('API health check failed:', err);
       }
     };
@@ -45,17 +48,25 @@ function App() {
     return () => clearInterval(interval);
   }, []);
 
+  // Reload as soon as the backend pushes a new candle, spike or payout instead of polling
+  useEffect(() => {
+    return apiService.subscribe(
+      ['prices:BTCUSDT', 'spikes', 'payouts:*'],
+      ['price', 'spike.created', 'spike.updated', 'spike.voided', 'spike.restored', 'payout.executed'],
+      () => loadBackendData()
+    );
+  }, []);
+
   // Load backend data
   const loadBackendData = async () => {
     try {
-      const [spikesData, pricesData, payoutsData, statsData] = await Promise.all([
+      const [spikesData, payoutsData, statsData] = await Promise.all([
         apiService.getSpikes(10),
-        apiService.getPrices('BTCUSDT', 100),
         apiService.getPayouts(10),
         apiService.getStats()
       ]);
       setSpikes(spikesData.spikes || []);
-      setPrices(pricesData.prices || []);
+      setChartVersion((v) => v + 1);
       setPayouts(payoutsData.payouts || []);
       setStats(statsData.stats || null);
     } catch (err) {
@@ -110,7 +121,7 @@ function App() {
       const initWalletData = async () => {
         try {
           // Link wallet to trigger backend sync
-          await apiService.linkWallet(account, process.env.REACT_APP_USDT_ADDRESS);
+          await apiService.linkWallet(account);
           console.log('Wallet linked successfully');
           // Then refresh data
           await refreshData();
@@ -519,7 +530,7 @@ function App() {
         )}
 
         {/* Recent Prices - K-line Chart */}
-        {apiStatus === 'online' && prices.length > 0 && (
+        {apiStatus === 'online' && (
           <div style={{ 
             background: 'rgba(255, 255, 255, 0.95)', 
             borderRadius: '20px', 
@@ -527,7 +538,7 @@ function App() {
             marginTop: '20px'
           }}>
             <h2>📈 Recent Price Data</h2>
-            <PriceChart prices={prices} />
+            <PriceChart symbol="BTCUSDT" refreshKey={chartVersion} />
           </div>
         )}
 
@@ -560,7 +571,7 @@ function App() {
                         {payout.UserAddress ? `${payout.UserAddress.slice(0, 6)}...${payout.UserAddress.slice(-4)}` : 'Unknown'}
                       </td>
                       <td style={{ padding: '12px', textAlign: 'right', color: '#48bb78', fontWeight: 'bold' }}>
-                        ${payout.Amount?.toFixed(2)}
+                        ${Number(payout.Amount || 0).toFixed(2)}
                       </td>
                       <td style={{ padding: '12px' }}>
                         {payout.TxHash ? (
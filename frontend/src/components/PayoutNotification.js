@@ -4,7 +4,7 @@ import { apiService } from '../services/api';
 
 /**
  * PayoutNotification component
- * Listens for payout events and displays notifications when user receives payouts
+ * Listens for payout events (pushed over /api/stream) and displays notifications when user receives payouts
  */
 const PayoutNotification = () => {
   const { account, isConnected } = useContract();
@@ -15,41 +15,49 @@ const PayoutNotification = () => {
 
     let mounted = true;
 
+    // Prepend items that are not in the current list and notify about them
+    const addPayouts = (items) => {
+      setPayouts(prev => {
+        const existing = new Set(prev.map(p => p.txHash));
+        const newOnes = items.filter(i => !existing.has(i.tx_hash || i.TxHash));
+        const normalized = newOnes.map(i => ({
+          txHash: i.tx_hash || i.TxHash || '',
+          amount: i.amount || i.Amount || 0,
+          policyId: i.policy_id || i.OnchainPolicyID || i.PolicyID || 0,
+          blockNumber: i.block_number || i.BlockNumber || i.executed_at || ''
+        }));
+        // Show notifications for new items
+        normalized.forEach(payoutData => {
+          if ('Notification' in window && Notification.permission === 'granted') {
+            new Notification('💰 Insurance Payout Received!', {
+              body: `You received ${payoutData.amount} USDT for policy #${payoutData.policyId}`,
+              icon: '/logo192.png'
+            });
+          }
+        });
+        return [...normalized, ...prev];
+      });
+    };
+
     const fetchPayouts = async () => {
       try {
         const resp = await apiService.getPayouts(10, account);
-        const items = resp?.payouts || [];
         if (!mounted) return;
-
-        // Prepend new items that are not in the current list
-        setPayouts(prev => {
-          const existing = new Set(prev.map(p => p.tx_hash || p.TxHash));
-          const newOnes = items.filter(i => !existing.has(i.tx_hash || i.TxHash));
-          const normalized = newOnes.map(i => ({
-            txHash: i.tx_hash || i.TxHash || '',
-            amount: i.amount || i.Amount || 0,
-            policyId: i.policy_id || i.PolicyID || 0,
-            blockNumber: i.block_number || i.executed_at || ''
-          }));
-          // Show notifications for new items
-          normalized.forEach(payoutData => {
-            if ('Notification' in window && Notification.permission === 'granted') {
-              new Notification('💰 Insurance Payout Received!', {
-                body: `You received ${payoutData.amount} USDT for policy #${payoutData.policyId}`,
-                icon: '/logo192.png'
-              });
-            }
-          });
-          return [...normalized, ...prev];
-        });
+        addPayouts(resp?.payouts || []);
       } catch (err) {
         console.error('Failed to fetch payouts for user:', err);
       }
     };
 
-    // Initial fetch and polling
+    // Initial fetch, then show payouts to this wallet the moment their transaction is mined
+    // (the indexed payout follows with the same tx hash); a slow poll covers stream outages
     fetchPayouts();
-    const iv = setInterval(fetchPayouts, 10000);
+    const unsubscribe = apiService.subscribe(
+      [`payouts:${account}`],
+      ['payout.mined', 'payout.executed'],
+      (ev) => { if (mounted) addPayouts([ev.data]); }
+    );
+    const iv = setInterval(fetchPayouts, 60000);
 
     // Request notification permission (once)
     if ('Notification' in window && Notification.permission === 'default') {
@@ -58,6 +66,7 @@ const PayoutNotification = () => {
 
     return () => {
       mounted = false;
+      unsubscribe();
       clearInterval(iv);
     };
   }, [isConnected, account]);
@@ -1,7 +1,13 @@
-import React, { useEffect, useRef } from 'react';
+import React, { useEffect, useRef, useState } from 'react';
 import { createChart } from 'lightweight-charts';
+import { apiService } from '../services/api';
 
-const PriceChart = ({ prices }) => {
+const INTERVALS = ['1m', '5m', '1h', '1d'];
+
+// Candles are aggregated by the backend; refreshKey changes whenever they should be reloaded
+const PriceChart = ({ symbol = 'BTCUSDT', refreshKey }) => {
+  const [timeframe, setTimeframe] = useState('1m');
+  const [candles, setCandles] = useState([]);
   const chartContainerRef = useRef();
   const chartRef = useRef();
   const candlestickSeriesRef = useRef();
@@ -62,32 +68,76 @@ const PriceChart = ({ prices }) => {
   }, []);
 
   useEffect(() => {
-    if (!candlestickSeriesRef.current || !prices || prices.length === 0) return;
+    let cancelled = false;
+    apiService
+      .getCandles(symbol, timeframe)
+      .then((data) => {
+        if (!cancelled) setCandles(data.candles || []);
+      })
+      .catch((err) => console.error('Failed to load candles:', err));
+    return () => {
+      cancelled = true;
+    };
+  }, [symbol, timeframe, refreshKey]);
+
+  useEffect(() => {
+    if (!candlestickSeriesRef.current) return;
 
-    // Convert prices to candlestick data
-    const candleData = prices
-      .map((price) => ({
-        time: Math.floor(new Date(price.Timestamp).getTime() / 1000),
-        open: price.Open,
-        high: price.High,
-        low: price.Low,
-        close: price.Close,
+    const time = (timestamp) => Math.floor(new Date(timestamp).getTime() / 1000);
+    candlestickSeriesRef.current.setData(
+      candles.map((candle) => ({
+        time: time(candle.Bucket),
+        open: candle.Open,
+        high: candle.High,
+        low: candle.Low,
+        close: candle.Close,
       }))
-      .sort((a, b) => a.time - b.time); // Sort by time ascending
+    );
 
-    candlestickSeriesRef.current.setData(candleData);
+    // Mark each spike on its candle, at the end of the wick that spiked
+    candlestickSeriesRef.current.setMarkers(
+      candles.flatMap((candle) =>
+        (candle.Spikes || []).map((spike) => ({
+          time: time(candle.Bucket),
+          position: spike.Direction === 'up' ? 'aboveBar' : 'belowBar',
+          color: '#ff9800',
+          shape: spike.Direction === 'up' ? 'arrowDown' : 'arrowUp',
+          text: `Spike ${(spike.RangeClosePercent * 100).toFixed(1)}%`,
+        }))
+      )
+    );
     chartRef.current.timeScale().fitContent();
-  }, [prices]);
+  }, [candles]);
 
   return (
-    <div
-      ref={chartContainerRef}
-      style={{
-        width: '100%',
-        height: '400px',
-        marginTop: '15px',
-      }}
-    />
+    <div>
+      <div style={{ display: 'flex', gap: '8px', marginTop: '10px' }}>
+        {INTERVALS.map((name) => (
+          <button
+            key={name}
+            onClick={() => setTimeframe(name)}
+            style={{
+              padding: '4px 12px',
+              borderRadius: '6px',
+              border: '1px solid #ccc',
+              background: name === timeframe ? '#667eea' : '#fff',
+              color: name === timeframe ? '#fff' : '#333',
+              cursor: 'pointer',
+            }}
+          >
+            {name}
+          </button>
+        ))}
+      </div>
+      <div
+        ref={chartContainerRef}
+        style={{
+          width: '100%',
+          height: '400px',
+          marginTop: '15px',
+        }}
+      />
+    </div>
   );
 };
 
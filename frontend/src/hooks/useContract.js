@@ -1,5 +1,6 @@
 import { useState, useEffect } from 'react';
 import { ethers } from 'ethers';
+import { apiService } from '../services/api';
 
 // Contract ABI (simplified for demo)
 const INSURANCE_POOL_ABI = [
@@ -49,6 +50,10 @@ export const useContract = () => {
       const provider = new ethers.BrowserProvider(window.ethereum);
       const signer = await provider.getSigner();
       const checksummedAccount = ethers.getAddress(accounts[0]);
+
+      // Sign in with Ethereum before the app reads wallet-scoped data from the backend
+      const { chainId } = await provider.getNetwork();
+      await apiService.signIn(signer, checksummedAccount, chainId.toString());
       setAccount(checksummedAccount);
 
       // Initialize contracts
@@ -62,24 +67,6 @@ export const useContract = () => {
       setUsdtContract(usdt);
 
       console.log("Wallet connected:", checksummedAccount);
-      // Notify backend to upsert this user's balances and policies
-      try {
-        const apiBase = process.env.REACT_APP_API_URL || '';
-        const url = apiBase ? `${apiBase.replace(/\/$/, '')}/api/wallet/link` : '/api/wallet/link';
-        console.log('address', checksummedAccount);
-        fetch(url, {
-          method: 'POST',
-          headers: { 'Content-Type': 'application/json' },
-          body: JSON.stringify({ address: checksummedAccount, token: usdtAddress }),
-        }).then((res) => {
-          if (!res.ok) console.error('Wallet link API responded with', res.status);
-          else console.log('Backend wallet-link upsert triggered');
-        }).catch((err) => {
-          console.error('Failed to notify backend on wallet link', err);
-        });
-      } catch (err) {
-        console.error('Wallet link notify error:', err);
-      }
     } catch (err) {
       setError(err.message);
       console.error("Connection error:", err);
@@ -90,6 +77,7 @@ export const useContract = () => {
 
   // Disconnect wallet
   const disconnectWallet = () => {
+    apiService.signOut();
     setAccount(null);
     setInsuranceContract(null);
     setUsdtContract(null);
@@ -160,12 +148,9 @@ export const useContract = () => {
   // Listen to account changes
   useEffect(() => {
     if (window.ethereum) {
-      window.ethereum.on('accountsChanged', (accounts) => {
-        if (accounts.length === 0) {
-          disconnectWallet();
-        } else {
-          setAccount(ethers.getAddress(accounts[0]));
-        }
+      window.ethereum.on('accountsChanged', () => {
+        // The backend session belongs to the previous account: reconnect to sign in again
+        disconnectWallet();
       });
 
       window.ethereum.on('chainChanged', () => {
@@ -1,24 +1,41 @@
 const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:8080';
+// The dashboard reads the v1 shapes; see "API versions" in the README before moving to v2
+const API_URL = `${API_BASE_URL}/api/v1`;
+// Sign-In With Ethereum session token for the wallet-scoped endpoints
+let sessionToken = null;
+
+const authHeaders = () => (sessionToken ? { Authorization: `Bearer ${sessionToken}` } : {});
 
 export const apiService = {
   // Get recent wick detection events
   async getSpikes(limit = 50) {
-    const response = await fetch(`${API_BASE_URL}/api/spikes?limit=${limit}`);
+    const response = await fetch(`${API_URL}/spikes?limit=${limit}`);
     if (!response.ok) throw new Error('Failed to fetch spikes');
     return response.json();
   },
 
   // Get recent price data
   async getPrices(symbol = 'BTCUSDT', limit = 100) {
-    const response = await fetch(`${API_BASE_URL}/api/prices?symbol=${symbol}&limit=${limit}`);
+    const response = await fetch(`${API_URL}/prices?symbol=${symbol}&limit=${limit}`);
     if (!response.ok) throw new Error('Failed to fetch prices');
     return response.json();
   },
 
+  // Get candles aggregated to an interval ('1m', '5m', '1h' or '1d'), each with the
+  // spikes detected on it; without from/to, the latest 300 intervals
+  async getCandles(symbol = 'BTCUSDT', interval = '1m', { from, to } = {}) {
+    const params = new URLSearchParams({ symbol, interval });
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+    const response = await fetch(`${API_URL}/candles?${params}`);
+    if (!response.ok) throw new Error('Failed to fetch candles');
+    return response.json();
+  },
+
   // Get payout history
   async getPayouts(limit = 50, user) {
     const userParam = user ? `&user=${user}` : '';
-    const response = await fetch(`${API_BASE_URL}/api/payouts?limit=${limit}${userParam}`);
+    const response = await fetch(`${API_URL}/payouts?limit=${limit}${userParam}`);
     if (!response.ok) throw new Error('Failed to fetch payouts');
     return response.json();
   },
@@ -26,7 +43,7 @@ export const apiService = {
   // Get user policies by address (server-side read)
   async getUserPolicies(address) {
     if (!address) return { count: 0, policies: [] };
-    const response = await fetch(`${API_BASE_URL}/api/policies?address=${address}`);
+    const response = await fetch(`${API_URL}/policies?address=${address}`, { headers: authHeaders() });
     if (!response.ok) throw new Error('Failed to fetch user policies');
     return response.json();
   },
@@ -34,32 +51,89 @@ export const apiService = {
   // Get user token balance (server-side read of ERC20)
   async getUserBalance(address) {
     if (!address) return { address: '', balance: '0', raw: '0', decimals: 6 };
-    const response = await fetch(`${API_BASE_URL}/api/balance?address=${address}`);
+    const response = await fetch(`${API_URL}/balance?address=${address}`, { headers: authHeaders() });
     if (!response.ok) throw new Error('Failed to fetch user balance');
     return response.json();
   },
 
   // Get system statistics
   async getStats() {
-    const response = await fetch(`${API_BASE_URL}/api/stats`);
+    const response = await fetch(`${API_URL}/stats`);
     if (!response.ok) throw new Error('Failed to fetch stats');
     return response.json();
   },
 
   // Health check
   async healthCheck() {
-    const response = await fetch(`${API_BASE_URL}/api/health`);
+    const response = await fetch(`${API_URL}/health`);
     if (!response.ok) throw new Error('API server is down');
     return response.json();
   },
 
-  // Link wallet to backend (triggers balance/policy sync)
-  async linkWallet(address, token = null) {
-    const body = { address };
-    if (token) body.token = token;
-    const response = await fetch(`${API_BASE_URL}/api/wallet/link`, {
+  // Subscribe to real-time events (Server-Sent Events). topics: e.g. ['spikes', 'prices:BTCUSDT',
+  // 'payouts:0x...']; types: event names to receive, e.g. ['spike.created', 'payout.mined'].
+  // onEvent gets {seq, topic, type, data, time}. Returns a function that closes the stream.
+  subscribe(topics, types, onEvent) {
+    const url = `${API_URL}/stream?topics=${encodeURIComponent(topics.join(','))}`;
+    const source = new EventSource(url);
+    const handler = (e) => {
+      try {
+        onEvent(JSON.parse(e.data));
+      } catch (err) {
+        console.error('Invalid stream event:', err);
+      }
+    };
+    types.forEach(type => source.addEventListener(type, handler));
+    // EventSource reconnects on its own after errors
+    return () => source.close();
+  },
+
+  // Sign in with Ethereum (EIP-4361): sign a nonce-bound message with the wallet and
+  // keep the session token for the wallet-scoped endpoints
+  async signIn(signer, address, chainId) {
+    const nonceResp = await fetch(`${API_URL}/auth/nonce`);
+    if (!nonceResp.ok) throw new Error('Failed to get sign-in nonce');
+    const { nonce } = await nonceResp.json();
+
+    const message = [
+      `${window.location.host} wants you to sign in with your Ethereum account:`,
+      address,
+      '',
+      'Sign in to SpikeShield to view your policies and balance.',
+      '',
+      `URI: ${window.location.origin}`,
+      'Version: 1',
+      `Chain ID: ${chainId}`,
+      `Nonce: ${nonce}`,
+      `Issued At: ${new Date().toISOString().replace(/\.\d{3}Z$/, 'Z')}`
+    ].join('\n');
+    const signature = await signer.signMessage(message);
+
+    const response = await fetch(`${API_URL}/auth/verify`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ message, signature })
+    });
+    if (!response.ok) throw new Error('Sign-in failed');
+    const session = await response.json();
+    sessionToken = session.token;
+    return session;
+  },
+
+  // End the wallet session
+  async signOut() {
+    if (!sessionToken) return;
+    const headers = authHeaders();
+    sessionToken = null;
+    await fetch(`${API_URL}/auth/logout`, { method: 'POST', headers }).catch(() => {});
+  },
+
+  // Link wallet to backend (triggers policy sync)
+  async linkWallet(address) {
+    const body = { address };
+    const response = await fetch(`${API_URL}/wallet/link`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json', ...authHeaders() },
       body: JSON.stringify(body)
     });
     if (!response.ok) throw new Error(`Failed to link wallet: ${response.statusText}`);